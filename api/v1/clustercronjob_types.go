@@ -0,0 +1,106 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CronJobTemplateSpec describes the CronJob to create from a ClusterCronJob.
+type CronJobTemplateSpec struct {
+	// Labels and annotations for the CronJobs.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// The spec of the CronJobs.
+	Spec CronJobSpec `json:"spec"`
+}
+
+// ClusterCronJobSpec defines the desired state of ClusterCronJob
+type ClusterCronJobSpec struct {
+	// Selects the namespaces to run in.  Every namespace is selected if this
+	// is empty.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// The CronJob to create in each selected namespace.  They're named after
+	// the ClusterCronJob.
+	Template CronJobTemplateSpec `json:"template"`
+}
+
+// ClusterCronJobStatus defines the observed state of ClusterCronJob
+type ClusterCronJobStatus struct {
+	// The generation of the spec that the controller last acted on.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The namespaces that have a CronJob of ours, in order.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// The number of namespaces that have a CronJob of ours.
+	// +optional
+	NamespaceCount int32 `json:"namespaceCount"`
+
+	// Represents the latest available observations of the ClusterCronJob's
+	// state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// ClusterCronJobNameLabel holds the name of the ClusterCronJob a CronJob
+	// was created for.
+	ClusterCronJobNameLabel = "batch.tutorial.kubebuilder.io/clustercronjob"
+
+	// SyncedCondition is true when a ClusterCronJob has an up-to-date CronJob
+	// in every selected namespace.
+	SyncedCondition = "Synced"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.template.spec.schedule`
+//+kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.namespaceCount`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterCronJob runs a CronJob in each of a set of namespaces, by creating a
+// CronJob in each of them and keeping it in line with its template.
+type ClusterCronJob struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterCronJobSpec   `json:"spec,omitempty"`
+	Status ClusterCronJobStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterCronJobList contains a list of ClusterCronJob
+type ClusterCronJobList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterCronJob `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCronJob{}, &ClusterCronJobList{})
+}
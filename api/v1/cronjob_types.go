@@ -17,16 +17,22 @@ limitations under the License.
 package v1
 
 import (
+	"strconv"
+	"time"
+
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	schedulepkg "kubebuilder-tutorial/pkg/schedule"
 )
 
 // ConcurrencyPolicy describes how the job will be handled.
 // Only one of the following concurrent policies may be specified.
 // If none of the following policies is specified, the default one is AllowConcurrent.
 
-//+kubebuilder:validation:Enum=Allow;Forbid;Replace
+//+kubebuilder:validation:Enum=Allow;Forbid;Replace;Queue
 type ConcurrencyPolicy string
 
 const (
@@ -39,13 +45,44 @@ const (
 
 	// ReplaceConcurrent cancels currently running job and replaces it with a new one.
 	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+
+	// QueueConcurrent forbids concurrent runs like ForbidConcurrent, but queues
+	// up runs that are missed while a previous one is running instead of
+	// skipping them, starting them in order as each previous run finishes.
+	QueueConcurrent ConcurrencyPolicy = "Queue"
 )
 
 // CronJobSpec defines the desired state of CronJob
 type CronJobSpec struct {
 	//the cron in CronJob
 	// the schedule is also a Cron format see https://en.wikipedia.org/wiki/Cron.
-	Schedule string `json:"schedule"`
+	// Alternatively, it may be an ISO 8601 repeating interval with an explicit
+	// start, like R/2024-01-01T00:00:00Z/PT6H.  It's left empty for a CronJob
+	// that runs on its calendarRef instead.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// The name of the time zone to evaluate the schedule (and any cron
+	// blackout windows) in, from the tz database, like Europe/Berlin.
+	// Defaults to the time zone the controller runs in.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// Specifies what happens to runs when daylight saving time starts or ends.
+	// Valid values are:
+	// - "RunEarlier" (default): runs at times the clocks skip run as soon as they've gone forward, and runs at times that happen twice run the first time round;
+	// - "SkipNonexistent": runs at times the clocks skip are skipped, and runs at times that happen twice run the first time round;
+	// - "RunBoth": runs at times the clocks skip run as soon as they've gone forward, and runs at times that happen twice run both times round.
+	// +optional
+	DSTPolicy DSTPolicy `json:"dstPolicy,omitempty"`
+
+	// How precisely the schedule picks its run times.
+	// Valid values are:
+	// - "Minutes" (default): the schedule is a standard five-field cron expression;
+	// - "Seconds": the schedule is a six-field cron expression, the first field being the second.
+	// Either way, runs may not come round more often than every five seconds.
+	// +optional
+	ScheduleGranularity ScheduleGranularity `json:"scheduleGranularity,omitempty"`
 
 	//+kubebuilder:validation:Minimum=0
 
@@ -54,46 +91,1115 @@ type CronJobSpec struct {
 	// +optional
 	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
 
+	//+kubebuilder:validation:Minimum=1
+
+	// Optional deadline in seconds for a run to finish once its job has
+	// started.  Jobs that are still running after this are failed by the
+	// controller, emitting a RunDeadlineExceeded event.  Unlike the job
+	// template's activeDeadlineSeconds, this applies to every run regardless
+	// of template, and to jobs that never got to start.
+	// +optional
+	RunDeadlineSeconds *int64 `json:"runDeadlineSeconds,omitempty"`
+
+	//+kubebuilder:validation:Minimum=1
+
+	// The number of missed start times the controller will look through before
+	// giving up on them and only considering the most recent one.  When this
+	// happens, the MissedRunsExceeded condition is set.  Defaults to 100.
+	// +optional
+	MaxMissedRuns *int32 `json:"maxMissedRuns,omitempty"`
+
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=60
+
+	// How many seconds ahead of its scheduled time a run may start, to allow
+	// for the controller's clock being a little behind the rest of the
+	// cluster's.  Overrides the controller's own tolerance.
+	// +optional
+	ClockSkewToleranceSeconds *int32 `json:"clockSkewToleranceSeconds,omitempty"`
+
 	//Specifies how to treat concurrent executions of a Job.
 	// Valid values are:
 	// - "Allow" (default): allows CronJobs to run concurrently;
 	// - "Forbid": forbids concurrent runs, skipping next run if previous run hasn't finished yet;
-	// - "Replace": cancels currently running job and replaces it with a new one
+	// - "Replace": cancels currently running job and replaces it with a new one;
+	// - "Queue": forbids concurrent runs, queueing up runs missed while a previous run hasn't finished yet
 	// +optional
 	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
 
+	// The maximum number of Jobs that may be active at once.  Once this many
+	// are running, further runs are skipped, or queued with the "Queue"
+	// concurrency policy.  Defaults to 1 for the "Forbid" and "Queue" policies,
+	// and to unlimited for "Allow".  Has no effect with "Replace".
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentRuns *int32 `json:"maxConcurrentRuns,omitempty"`
+
+	// The Kueue LocalQueue, in the CronJob's namespace, to submit jobs to.
+	// Jobs are created suspended, and only start once Kueue admits them;
+	// until then they count towards the concurrency policy, but not as
+	// active.  Only for runs that create Jobs.
+	// +optional
+	QueueName string `json:"queueName,omitempty"`
+
 	// This flag tells the controller to suspend subsequent executions, it does
 	// not apply to already started executions.  Defaults to false.
 	// +optional
 	Suspend *bool `json:"suspend,omitempty"`
 
-	// Specifies the job that will be created when executing a CronJob.
+	// The number of upcoming scheduled runs to skip.  The controller counts
+	// this down as it skips each run.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SkipNextRuns *int32 `json:"skipNextRuns,omitempty"`
+
+	// This tells the controller to suspend subsequent executions until the
+	// given time, after which scheduling resumes on its own.  Like suspend, it
+	// does not apply to already started executions.
+	// +optional
+	SuspendUntil *metav1.Time `json:"suspendUntil,omitempty"`
+
+	// This tells the controller to hold off on starting runs for the time
+	// being, as an operational measure, say during an incident.  Unlike
+	// suspend, which says the CronJob isn't meant to run, a pause is
+	// temporary: it's reported as such, runs skipped while paused aren't
+	// alerted on, and notifications are held back.  Defaults to false.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Why the CronJob is paused, for whoever comes across it.
+	// +optional
+	PauseReason string `json:"pauseReason,omitempty"`
+
+	// Specifies what happens to runs that came due while the CronJob was
+	// suspended, once it's resumed.
+	// Valid values are:
+	// - "SkipMissed" (default): they're skipped, and recorded in status; scheduling picks up with the first run after resuming;
+	// - "RunMissed": the most recent of them starts on resuming, with startingDeadlineSeconds counted from then, and the rest are dropped (unless the concurrency policy is Queue, which queues them all).
+	// Suspensions by suspendUntil or a CronJobGroup, and pauses, count too.
+	// +optional
+	ResumePolicy ResumePolicy `json:"resumePolicy,omitempty"`
+
+	// Explicit times at which to run the job once, in addition to the regular
+	// schedule.
+	// +optional
+	RunAt []metav1.Time `json:"runAt,omitempty"`
+
+	// An iCalendar feed whose events start the CronJob's runs, instead of a
+	// schedule: each event starts a run at its start time, or at midnight in
+	// the CronJob's time zone for an all-day event.  The controller syncs the
+	// feed every so often, and carries on with the events it last read while
+	// it can't.  The schedule must be left empty.
+	// +optional
+	CalendarRef *CalendarRef `json:"calendarRef,omitempty"`
+
+	// Sources of events that each start a run as they arrive, in addition to
+	// the regular schedule.  Every event gets a CronJobTrigger, so its run
+	// waits on the concurrency policy like a run triggered by hand would.
+	// +optional
+	Triggers []EventTrigger `json:"triggers,omitempty"`
+
+	// Whether the schedule starts runs, as well as runAt and triggers do.
+	// Set it to false for a CronJob that only runs when triggered.
+	// Defaults to true.
+	// +optional
+	RunOnSchedule *bool `json:"runOnSchedule,omitempty"`
+
+	// A list of windows during which scheduled runs are skipped rather than
+	// started.  Skipped runs are recorded in status.
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+
+	// A HolidayCalendar in the CronJob's namespace, on whose holidays runs are
+	// handled as per holidayPolicy.
+	// +optional
+	HolidayCalendarRef *corev1.LocalObjectReference `json:"holidayCalendarRef,omitempty"`
+
+	// Specifies what happens to runs scheduled on a holiday.
+	// Valid values are:
+	// - "Skip" (default): they're skipped, and recorded in status;
+	// - "NextBusinessDay": they're moved to the same time on the next weekday that isn't a holiday.
+	// +optional
+	HolidayPolicy HolidayPolicy `json:"holidayPolicy,omitempty"`
+
+	// Specifies how failed runs are retried by the controller, independently of
+	// the job's own backoffLimit.  Failed runs aren't retried if unset.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Specifies what the controller does about runs that keep failing.
+	// +optional
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// Other CronJobs whose runs must succeed before this one's do.  A run only
+	// starts once the most recent run of each dependency scheduled at or before
+	// it has completed successfully.
+	// +optional
+	DependsOn []CronJobDependency `json:"dependsOn,omitempty"`
+
+	// Jobs to run before and after each run's main job.
+	// +optional
+	Hooks *RunHooks `json:"hooks,omitempty"`
+
+	// HTTP endpoints to notify when runs fail or are missed.
+	// +optional
+	Notifications []Notification `json:"notifications,omitempty"`
+
+	// Specifies the job that will be created when executing a CronJob.  When
+	// jobTemplateRef is set, only its labels and annotations are used, on top
+	// of those of the referenced template.
+	// +optional
 	JobTemplate batchv1beta1.JobTemplateSpec `json:"jobTemplate"`
+
+	// A JobTemplate in the CronJob's namespace to take the job's spec from,
+	// instead of spec.jobTemplate.
+	// +optional
+	JobTemplateRef *corev1.LocalObjectReference `json:"jobTemplateRef,omitempty"`
+
+	// What each run's main step creates, if not a batch Job.  Hooks always
+	// run as Jobs.
+	// +optional
+	WorkloadRef *WorkloadRef `json:"workloadRef,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
 	// The number of successful finished jobs to retain.
 	// This is a pointer to distinguish between explicit zero and not specified.
 	// +optional
 	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
 
 	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
 	// The number of failed finished jobs to retain.
 	// This is a pointer to distinguish between explicit zero and not specified.
 	// +optional
 	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The TTL, in seconds, stamped onto every job created from this CronJob, so
+	// that the cluster cleans up finished jobs even while the controller isn't
+	// running.  Overrides any TTL set in the job template.  The history limits
+	// above still apply in the meantime.
+	// +optional
+	JobTTLSecondsAfterFinished *int32 `json:"jobTTLSecondsAfterFinished,omitempty"`
+
+	// Which finished jobs get their pods' logs archived before they're
+	// cleaned up for the history limits.  Valid values are:
+	// - "Never" (default): logs go when the jobs do;
+	// - "Failed": only failed jobs' logs are archived;
+	// - "Always": every job's logs are archived.
+	// Logs only get archived if the controller has somewhere to put them.
+	// Archived logs are kept as long as their runs are in status.recentRuns,
+	// where stores support deleting them.
+	// +optional
+	ArchiveLogs LogArchivePolicy `json:"archiveLogs,omitempty"`
+
+	// Secrets to resolve from a store outside the cluster each time a job is
+	// created, and mount into it, rather than keeping them in a Secret of
+	// their own.
+	// +optional
+	SecretInjection *SecretInjection `json:"secretInjection,omitempty"`
+
+	// Fences each job's pods in with a NetworkPolicy of their own, rather than
+	// leaving them to whatever policies their namespace has.
+	// +optional
+	NetworkProfile *NetworkProfile `json:"networkProfile,omitempty"`
+
+	// What to do about a service mesh sidecar in jobs' pods, which would keep
+	// running once the jobs' own containers have finished, so that the jobs
+	// never complete.  Valid values are:
+	// - "Auto" (default): if the pods would get an Istio sidecar, going by their namespace's labels and their own, it's told to quit once the other containers have finished;
+	// - "Quit": the sidecar is always told to quit, for pods whose injection can't be told from their labels;
+	// - "Ignore": any sidecar is left be, say for meshes that run it as a native sidecar container.
+	// Telling the sidecar to quit takes a container of our own in each pod,
+	// with the pod's containers sharing a process namespace, and pods whose
+	// restartPolicy is Never: it can't tell a container that's finished from
+	// one waiting to restart.
+	// +optional
+	MeshSidecar MeshSidecarPolicy `json:"meshSidecar,omitempty"`
+
+	// A Prometheus query whose result has to pass a threshold for scheduled
+	// runs to go ahead, say for a reindexing job that's only worth running
+	// once its queue is deep enough.  Runs triggered by hand, by events or
+	// by runAt aren't held back.  The ConditionGateMet condition says whether
+	// the result passed, but not what it was.
+	// +optional
+	ConditionGate *ConditionGate `json:"conditionGate,omitempty"`
+}
+
+// DSTPolicy describes what happens to runs when daylight saving time starts or
+// ends.
+// +kubebuilder:validation:Enum=RunEarlier;SkipNonexistent;RunBoth
+type DSTPolicy string
+
+const (
+	// RunEarlierOnDST runs skipped times as soon as the clocks have gone
+	// forward, and repeated times only the first, earlier, time round.
+	RunEarlierOnDST DSTPolicy = "RunEarlier"
+
+	// SkipNonexistentOnDST skips runs at skipped times, and runs repeated
+	// times only the first time round.
+	SkipNonexistentOnDST DSTPolicy = "SkipNonexistent"
+
+	// RunBothOnDST runs skipped times as soon as the clocks have gone
+	// forward, and repeated times both times round.
+	RunBothOnDST DSTPolicy = "RunBoth"
+)
+
+// SchedulePolicy converts the policy for the schedule package, which shares
+// its values, filling in the default.
+func (p DSTPolicy) SchedulePolicy() schedulepkg.DSTPolicy {
+	if p == "" {
+		return schedulepkg.RunEarlier
+	}
+	return schedulepkg.DSTPolicy(p)
+}
+
+// ScheduleGranularity describes how precisely a schedule picks its run times.
+// +kubebuilder:validation:Enum=Minutes;Seconds
+type ScheduleGranularity string
+
+const (
+	// MinuteGranularity schedules runs to the minute.
+	MinuteGranularity ScheduleGranularity = "Minutes"
+
+	// SecondGranularity schedules runs to the second.
+	SecondGranularity ScheduleGranularity = "Seconds"
+)
+
+// MinScheduleInterval is the shortest interval between runs a schedule to the
+// second may have.  Anything shorter can't reliably be delivered, what with
+// the time a reconcile takes, and the rate at which they're allowed.
+const MinScheduleInterval = 5 * time.Second
+
+// Parse parses a schedule at the granularity, filling in the default.
+func (g ScheduleGranularity) Parse(spec string) (schedulepkg.Schedule, error) {
+	if g == SecondGranularity {
+		return schedulepkg.ParseWithSeconds(spec)
+	}
+	return schedulepkg.Parse(spec)
+}
+
+// ResumePolicy describes what happens to runs that came due while a CronJob
+// was suspended.
+// +kubebuilder:validation:Enum=SkipMissed;RunMissed
+type ResumePolicy string
+
+const (
+	// SkipMissedOnResume skips the runs that came due while suspended.
+	SkipMissedOnResume ResumePolicy = "SkipMissed"
+
+	// RunMissedOnResume starts the most recent run that came due while
+	// suspended as soon as the CronJob is resumed.
+	RunMissedOnResume ResumePolicy = "RunMissed"
+)
+
+// HolidayPolicy describes what happens to runs scheduled on a holiday.
+// +kubebuilder:validation:Enum=Skip;NextBusinessDay
+type HolidayPolicy string
+
+const (
+	// SkipHolidays skips runs scheduled on a holiday.
+	SkipHolidays HolidayPolicy = "Skip"
+
+	// NextBusinessDayOnHolidays moves runs scheduled on a holiday to the
+	// same time on the next business day.  A moved run that lands on the
+	// same time as another run happens only once.
+	NextBusinessDayOnHolidays HolidayPolicy = "NextBusinessDay"
+)
+
+// LogArchivePolicy describes which jobs' logs get archived.
+// +kubebuilder:validation:Enum=Never;Failed;Always
+type LogArchivePolicy string
+
+const (
+	// ArchiveNever doesn't archive any logs.
+	ArchiveNever LogArchivePolicy = "Never"
+
+	// ArchiveFailed archives the logs of failed jobs.
+	ArchiveFailed LogArchivePolicy = "Failed"
+
+	// ArchiveAlways archives the logs of every job.
+	ArchiveAlways LogArchivePolicy = "Always"
+)
+
+// RetryPolicy describes how failed runs are retried as a whole.  Each retry
+// is a new job for the same scheduled time.
+type RetryPolicy struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// The maximum number of times a failed run is retried.
+	MaxRetries int32 `json:"maxRetries"`
+
+	// How long to wait after a failure before retrying.  The wait doubles
+	// with every further attempt, up to a day.  Retries happen immediately
+	// if unset.
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// The job failure reasons to retry on, such as BackoffLimitExceeded or
+	// DeadlineExceeded.  Any failure is retried if empty.
+	// +optional
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+// BlackoutWindow describes a period during which no new runs are started.
+// Start and End are either both RFC 3339 timestamps, bounding a single window,
+// or both cron expressions, bounding a window that opens at every Start and
+// closes at the End that follows it.
+type BlackoutWindow struct {
+	// The time or cron schedule at which the window opens.
+	Start string `json:"start"`
+
+	// The time or cron schedule at which the window closes.
+	End string `json:"end"`
+}
+
+// FailurePolicy describes what the controller does about runs that keep
+// failing, after any retries.
+type FailurePolicy struct {
+	// +kubebuilder:validation:Minimum=1
+
+	// The number of consecutive failed runs after which the controller
+	// suspends the CronJob, setting the AutoSuspended condition.  Runs keep
+	// happening regardless of failures if unset.
+	// +optional
+	SuspendAfterConsecutiveFailures *int32 `json:"suspendAfterConsecutiveFailures,omitempty"`
+}
+
+// RunHooks describes jobs that run before and after each run's main job.
+// Each hook runs as a job of its own, using the job template's spec with the
+// hook's pod template.
+type RunHooks struct {
+	// The pod template to run before each run.  The run's main job only starts
+	// once this has completed successfully.
+	// +optional
+	PreRun *corev1.PodTemplateSpec `json:"preRun,omitempty"`
+
+	// The pod template to run after each run's main job has completed
+	// successfully.  The run only counts as successful once this has too.
+	// +optional
+	PostRun *corev1.PodTemplateSpec `json:"postRun,omitempty"`
+}
+
+// DefaultSecretMountPath is where injected secrets are mounted unless a
+// CronJob says otherwise.
+const DefaultSecretMountPath = "/var/run/secrets/injected"
+
+// SecretInjection describes secrets that are resolved from an external store
+// when each job is created.  They're put in a Secret owned by the job, which
+// is mounted into every one of its containers, and deleted as soon as the job
+// finishes.
+type SecretInjection struct {
+	// The store to resolve the secrets from, as the controller knows it: for
+	// example "vault" or "aws-secretsmanager".
+	Provider string `json:"provider"`
+
+	// The secrets to resolve.
+	// +kubebuilder:validation:MinItems=1
+	Secrets []InjectedSecret `json:"secrets"`
+
+	// The directory to mount the secrets in, one file per key.  Defaults to
+	// /var/run/secrets/injected.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// InjectedSecret is a secret to resolve from an external store.
+type InjectedSecret struct {
+	// The key to put the secret under, which is also the name of the file
+	// it's mounted as.
+	Key string `json:"key"`
+
+	// Where the secret lives in the store: a path for Vault, or a secret's
+	// name or ARN for AWS Secrets Manager.
+	Path string `json:"path"`
+
+	// The field to take from the secret, for stores that keep several under
+	// one path.  The whole secret, as JSON if it has fields, if unset.
+	// +optional
+	Field string `json:"field,omitempty"`
+}
+
+// NetworkProfile describes the traffic a job's pods are allowed.  Nothing may
+// reach them, and they may reach nothing but what the egress rules allow.
+// NetworkPolicies add up, so the namespace's own policies may still let more
+// through; they can't let through less.
+type NetworkProfile struct {
+	// Where the pods may send traffic to, on top of DNS.
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+
+	// Whether the pods may look names up over DNS, on port 53 of any host.
+	// Defaults to true.
+	// +optional
+	AllowDNS *bool `json:"allowDNS,omitempty"`
+}
+
+// DNSAllowed reports whether the profile lets pods use DNS.
+func (p *NetworkProfile) DNSAllowed() bool {
+	return p.AllowDNS == nil || *p.AllowDNS
+}
+
+// MeshSidecarPolicy describes what happens to a service mesh sidecar in jobs'
+// pods.
+// +kubebuilder:validation:Enum=Auto;Quit;Ignore
+type MeshSidecarPolicy string
+
+const (
+	// AutoMeshSidecar tells the sidecar to quit if the pods look like they'll
+	// get one.
+	AutoMeshSidecar MeshSidecarPolicy = "Auto"
+
+	// QuitMeshSidecar always tells the sidecar to quit.
+	QuitMeshSidecar MeshSidecarPolicy = "Quit"
+
+	// IgnoreMeshSidecar leaves any sidecar be.
+	IgnoreMeshSidecar MeshSidecarPolicy = "Ignore"
+)
+
+// WorkloadBackend is the kind of workload a run creates.
+// +kubebuilder:validation:Enum=Job;Pod;ArgoWorkflow;TektonPipelineRun
+type WorkloadBackend string
+
+const (
+	// JobBackend creates a batch Job from the job template, as if there were
+	// no workloadRef at all.
+	JobBackend WorkloadBackend = "Job"
+
+	// PodBackend creates a bare Pod from the job template's pod template.
+	// The rest of the job's spec, like its parallelism and backoff limit,
+	// doesn't apply.
+	PodBackend WorkloadBackend = "Pod"
+
+	// ArgoWorkflowBackend creates an Argo Workflow from a WorkflowTemplate.
+	ArgoWorkflowBackend WorkloadBackend = "ArgoWorkflow"
+
+	// TektonPipelineRunBackend creates a Tekton PipelineRun of a Pipeline.
+	TektonPipelineRunBackend WorkloadBackend = "TektonPipelineRun"
+)
+
+// WorkloadRef says what a run creates in place of a batch Job.  Whatever it
+// is, it gets the labels and annotations the job would have had, and counts
+// towards the concurrency policy and history limits just like a job.  The
+// controller only creates the kinds of workload it has been set up for.
+type WorkloadRef struct {
+	// The kind of workload to create.  Valid values are:
+	// - "Job" (default): a batch Job from the job template;
+	// - "Pod": a Pod from the job template's pod template;
+	// - "ArgoWorkflow": an Argo Workflow of the named WorkflowTemplate;
+	// - "TektonPipelineRun": a Tekton PipelineRun of the named Pipeline.
+	// Argo Workflows and Tekton PipelineRuns don't use the job template's
+	// pod template, so neither secretInjection nor networkProfile apply to
+	// them.
+	// +optional
+	Backend WorkloadBackend `json:"backend,omitempty"`
+
+	// The WorkflowTemplate or Pipeline to run, in the CronJob's namespace.
+	// Required for ArgoWorkflow and TektonPipelineRun, and not allowed
+	// otherwise.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// The parameters to pass the WorkflowTemplate or Pipeline.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// RunsPodTemplate reports whether the workload runs the job template's pod
+// template.
+func (w *WorkloadRef) RunsPodTemplate() bool {
+	return w == nil || w.Backend == "" || w.Backend == JobBackend || w.Backend == PodBackend
+}
+
+// GateOperator compares the result of a ConditionGate's query with its
+// threshold.
+// +kubebuilder:validation:Enum=">";">=";"<";"<=";"==";"!="
+type GateOperator string
+
+// The operators compare the way PromQL's own comparisons do.
+const (
+	GreaterThan        GateOperator = ">"
+	GreaterThanOrEqual GateOperator = ">="
+	LessThan           GateOperator = "<"
+	LessThanOrEqual    GateOperator = "<="
+	EqualTo            GateOperator = "=="
+	NotEqualTo         GateOperator = "!="
+)
+
+// GateAction describes what happens to a scheduled run whose ConditionGate
+// isn't met.
+// +kubebuilder:validation:Enum=Skip;Defer
+type GateAction string
+
+const (
+	// SkipUnlessMet skips the run, recording why in status.
+	SkipUnlessMet GateAction = "Skip"
+
+	// DeferUntilMet holds the run back, checking the condition again every
+	// so often, until it's met or the run's starting deadline passes.
+	DeferUntilMet GateAction = "Defer"
+)
+
+// ConditionGate holds scheduled runs back unless a Prometheus query says
+// they're worth doing.  The query is evaluated against the controller's
+// --prometheus-url when each run comes due.
+type ConditionGate struct {
+	// The PromQL query, whose result must be a scalar or an instant vector
+	// with a single sample.  An empty result counts as the condition not
+	// being met, so a query that's a comparison itself, like
+	// `sum(queue_depth) > 1000`, can use the default operator and threshold.
+	Query string `json:"query"`
+
+	// How the query's result is compared with the threshold: one of >, >=,
+	// <, <=, == or !=.  Defaults to >.
+	// +optional
+	Operator GateOperator `json:"operator,omitempty"`
+
+	// The decimal number the query's result is compared with.  Defaults to
+	// 0.
+	// +optional
+	Threshold string `json:"threshold,omitempty"`
+
+	// What happens to a run whose condition isn't met, or can't be checked.
+	// Valid values are:
+	// - "Skip" (default): the run is skipped, and recorded in status;
+	// - "Defer": the run waits, and the condition is checked again every recheckIntervalSeconds, until it's met, the run's starting deadline passes, or the next run comes due.
+	// +optional
+	Action GateAction `json:"action,omitempty"`
+
+	// How often a deferred run checks the condition again.  Defaults to 60.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RecheckIntervalSeconds *int32 `json:"recheckIntervalSeconds,omitempty"`
+}
+
+// ThresholdValue parses the gate's threshold.
+func (g *ConditionGate) ThresholdValue() (float64, error) {
+	if g.Threshold == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(g.Threshold, 64)
+}
+
+// SchedulesRuns reports whether the schedule starts runs of its own.
+func (s *CronJobSpec) SchedulesRuns() bool {
+	return s.RunOnSchedule == nil || *s.RunOnSchedule
+}
+
+// CalendarRef points to an iCalendar feed.  Exactly one of url and
+// secretKeyRef must be set.
+type CalendarRef struct {
+	// The http or https URL to fetch the feed from, like the iCal address of
+	// a Google Calendar.  It has to be at a public address, unless the
+	// controller lists its host as internal.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// A key of a Secret in the CronJob's namespace holding either the URL to
+	// fetch the feed from, for calendars whose address is itself a secret,
+	// or the feed itself.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// How often to sync the feed, in seconds.  Defaults to 3600.
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	RefreshIntervalSeconds *int32 `json:"refreshIntervalSeconds,omitempty"`
+}
+
+// CalendarStatus describes the runs a CronJob's calendarRef has in store.
+type CalendarStatus struct {
+	// When the feed was last read successfully.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// When the controller last tried to read the feed.
+	// +optional
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+
+	// The start times of the feed's events from the CronJob's last scheduled
+	// run on, as of the last successful sync, oldest first.  Only so many are
+	// kept: the rest are picked up by later syncs.
+	// +optional
+	RunTimes []metav1.Time `json:"runTimes,omitempty"`
+}
+
+// EventTrigger starts a run for every event that arrives from its source.
+// Exactly one source must be set.
+type EventTrigger struct {
+	// The trigger's name, unique among the CronJob's triggers.  Runs it
+	// starts are recorded as triggered by "event:<name>".
+	Name string `json:"name"`
+
+	// Messages published to a NATS subject.
+	// +optional
+	NATS *NATSSource `json:"nats,omitempty"`
+
+	// Records produced to a Kafka topic.
+	// +optional
+	Kafka *KafkaSource `json:"kafka,omitempty"`
+
+	// Messages sent to an Amazon SQS queue.
+	// +optional
+	SQS *SQSSource `json:"sqs,omitempty"`
+
+	// CloudEvents POSTed to the controller.
+	// +optional
+	CloudEvents *CloudEventsSource `json:"cloudEvents,omitempty"`
+}
+
+// NATSSource receives messages from a NATS server that doesn't need
+// credentials.  Core NATS only delivers messages to subscribers that are
+// connected at the time, so any published while the controller is
+// restarting, or the CronJob is suspended, are missed.
+type NATSSource struct {
+	// The server, as nats://host:port, or tls://host:port to connect over
+	// TLS.  The port defaults to 4222.
+	URL string `json:"url"`
+
+	// The subject to subscribe to, which may have wildcards.
+	Subject string `json:"subject"`
+
+	// The queue group to subscribe in, to share the subject's messages with
+	// other subscribers in the group.
+	// +optional
+	Queue string `json:"queue,omitempty"`
+}
+
+// KafkaSource consumes records from a Kafka topic through a Kafka REST Proxy,
+// committing their offsets once their runs have been triggered.
+type KafkaSource struct {
+	// The REST proxy, serving its v2 API, e.g. http://kafka-rest:8082.
+	RESTProxyURL string `json:"restProxyURL"`
+
+	// The topic to consume.
+	Topic string `json:"topic"`
+
+	// The consumer group to consume in.  Defaults to
+	// cronjob-<namespace>-<name>-<trigger>.
+	// +optional
+	ConsumerGroup string `json:"consumerGroup,omitempty"`
 }
 
+// SQSSource receives messages from an Amazon SQS queue, deleting them once
+// their runs have been triggered.  Requests are signed with the controller's
+// own AWS credentials.
+type SQSSource struct {
+	// The queue's URL, e.g.
+	// https://sqs.us-east-1.amazonaws.com/123456789012/my-queue.
+	QueueURL string `json:"queueURL"`
+
+	// The queue's region.  Defaults to the region in its URL.
+	// +optional
+	Region string `json:"region,omitempty"`
+}
+
+// CloudEventsSource receives CloudEvents POSTed to the controller's
+// CloudEvents receiver, at /<namespace>/<cronjob>/<trigger>.  The receiver
+// has to be turned on with --cloudevents-bind-address.
+type CloudEventsSource struct {
+	// The types of event that start a run.  Any type does if empty.
+	// +optional
+	Types []string `json:"types,omitempty"`
+}
+
+// CronJobDependency refers to a CronJob that another CronJob depends on.
+type CronJobDependency struct {
+	// The name of the CronJob.
+	Name string `json:"name"`
+
+	// The namespace of the CronJob.  Defaults to the namespace of the
+	// dependent CronJob.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NotificationEvent is something that can be notified about.
+// +kubebuilder:validation:Enum=RunFailed;RunMissed;Resumed
+type NotificationEvent string
+
+const (
+	// NotifyRunFailed fires when a run fails, after any retries.
+	NotifyRunFailed NotificationEvent = "RunFailed"
+
+	// NotifyRunMissed fires when a run misses its starting deadline.
+	NotifyRunMissed NotificationEvent = "RunMissed"
+
+	// NotifyResumed fires when a CronJob the controller suspended for failing
+	// too often is resumed.
+	NotifyResumed NotificationEvent = "Resumed"
+)
+
+// NotificationFormat is the shape of a notification's payload.
+// +kubebuilder:validation:Enum=JSON;Slack
+type NotificationFormat string
+
+const (
+	// JSONNotification sends the details of what happened as a JSON object,
+	// with the message under "text".
+	JSONNotification NotificationFormat = "JSON"
+
+	// SlackNotification sends just the message, the way Slack's incoming
+	// webhooks expect it.
+	SlackNotification NotificationFormat = "Slack"
+)
+
+// Notification is an HTTP endpoint to notify about some of a CronJob's runs.
+type Notification struct {
+	// The URL to POST notifications to.  It has to be at a public address,
+	// unless the controller lists its host as internal.
+	URL string `json:"url"`
+
+	// The events to notify about.
+	// +kubebuilder:validation:MinItems=1
+	Events []NotificationEvent `json:"events"`
+
+	// The shape of the payload.  Defaults to JSON.
+	// +optional
+	Format NotificationFormat `json:"format,omitempty"`
+
+	// A Go template for the message, executed with .Event, .Namespace,
+	// .CronJob, .ScheduledTime, .Job and .Reason.  Defaults to a one-line
+	// summary.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// SkipReason describes why a scheduled run was not started.
+type SkipReason string
+
+const (
+	// BlackoutWindowSkip means the run was scheduled inside one of the
+	// CronJob's blackout windows.
+	BlackoutWindowSkip SkipReason = "BlackoutWindow"
+
+	// RequestedSkip means the run was skipped because of .spec.skipNextRuns.
+	RequestedSkip SkipReason = "SkipNextRuns"
+
+	// ConcurrencySkip means the run was skipped because the concurrency
+	// policy didn't allow it to start alongside the runs already active.
+	ConcurrencySkip SkipReason = "ConcurrencyPolicy"
+
+	// DeadlineExceededSkip means the run couldn't be started within
+	// .spec.startingDeadlineSeconds of its scheduled time.
+	DeadlineExceededSkip SkipReason = "StartingDeadlineExceeded"
+
+	// SuspendedSkip means the run was scheduled while the CronJob was
+	// suspended.
+	SuspendedSkip SkipReason = "Suspended"
+
+	// PausedSkip means the run was scheduled while the CronJob was paused.
+	PausedSkip SkipReason = "Paused"
+
+	// PolicyViolationSkip means the CronJob broke one of the CronJobPolicies
+	// in its namespace when the run was due.
+	PolicyViolationSkip SkipReason = "PolicyViolation"
+
+	// MaintenanceWindowSkip means the run was scheduled inside a
+	// MaintenanceWindow that skips runs, rather than deferring them.
+	MaintenanceWindowSkip SkipReason = "MaintenanceWindow"
+
+	// HolidaySkip means the run was scheduled on a holiday in the CronJob's
+	// HolidayCalendar, and its holiday policy is to skip.
+	HolidaySkip SkipReason = "Holiday"
+
+	// ImageVerificationSkip means the images of the run's job weren't signed
+	// as the namespace's CronJobPolicies require.
+	ImageVerificationSkip SkipReason = "ImageVerification"
+
+	// RunDeniedSkip means the endpoint of one of the namespace's
+	// CronJobPolicies vetoed the run.
+	RunDeniedSkip SkipReason = "RunDenied"
+
+	// ConditionGateSkip means the CronJob's conditionGate wasn't met when
+	// the run was due.
+	ConditionGateSkip SkipReason = "ConditionNotMet"
+)
+
+// SkippedRun records a scheduled run that the controller deliberately did not start.
+type SkippedRun struct {
+	// The time at which the run was scheduled.
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+
+	// Why the run was skipped.
+	Reason SkipReason `json:"reason"`
+}
+
+// RunDeferral describes a run being held back by a MaintenanceWindow.
+type RunDeferral struct {
+	// The time at which the run was scheduled.
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+
+	// The name of the MaintenanceWindow holding the run back.
+	MaintenanceWindow string `json:"maintenanceWindow"`
+
+	// When the window closes, and the run can start.
+	Until metav1.Time `json:"until"`
+}
+
+// AppliedScheduleOverride describes the ScheduleOverride a CronJob is
+// running on.
+type AppliedScheduleOverride struct {
+	// The name of the ScheduleOverride.
+	Name string `json:"name"`
+
+	// The schedule the CronJob is running on instead of its own.
+	Schedule string `json:"schedule"`
+
+	// When the CronJob goes back to its own schedule.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// RunResult describes how a finished run turned out.
+type RunResult string
+
+const (
+	// RunSucceeded means the run's final step completed successfully.
+	RunSucceeded RunResult = "Succeeded"
+
+	// RunFailed means one of the run's steps failed, after any retries.
+	RunFailed RunResult = "Failed"
+)
+
+// RunRecord summarises a finished run, so that it can be looked back on once
+// its jobs have been cleaned up.
+type RunRecord struct {
+	// The time at which the run was scheduled.
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+
+	// When the run's first job started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the run's last job finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// How the run turned out.
+	Result RunResult `json:"result"`
+
+	// The name of the run's last job.
+	JobName string `json:"jobName"`
+
+	// Where the logs of the run's last job were archived, if they were.
+	// +optional
+	LogsLocation string `json:"logsLocation,omitempty"`
+
+	// Who triggered the run by hand, if anyone did.
+	// +optional
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+}
+
+const (
+	// ReadyCondition is true when the CronJob is healthy: its schedule is
+	// valid, and its runs aren't failing.
+	ReadyCondition = "Ready"
+
+	// ScheduleValidCondition is true when .spec.schedule can be parsed.
+	ScheduleValidCondition = "ScheduleValid"
+
+	// ActiveCondition is true when any of the CronJob's jobs are running.
+	ActiveCondition = "Active"
+
+	// FailedRunsExceededCondition is true when as many runs in a row have
+	// failed as .spec.failurePolicy allows, or when the most recent run failed
+	// if there's no such limit.
+	FailedRunsExceededCondition = "FailedRunsExceeded"
+
+	// MissedRunsExceededCondition is true when more runs were missed than
+	// .spec.maxMissedRuns allows, so only the most recent was considered.
+	MissedRunsExceededCondition = "MissedRunsExceeded"
+
+	// AutoSuspendedCondition is true when the controller suspended the CronJob
+	// because too many runs failed in a row, as per .spec.failurePolicy.
+	AutoSuspendedCondition = "AutoSuspended"
+
+	// ReplacingCondition is true while jobs the controller replaced, as per
+	// the Replace concurrency policy, are still being deleted.  The schedule
+	// can't be changed in the meantime.
+	ReplacingCondition = "Replacing"
+
+	// QuotaExceededCondition is true while a run is waiting because starting
+	// it would go over one of the RunQuotas in the CronJob's namespace, or
+	// over the controller's budget for the CronJob's tenant.
+	QuotaExceededCondition = "QuotaExceeded"
+
+	// PausedCondition is true while the CronJob is paused, with
+	// .spec.pauseReason as its message.
+	PausedCondition = "Paused"
+
+	// PodSecurityCompliantCondition is true when the CronJob's pod template
+	// meets the Pod Security Standards level its namespace enforces.  While it
+	// doesn't, its jobs' pods are likely to be rejected, but runs still go
+	// ahead, since the API server may exempt them.
+	PodSecurityCompliantCondition = "PodSecurityCompliant"
+
+	// ImagesVerifiedCondition is true when the images of the CronJob's latest
+	// job were signed as its namespace's CronJobPolicies require, or didn't
+	// need to be.
+	ImagesVerifiedCondition = "ImagesVerified"
+
+	// ConditionGateMetCondition is true when the CronJob's conditionGate was
+	// met the last time a scheduled run came due, and false, with the query's
+	// result in its message, when it held a run back.
+	ConditionGateMetCondition = "ConditionGateMet"
+
+	// CalendarSyncedCondition is true when the CronJob's calendarRef was
+	// read the last time the controller tried, and false, with why in its
+	// message, while it's running on the runs it last read.
+	CalendarSyncedCondition = "CalendarSynced"
+)
+
 // CronJobStatus defines the observed state of CronJob
 type CronJobStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// The generation of the spec that the controller last acted on.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// A list of pointers to currently running jobs.
 	Active []corev1.ObjectReference `json:"active,omitempty"`
 
+	// The number of currently running jobs.
+	// +optional
+	ActiveCount int32 `json:"activeCount"`
+
+	// A list of pointers to jobs waiting in their Kueue queue to be
+	// admitted.
+	// +optional
+	QueuedJobs []corev1.ObjectReference `json:"queuedJobs,omitempty"`
+
+	// When the next run is scheduled, if the CronJob isn't suspended
+	// indefinitely and its schedule will ever run again.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// How the most recently finished run turned out.
+	// +optional
+	LastRunResult RunResult `json:"lastRunResult,omitempty"`
+
 	// Information when was the last time the job was successfully scheduled.
 	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// The last time a run completed successfully.
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// The scheduled time of the most recent run that succeeded, which
+	// CronJobs depending on this one go by once its jobs are gone.
+	// +optional
+	LastSuccessfulScheduleTime *metav1.Time `json:"lastSuccessfulScheduleTime,omitempty"`
+
+	// The entries of .spec.runAt for which a job has already been created.
+	// +optional
+	ConsumedRunAt []metav1.Time `json:"consumedRunAt,omitempty"`
+
+	// Runs that are waiting for a previous run to finish under the Queue
+	// concurrency policy, oldest first.
+	// +optional
+	QueuedRuns []metav1.Time `json:"queuedRuns,omitempty"`
+
+	// The scheduled time of the latest run skipped at the head of the queue.
+	// The runs before it have all been started or skipped, whether or not
+	// they're still among skippedRuns.
+	// +optional
+	QueueSkippedUpTo *metav1.Time `json:"queueSkippedUpTo,omitempty"`
+
+	// When the CronJob was paused, if it is.
+	// +optional
+	PausedSince *metav1.Time `json:"pausedSince,omitempty"`
+
+	// The time of the most recent manual trigger that the controller has acted on.
+	// +optional
+	LastTriggerTime *metav1.Time `json:"lastTriggerTime,omitempty"`
+
+	// When the controller last saw the CronJob suspended after running on
+	// schedule.
+	// +optional
+	LastSuspendTime *metav1.Time `json:"lastSuspendTime,omitempty"`
+
+	// When the controller last saw the CronJob resumed after being suspended.
+	// +optional
+	LastResumeTime *metav1.Time `json:"lastResumeTime,omitempty"`
+
+	// Represents the latest available observations of the CronJob's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The most recent runs that were skipped rather than started, oldest first.
+	// +optional
+	SkippedRuns []SkippedRun `json:"skippedRuns,omitempty"`
+
+	// The run currently being held back by a MaintenanceWindow, if any.
+	// +optional
+	DeferredRun *RunDeferral `json:"deferredRun,omitempty"`
+
+	// The ScheduleOverride the CronJob is running on instead of its own
+	// schedule, if any.
+	// +optional
+	ScheduleOverride *AppliedScheduleOverride `json:"scheduleOverride,omitempty"`
+
+	// When the CronJob last went onto a ScheduleOverride, or back off one.
+	// Runs that came due before then aren't made up for.
+	// +optional
+	ScheduleChangeTime *metav1.Time `json:"scheduleChangeTime,omitempty"`
+
+	// The runs the CronJob's calendarRef has in store, if it has one.
+	// +optional
+	Calendar *CalendarStatus `json:"calendar,omitempty"`
+
+	// The number of runs in a row that have failed, counting up to the most
+	// recently finished run.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// The number of runs that have failed over the CronJob's lifetime.
+	// +optional
+	TotalFailures int32 `json:"totalFailures,omitempty"`
+
+	// The scheduled time of the most recently finished run counted towards
+	// consecutiveFailures.
+	// +optional
+	LastFinishedRunTime *metav1.Time `json:"lastFinishedRunTime,omitempty"`
+
+	// The number of jobs the controller has created over the CronJob's
+	// lifetime, including retries and hooks.
+	// +optional
+	JobsCreated int64 `json:"jobsCreated,omitempty"`
+
+	// The number of jobs that have completed successfully over the CronJob's
+	// lifetime.
+	// +optional
+	JobsSucceeded int64 `json:"jobsSucceeded,omitempty"`
+
+	// The number of jobs that have failed over the CronJob's lifetime.
+	// +optional
+	JobsFailed int64 `json:"jobsFailed,omitempty"`
+
+	// The number of scheduled runs that were skipped rather than started over
+	// the CronJob's lifetime.
+	// +optional
+	JobsSkipped int64 `json:"jobsSkipped,omitempty"`
+
+	// The most recently finished runs, oldest first.  Unlike the jobs
+	// themselves, these aren't subject to the history limits.
+	// +optional
+	RecentRuns []RunRecord `json:"recentRuns,omitempty"`
 }
 
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Active",type=integer,JSONPath=`.status.activeCount`
+//+kubebuilder:printcolumn:name="Next Run",type=date,JSONPath=`.status.nextScheduleTime`
+//+kubebuilder:printcolumn:name="Last Result",type=string,JSONPath=`.status.lastRunResult`
+//+kubebuilder:printcolumn:name="Last Success",type=date,JSONPath=`.status.lastSuccessfulTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // CronJob is the Schema for the cronjobs API
 type CronJob struct {
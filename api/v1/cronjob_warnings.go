@@ -0,0 +1,214 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"kubebuilder-tutorial/pkg/podsecurity"
+	schedulepkg "kubebuilder-tutorial/pkg/schedule"
+)
+
+/*
+Some settings are allowed, but are more likely to be mistakes than not.  Rather
+than rejecting them, we point them out as admission warnings, which kubectl shows
+to the user.  `webhook.Validator` can't return warnings, so these get a webhook
+of their own, which never rejects anything.
+
+The same goes for a preview of the next few runs: it's much easier to spot a
+cron expression that doesn't mean what you think it means from the times it
+actually fires at than from the expression itself.
+
+And for a pod template that breaks the Pod Security Standards level its
+namespace enforces: the CronJob itself is fine, but Pod Security admission
+would reject every one of its pods.  Like Pod Security admission does for
+other workloads, we only warn.
+*/
+
+//+kubebuilder:webhook:verbs=create;update,path=/warn-batch-tutorial-kubebuilder-io-v1-cronjob,mutating=false,failurePolicy=ignore,groups=batch.tutorial.kubebuilder.io,resources=cronjobs,versions=v1,name=wcronjob.kb.io
+
+const warningWebhookPath = "/warn-batch-tutorial-kubebuilder-io-v1-cronjob"
+
+// minScheduleInterval is the shortest interval between runs that we don't
+// warn about.
+const minScheduleInterval = time.Minute
+
+// minStartingDeadlineSeconds is the shortest starting deadline that we don't
+// warn about: anything shorter may be missed just by the controller being busy.
+const minStartingDeadlineSeconds = 10
+
+// previewRuns is how many upcoming runs we preview.
+const previewRuns = 5
+
+// cronJobWarner is an admission handler that only ever warns.
+type cronJobWarner struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &cronJobWarner{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (w *cronJobWarner) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (w *cronJobWarner) Handle(ctx context.Context, req admission.Request) admission.Response {
+	cronJob := &CronJob{}
+	if err := w.decoder.Decode(req, cronJob); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	warnings := append(cronJob.warnings(time.Now()), cronJob.podSecurityWarnings(ctx)...)
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// podSecurityWarnings lists the ways the CronJob's own pod template breaks
+// the level its namespace enforces.  Templates from JobTemplates are checked
+// by the controller instead.
+func (r *CronJob) podSecurityWarnings(ctx context.Context) []string {
+	if policyReader == nil || r.Spec.JobTemplateRef != nil {
+		return nil
+	}
+	var namespace corev1.Namespace
+	if err := policyReader.Get(ctx, types.NamespacedName{Name: r.Namespace}, &namespace); err != nil {
+		return nil
+	}
+	level := podsecurity.EnforcedLevel(namespace.Labels)
+	violations := podsecurity.Check(level, &r.Spec.JobTemplate.Spec.Template)
+	warnings := make([]string, len(violations))
+	for i, violation := range violations {
+		warnings[i] = fmt.Sprintf("spec.jobTemplate.spec.template: would violate Pod Security level %q: %s", level, violation)
+	}
+	return warnings
+}
+
+// warnings lists the CronJob's suspicious settings, along with a preview of
+// its next few runs.  There's no preview for a CronJob on a calendar, whose
+// runs aren't known until the controller has read it.
+func (r *CronJob) warnings(now time.Time) []string {
+	var warnings []string
+
+	if !r.Spec.SchedulesRuns() {
+		if len(r.Spec.Triggers) == 0 && len(r.Spec.RunAt) == 0 {
+			warnings = append(warnings, "spec.runOnSchedule: false with neither triggers nor runAt, so the CronJob only runs when triggered by hand")
+		}
+	} else if sched, err := r.parsedSchedule(); err == nil && r.Spec.CalendarRef == nil {
+		upcoming := upcomingRuns(sched, now, previewRuns)
+
+		// two consecutive runs are enough to tell for the schedules we support
+		if len(upcoming) >= 2 {
+			// schedules to the second are meant to run that often
+			if interval := upcoming[1].Sub(upcoming[0]); interval < minScheduleInterval && r.Spec.ScheduleGranularity != SecondGranularity {
+				warnings = append(warnings, fmt.Sprintf("spec.schedule: runs every %s, more often than once a minute", interval))
+			}
+		}
+		if r.Spec.Suspend == nil || !*r.Spec.Suspend {
+			warnings = append(warnings, previewWarning(upcoming))
+		}
+	}
+	if deadline := r.Spec.StartingDeadlineSeconds; deadline != nil && *deadline < minStartingDeadlineSeconds {
+		warnings = append(warnings, fmt.Sprintf("spec.startingDeadlineSeconds: %d seconds is short enough that runs may be missed whenever the controller is busy", *deadline))
+	}
+	if limit := r.Spec.SuccessfulJobsHistoryLimit; limit != nil && *limit == 0 {
+		warnings = append(warnings, "spec.successfulJobsHistoryLimit: successful jobs will be deleted as soon as they finish")
+	}
+	if limit := r.Spec.FailedJobsHistoryLimit; limit != nil && *limit == 0 {
+		warnings = append(warnings, "spec.failedJobsHistoryLimit: failed jobs will be deleted as soon as they finish, along with their logs")
+	}
+	return warnings
+}
+
+// parsedSchedule parses the CronJob's schedule, in its time zone if it has a
+// valid one, or hands back its calendar's if it's on one.
+func (r *CronJob) parsedSchedule() (schedulepkg.Schedule, error) {
+	if r.Spec.CalendarRef != nil {
+		return r.CalendarSchedule(), nil
+	}
+	sched, err := r.Spec.ScheduleGranularity.Parse(r.Spec.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return schedulepkg.WithDSTPolicy(sched, r.location(), r.Spec.DSTPolicy.SchedulePolicy()), nil
+}
+
+// location is the CronJob's time zone, if it has a valid one, or the local
+// one if not.
+func (r *CronJob) location() *time.Location {
+	if r.Spec.TimeZone != nil {
+		if loc, err := schedulepkg.LoadLocation(*r.Spec.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// CalendarSchedule is the schedule of a CronJob on a calendar: the runs the
+// controller last read from it, in the CronJob's time zone.
+func (r *CronJob) CalendarSchedule() schedulepkg.Schedule {
+	var times []time.Time
+	if calendar := r.Status.Calendar; calendar != nil {
+		loc := r.location()
+		for _, t := range calendar.RunTimes {
+			times = append(times, t.In(loc))
+		}
+	}
+	return schedulepkg.Times(times)
+}
+
+// NextRuns lists up to n of the CronJob's runs after the given time, as its
+// own schedule (or calendar) has them: holidays and ScheduleOverrides aren't
+// taken into account.  There are none if the schedule doesn't start runs.
+func (r *CronJob) NextRuns(after time.Time, n int) ([]time.Time, error) {
+	sched, err := r.parsedSchedule()
+	if err != nil {
+		return nil, err
+	}
+	if !r.Spec.SchedulesRuns() {
+		return nil, nil
+	}
+	return upcomingRuns(sched, after, n), nil
+}
+
+// upcomingRuns lists up to n activations of the schedule after now.
+func upcomingRuns(sched schedulepkg.Schedule, now time.Time, n int) []time.Time {
+	var runs []time.Time
+	for t := sched.Next(now); !t.IsZero() && len(runs) < n; t = sched.Next(t) {
+		runs = append(runs, t)
+	}
+	return runs
+}
+
+// previewWarning describes the upcoming runs, in the schedule's own time zone.
+func previewWarning(upcoming []time.Time) string {
+	if len(upcoming) == 0 {
+		return "spec.schedule: will never run again"
+	}
+	times := make([]string, len(upcoming))
+	for i, t := range upcoming {
+		times[i] = t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("spec.schedule: next runs at %s", strings.Join(times, ", "))
+}
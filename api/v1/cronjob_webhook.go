@@ -18,15 +18,36 @@ limitations under the License.
 package v1
 
 import (
-	"github.com/robfig/cron"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	validationutils "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"kubebuilder-tutorial/pkg/eventsource"
+	"kubebuilder-tutorial/pkg/notify"
+	schedulepkg "kubebuilder-tutorial/pkg/schedule"
 )
 
 // +kubebuilder:docs-gen:collapse=Go imports
@@ -38,10 +59,16 @@ Next, we'll setup a logger for the webhooks.
 var cronjoblog = logf.Log.WithName("cronjob-resource")
 
 /*
-Then, we set up the webhook with the manager.
+Then, we set up the webhook with the manager, along with the one that warns
+about suspicious settings (see below), and the one that records who triggers
+runs by hand (see cronjobtrigger_webhook.go).
 */
 
 func (r *CronJob) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	policyReader = mgr.GetClient()
+	mgr.GetWebhookServer().Register(warningWebhookPath, &webhook.Admission{Handler: &cronJobWarner{}})
+	mgr.GetWebhookServer().Register(initiatorWebhookPath, &webhook.Admission{Handler: &initiatorRecorder{}})
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -69,22 +96,133 @@ var _ webhook.Defaulter = &CronJob{}
 func (r *CronJob) Default() {
 	cronjoblog.Info("default", "name", r.Name)
 
+	// the namespace's policies get first say, then our own defaults fill in
+	// whatever they leave unset
+	policies, err := r.policies()
+	if err != nil {
+		cronjoblog.Error(err, "unable to list CronJobPolicies, skipping their defaults", "name", r.Name)
+	}
+	for i := range policies {
+		policies[i].ApplyDefaults(r)
+	}
+
 	if r.Spec.ConcurrencyPolicy == "" {
 		r.Spec.ConcurrencyPolicy = AllowConcurrent
 	}
+	if r.Spec.DSTPolicy == "" {
+		r.Spec.DSTPolicy = RunEarlierOnDST
+	}
+	if r.Spec.ScheduleGranularity == "" {
+		r.Spec.ScheduleGranularity = MinuteGranularity
+	}
+	if r.Spec.ResumePolicy == "" {
+		r.Spec.ResumePolicy = SkipMissedOnResume
+	}
+	if r.Spec.HolidayCalendarRef != nil && r.Spec.HolidayPolicy == "" {
+		r.Spec.HolidayPolicy = SkipHolidays
+	}
 	if r.Spec.Suspend == nil {
 		r.Spec.Suspend = new(bool)
 	}
+	successfulLimit, failedLimit := defaultHistoryLimits()
 	if r.Spec.SuccessfulJobsHistoryLimit == nil {
 		r.Spec.SuccessfulJobsHistoryLimit = new(int32)
-		*r.Spec.SuccessfulJobsHistoryLimit = 3
+		*r.Spec.SuccessfulJobsHistoryLimit = successfulLimit
 	}
 	if r.Spec.FailedJobsHistoryLimit == nil {
 		r.Spec.FailedJobsHistoryLimit = new(int32)
-		*r.Spec.FailedJobsHistoryLimit = 1
+		*r.Spec.FailedJobsHistoryLimit = failedLimit
+	}
+
+	r.defaultJobTemplateLabels()
+}
+
+/*
+The default history limits are the manager's to set, from its config, and it may
+change them while it's running.
+*/
+
+// Our own default history limits.
+const (
+	defaultSuccessfulJobsHistoryLimit = 3
+	defaultFailedJobsHistoryLimit     = 1
+)
+
+var historyLimits = struct {
+	sync.RWMutex
+	successful, failed int32
+}{successful: defaultSuccessfulJobsHistoryLimit, failed: defaultFailedJobsHistoryLimit}
+
+// SetDefaultHistoryLimits changes the history limits given to CronJobs that
+// don't set their own.  Either may be nil, to go back to our own default.
+func SetDefaultHistoryLimits(successful, failed *int32) {
+	historyLimits.Lock()
+	defer historyLimits.Unlock()
+	historyLimits.successful, historyLimits.failed = defaultSuccessfulJobsHistoryLimit, defaultFailedJobsHistoryLimit
+	if successful != nil {
+		historyLimits.successful = *successful
+	}
+	if failed != nil {
+		historyLimits.failed = *failed
 	}
 }
 
+// defaultHistoryLimits returns the history limits given to CronJobs that
+// don't set their own.
+func defaultHistoryLimits() (successful, failed int32) {
+	historyLimits.RLock()
+	defer historyLimits.RUnlock()
+	return historyLimits.successful, historyLimits.failed
+}
+
+/*
+We also stamp some standard labels onto the job template, which the controller
+carries over onto every job and pod it creates, so that they can be picked out
+by CronJob (and by schedule) for things like cost attribution and log queries.
+*/
+
+const (
+	// ManagedByLabel is the standard label for the tool managing an object.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// ManagedByValue is the value of ManagedByLabel on jobs created by our controller.
+	ManagedByValue = "kubebuilder-tutorial"
+
+	// CronJobNameLabel holds the name of the CronJob a job was created for.
+	CronJobNameLabel = "batch.tutorial.kubebuilder.io/cronjob"
+
+	// ScheduleHashLabel holds a hash of the schedule a job was created under,
+	// so that jobs from before and after a schedule change can be told apart.
+	ScheduleHashLabel = "batch.tutorial.kubebuilder.io/schedule-hash"
+
+	// TeamLabel names the team a CronJob belongs to, so that all of a team's
+	// CronJobs can be found together, across namespaces.
+	TeamLabel = "batch.tutorial.kubebuilder.io/team"
+)
+
+// StandardLabels lists the labels that we stamp onto job templates.
+var StandardLabels = []string{ManagedByLabel, CronJobNameLabel, ScheduleHashLabel}
+
+func (r *CronJob) defaultJobTemplateLabels() {
+	if r.Spec.JobTemplate.Labels == nil {
+		r.Spec.JobTemplate.Labels = make(map[string]string)
+	}
+	labels := r.Spec.JobTemplate.Labels
+	labels[ManagedByLabel] = ManagedByValue
+	if r.Name != "" {
+		// names are only known up front if they're not generated
+		labels[CronJobNameLabel] = r.Name
+	}
+	labels[ScheduleHashLabel] = ScheduleHash(r.Spec.Schedule)
+}
+
+// ScheduleHash hashes a schedule short enough for a label value.
+func ScheduleHash(schedule string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(schedule))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
 /*
 This marker is responsible for generating a validating webhook manifest.
 */
@@ -109,9 +247,9 @@ We separate out ValidateCreate from ValidateUpdate to allow behavior like making
 certain fields immutable, so that they can only be set on creation.
 ValidateDelete is also separated from ValidateUpdate to allow different
 validation behavior on deletion.
-Here, however, we just use the same shared validation for `ValidateCreate` and
-`ValidateUpdate`. And we do nothing in `ValidateDelete`, since we don't need to
-validate anything on deletion.
+Here, `ValidateCreate` and `ValidateUpdate` share most of their validation, but
+updates also get checked against the object they replace. And we do nothing in
+`ValidateDelete`, since we don't need to validate anything on deletion.
 */
 
 var _ webhook.Validator = &CronJob{}
@@ -120,14 +258,18 @@ var _ webhook.Validator = &CronJob{}
 func (r *CronJob) ValidateCreate() error {
 	cronjoblog.Info("validate create", "name", r.Name)
 
-	return r.validateCronJob()
+	return r.validateCronJob(r.validateCronJobCreate(time.Now())...)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *CronJob) ValidateUpdate(old runtime.Object) error {
 	cronjoblog.Info("validate update", "name", r.Name)
 
-	return r.validateCronJob()
+	oldCronJob, ok := old.(*CronJob)
+	if !ok {
+		return fmt.Errorf("expected a CronJob but got a %T", old)
+	}
+	return r.validateCronJob(r.validateCronJobUpdate(oldCronJob)...)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -137,17 +279,17 @@ func (r *CronJob) ValidateDelete() error {
 }
 
 /*
-We validate the name and the spec of the CronJob.
+We validate the name and the spec of the CronJob, along with anything the caller
+has found wrong already.
 */
 
-func (r *CronJob) validateCronJob() error {
-	var allErrs field.ErrorList
+func (r *CronJob) validateCronJob(errs ...*field.Error) error {
+	allErrs := field.ErrorList(errs)
 	if err := r.validateCronJobName(); err != nil {
 		allErrs = append(allErrs, err)
 	}
-	if err := r.validateCronJobSpec(); err != nil {
-		allErrs = append(allErrs, err)
-	}
+	allErrs = append(allErrs, r.validateCronJobSpec()...)
+	allErrs = append(allErrs, r.validateCronJobPolicies(time.Now())...)
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -157,6 +299,75 @@ func (r *CronJob) validateCronJob() error {
 		r.Name, allErrs)
 }
 
+/*
+On top of that, the CronJob has to meet the rules of every CronJobPolicy in its
+namespace.
+*/
+
+func (r *CronJob) validateCronJobPolicies(now time.Time) field.ErrorList {
+	policies, err := r.policies()
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata", "namespace"), fmt.Errorf("unable to list CronJobPolicies: %v", err))}
+	}
+	var allErrs field.ErrorList
+	for i := range policies {
+		allErrs = append(allErrs, policies[i].Violations(r, now)...)
+	}
+	return allErrs
+}
+
+// policies lists the CronJobPolicies in the CronJob's namespace, if we have
+// a way to look them up.
+func (r *CronJob) policies() ([]CronJobPolicy, error) {
+	if policyReader == nil {
+		return nil, nil
+	}
+	return PoliciesFor(context.Background(), policyReader, r.Namespace)
+}
+
+/*
+A new CronJob suspended until some time that's already passed is almost
+certainly a copy-paste from an older one, so we only accept a future
+`suspendUntil` on creation.  Later on, the time passing is just how it's
+supposed to work.
+
+Rules like this one, that depend on the operation, would be CEL rules in the
+schema on newer API servers, but our CRD is still served as
+apiextensions.k8s.io/v1beta1, which can't carry them, so they live here alone.
+*/
+
+func (r *CronJob) validateCronJobCreate(now time.Time) field.ErrorList {
+	var allErrs field.ErrorList
+	if until := r.Spec.SuspendUntil; until != nil && !until.Time.After(now) {
+		allErrs = append(allErrs, field.Invalid(
+			field.NewPath("spec").Child("suspendUntil"),
+			until.Time.Format(time.RFC3339),
+			"must be in the future"))
+	}
+	return allErrs
+}
+
+/*
+Updates can't change the schedule while jobs being replaced under the `Replace`
+concurrency policy are still going away: the controller has already decided
+which run replaces them, and a new schedule could leave that run orphaned from
+the one the controller works out next.
+
+Fields that identify a CronJob's runs would be immutable here too, but for now
+runs are identified by the CronJob's name alone, which is immutable anyway.
+*/
+
+func (r *CronJob) validateCronJobUpdate(old *CronJob) field.ErrorList {
+	var allErrs field.ErrorList
+	replacing := meta.FindStatusCondition(old.Status.Conditions, ReplacingCondition)
+	if replacing != nil && replacing.Status == metav1.ConditionTrue && r.Spec.Schedule != old.Spec.Schedule {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("spec").Child("schedule"),
+			"may not change while jobs are being replaced; try again once the Replacing condition clears"))
+	}
+	return allErrs
+}
+
 /*
 Some fields are declaratively validated by OpenAPI schema.
 You can find kubebuilder validation markers (prefixed
@@ -167,26 +378,619 @@ declaring validation by running `controller-gen crd -w`,
 or [here](/reference/markers/crd-validation.md).
 */
 
-func (r *CronJob) validateCronJobSpec() *field.Error {
+func (r *CronJob) validateCronJobSpec() field.ErrorList {
+	var allErrs field.ErrorList
 	// The field helpers from the kubernetes API machinery help us return nicely
-	// structured validation errors.
-	return validateScheduleFormat(
+	// structured validation errors.  A CronJob on a calendar has no schedule
+	// to validate, just the calendar's reference.
+	if r.Spec.CalendarRef != nil {
+		allErrs = append(allErrs, validateCalendarRef(
+			&r.Spec,
+			field.NewPath("spec"))...)
+	} else if err := validateScheduleAt(
 		r.Spec.Schedule,
-		field.NewPath("spec").Child("schedule"))
+		r.Spec.ScheduleGranularity,
+		field.NewPath("spec").Child("schedule")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if r.Spec.TimeZone != nil {
+		if err := validateTimeZone(
+			*r.Spec.TimeZone,
+			field.NewPath("spec").Child("timeZone")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	allErrs = append(allErrs, validateBlackoutWindows(
+		r.Spec.BlackoutWindows,
+		field.NewPath("spec").Child("blackoutWindows"))...)
+	if r.Spec.HolidayCalendarRef != nil && r.Spec.HolidayCalendarRef.Name == "" {
+		allErrs = append(allErrs, field.Required(
+			field.NewPath("spec").Child("holidayCalendarRef", "name"),
+			"must name a HolidayCalendar"))
+	}
+	allErrs = append(allErrs, validateNotifications(
+		r.Spec.Notifications,
+		field.NewPath("spec").Child("notifications"))...)
+	allErrs = append(allErrs, validateHistoryLimits(
+		&r.Spec,
+		field.NewPath("spec"))...)
+	if r.Spec.SecretInjection != nil {
+		allErrs = append(allErrs, validateSecretInjection(
+			r.Spec.SecretInjection,
+			field.NewPath("spec").Child("secretInjection"))...)
+	}
+	allErrs = append(allErrs, validateEventTriggers(
+		r.Spec.Triggers,
+		field.NewPath("spec").Child("triggers"))...)
+	if r.Spec.NetworkProfile != nil {
+		allErrs = append(allErrs, validateNetworkProfile(
+			r.Spec.NetworkProfile,
+			field.NewPath("spec").Child("networkProfile"))...)
+	}
+	if r.Spec.ConditionGate != nil {
+		allErrs = append(allErrs, validateConditionGate(
+			r.Spec.ConditionGate,
+			field.NewPath("spec").Child("conditionGate"))...)
+	}
+	if r.Spec.QueueName != "" {
+		for _, msg := range validationutils.IsValidLabelValue(r.Spec.QueueName) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("queueName"), r.Spec.QueueName, msg))
+		}
+	}
+	if r.Spec.MeshSidecar == QuitMeshSidecar {
+		allErrs = append(allErrs, validateMeshSidecarQuit(
+			&r.Spec,
+			field.NewPath("spec"))...)
+	}
+	if r.Spec.WorkloadRef != nil {
+		allErrs = append(allErrs, validateWorkloadRef(
+			&r.Spec,
+			field.NewPath("spec"))...)
+	}
+	if r.Spec.JobTemplateRef != nil {
+		allErrs = append(allErrs, validateJobTemplateRef(
+			r.Spec.JobTemplateRef,
+			&r.Spec.JobTemplate,
+			field.NewPath("spec"))...)
+	} else {
+		allErrs = append(allErrs, validateJobTemplate(
+			&r.Spec.JobTemplate,
+			field.NewPath("spec").Child("jobTemplate"))...)
+	}
+	if r.Spec.Hooks != nil {
+		hooksPath := field.NewPath("spec").Child("hooks")
+		if r.Spec.Hooks.PreRun != nil {
+			allErrs = append(allErrs, validatePodTemplate(r.Spec.Hooks.PreRun, hooksPath.Child("preRun"))...)
+		}
+		if r.Spec.Hooks.PostRun != nil {
+			allErrs = append(allErrs, validatePodTemplate(r.Spec.Hooks.PostRun, hooksPath.Child("postRun"))...)
+		}
+	}
+	return allErrs
 }
 
 /*
 We'll need to validate the [cron](https://en.wikipedia.org/wiki/Cron) schedule
+(or [ISO 8601 repeating interval](https://en.wikipedia.org/wiki/ISO_8601#Repeating_intervals))
 is well-formatted.
 */
 
 func validateScheduleFormat(schedule string, fldPath *field.Path) *field.Error {
-	if _, err := cron.ParseStandard(schedule); err != nil {
+	if _, err := schedulepkg.Parse(schedule); err != nil {
 		return field.Invalid(fldPath, schedule, err.Error())
 	}
 	return nil
 }
 
+/*
+Schedules to the second have a sixth field, and we hold them to a minimum
+interval between runs, since we can't keep up with just any schedule.  A few
+upcoming runs are enough to tell for the schedules we support.
+*/
+
+func validateScheduleAt(schedule string, granularity ScheduleGranularity, fldPath *field.Path) *field.Error {
+	sched, err := granularity.Parse(schedule)
+	if err != nil {
+		return field.Invalid(fldPath, schedule, err.Error())
+	}
+	if granularity != SecondGranularity {
+		return nil
+	}
+	upcoming := upcomingRuns(sched, time.Now(), previewRuns)
+	for i := 1; i < len(upcoming); i++ {
+		if interval := upcoming[i].Sub(upcoming[i-1]); interval < MinScheduleInterval {
+			return field.Invalid(fldPath, schedule, fmt.Sprintf("runs every %s, more often than every %s", interval, MinScheduleInterval))
+		}
+	}
+	return nil
+}
+
+/*
+Time zones have to be in the tz database the controller uses.  Unlike native
+CronJobs, we'd rather reject a typo than quietly fall back to UTC.
+*/
+
+func validateTimeZone(timeZone string, fldPath *field.Path) *field.Error {
+	if _, err := schedulepkg.LoadLocation(timeZone); err != nil {
+		return field.Invalid(fldPath, timeZone, err.Error())
+	}
+	return nil
+}
+
+/*
+The schema already bounds the history limits, but only on servers that enforce
+it, so we check them here too.  Failed jobs keep their pods around for their
+logs, so we don't let those pile up past a hundred.
+*/
+
+// maxFailedJobsHistoryLimit mirrors the schema's maximum for
+// .spec.failedJobsHistoryLimit.
+const maxFailedJobsHistoryLimit = 100
+
+func validateHistoryLimits(spec *CronJobSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if limit := spec.SuccessfulJobsHistoryLimit; limit != nil && *limit < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("successfulJobsHistoryLimit"), *limit, "must be non-negative"))
+	}
+	if limit := spec.FailedJobsHistoryLimit; limit != nil && *limit < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("failedJobsHistoryLimit"), *limit, "must be non-negative"))
+	} else if limit != nil && *limit > maxFailedJobsHistoryLimit {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("failedJobsHistoryLimit"), *limit, fmt.Sprintf("must be no more than %d", maxFailedJobsHistoryLimit)))
+	}
+	return allErrs
+}
+
+/*
+The job template gets turned into a job every time we run, so a broken one
+would otherwise only show up as failures to create jobs, over and over.  We
+can't pull in the full validation the API server does for jobs and pods, but
+we can catch the usual mistakes, with the help of the apimachinery validation
+for the metadata.
+*/
+
+func validateJobTemplate(template *batchv1beta1.JobTemplateSpec, fldPath *field.Path) field.ErrorList {
+	if equality.Semantic.DeepEqual(*template, batchv1beta1.JobTemplateSpec{}) {
+		return field.ErrorList{field.Required(fldPath, "a job template is required")}
+	}
+
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, metav1validation.ValidateLabels(template.Labels, fldPath.Child("metadata", "labels"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(template.Annotations, fldPath.Child("metadata", "annotations"))...)
+
+	specPath := fldPath.Child("spec")
+	for name, value := range map[string]*int32{
+		"parallelism":  template.Spec.Parallelism,
+		"completions":  template.Spec.Completions,
+		"backoffLimit": template.Spec.BackoffLimit,
+	} {
+		if value != nil && *value < 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child(name), *value, "must be non-negative"))
+		}
+	}
+	if ads := template.Spec.ActiveDeadlineSeconds; ads != nil && *ads <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("activeDeadlineSeconds"), *ads, "must be positive"))
+	}
+	allErrs = append(allErrs, validatePodTemplate(&template.Spec.Template, specPath.Child("template"))...)
+	return allErrs
+}
+
+/*
+A CronJob that refers to a shared JobTemplate can still label and annotate its
+jobs through its own template, but the job spec has to come from one place or
+the other.  Whether the JobTemplate exists is up to the controller: it may well
+be created after the CronJob.
+*/
+
+func validateJobTemplateRef(ref *corev1.LocalObjectReference, template *batchv1beta1.JobTemplateSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	refPath := fldPath.Child("jobTemplateRef", "name")
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(refPath, "must name a JobTemplate"))
+	} else {
+		for _, msg := range validationutils.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(refPath, ref.Name, msg))
+		}
+	}
+
+	templatePath := fldPath.Child("jobTemplate")
+	if !equality.Semantic.DeepEqual(template.Spec, batchv1beta1.JobTemplateSpec{}.Spec) {
+		allErrs = append(allErrs, field.Forbidden(templatePath.Child("spec"), "may not be set along with jobTemplateRef"))
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabels(template.Labels, templatePath.Child("metadata", "labels"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(template.Annotations, templatePath.Child("metadata", "annotations"))...)
+	return allErrs
+}
+
+func validatePodTemplate(template *corev1.PodTemplateSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, metav1validation.ValidateLabels(template.Labels, fldPath.Child("metadata", "labels"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(template.Annotations, fldPath.Child("metadata", "annotations"))...)
+
+	specPath := fldPath.Child("spec")
+	switch template.Spec.RestartPolicy {
+	case corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure:
+	case "":
+		allErrs = append(allErrs, field.Required(specPath.Child("restartPolicy"), "must be Never or OnFailure for jobs"))
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("restartPolicy"), template.Spec.RestartPolicy,
+			[]string{string(corev1.RestartPolicyNever), string(corev1.RestartPolicyOnFailure)}))
+	}
+
+	if len(template.Spec.Containers) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("containers"), "at least one container is required"))
+	}
+	names := make(map[string]bool)
+	validateContainers := func(containers []corev1.Container, containersPath *field.Path) {
+		for i, container := range containers {
+			idxPath := containersPath.Index(i)
+			switch {
+			case container.Name == "":
+				allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+			case names[container.Name]:
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), container.Name))
+			default:
+				for _, msg := range validationutils.IsDNS1123Label(container.Name) {
+					allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), container.Name, msg))
+				}
+			}
+			names[container.Name] = true
+			if container.Image == "" {
+				allErrs = append(allErrs, field.Required(idxPath.Child("image"), ""))
+			}
+			allErrs = append(allErrs, validateResources(&container.Resources, idxPath.Child("resources"))...)
+		}
+	}
+	validateContainers(template.Spec.InitContainers, specPath.Child("initContainers"))
+	validateContainers(template.Spec.Containers, specPath.Child("containers"))
+	return allErrs
+}
+
+/*
+Resource quantities can't be negative, and a container can't ask for more
+than it's limited to.
+*/
+
+func validateResources(resources *corev1.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for name, quantity := range resources.Limits {
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limits").Key(string(name)), quantity.String(), "must be non-negative"))
+		}
+	}
+	for name, quantity := range resources.Requests {
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests").Key(string(name)), quantity.String(), "must be non-negative"))
+			continue
+		}
+		if limit, ok := resources.Limits[name]; ok && quantity.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests").Key(string(name)), quantity.String(), fmt.Sprintf("must be less than or equal to %s limit of %s", name, limit.String())))
+		}
+	}
+	return allErrs
+}
+
+/*
+Blackout windows are bounded either by a pair of timestamps or by a pair of
+cron schedules -- mixing the two doesn't mean anything sensible.
+*/
+
+func validateBlackoutWindows(windows []BlackoutWindow, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, window := range windows {
+		idxPath := fldPath.Index(i)
+		start, startErr := time.Parse(time.RFC3339, window.Start)
+		end, endErr := time.Parse(time.RFC3339, window.End)
+		switch {
+		case startErr == nil && endErr == nil:
+			if !end.After(start) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("end"), window.End, "must be after start"))
+			}
+		case startErr == nil || endErr == nil:
+			allErrs = append(allErrs, field.Invalid(idxPath, window, "start and end must both be RFC 3339 timestamps or both be cron schedules"))
+		default:
+			if err := validateScheduleFormat(window.Start, idxPath.Child("start")); err != nil {
+				allErrs = append(allErrs, err)
+			}
+			if err := validateScheduleFormat(window.End, idxPath.Child("end")); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+	return allErrs
+}
+
+/*
+Notifications are sent from the controller, long after the CronJob was accepted,
+so a URL or template that can't work is better caught now.
+*/
+
+func validateNotifications(notifications []Notification, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, notification := range notifications {
+		idxPath := fldPath.Index(i)
+		if u, err := url.Parse(notification.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("url"), notification.URL, "must be an absolute http or https URL"))
+		}
+		if len(notification.Events) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("events"), "at least one event is required"))
+		}
+		if notification.Template != "" {
+			if _, err := notify.ParseTemplate(notification.Template); err != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("template"), notification.Template, err.Error()))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateSecretInjection checks that the injected secrets make a valid
+// Secret, mounted somewhere a container can have it.
+func validateSecretInjection(injection *SecretInjection, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if injection.Provider == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("provider"), "must name a secret store"))
+	}
+	if len(injection.Secrets) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("secrets"), "at least one secret is required"))
+	}
+	keys := map[string]bool{}
+	for i, secret := range injection.Secrets {
+		idxPath := fldPath.Child("secrets").Index(i)
+		for _, msg := range validationutils.IsConfigMapKey(secret.Key) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("key"), secret.Key, msg))
+		}
+		if keys[secret.Key] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("key"), secret.Key))
+		}
+		keys[secret.Key] = true
+		if secret.Path == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("path"), ""))
+		}
+	}
+	if injection.MountPath != "" && !path.IsAbs(injection.MountPath) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mountPath"), injection.MountPath, "must be an absolute path"))
+	}
+	return allErrs
+}
+
+// validateEventTriggers checks that each trigger has a unique name that fits
+// in the names of its CronJobTriggers, and exactly one source we can connect
+// to.
+func validateEventTriggers(triggers []EventTrigger, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, trigger := range triggers {
+		idxPath := fldPath.Index(i)
+		for _, msg := range validationutils.IsDNS1123Label(trigger.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), trigger.Name, msg))
+		}
+		if names[trigger.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), trigger.Name))
+		}
+		names[trigger.Name] = true
+
+		sources := 0
+		if source := trigger.NATS; source != nil {
+			sources++
+			natsPath := idxPath.Child("nats")
+			if u, err := url.Parse(source.URL); err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(natsPath.Child("url"), source.URL, "must be a nats:// or tls:// URL"))
+			} else if u.User != nil {
+				allErrs = append(allErrs, field.Invalid(natsPath.Child("url"), source.URL, "credentials aren't supported"))
+			}
+			if source.Subject == "" || strings.ContainsAny(source.Subject, " \t\r\n") {
+				allErrs = append(allErrs, field.Invalid(natsPath.Child("subject"), source.Subject, "must be a non-empty subject without whitespace"))
+			}
+			if strings.ContainsAny(source.Queue, " \t\r\n") {
+				allErrs = append(allErrs, field.Invalid(natsPath.Child("queue"), source.Queue, "must not contain whitespace"))
+			}
+		}
+		if source := trigger.Kafka; source != nil {
+			sources++
+			kafkaPath := idxPath.Child("kafka")
+			if u, err := url.Parse(source.RESTProxyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(kafkaPath.Child("restProxyURL"), source.RESTProxyURL, "must be an absolute http or https URL"))
+			}
+			if source.Topic == "" {
+				allErrs = append(allErrs, field.Required(kafkaPath.Child("topic"), ""))
+			}
+		}
+		if source := trigger.SQS; source != nil {
+			sources++
+			sqsPath := idxPath.Child("sqs")
+			if u, err := url.Parse(source.QueueURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(sqsPath.Child("queueURL"), source.QueueURL, "must be an absolute https URL"))
+			} else if _, err := eventsource.SQSRegion(source.QueueURL); err != nil && source.Region == "" {
+				allErrs = append(allErrs, field.Required(sqsPath.Child("region"), "the queue's URL doesn't say"))
+			}
+		}
+		if source := trigger.CloudEvents; source != nil {
+			sources++
+			for j, t := range source.Types {
+				if t == "" {
+					allErrs = append(allErrs, field.Invalid(idxPath.Child("cloudEvents", "types").Index(j), t, "must not be empty"))
+				}
+			}
+		}
+		if sources != 1 {
+			allErrs = append(allErrs, field.Invalid(idxPath, trigger.Name, "must have exactly one of nats, kafka, sqs or cloudEvents"))
+		}
+	}
+	return allErrs
+}
+
+// validateConditionGate checks what it can of the gate without asking
+// Prometheus: the query itself only fails once it's evaluated.
+func validateConditionGate(gate *ConditionGate, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if strings.TrimSpace(gate.Query) == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("query"), ""))
+	}
+	if gate.Threshold != "" {
+		if _, err := gate.ThresholdValue(); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("threshold"), gate.Threshold, "must be a decimal number"))
+		}
+	}
+	return allErrs
+}
+
+// validateCalendarRef checks that the calendar says where to read it from,
+// and that it isn't given a schedule to go with it.
+func validateCalendarRef(spec *CronJobSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	ref := spec.CalendarRef
+	refPath := fldPath.Child("calendarRef")
+	if spec.Schedule != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("schedule"), "runs come from calendarRef instead"))
+	}
+	switch {
+	case ref.URL == "" && ref.SecretKeyRef == nil:
+		allErrs = append(allErrs, field.Required(refPath, "must have one of url or secretKeyRef"))
+	case ref.URL != "" && ref.SecretKeyRef != nil:
+		allErrs = append(allErrs, field.Forbidden(refPath.Child("secretKeyRef"), "may not be set along with url"))
+	}
+	if ref.URL != "" {
+		if u, err := url.Parse(ref.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(refPath.Child("url"), ref.URL, "must be an absolute http or https URL"))
+		}
+	}
+	if secretRef := ref.SecretKeyRef; secretRef != nil {
+		if secretRef.Name == "" {
+			allErrs = append(allErrs, field.Required(refPath.Child("secretKeyRef", "name"), "must name a Secret"))
+		}
+		if secretRef.Key == "" {
+			allErrs = append(allErrs, field.Required(refPath.Child("secretKeyRef", "key"), ""))
+		}
+	}
+	return allErrs
+}
+
+// validateWorkloadRef checks that the workload names what it runs if, and
+// only if, it doesn't run the job template's pods, and that nothing else in
+// the spec relies on those pods, or on runs creating Jobs, when it doesn't.
+func validateWorkloadRef(spec *CronJobSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	workload := spec.WorkloadRef
+	refPath := fldPath.Child("workloadRef")
+	if spec.QueueName != "" && workload.Backend != "" && workload.Backend != JobBackend {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("queueName"), fmt.Sprintf("only Jobs can be queued, not %s workloads", workload.Backend)))
+	}
+	if workload.RunsPodTemplate() {
+		if workload.Name != "" {
+			allErrs = append(allErrs, field.Forbidden(refPath.Child("name"), fmt.Sprintf("not used by the %s backend", workload.Backend)))
+		}
+		if len(workload.Parameters) > 0 {
+			allErrs = append(allErrs, field.Forbidden(refPath.Child("parameters"), fmt.Sprintf("not used by the %s backend", workload.Backend)))
+		}
+		return allErrs
+	}
+
+	if workload.Name == "" {
+		allErrs = append(allErrs, field.Required(refPath.Child("name"), fmt.Sprintf("the %s backend needs something to run", workload.Backend)))
+	} else {
+		for _, msg := range validationutils.IsDNS1123Subdomain(workload.Name) {
+			allErrs = append(allErrs, field.Invalid(refPath.Child("name"), workload.Name, msg))
+		}
+	}
+	for name := range workload.Parameters {
+		if name == "" {
+			allErrs = append(allErrs, field.Invalid(refPath.Child("parameters"), name, "parameter names must not be empty"))
+		}
+	}
+	if spec.SecretInjection != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("secretInjection"), fmt.Sprintf("the %s backend doesn't run the job template's pods", workload.Backend)))
+	}
+	if spec.NetworkProfile != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("networkProfile"), fmt.Sprintf("the %s backend doesn't run the job template's pods", workload.Backend)))
+	}
+	if spec.MeshSidecar == QuitMeshSidecar {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("meshSidecar"), fmt.Sprintf("the %s backend doesn't run the job template's pods", workload.Backend)))
+	}
+	return allErrs
+}
+
+// validateMeshSidecarQuit checks that the pods whose mesh sidecar is always
+// told to quit don't restart their containers in place: while one's waiting to
+// restart, nothing else is running, and the sidecar would be told to quit too
+// soon.
+func validateMeshSidecarQuit(spec *CronJobSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	check := func(template *corev1.PodTemplateSpec, templatePath *field.Path) {
+		if template.Spec.RestartPolicy == corev1.RestartPolicyOnFailure {
+			allErrs = append(allErrs, field.Forbidden(templatePath.Child("spec", "restartPolicy"), "must be Never when meshSidecar is Quit"))
+		}
+	}
+	if spec.JobTemplateRef == nil {
+		check(&spec.JobTemplate.Spec.Template, fldPath.Child("jobTemplate", "spec", "template"))
+	}
+	if spec.Hooks != nil {
+		if spec.Hooks.PreRun != nil {
+			check(spec.Hooks.PreRun, fldPath.Child("hooks", "preRun"))
+		}
+		if spec.Hooks.PostRun != nil {
+			check(spec.Hooks.PostRun, fldPath.Child("hooks", "postRun"))
+		}
+	}
+	return allErrs
+}
+
+// validateNetworkProfile checks the parts of the egress rules the API server
+// would otherwise only reject once a job's NetworkPolicy is created.
+func validateNetworkProfile(profile *NetworkProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, rule := range profile.Egress {
+		rulePath := fldPath.Child("egress").Index(i)
+		for j, port := range rule.Ports {
+			portPath := rulePath.Child("ports").Index(j)
+			if port.Protocol != nil && *port.Protocol != corev1.ProtocolTCP && *port.Protocol != corev1.ProtocolUDP && *port.Protocol != corev1.ProtocolSCTP {
+				allErrs = append(allErrs, field.NotSupported(portPath.Child("protocol"), *port.Protocol,
+					[]string{string(corev1.ProtocolTCP), string(corev1.ProtocolUDP), string(corev1.ProtocolSCTP)}))
+			}
+			if port.Port == nil {
+				continue
+			}
+			if port.Port.Type == intstr.Int {
+				for _, msg := range validationutils.IsValidPortNum(port.Port.IntValue()) {
+					allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port.IntValue(), msg))
+				}
+			} else {
+				for _, msg := range validationutils.IsValidPortName(port.Port.String()) {
+					allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port.String(), msg))
+				}
+			}
+		}
+		for j, peer := range rule.To {
+			peerPath := rulePath.Child("to").Index(j)
+			if peer.IPBlock == nil {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(peer.IPBlock.CIDR); err != nil {
+				allErrs = append(allErrs, field.Invalid(peerPath.Child("ipBlock", "cidr"), peer.IPBlock.CIDR, "must be a valid CIDR"))
+			}
+			for k, except := range peer.IPBlock.Except {
+				if _, _, err := net.ParseCIDR(except); err != nil {
+					allErrs = append(allErrs, field.Invalid(peerPath.Child("ipBlock", "except").Index(k), except, "must be a valid CIDR"))
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+// JobNameSuffixLength is the length of the longest suffix the controller adds
+// to the name of a run's job: retries get a `-r$INDEX` suffix, and hooks a
+// `-pre` or `-post` one.
+func (r *CronJob) JobNameSuffixLength() int {
+	suffixLength := 0
+	if r.Spec.RetryPolicy != nil && r.Spec.RetryPolicy.MaxRetries > 0 {
+		suffixLength = len(fmt.Sprintf("-r%d", r.Spec.RetryPolicy.MaxRetries))
+	}
+	if r.Spec.Hooks != nil && suffixLength < len("-post") {
+		suffixLength = len("-post")
+	}
+	return suffixLength
+}
+
 /*
 Validating the length of a string field can be done declaratively by
 the validation schema.
@@ -197,14 +1001,12 @@ the validation schema.
 */
 
 func (r *CronJob) validateCronJobName() *field.Error {
-	if len(r.ObjectMeta.Name) > validationutils.DNS1035LabelMaxLength-11 {
-		// The job name length is 63 character like all Kubernetes objects
-		// (which must fit in a DNS subdomain). The cronjob controller appends
-		// a 11-character suffix to the cronjob (`-$TIMESTAMP`) when creating
-		// a job. The job name length limit is 63 characters. Therefore cronjob
-		// names must have length <= 63-11=52. If we don't validate this here,
-		// then job creation will fail later.
-		return field.Invalid(field.NewPath("metadata").Child("name"), r.Name, "must be no more than 52 characters")
+	// The controller shortens the CronJob's name as much as it needs to in
+	// the names of its jobs, but the name also goes in a label on them, and
+	// label values are limited to 63 characters, like DNS labels.
+	maxLength := validationutils.DNS1035LabelMaxLength
+	if len(r.ObjectMeta.Name) > maxLength {
+		return field.Invalid(field.NewPath("metadata").Child("name"), r.Name, fmt.Sprintf("must be no more than %d characters", maxLength))
 	}
 	return nil
 }
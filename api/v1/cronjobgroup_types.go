@@ -0,0 +1,73 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CronJobGroupSpec defines the desired state of CronJobGroup
+type CronJobGroupSpec struct {
+	// Selects the CronJobs in the group's namespace that belong to it.  An
+	// empty selector selects every CronJob in the namespace.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Suspends every member of the group, on top of their own suspend flags.
+	// Runs that come due meanwhile are skipped, as for a suspended CronJob.
+	// Defaults to false.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// The priority class to give the pods of the members' jobs, unless their
+	// job templates ask for one of their own.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// The names of members whose runs go strictly in this order: each run of
+	// a member in the list waits for the run of the member before it,
+	// scheduled at or before the same time, to succeed, just as if it
+	// depended on it.
+	// +optional
+	Sequence []string `json:"sequence,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Priority Class",type=string,JSONPath=`.spec.priorityClassName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CronJobGroup gathers CronJobs by label, so that a whole subsystem's batch
+// work can be suspended with one switch, prioritized, and sequenced.
+type CronJobGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CronJobGroupSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CronJobGroupList contains a list of CronJobGroup
+type CronJobGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronJobGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CronJobGroup{}, &CronJobGroupList{})
+}
@@ -0,0 +1,80 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *CronJobGroup) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-cronjobgroup,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=cronjobgroups,versions=v1,name=vcronjobgroup.kb.io
+
+var _ webhook.Validator = &CronJobGroup{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobGroup) ValidateCreate() error {
+	return r.validateCronJobGroup()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobGroup) ValidateUpdate(old runtime.Object) error {
+	return r.validateCronJobGroup()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobGroup) ValidateDelete() error {
+	return nil
+}
+
+/*
+A member listed twice in the sequence would have to wait for itself, so we
+don't allow that.
+*/
+
+func (r *CronJobGroup) validateCronJobGroup() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if _, err := metav1.LabelSelectorAsSelector(&r.Spec.Selector); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("selector"), r.Spec.Selector, err.Error()))
+	}
+	seen := make(map[string]bool)
+	for i, name := range r.Spec.Sequence {
+		if seen[name] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("sequence").Index(i), name))
+		}
+		seen[name] = true
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "CronJobGroup"},
+		r.Name, allErrs)
+}
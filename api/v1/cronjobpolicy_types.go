@@ -0,0 +1,170 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CronJobPolicySpec defines the defaults and constraints a CronJobPolicy
+// imposes on the CronJobs in its namespace.
+type CronJobPolicySpec struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// The number of successful finished jobs to retain, for CronJobs that
+	// don't say.
+	// +optional
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+
+	// The number of failed finished jobs to retain, for CronJobs that don't
+	// say.
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+
+	// The shortest interval allowed between two runs of a CronJob.  Runs
+	// from a calendar that come sooner than this after the one before are
+	// left out.
+	// +optional
+	MinScheduleInterval *metav1.Duration `json:"minScheduleInterval,omitempty"`
+
+	// Periods during which no CronJob starts new runs, on top of each
+	// CronJob's own blackout windows.
+	// +optional
+	ForbiddenWindows []BlackoutWindow `json:"forbiddenWindows,omitempty"`
+
+	// Labels that every CronJob must carry.
+	// +optional
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+
+	// Keys that the images of the CronJobs' jobs must be signed with.  Jobs
+	// whose images aren't signed with one of them are never created.
+	// +optional
+	ImageSignatures *ImageSignaturePolicy `json:"imageSignatures,omitempty"`
+
+	// An endpoint that may veto each run of the CronJobs, just before its job
+	// is created.
+	// +optional
+	RunAdmission *RunAdmissionHook `json:"runAdmission,omitempty"`
+}
+
+// RunAdmissionFailurePolicy says what happens to a run when its policy
+// endpoint can't be reached, or gives no clear answer.
+// +kubebuilder:validation:Enum=Fail;Ignore
+type RunAdmissionFailurePolicy string
+
+const (
+	// RunAdmissionFail holds the run back, as if it had been vetoed.
+	RunAdmissionFail RunAdmissionFailurePolicy = "Fail"
+
+	// RunAdmissionIgnore lets the run go ahead.
+	RunAdmissionIgnore RunAdmissionFailurePolicy = "Ignore"
+)
+
+// RunAdmissionHook is an HTTP endpoint that decides whether runs may go
+// ahead.  It's sent a POST with the run's context as JSON: the CronJob's
+// namespace, name and labels, the job, the scheduled time, how many runs have
+// failed in a row, and how many jobs are running.  It answers with
+// {"allowed": true} to let the run go ahead, or {"allowed": false, "reason":
+// "..."} to veto it.
+type RunAdmissionHook struct {
+	// The http or https URL to POST to.
+	URL string `json:"url"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+
+	// How long to wait for an answer.  Defaults to 5 seconds.
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// What to do when the endpoint can't be reached or gives no clear
+	// answer.  Defaults to Fail.
+	// +optional
+	FailurePolicy RunAdmissionFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// ImageSignaturePolicy requires images to carry cosign signatures by trusted
+// keys.
+type ImageSignaturePolicy struct {
+	// The images the policy covers, as written in pod templates.  A pattern
+	// ending in * covers every image starting with the rest of it.  All
+	// images, if empty.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+
+	// PEM-encoded public keys, as written by `cosign generate-key-pair`.
+	// Images must be signed by at least one of them.
+	PublicKeys []string `json:"publicKeys"`
+}
+
+// Covers reports whether the policy covers the image.
+func (p *ImageSignaturePolicy) Covers(image string) bool {
+	if len(p.Images) == 0 {
+		return true
+	}
+	for _, pattern := range p.Images {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(image, prefix) {
+				return true
+			}
+		} else if image == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// PolicyCompliantCondition is true when the CronJob meets the constraints
+	// of every CronJobPolicy in its namespace.  Runs are skipped while it
+	// doesn't.
+	PolicyCompliantCondition = "PolicyCompliant"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Min Interval",type=string,JSONPath=`.spec.minScheduleInterval`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CronJobPolicy sets defaults for, and constrains, the CronJobs in its
+// namespace.  When a namespace has more than one, the constraints of all of
+// them apply, and defaults come from the first by name that sets them.
+type CronJobPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CronJobPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CronJobPolicyList contains a list of CronJobPolicy
+type CronJobPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronJobPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CronJobPolicy{}, &CronJobPolicyList{})
+}
@@ -0,0 +1,182 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"kubebuilder-tutorial/pkg/imagesig"
+)
+
+/*
+CronJobPolicies let whoever runs a namespace put guardrails on its CronJobs
+without touching the controller.  The CronJob webhooks fill in a policy's
+defaults and reject CronJobs that break its rules; the controller skips the
+runs of any CronJob that got in before the policy did, and keeps new runs out
+of the policy's forbidden windows.
+
+The CronJob webhooks need to look policies up, so they get a reader when
+they're set up.
+*/
+
+// policyReader is how the CronJob webhooks find CronJobPolicies.  Policies
+// aren't enforced at admission if it's unset.
+var policyReader client.Reader
+
+func (r *CronJobPolicy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// PoliciesFor lists the CronJobPolicies that apply in the given namespace,
+// in order of name.
+func PoliciesFor(ctx context.Context, reader client.Reader, namespace string) ([]CronJobPolicy, error) {
+	var policies CronJobPolicyList
+	if err := reader.List(ctx, &policies, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	// lists normally come back in order already, but caches don't promise it
+	sort.Slice(policies.Items, func(i, j int) bool {
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+	return policies.Items, nil
+}
+
+// ApplyDefaults fills in the policy's defaults on the CronJob, wherever it
+// doesn't set its own.
+func (p *CronJobPolicy) ApplyDefaults(cronJob *CronJob) {
+	if cronJob.Spec.SuccessfulJobsHistoryLimit == nil && p.Spec.SuccessfulJobsHistoryLimit != nil {
+		limit := *p.Spec.SuccessfulJobsHistoryLimit
+		cronJob.Spec.SuccessfulJobsHistoryLimit = &limit
+	}
+	if cronJob.Spec.FailedJobsHistoryLimit == nil && p.Spec.FailedJobsHistoryLimit != nil {
+		limit := *p.Spec.FailedJobsHistoryLimit
+		cronJob.Spec.FailedJobsHistoryLimit = &limit
+	}
+}
+
+// Violations lists the ways in which the CronJob breaks the policy's rules,
+// as of the given time.  Forbidden windows aren't among them: they restrict
+// when runs start, not what CronJobs look like.
+func (p *CronJobPolicy) Violations(cronJob *CronJob, now time.Time) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, label := range p.Spec.RequiredLabels {
+		if _, ok := cronJob.Labels[label]; !ok {
+			allErrs = append(allErrs, field.Required(
+				field.NewPath("metadata", "labels").Key(label),
+				fmt.Sprintf("required by CronJobPolicy %s", p.Name)))
+		}
+	}
+
+	// a calendar's runs aren't known until the controller reads it, so it's
+	// the controller that leaves out those that come too soon
+	if p.Spec.MinScheduleInterval != nil && cronJob.Spec.CalendarRef == nil {
+		if sched, err := cronJob.parsedSchedule(); err == nil {
+			// irregular schedules can run closer together than their first
+			// two runs suggest, so look a little further ahead
+			upcoming := upcomingRuns(sched, now, previewRuns)
+			for i := 1; i < len(upcoming); i++ {
+				if interval := upcoming[i].Sub(upcoming[i-1]); interval < p.Spec.MinScheduleInterval.Duration {
+					allErrs = append(allErrs, field.Invalid(
+						field.NewPath("spec", "schedule"),
+						cronJob.Spec.Schedule,
+						fmt.Sprintf("runs %s apart, but CronJobPolicy %s requires at least %s between runs", interval, p.Name, p.Spec.MinScheduleInterval.Duration)))
+					break
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-cronjobpolicy,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=cronjobpolicies,versions=v1,name=vcronjobpolicy.kb.io
+
+var _ webhook.Validator = &CronJobPolicy{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobPolicy) ValidateCreate() error {
+	return r.validateCronJobPolicy()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobPolicy) ValidateUpdate(old runtime.Object) error {
+	return r.validateCronJobPolicy()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CronJobPolicy) ValidateDelete() error {
+	return nil
+}
+
+/*
+A broken forbidden window would hold up every CronJob in the namespace, so
+policies get the same checks as the CronJob fields they mirror.
+*/
+
+func (r *CronJobPolicy) validateCronJobPolicy() error {
+	fldPath := field.NewPath("spec")
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateHistoryLimits(&CronJobSpec{
+		SuccessfulJobsHistoryLimit: r.Spec.SuccessfulJobsHistoryLimit,
+		FailedJobsHistoryLimit:     r.Spec.FailedJobsHistoryLimit,
+	}, fldPath)...)
+	if interval := r.Spec.MinScheduleInterval; interval != nil && interval.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("minScheduleInterval"), interval.Duration.String(), "must be non-negative"))
+	}
+	allErrs = append(allErrs, validateBlackoutWindows(r.Spec.ForbiddenWindows, fldPath.Child("forbiddenWindows"))...)
+	for i, label := range r.Spec.RequiredLabels {
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(label, fldPath.Child("requiredLabels").Index(i))...)
+	}
+	if signatures := r.Spec.ImageSignatures; signatures != nil {
+		keysPath := fldPath.Child("imageSignatures", "publicKeys")
+		if len(signatures.PublicKeys) == 0 {
+			allErrs = append(allErrs, field.Required(keysPath, "at least one key is needed to check signatures against"))
+		}
+		for i, key := range signatures.PublicKeys {
+			if _, err := imagesig.ParsePublicKey(key); err != nil {
+				allErrs = append(allErrs, field.Invalid(keysPath.Index(i), "<public key>", err.Error()))
+			}
+		}
+	}
+	if hook := r.Spec.RunAdmission; hook != nil {
+		urlPath := fldPath.Child("runAdmission", "url")
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(urlPath, hook.URL, "must be an absolute http or https URL"))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "CronJobPolicy"},
+		r.Name, allErrs)
+}
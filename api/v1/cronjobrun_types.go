@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CronJobRunPhase describes where a run is in its lifecycle.
+type CronJobRunPhase string
+
+const (
+	// CronJobRunPending means the run is due, but none of its jobs have
+	// started yet.
+	CronJobRunPending CronJobRunPhase = "Pending"
+
+	// CronJobRunRunning means some of the run's jobs are still going, or it's
+	// due a retry or its next hook.
+	CronJobRunRunning CronJobRunPhase = "Running"
+
+	// CronJobRunSucceeded means the run's final step completed successfully.
+	CronJobRunSucceeded CronJobRunPhase = "Succeeded"
+
+	// CronJobRunFailed means one of the run's steps failed, after any retries.
+	CronJobRunFailed CronJobRunPhase = "Failed"
+
+	// CronJobRunSkipped means the controller deliberately didn't start the run.
+	CronJobRunSkipped CronJobRunPhase = "Skipped"
+)
+
+// CronJobRunSpec identifies the run of a CronJob.
+type CronJobRunSpec struct {
+	// The name of the CronJob the run belongs to.
+	CronJobName string `json:"cronJobName"`
+
+	// The time at which the run was scheduled.
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+
+	// The name of the CronJobTrigger that fired the run, if one did.
+	// +optional
+	Trigger string `json:"trigger,omitempty"`
+
+	// Who triggered the run by hand, if anyone did.
+	// +optional
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+}
+
+// CronJobRunStatus defines the observed state of CronJobRun
+type CronJobRunStatus struct {
+	// Where the run is in its lifecycle.
+	// +optional
+	Phase CronJobRunPhase `json:"phase,omitempty"`
+
+	// When the run's first job started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the run's last job finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// The names of the jobs created for the run, in the order they were
+	// created.
+	// +optional
+	Jobs []string `json:"jobs,omitempty"`
+
+	// Why the run was skipped, if it was.
+	// +optional
+	SkipReason SkipReason `json:"skipReason,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="CronJob",type=string,JSONPath=`.spec.cronJobName`
+//+kubebuilder:printcolumn:name="Scheduled",type=date,JSONPath=`.spec.scheduledTime`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CronJobRun records a single scheduled run of a CronJob.  It owns the run's
+// jobs, and outlives them once they've been cleaned up.
+type CronJobRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CronJobRunSpec   `json:"spec,omitempty"`
+	Status CronJobRunStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CronJobRunList contains a list of CronJobRun
+type CronJobRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronJobRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CronJobRun{}, &CronJobRunList{})
+}
@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CronJobTriggerPhase describes where a trigger is in its lifecycle.
+type CronJobTriggerPhase string
+
+const (
+	// CronJobTriggerPending means the triggered run hasn't started yet, say
+	// because the CronJob's concurrency policy has it waiting.
+	CronJobTriggerPending CronJobTriggerPhase = "Pending"
+
+	// CronJobTriggerStarted means the triggered run's job has been created.
+	CronJobTriggerStarted CronJobTriggerPhase = "Started"
+
+	// CronJobTriggerSkipped means the triggered run was skipped rather than
+	// started, say because its images couldn't be verified.
+	CronJobTriggerSkipped CronJobTriggerPhase = "Skipped"
+)
+
+// CronJobTriggerSpec defines the desired state of CronJobTrigger
+type CronJobTriggerSpec struct {
+	// The name of the CronJob, in the same namespace, to run.
+	CronJobName string `json:"cronJobName"`
+
+	// The event that fired the trigger, for triggers the controller creates
+	// for the CronJob's event triggers.
+	// +optional
+	Event *TriggerEvent `json:"event,omitempty"`
+}
+
+// TriggerEvent identifies an event that fired a CronJobTrigger.
+type TriggerEvent struct {
+	// The name of the CronJob's event trigger the event came through.
+	Trigger string `json:"trigger"`
+
+	// The event's ID, for sources that give their events IDs.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// The event's type, for sources that give their events types.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Where the event came from: a subject, topic or queue, or a CloudEvent's
+	// own source.
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
+// CronJobTriggerStatus defines the observed state of CronJobTrigger
+type CronJobTriggerStatus struct {
+	// Whether the triggered run has started yet.
+	// +optional
+	Phase CronJobTriggerPhase `json:"phase,omitempty"`
+
+	// The name of the job started for the triggered run.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// When the triggered run's job was created, or the run skipped.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="CronJob",type=string,JSONPath=`.spec.cronJobName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Job",type=string,JSONPath=`.status.jobName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CronJobTrigger asks for a run of a CronJob outside of its schedule, at the
+// time the trigger was created.  Being a resource of its own, permission to
+// fire runs can be granted without permission to change the CronJob.  Each
+// trigger fires once, and is deleted a day after it does.
+type CronJobTrigger struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CronJobTriggerSpec   `json:"spec,omitempty"`
+	Status CronJobTriggerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CronJobTriggerList contains a list of CronJobTrigger
+type CronJobTriggerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronJobTrigger `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CronJobTrigger{}, &CronJobTriggerList{})
+}
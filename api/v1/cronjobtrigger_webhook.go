@@ -0,0 +1,132 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+/*
+Runs triggered by hand are worth knowing the initiator of, when looking back
+on who ran what.  Whoever triggers a run, by creating a CronJobTrigger or by
+setting a CronJob's trigger-at annotation, gets recorded in a triggered-by
+annotation by the webhooks here, and the controller passes it on to the run's
+jobs and its history.
+
+Only the API server knows who's asking, so the annotation is ours alone to
+write: whatever a user sets it to themselves is overwritten, and it never
+changes except along with the trigger.
+*/
+
+//+kubebuilder:webhook:path=/mutate-batch-tutorial-kubebuilder-io-v1-cronjobtrigger,mutating=true,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=cronjobtriggers,verbs=create;update,versions=v1,name=mcronjobtrigger.kb.io
+//+kubebuilder:webhook:path=/initiator-batch-tutorial-kubebuilder-io-v1-cronjob,mutating=true,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=cronjobs,verbs=create;update,versions=v1,name=icronjob.kb.io
+
+const (
+	triggerWebhookPath   = "/mutate-batch-tutorial-kubebuilder-io-v1-cronjobtrigger"
+	initiatorWebhookPath = "/initiator-batch-tutorial-kubebuilder-io-v1-cronjob"
+)
+
+const (
+	// TriggerAtAnnotation requests a run of a CronJob outside of its schedule
+	// at the given RFC 3339 time.
+	TriggerAtAnnotation = "batch.tutorial.kubebuilder.io/trigger-at"
+
+	// TriggeredByAnnotation records who triggered a run by hand, on the
+	// CronJobTrigger or CronJob they triggered it with, and on the run's jobs.
+	TriggeredByAnnotation = "batch.tutorial.kubebuilder.io/triggered-by"
+)
+
+// SetupWebhookWithManager registers the webhook that records who created
+// each CronJobTrigger.
+func (r *CronJobTrigger) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(triggerWebhookPath, &webhook.Admission{Handler: &initiatorRecorder{}})
+	return nil
+}
+
+// initiatorRecorder is an admission handler that records who triggered a run
+// in the triggered-by annotation.  CronJobTriggers trigger a run just by being
+// created; CronJobs, whenever their trigger-at annotation changes.
+type initiatorRecorder struct{}
+
+// objectMeta is just enough of an object to get at its annotations.
+type objectMeta struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+}
+
+// Handle implements admission.Handler.
+func (h *initiatorRecorder) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var obj, old objectMeta
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Update {
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	// by default, whoever was recorded stays recorded
+	initiator := old.Metadata.Annotations[TriggeredByAnnotation]
+	switch req.Kind.Kind {
+	case "CronJobTrigger":
+		if req.Operation == admissionv1.Create {
+			initiator = req.UserInfo.Username
+		}
+	case "CronJob":
+		triggerAt := obj.Metadata.Annotations[TriggerAtAnnotation]
+		if triggerAt != "" && triggerAt != old.Metadata.Annotations[TriggerAtAnnotation] {
+			initiator = req.UserInfo.Username
+		}
+	}
+	if current, ok := obj.Metadata.Annotations[TriggeredByAnnotation]; current == initiator && (ok || initiator == "") {
+		return admission.Allowed("")
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(req.Object.Raw, &raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	metadata, _ := raw["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		raw["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	if initiator == "" {
+		delete(annotations, TriggeredByAnnotation)
+	} else {
+		annotations[TriggeredByAnnotation] = initiator
+	}
+	modified, err := json.Marshal(raw)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, modified)
+}
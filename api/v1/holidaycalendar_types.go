@@ -0,0 +1,72 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Holiday is a single day off.
+type Holiday struct {
+	// +kubebuilder:validation:Pattern=`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`
+
+	// The date of the holiday, as YYYY-MM-DD.
+	Date string `json:"date"`
+
+	// What the holiday is called.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// HolidayCalendarSpec defines the desired state of HolidayCalendar
+type HolidayCalendarSpec struct {
+	// The holidays in the calendar.
+	// +optional
+	Holidays []Holiday `json:"holidays,omitempty"`
+
+	// The contents of an iCalendar file to take more holidays from, like the
+	// ones governments and banks publish.  Every day an event covers is a
+	// holiday.  Recurring events only count for their first occurrence.
+	// +optional
+	ICal string `json:"ical,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HolidayCalendar lists days on which the CronJobs that refer to it, with
+// spec.holidayCalendarRef, shouldn't run as usual.  Holidays are dates in the
+// time zone of each CronJob's schedule.
+type HolidayCalendar struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HolidayCalendarSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HolidayCalendarList contains a list of HolidayCalendar
+type HolidayCalendarList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HolidayCalendar `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HolidayCalendar{}, &HolidayCalendarList{})
+}
@@ -0,0 +1,104 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"kubebuilder-tutorial/pkg/ical"
+)
+
+func (r *HolidayCalendar) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-holidaycalendar,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=holidaycalendars,versions=v1,name=vholidaycalendar.kb.io
+
+var _ webhook.Validator = &HolidayCalendar{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *HolidayCalendar) ValidateCreate() error {
+	return r.validateHolidayCalendar()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *HolidayCalendar) ValidateUpdate(old runtime.Object) error {
+	return r.validateHolidayCalendar()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *HolidayCalendar) ValidateDelete() error {
+	return nil
+}
+
+/*
+The pattern on each date only checks its shape, so we make sure it's a real
+date here.  The iCalendar data has to parse, too: a calendar we can't read
+would otherwise let runs go ahead on holidays without anyone noticing.
+*/
+
+func (r *HolidayCalendar) validateHolidayCalendar() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	for i, holiday := range r.Spec.Holidays {
+		if _, err := time.Parse(holidayDateFormat, holiday.Date); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("holidays").Index(i).Child("date"), holiday.Date, "must be a date, as YYYY-MM-DD"))
+		}
+	}
+	if _, err := ical.Parse(r.Spec.ICal); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("ical"), "", err.Error()))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "HolidayCalendar"},
+		r.Name, allErrs)
+}
+
+// holidayDateFormat is the layout of holiday dates.
+const holidayDateFormat = "2006-01-02"
+
+// Dates returns the set of days the calendar holds, from both its list of
+// holidays and its iCalendar data, as YYYY-MM-DD.
+func (r *HolidayCalendar) Dates() (map[string]bool, error) {
+	dates := make(map[string]bool)
+	for _, holiday := range r.Spec.Holidays {
+		dates[holiday.Date] = true
+	}
+	events, err := ical.Parse(r.Spec.ICal)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		for _, date := range event.Dates() {
+			dates[date] = true
+		}
+	}
+	return dates, nil
+}
@@ -0,0 +1,67 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *JobTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-jobtemplate,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=jobtemplates,versions=v1,name=vjobtemplate.kb.io
+
+var _ webhook.Validator = &JobTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *JobTemplate) ValidateCreate() error {
+	return r.validateJobTemplate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *JobTemplate) ValidateUpdate(old runtime.Object) error {
+	return r.validateJobTemplate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *JobTemplate) ValidateDelete() error {
+	return nil
+}
+
+/*
+A shared template gets the same checks as one inlined in a CronJob, since
+every CronJob that refers to it would otherwise fail the same way.
+*/
+
+func (r *JobTemplate) validateJobTemplate() error {
+	allErrs := validateJobTemplate(&r.Template, field.NewPath("template"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "JobTemplate"},
+		r.Name, allErrs)
+}
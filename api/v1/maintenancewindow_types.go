@@ -0,0 +1,88 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaintenanceAction describes what happens to runs that fall inside a
+// maintenance window.
+// +kubebuilder:validation:Enum=Defer;Skip
+type MaintenanceAction string
+
+const (
+	// DeferDuringMaintenance holds runs back until the window closes, as
+	// long as they can still start within their starting deadline.  This is
+	// the default.
+	DeferDuringMaintenance MaintenanceAction = "Defer"
+
+	// SkipDuringMaintenance skips runs outright, recording the skip.
+	SkipDuringMaintenance MaintenanceAction = "Skip"
+)
+
+// MaintenanceWindowSpec defines when a MaintenanceWindow is open, and which
+// CronJobs it holds back.
+type MaintenanceWindowSpec struct {
+	// +kubebuilder:validation:MinItems=1
+
+	// The periods the maintenance window covers, either one-off or recurring.
+	Windows []BlackoutWindow `json:"windows"`
+
+	// Selects the namespaces whose CronJobs the window covers.  Every
+	// namespace is covered if this is unset.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selects the CronJobs the window covers, by label.  Every CronJob in the
+	// covered namespaces is covered if this is unset.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// What happens to runs scheduled while the window is open.  Defaults to
+	// Defer.
+	// +optional
+	Action MaintenanceAction `json:"action,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Action",type=string,JSONPath=`.spec.action`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MaintenanceWindow holds back the runs of the CronJobs it selects while
+// it's open, so that freezes can be managed in one place rather than on
+// every CronJob.
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MaintenanceWindowSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MaintenanceWindowList contains a list of MaintenanceWindow
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MaintenanceWindow{}, &MaintenanceWindowList{})
+}
@@ -0,0 +1,79 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *MaintenanceWindow) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-maintenancewindow,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=maintenancewindows,versions=v1,name=vmaintenancewindow.kb.io
+
+var _ webhook.Validator = &MaintenanceWindow{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MaintenanceWindow) ValidateCreate() error {
+	return r.validateMaintenanceWindow()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MaintenanceWindow) ValidateUpdate(old runtime.Object) error {
+	return r.validateMaintenanceWindow()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MaintenanceWindow) ValidateDelete() error {
+	return nil
+}
+
+/*
+Maintenance windows are made of the same periods as blackout windows, so they
+get the same checks, along with the usual ones for label selectors.
+*/
+
+func (r *MaintenanceWindow) validateMaintenanceWindow() error {
+	fldPath := field.NewPath("spec")
+	var allErrs field.ErrorList
+	if len(r.Spec.Windows) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("windows"), "at least one window is required"))
+	}
+	allErrs = append(allErrs, validateBlackoutWindows(r.Spec.Windows, fldPath.Child("windows"))...)
+	if r.Spec.NamespaceSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(r.Spec.NamespaceSelector, fldPath.Child("namespaceSelector"))...)
+	}
+	if r.Spec.Selector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(r.Spec.Selector, fldPath.Child("selector"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "MaintenanceWindow"},
+		r.Name, allErrs)
+}
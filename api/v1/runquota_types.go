@@ -0,0 +1,80 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RunQuotaSpec defines the desired state of RunQuota
+type RunQuotaSpec struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// The most jobs the namespace's CronJobs may have running at once.
+	// +optional
+	MaxConcurrentJobs *int32 `json:"maxConcurrentJobs,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// The most scheduled runs the namespace's CronJobs may start in any hour.
+	// +optional
+	MaxRunsPerHour *int32 `json:"maxRunsPerHour,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// The most scheduled runs the namespace's CronJobs may start in any day.
+	// +optional
+	MaxRunsPerDay *int32 `json:"maxRunsPerDay,omitempty"`
+}
+
+// RunQuotaStatus defines the observed state of RunQuota
+type RunQuotaStatus struct {
+	// When the namespace's scheduled runs started over the last day, oldest
+	// first, which is what the hourly and daily limits are counted against.
+	// +optional
+	RecentRuns []metav1.Time `json:"recentRuns,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Max Concurrent",type=integer,JSONPath=`.spec.maxConcurrentJobs`
+//+kubebuilder:printcolumn:name="Per Hour",type=integer,JSONPath=`.spec.maxRunsPerHour`
+//+kubebuilder:printcolumn:name="Per Day",type=integer,JSONPath=`.spec.maxRunsPerDay`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RunQuota limits how much the CronJobs in its namespace may run.  Scheduled
+// runs that would go over it wait until there's room, instead of starting.
+type RunQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RunQuotaSpec   `json:"spec,omitempty"`
+	Status RunQuotaStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RunQuotaList contains a list of RunQuota
+type RunQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RunQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RunQuota{}, &RunQuotaList{})
+}
@@ -0,0 +1,65 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScheduleOverrideSpec defines the desired state of ScheduleOverride
+type ScheduleOverrideSpec struct {
+	// The name of the CronJob, in the same namespace, whose schedule to
+	// override.
+	CronJobName string `json:"cronJobName"`
+
+	// The schedule to use instead of the CronJob's own, in the same format,
+	// and evaluated in the CronJob's time zone.
+	Schedule string `json:"schedule"`
+
+	// When the override stops applying, and the CronJob goes back to its own
+	// schedule.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="CronJob",type=string,JSONPath=`.spec.cronJobName`
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expiresAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ScheduleOverride temporarily replaces a CronJob's schedule, without
+// touching the CronJob itself.  Once it expires, the CronJob goes back to its
+// own schedule by itself.
+type ScheduleOverride struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ScheduleOverrideSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ScheduleOverrideList contains a list of ScheduleOverride
+type ScheduleOverrideList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduleOverride `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduleOverride{}, &ScheduleOverrideList{})
+}
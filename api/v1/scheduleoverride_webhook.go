@@ -0,0 +1,84 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *ScheduleOverride) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-scheduleoverride,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=scheduleoverrides,versions=v1,name=vscheduleoverride.kb.io
+
+var _ webhook.Validator = &ScheduleOverride{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleOverride) ValidateCreate() error {
+	return r.validateScheduleOverride(nil, time.Now())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleOverride) ValidateUpdate(old runtime.Object) error {
+	return r.validateScheduleOverride(old.(*ScheduleOverride), time.Now())
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleOverride) ValidateDelete() error {
+	return nil
+}
+
+/*
+An override that has already expired would never do anything, which is almost
+certainly a mistake, so we only allow one to be created (or have its expiry
+changed) if it expires in the future.  Expired overrides can otherwise be left
+alone, or edited, like anything else.
+*/
+
+func (r *ScheduleOverride) validateScheduleOverride(old *ScheduleOverride, now time.Time) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.CronJobName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("cronJobName"), "must name the CronJob to override"))
+	}
+	if err := validateScheduleFormat(r.Spec.Schedule, specPath.Child("schedule")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if old == nil || !old.Spec.ExpiresAt.Equal(&r.Spec.ExpiresAt) {
+		if !r.Spec.ExpiresAt.After(now) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("expiresAt"), r.Spec.ExpiresAt, "must be in the future"))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "ScheduleOverride"},
+		r.Name, allErrs)
+}
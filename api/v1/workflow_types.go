@@ -0,0 +1,174 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkflowNode is one step of a Workflow: a job that starts once all the
+// nodes it depends on have succeeded.
+type WorkflowNode struct {
+	// The name of the node, unique within the Workflow.
+	Name string `json:"name"`
+
+	// The names of the nodes that must succeed before this one starts.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// How many more times to try the node's job after it fails, before
+	// giving up on the node.  Defaults to 0.
+	// +optional
+	Retries *int32 `json:"retries,omitempty"`
+
+	// The job to create for the node.
+	// +optional
+	JobTemplate *batchv1beta1.JobTemplateSpec `json:"jobTemplate,omitempty"`
+
+	// A JobTemplate in the Workflow's namespace to create the node's job
+	// from, instead of jobTemplate.
+	// +optional
+	JobTemplateRef *corev1.LocalObjectReference `json:"jobTemplateRef,omitempty"`
+}
+
+// WorkflowSpec defines the desired state of Workflow
+type WorkflowSpec struct {
+	// The schedule to start runs of the whole Workflow on, in the same format
+	// as a CronJob's.
+	Schedule string `json:"schedule"`
+
+	// The name of the time zone to evaluate the schedule in, from the tz
+	// database.  Defaults to the time zone the controller runs in.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	//+kubebuilder:validation:Minimum=0
+
+	// Optional deadline in seconds for starting a run if it misses its
+	// scheduled time for any reason.
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// Stops new runs from starting.  Runs already going carry on.  Defaults
+	// to false.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+
+	// The steps of the Workflow, which must form a DAG.
+	// +listType=map
+	// +listMapKey=name
+	Nodes []WorkflowNode `json:"nodes"`
+}
+
+// WorkflowPhase describes where a run of a Workflow, or one of its nodes,
+// is in its lifecycle.
+type WorkflowPhase string
+
+const (
+	// WorkflowPending means a node is waiting for its dependencies.
+	WorkflowPending WorkflowPhase = "Pending"
+
+	// WorkflowRunning means a run, or a node, has jobs still going.
+	WorkflowRunning WorkflowPhase = "Running"
+
+	// WorkflowSucceeded means every node of a run, or a node's job,
+	// succeeded.
+	WorkflowSucceeded WorkflowPhase = "Succeeded"
+
+	// WorkflowFailed means a node failed after all its retries, and the
+	// rest of its run has finished as far as it can.
+	WorkflowFailed WorkflowPhase = "Failed"
+
+	// WorkflowSkipped means a node never ran, because one of its
+	// dependencies failed.
+	WorkflowSkipped WorkflowPhase = "Skipped"
+)
+
+// WorkflowNodeStatus describes how a node of the current run is doing.
+type WorkflowNodeStatus struct {
+	// The name of the node.
+	Name string `json:"name"`
+
+	// Where the node is in its lifecycle.
+	Phase WorkflowPhase `json:"phase"`
+
+	// How many jobs have been created for the node, including retries.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// The name of the node's most recent job.
+	// +optional
+	Job string `json:"job,omitempty"`
+}
+
+// WorkflowStatus defines the observed state of Workflow
+type WorkflowStatus struct {
+	// The generation of the spec that the controller last acted on.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The scheduled time of the current, or most recent, run.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Where the current, or most recent, run is in its lifecycle.
+	// +optional
+	Phase WorkflowPhase `json:"phase,omitempty"`
+
+	// How each node of the current, or most recent, run is doing.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Nodes []WorkflowNodeStatus `json:"nodes,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Workflow runs a DAG of jobs on a schedule: each run starts the nodes with
+// no dependencies, and every other node as soon as the nodes it depends on
+// have succeeded.
+type Workflow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkflowSpec   `json:"spec,omitempty"`
+	Status WorkflowStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// WorkflowList contains a list of Workflow
+type WorkflowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Workflow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Workflow{}, &WorkflowList{})
+}
@@ -0,0 +1,193 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	validationutils "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *Workflow) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-batch-tutorial-kubebuilder-io-v1-workflow,mutating=false,failurePolicy=fail,groups=batch.tutorial.kubebuilder.io,resources=workflows,versions=v1,name=vworkflow.kb.io
+
+var _ webhook.Validator = &Workflow{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Workflow) ValidateCreate() error {
+	return r.validateWorkflow()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Workflow) ValidateUpdate(old runtime.Object) error {
+	return r.validateWorkflow()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Workflow) ValidateDelete() error {
+	return nil
+}
+
+/*
+Besides the checks a CronJob's schedule and job templates get, the nodes have
+to form a DAG: every dependency has to name another node, and following them
+must never lead back to where we started, or the run could never finish.
+*/
+
+func (r *Workflow) validateWorkflow() error {
+	specPath := field.NewPath("spec")
+	var allErrs field.ErrorList
+	if err := validateScheduleFormat(r.Spec.Schedule, specPath.Child("schedule")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if r.Spec.TimeZone != nil {
+		if err := validateTimeZone(*r.Spec.TimeZone, specPath.Child("timeZone")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	nodesPath := specPath.Child("nodes")
+	if len(r.Spec.Nodes) == 0 {
+		allErrs = append(allErrs, field.Required(nodesPath, "at least one node is required"))
+	}
+	nodes := make(map[string]*WorkflowNode)
+	for i := range r.Spec.Nodes {
+		node := &r.Spec.Nodes[i]
+		idxPath := nodesPath.Index(i)
+		if _, dup := nodes[node.Name]; dup {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), node.Name))
+		}
+		nodes[node.Name] = node
+		allErrs = append(allErrs, r.validateWorkflowNode(node, idxPath)...)
+	}
+	for i, node := range r.Spec.Nodes {
+		for j, dep := range node.DependsOn {
+			depPath := nodesPath.Index(i).Child("dependsOn").Index(j)
+			switch {
+			case dep == node.Name:
+				allErrs = append(allErrs, field.Invalid(depPath, dep, "a node may not depend on itself"))
+			case nodes[dep] == nil:
+				allErrs = append(allErrs, field.NotFound(depPath, dep))
+			}
+		}
+	}
+	if cycle := workflowCycle(r.Spec.Nodes); cycle != nil {
+		allErrs = append(allErrs, field.Invalid(nodesPath, cycle, "dependencies must not form a cycle"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "batch.tutorial.kubebuilder.io", Kind: "Workflow"},
+		r.Name, allErrs)
+}
+
+func (r *Workflow) validateWorkflowNode(node *WorkflowNode, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, msg := range validationutils.IsDNS1123Label(node.Name) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), node.Name, msg))
+	}
+	// jobs are named `$WORKFLOW-$TIMESTAMP-$NODE`, with a further `-r$INDEX`
+	// on retries, and like any job name that has to fit in 63 characters
+	suffixLength := 0
+	if node.Retries != nil && *node.Retries > 0 {
+		suffixLength = len(fmt.Sprintf("-r%d", *node.Retries))
+	}
+	if maxLength := validationutils.DNS1035LabelMaxLength - 11 - 1 - suffixLength; len(r.Name)+len(node.Name) > maxLength {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), node.Name,
+			fmt.Sprintf("together with the Workflow's name, must be no more than %d characters", maxLength)))
+	}
+	if node.Retries != nil && *node.Retries < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("retries"), *node.Retries, "must be non-negative"))
+	}
+
+	switch {
+	case node.JobTemplate != nil && node.JobTemplateRef != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("jobTemplateRef"), "may not be set along with jobTemplate"))
+	case node.JobTemplate != nil:
+		allErrs = append(allErrs, validateJobTemplate(node.JobTemplate, fldPath.Child("jobTemplate"))...)
+	case node.JobTemplateRef != nil:
+		refPath := fldPath.Child("jobTemplateRef", "name")
+		for _, msg := range validationutils.IsDNS1123Subdomain(node.JobTemplateRef.Name) {
+			allErrs = append(allErrs, field.Invalid(refPath, node.JobTemplateRef.Name, msg))
+		}
+	default:
+		allErrs = append(allErrs, field.Required(fldPath, "one of jobTemplate or jobTemplateRef is required"))
+	}
+	return allErrs
+}
+
+// workflowCycle returns the names of the nodes along a dependency cycle, if
+// there is one.  Dependencies on nodes that don't exist are ignored.
+func workflowCycle(nodes []WorkflowNode) []string {
+	deps := make(map[string][]string)
+	for _, node := range nodes {
+		deps[node.Name] = node.DependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string(nil), path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if _, ok := deps[dep]; !ok || dep == name {
+				continue
+			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, node := range nodes {
+		if cycle := visit(node.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
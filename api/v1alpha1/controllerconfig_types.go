@@ -0,0 +1,258 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+
+	batchv1 "kubebuilder-tutorial/api/v1"
+)
+
+// WorkersConfig configures how CronJobs are reconciled: how many at once, and
+// how fast.
+type WorkersConfig struct {
+	// How many CronJobs may be reconciled at once.
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// How long to wait before first retrying a CronJob whose reconcile failed,
+	// doubling with each failure.
+	// +optional
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+
+	// The longest to wait before retrying a CronJob whose reconcile failed.
+	// +optional
+	MaxDelay metav1.Duration `json:"maxDelay,omitempty"`
+
+	// How many CronJobs per second may be let out of the queue overall.
+	// +optional
+	QPS float64 `json:"qps,omitempty"`
+
+	// How many CronJobs may be let out of the queue at once, above the QPS.
+	// +optional
+	Burst int `json:"burst,omitempty"`
+
+	// How many times per second any one CronJob may be reconciled.  Unlimited
+	// if zero.
+	// +optional
+	PerObjectQPS float64 `json:"perObjectQPS,omitempty"`
+
+	// How many times any one CronJob may be reconciled in quick succession,
+	// above its QPS.
+	// +optional
+	PerObjectBurst int `json:"perObjectBurst,omitempty"`
+
+	// The longest a single reconcile may take before it's abandoned and
+	// retried.  Unlimited if zero.
+	// +optional
+	ReconcileTimeout metav1.Duration `json:"reconcileTimeout,omitempty"`
+}
+
+// ShardConfig configures which shard of the namespaces a replica works on.
+type ShardConfig struct {
+	// How many shards to split namespaces between, each with its own leader
+	// election.
+	// +optional
+	Count int `json:"count,omitempty"`
+
+	// Which shard this replica works on, counting from zero.  ClusterCronJobs
+	// are looked after by shard 0.
+	// +optional
+	Index int `json:"index,omitempty"`
+}
+
+// TracingConfig configures where traces are sent.
+type TracingConfig struct {
+	// The host:port of the OTLP gRPC collector to send traces to.  Tracing is
+	// disabled if empty.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Whether to connect to the collector without TLS.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// TenantsConfig configures the budgets of active jobs the controller holds
+// tenants to.  Each shard holds them to the budgets on its own.
+type TenantsConfig struct {
+	// What the tenants are: Namespace, or Team, for the CronJobs' team label,
+	// falling back on their namespace.  Defaults to Namespace.
+	// +optional
+	By string `json:"by,omitempty"`
+
+	// The most jobs any one tenant may have running at once.  Unlimited if
+	// zero.
+	// +optional
+	MaxActiveJobs int32 `json:"maxActiveJobs,omitempty"`
+
+	// Budgets for particular tenants, overriding maxActiveJobs, keyed by
+	// namespace:<name> or team:<name>.
+	// +optional
+	Budgets map[string]int32 `json:"budgets,omitempty"`
+
+	// The most jobs all tenants together may have running at once, with room
+	// given to each tenant in turn.  Unlimited if zero.
+	// +optional
+	TotalMaxActiveJobs int32 `json:"totalMaxActiveJobs,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ControllerConfig is the controller manager's configuration file.  Each of
+// its settings can also be set by a flag, which wins over the file.
+type ControllerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// The manager's own settings: leader election, the metrics and webhook
+	// servers, and the sync period.
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// The namespaces to watch, along with cacheNamespace.  All of them, if
+	// neither is set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Namespaces to leave alone, even if they're watched.  Objects in them are
+	// still cached, unless the namespaces to watch are narrowed down too.
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// Run with only namespace-scoped permissions in the namespaces to watch,
+	// for clusters where the manager can't be given a ClusterRole.
+	// Cluster-scoped objects are never read, so MaintenanceWindows don't apply
+	// and Pod Security levels aren't checked.  Needs namespaces to be set.
+	// +optional
+	Namespaced bool `json:"namespaced,omitempty"`
+
+	// How CronJobs are reconciled.
+	// +optional
+	Workers WorkersConfig `json:"workers,omitempty"`
+
+	// How far ahead of their scheduled time runs may start, to allow for clock
+	// skew.  CronJobs may override this.
+	// +optional
+	ClockSkewTolerance metav1.Duration `json:"clockSkewTolerance,omitempty"`
+
+	// Which shard of the namespaces this replica works on.
+	// +optional
+	Shard ShardConfig `json:"shard,omitempty"`
+
+	// The budgets of active jobs tenants are held to.
+	// +optional
+	Tenants TenantsConfig `json:"tenants,omitempty"`
+
+	// Where traces are sent.
+	// +optional
+	Tracing TracingConfig `json:"tracing,omitempty"`
+
+	// Where to record scheduling decisions: stdout, file:<path> or
+	// configmap:<namespace>/<name>.  Decisions aren't recorded if empty.
+	// +optional
+	AuditSink string `json:"auditSink,omitempty"`
+
+	// Where to archive the logs of jobs before cleaning them up, for CronJobs
+	// that ask for it: configmap.  Logs aren't archived if empty.
+	// +optional
+	LogArchive string `json:"logArchive,omitempty"`
+
+	// The stores CronJobs can have secrets injected from, as a comma-separated
+	// list of vault and aws-secretsmanager.  Each is configured from the
+	// environment, as its own CLI would be.  None if empty.
+	// +optional
+	SecretProviders string `json:"secretProviders,omitempty"`
+
+	// The paths in the secret stores each namespace's CronJobs may have
+	// secrets injected from, as prefixes keyed by namespace, with * for every
+	// namespace.  {namespace} in a prefix stands for the CronJob's own
+	// namespace, e.g. "*": ["secret/data/{namespace}/"].  No secrets can be
+	// injected in namespaces with no prefixes.
+	// +optional
+	SecretPaths map[string][]string `json:"secretPaths,omitempty"`
+
+	// Where to listen for CloudEvents for CronJobs' CloudEvents triggers,
+	// e.g. :8090.  CloudEvents triggers can't run if empty.
+	// +optional
+	CloudEventsBindAddress string `json:"cloudEventsBindAddress,omitempty"`
+
+	// The hosts CronJobs' NATS, Kafka and SQS triggers may connect to, by
+	// name, or as *.example.com for any host under example.com.  Those
+	// triggers can't run if empty.
+	// +optional
+	EventSourceHosts []string `json:"eventSourceHosts,omitempty"`
+
+	// The hosts on the cluster's network CronJobs' notifications and
+	// calendars may be sent to and fetched from, by name, or as
+	// *.example.com for any host under example.com.  Anywhere else, they
+	// have to be at public addresses.
+	// +optional
+	InternalHosts []string `json:"internalHosts,omitempty"`
+
+	// The Prometheus server CronJobs' condition gates query, e.g.
+	// http://prometheus:9090.  Condition gates are never met if empty.
+	// +optional
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+
+	// The image of the container that has a service mesh sidecar quit once
+	// a job's own containers have finished.  It needs a busybox shell, with
+	// wget.
+	// +optional
+	MeshQuitImage string `json:"meshQuitImage,omitempty"`
+
+	// Optional behaviours of the controller to turn on or off, by name.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// The rest of the settings are picked up as the file changes, without a
+	// restart.
+
+	// How much to log: debug, info or error, or a verbosity like 2 for more
+	// than debug.  Defaults to debug.
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Notifications to send for every CronJob, on top of its own.
+	// +optional
+	Notifications []batchv1.Notification `json:"notifications,omitempty"`
+
+	// The history limits given to CronJobs that don't set their own.
+	// Defaults to 3 successful jobs and 1 failed one.
+	// +optional
+	DefaultSuccessfulJobsHistoryLimit *int32 `json:"defaultSuccessfulJobsHistoryLimit,omitempty"`
+	// +optional
+	DefaultFailedJobsHistoryLimit *int32 `json:"defaultFailedJobsHistoryLimit,omitempty"`
+
+	// The most jobs the CronJobs of a namespace without any RunQuotas may have
+	// running at once.  Unlimited if unset.
+	// +optional
+	DefaultMaxConcurrentJobs *int32 `json:"defaultMaxConcurrentJobs,omitempty"`
+}
+
+// WatchedNamespaces lists the namespaces to watch, or none if all of them
+// are.
+func (c *ControllerConfig) WatchedNamespaces() []string {
+	namespaces := append([]string(nil), c.Namespaces...)
+	if c.CacheNamespace != "" {
+		namespaces = append(namespaces, c.CacheNamespace)
+	}
+	return namespaces
+}
+
+func init() {
+	SchemeBuilder.Register(&ControllerConfig{})
+}
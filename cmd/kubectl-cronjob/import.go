@@ -0,0 +1,192 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Importing converts the built-in batch/v1 CronJobs into ours, one by one or a
+whole namespace at a time.  The client-go we build against predates batch/v1
+CronJobs, so we read them as unstructured objects, and convert them to the
+v1beta1 types, whose shape is the same.
+*/
+
+// nativeCronJob is the group-version-kind of the built-in CronJob.
+var nativeCronJob = schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}
+
+const (
+	// importedFromAnnotation marks our CronJobs with the built-in CronJob they
+	// were imported from.
+	importedFromAnnotation = "batch.tutorial.kubebuilder.io/imported-from"
+
+	// lastAppliedAnnotation is kubectl's record of the built-in CronJob's last
+	// applied configuration, which means nothing for ours.
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+var (
+	importAll     bool
+	importSuspend bool
+	importDryRun  bool
+)
+
+// importCronJobs imports the named built-in CronJob, or all of those in the
+// namespace, carrying on past any that fail.
+func importCronJobs(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var natives []unstructured.Unstructured
+	if key.Name != "" {
+		native := unstructured.Unstructured{}
+		native.SetGroupVersionKind(nativeCronJob)
+		if err := c.Get(ctx, key, &native); err != nil {
+			return err
+		}
+		natives = append(natives, native)
+	} else {
+		list := unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(nativeCronJob.GroupVersion().WithKind("CronJobList"))
+		if err := c.List(ctx, &list, client.InNamespace(key.Namespace)); err != nil {
+			return err
+		}
+		natives = list.Items
+	}
+
+	failed := 0
+	for i := range natives {
+		if err := importCronJob(ctx, c, &natives[i], out); err != nil {
+			fmt.Fprintf(out, "cronjob.batch/%s: %v\n", natives[i].GetName(), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d CronJobs failed to import", failed, len(natives))
+	}
+	return nil
+}
+
+// importCronJob creates our CronJob from the built-in one, carrying over its
+// last schedule time, and suspends the original if asked to.  CronJobs that
+// exist already, say from an import that didn't get that far, are left as
+// they are, but the rest is done all the same, so that importing again
+// finishes the job.
+func importCronJob(ctx context.Context, c client.Client, native *unstructured.Unstructured, out io.Writer) error {
+	cronJob, lastScheduleTime, err := convertCronJob(native)
+	if err != nil {
+		return err
+	}
+
+	var opts []client.CreateOption
+	if importDryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	err = c.Create(ctx, cronJob, opts...)
+	switch {
+	case apierrors.IsAlreadyExists(err) && importDryRun:
+		fmt.Fprintf(out, "cronjob/%s already exists (dry run)\n", cronJob.Name)
+		return nil
+	case apierrors.IsAlreadyExists(err):
+		if err := c.Get(ctx, client.ObjectKeyFromObject(cronJob), cronJob); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "cronjob/%s already exists\n", cronJob.Name)
+	case err != nil:
+		return err
+	case importDryRun:
+		fmt.Fprintf(out, "cronjob/%s created (dry run)\n", cronJob.Name)
+		return nil
+	default:
+		fmt.Fprintf(out, "cronjob/%s created\n", cronJob.Name)
+	}
+
+	// the controller only ever moves this forward, so the runs the original
+	// already made aren't made again.  It writes status of its own as soon as
+	// it sees the CronJob, so we patch in just this field, rather than
+	// conflict with it.
+	if last := cronJob.Status.LastScheduleTime; lastScheduleTime != nil && (last == nil || last.Before(lastScheduleTime)) {
+		patch := client.MergeFrom(cronJob.DeepCopy())
+		cronJob.Status.LastScheduleTime = lastScheduleTime
+		if err := c.Status().Patch(ctx, cronJob, patch); err != nil {
+			return fmt.Errorf("unable to carry over its last schedule time: %v", err)
+		}
+	}
+
+	if importSuspend {
+		patch := client.MergeFrom(native.DeepCopy())
+		if err := unstructured.SetNestedField(native.Object, true, "spec", "suspend"); err != nil {
+			return err
+		}
+		if err := c.Patch(ctx, native, patch); err != nil {
+			return fmt.Errorf("imported, but unable to suspend the original: %v", err)
+		}
+		fmt.Fprintf(out, "cronjob.batch/%s suspended\n", native.GetName())
+	}
+	return nil
+}
+
+// convertCronJob maps the built-in CronJob onto ours, returning its last
+// schedule time alongside, since that's status, and has to be set separately.
+func convertCronJob(native *unstructured.Unstructured) (*batch.CronJob, *metav1.Time, error) {
+	var src batchv1beta1.CronJob
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(native.Object, &src); err != nil {
+		return nil, nil, err
+	}
+
+	annotations := map[string]string{}
+	for k, v := range src.Annotations {
+		if k != lastAppliedAnnotation {
+			annotations[k] = v
+		}
+	}
+	annotations[importedFromAnnotation] = "batch/v1 CronJob " + src.Name
+
+	cronJob := &batch.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        src.Name,
+			Namespace:   src.Namespace,
+			Labels:      src.Labels,
+			Annotations: annotations,
+		},
+		Spec: batch.CronJobSpec{
+			Schedule:                   src.Spec.Schedule,
+			StartingDeadlineSeconds:    src.Spec.StartingDeadlineSeconds,
+			ConcurrencyPolicy:          batch.ConcurrencyPolicy(src.Spec.ConcurrencyPolicy),
+			Suspend:                    src.Spec.Suspend,
+			JobTemplate:                src.Spec.JobTemplate,
+			SuccessfulJobsHistoryLimit: src.Spec.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     src.Spec.FailedJobsHistoryLimit,
+		},
+	}
+	// time zones came to batch/v1 after v1beta1, so the types don't have them
+	if timeZone, ok, _ := unstructured.NestedString(native.Object, "spec", "timeZone"); ok && timeZone != "" {
+		cronJob.Spec.TimeZone = &timeZone
+	}
+	return cronJob, src.Status.LastScheduleTime, nil
+}
@@ -0,0 +1,562 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kubectl-cronjob is a kubectl plugin for finding out what our
+// CronJobs are up to, and poking them, without reading through their YAML:
+//
+//	kubectl cronjob next my-job            # when it runs next
+//	kubectl cronjob history my-job         # how its recent runs went
+//	kubectl cronjob trigger my-job         # run it now
+//	kubectl cronjob suspend my-job --until 2h
+//
+// It can also list the runs a CronJob would have over a stretch of time,
+// which works from a file just as well as from the cluster, so that complex
+// schedules can be checked in CI before they're merged:
+//
+//	kubectl cronjob simulate -f cronjob.yaml --holiday-calendar holidays.yaml --to 720h
+//
+// And it can import the built-in batch/v1 CronJobs into ours, or export ours
+// back again:
+//
+//	kubectl cronjob import --all -n my-namespace --suspend-originals
+//	kubectl cronjob export my-job > my-job.yaml
+//
+// Put it on your PATH, and kubectl picks it up.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/controllers"
+)
+
+const usage = `Usage: kubectl cronjob <command> <name> [flags]
+
+Commands:
+  next      show when the CronJob runs next
+  history   show how the CronJob's recent runs went, skipped ones included
+  trigger   start a run of the CronJob now
+  suspend   suspend the CronJob, indefinitely or --until a time or for a duration
+  simulate  list the runs the CronJob would have --from one time --to another
+  import    create one of our CronJobs from the built-in batch/v1 CronJob of the same name,
+            or from each of them in the namespace, with --all
+  export    print the CronJob as a built-in batch/v1 CronJob, ready to apply
+
+Flags:
+  -n, --namespace   the namespace of the CronJob (defaults to the current context's)
+  --kubeconfig      the kubeconfig file to use
+  --count           for next, how many upcoming runs to show (default 5)
+  --until           for suspend, when to resume: an RFC 3339 time, or a duration like 2h
+  --from            for simulate, where to start: an RFC 3339 time, or a duration from now (default now)
+  --to              for simulate, where to stop: an RFC 3339 time, or a duration from --from (default 168h)
+  -f, --filename    for simulate and export, a file holding the CronJob, in place of its name
+  --holiday-calendar
+                    for simulate, a file holding the CronJob's HolidayCalendar, if it has one
+  --suspend-originals
+                    for import, suspend the built-in CronJobs once imported, so they don't run twice
+  --dry-run         for import, check the imports with the server without making them
+`
+
+// command is one of the plugin's commands.
+type command struct {
+	// flags adds the command's own flags.
+	flags func(fs *flag.FlagSet)
+	// run runs the command against the named CronJob.
+	run func(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error
+	// offline runs the command without a cluster, if its flags allow, and
+	// reports whether it did.
+	offline func(out io.Writer) (bool, error)
+	// all, once set, lets the command run against every CronJob in the
+	// namespace, when none is named.
+	all *bool
+}
+
+var (
+	nextCount    int
+	suspendUntil string
+
+	simulateFrom, simulateTo string
+	simulateHolidays         string
+
+	// filename is a file holding the CronJob, for the commands that can work
+	// without a cluster.
+	filename string
+)
+
+var commands = map[string]command{
+	"next": {
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&nextCount, "count", 5, "how many upcoming runs to show")
+		},
+		run: next,
+	},
+	"history": {run: history},
+	"trigger": {run: trigger},
+	"suspend": {
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&suspendUntil, "until", "", "when to resume, as an RFC 3339 time or a duration from now, like 2h")
+		},
+		run: suspend,
+	},
+	"simulate": {
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&simulateFrom, "from", "", "where to start, as an RFC 3339 time or a duration from now")
+			fs.StringVar(&simulateTo, "to", "168h", "where to stop, as an RFC 3339 time or a duration from --from")
+			filenameFlags(fs)
+			fs.StringVar(&simulateHolidays, "holiday-calendar", "", "a file holding the CronJob's HolidayCalendar")
+		},
+		run:     simulate,
+		offline: simulateOffline,
+	},
+	"import": {
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&importAll, "all", false, "import all the built-in CronJobs in the namespace")
+			fs.BoolVar(&importSuspend, "suspend-originals", false, "suspend the built-in CronJobs once imported")
+			fs.BoolVar(&importDryRun, "dry-run", false, "check the imports with the server without making them")
+		},
+		run: importCronJobs,
+		all: &importAll,
+	},
+	"export": {
+		flags:   filenameFlags,
+		run:     export,
+		offline: exportOffline,
+	},
+}
+
+// filenameFlags adds the flags for reading the CronJob from a file.
+func filenameFlags(fs *flag.FlagSet) {
+	fs.StringVar(&filename, "f", "", "a file holding the CronJob")
+	fs.StringVar(&filename, "filename", "", "a file holding the CronJob")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("kubectl cronjob "+os.Args[1], flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	var namespace, kubeconfig string
+	fs.StringVar(&namespace, "n", "", "the namespace of the CronJob")
+	fs.StringVar(&namespace, "namespace", "", "the namespace of the CronJob")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "the kubeconfig file to use")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	name, err := parseArgs(fs, os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		os.Exit(2)
+	}
+	if cmd.offline != nil {
+		ran, err := cmd.offline(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if ran {
+			return
+		}
+	}
+	if name == "" && (cmd.all == nil || !*cmd.all) {
+		fmt.Fprintf(os.Stderr, "a CronJob name is required\n\n%s", usage)
+		os.Exit(2)
+	}
+
+	c, defaultNamespace, err := newClient(kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if err := cmd.run(context.Background(), c, key, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseArgs parses the flags, which may come before or after the CronJob's
+// name, the way kubectl's own do, and returns the name, if there is one.
+func parseArgs(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() == 0 {
+		return "", nil
+	}
+	name := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	return name, nil
+}
+
+// newClient builds a client from the kubeconfig, the same way kubectl does,
+// and returns it along with the current context's namespace.
+func newClient(kubeconfig string) (client.Client, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+
+	scheme := runtime.NewScheme()
+	if err := batch.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", err
+	}
+	return c, namespace, nil
+}
+
+// next shows when the CronJob runs next, both as the controller last worked
+// it out, and as its schedule has it from here on.
+func next(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+
+	loc := time.UTC
+	if cronJob.Spec.TimeZone != nil {
+		if l, err := time.LoadLocation(*cronJob.Spec.TimeZone); err == nil {
+			loc = l
+		}
+	}
+
+	if cronJob.Spec.CalendarRef != nil {
+		fmt.Fprint(out, "Schedule:\tcalendar")
+		if calendar := cronJob.Status.Calendar; calendar != nil && calendar.LastSyncTime != nil {
+			fmt.Fprintf(out, ", as read at %s", calendar.LastSyncTime.In(loc).Format(time.RFC3339))
+		}
+	} else {
+		fmt.Fprintf(out, "Schedule:\t%s", cronJob.Spec.Schedule)
+	}
+	if cronJob.Spec.TimeZone != nil {
+		fmt.Fprintf(out, " (%s)", *cronJob.Spec.TimeZone)
+	}
+	fmt.Fprintln(out)
+	if override := cronJob.Status.ScheduleOverride; override != nil {
+		fmt.Fprintf(out, "Overridden:\t%s by ScheduleOverride %s, until %s\n", override.Schedule, override.Name, override.ExpiresAt.In(loc).Format(time.RFC3339))
+	}
+	switch {
+	case cronJob.Spec.Paused:
+		fmt.Fprintf(out, "Paused:\t%s\n", cronJob.Spec.PauseReason)
+	case cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend:
+		fmt.Fprintln(out, "Suspended")
+	case cronJob.Spec.SuspendUntil != nil && time.Now().Before(cronJob.Spec.SuspendUntil.Time):
+		fmt.Fprintf(out, "Suspended until:\t%s\n", cronJob.Spec.SuspendUntil.In(loc).Format(time.RFC3339))
+	}
+	if next := cronJob.Status.NextScheduleTime; next != nil {
+		fmt.Fprintf(out, "Next run:\t%s\n", next.In(loc).Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(out, "Next run:\tnone")
+	}
+
+	// without a time zone of its own, the CronJob runs in the controller's,
+	// which we can't know, so these are only a guide
+	upcoming, err := cronJob.NextRuns(time.Now(), nextCount)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Upcoming, as scheduled:")
+	for _, t := range upcoming {
+		fmt.Fprintf(out, "  %s\n", t.In(loc).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// history shows the CronJob's recent runs and skipped runs, oldest first.
+func history(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+
+	type row struct {
+		scheduled metav1.Time
+		cells     string
+	}
+	var rows []row
+	for _, run := range cronJob.Status.RecentRuns {
+		duration := "-"
+		if run.StartTime != nil && run.CompletionTime != nil {
+			duration = run.CompletionTime.Sub(run.StartTime.Time).Round(time.Second).String()
+		}
+		triggeredBy := run.TriggeredBy
+		if triggeredBy == "" {
+			triggeredBy = "-"
+		}
+		rows = append(rows, row{run.ScheduledTime, fmt.Sprintf("%s\t%s\t%s\t%s", run.Result, run.JobName, duration, triggeredBy)})
+	}
+	for _, skipped := range cronJob.Status.SkippedRuns {
+		rows = append(rows, row{skipped.ScheduledTime, fmt.Sprintf("Skipped (%s)\t-\t-\t-", skipped.Reason)})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].scheduled.Before(&rows[j].scheduled) })
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEDULED\tRESULT\tJOB\tDURATION\tTRIGGERED BY")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", r.scheduled.Format(time.RFC3339), r.cells)
+	}
+	return w.Flush()
+}
+
+// trigger starts a run of the CronJob now, by creating a CronJobTrigger for
+// it.
+func trigger(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+	t := &batch.CronJobTrigger{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    key.Namespace,
+			GenerateName: key.Name + "-",
+		},
+		Spec: batch.CronJobTriggerSpec{CronJobName: key.Name},
+	}
+	if err := c.Create(ctx, t); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "cronjobtrigger/%s created\n", t.Name)
+	return nil
+}
+
+// suspend suspends the CronJob, either until the given time, or until it's
+// resumed by hand.
+func suspend(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var until *metav1.Time
+	if suspendUntil != "" {
+		t, err := parseUntil(suspendUntil, time.Now())
+		if err != nil {
+			return err
+		}
+		until = &metav1.Time{Time: t}
+	}
+
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(cronJob.DeepCopy())
+	if until != nil {
+		cronJob.Spec.SuspendUntil = until
+	} else {
+		suspended := true
+		cronJob.Spec.Suspend = &suspended
+	}
+	if err := c.Patch(ctx, &cronJob, patch); err != nil {
+		return err
+	}
+
+	if until != nil {
+		fmt.Fprintf(out, "cronjob/%s suspended until %s\n", key.Name, until.Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(out, "cronjob/%s suspended\n", key.Name)
+	}
+	return nil
+}
+
+// parseUntil parses an RFC 3339 time, or a duration from now.
+func parseUntil(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--until %q is neither an RFC 3339 time nor a duration", value)
+	}
+	return now.Add(d), nil
+}
+
+// simulate lists the runs the CronJob in the cluster would have, taking its
+// HolidayCalendar from the cluster too.
+func simulate(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+	var calendar *batch.HolidayCalendar
+	if ref := cronJob.Spec.HolidayCalendarRef; ref != nil {
+		calendar = &batch.HolidayCalendar{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: ref.Name}, calendar); err != nil {
+			return err
+		}
+	}
+	return printSimulatedRuns(&cronJob, calendar, out)
+}
+
+// simulateOffline lists the runs of the CronJob in the --filename file, if
+// there is one, taking its HolidayCalendar from the --holiday-calendar file.
+func simulateOffline(out io.Writer) (bool, error) {
+	if filename == "" {
+		return false, nil
+	}
+	var cronJob batch.CronJob
+	if err := decodeFile(filename, &cronJob); err != nil {
+		return true, err
+	}
+	var calendar *batch.HolidayCalendar
+	if simulateHolidays != "" {
+		calendar = &batch.HolidayCalendar{}
+		if err := decodeFile(simulateHolidays, calendar); err != nil {
+			return true, err
+		}
+	} else if cronJob.Spec.HolidayCalendarRef != nil {
+		fmt.Fprintf(os.Stderr, "warning: no --holiday-calendar given for HolidayCalendar %s, so no holidays are taken into account\n", cronJob.Spec.HolidayCalendarRef.Name)
+	}
+	return true, printSimulatedRuns(&cronJob, calendar, out)
+}
+
+// decodeFile decodes the YAML or JSON object in the file.
+func decodeFile(path string, obj interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(obj); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// printSimulatedRuns lists the CronJob's runs between --from and --to, in
+// the CronJob's own time zone.
+func printSimulatedRuns(cronJob *batch.CronJob, calendar *batch.HolidayCalendar, out io.Writer) error {
+	now := time.Now()
+	from := now
+	if simulateFrom != "" {
+		t, err := parseUntil(simulateFrom, now)
+		if err != nil {
+			return err
+		}
+		from = t
+	}
+	to, err := parseUntil(simulateTo, from)
+	if err != nil {
+		return err
+	}
+
+	// a file straight from a repo hasn't been through our defaulting
+	// webhook, and the holiday policy's default matters here
+	if cronJob.Spec.HolidayCalendarRef != nil && cronJob.Spec.HolidayPolicy == "" {
+		cronJob.Spec.HolidayPolicy = batch.SkipHolidays
+	}
+	var holidays map[string]bool
+	if calendar != nil {
+		if holidays, err = calendar.Dates(); err != nil {
+			return err
+		}
+	}
+
+	runs, err := controllers.SimulateRuns(cronJob, holidays, from, to)
+	if err != nil {
+		return err
+	}
+
+	loc := time.UTC
+	if cronJob.Spec.TimeZone != nil {
+		if l, err := time.LoadLocation(*cronJob.Spec.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEDULED\tSTART BY\tSKIPPED")
+	for _, run := range runs {
+		skipped := "-"
+		if run.SkipReason != "" {
+			skipped = string(run.SkipReason)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", run.ScheduledTime.In(loc).Format(time.RFC3339), run.StartBy.In(loc).Format(time.RFC3339), skipped)
+	}
+	return w.Flush()
+}
+
+// export prints the CronJob in the cluster as a built-in one, resolving its
+// JobTemplateRef from the cluster too.
+func export(ctx context.Context, c client.Client, key types.NamespacedName, out io.Writer) error {
+	var cronJob batch.CronJob
+	if err := c.Get(ctx, key, &cronJob); err != nil {
+		return err
+	}
+	return printExported(ctx, c, &cronJob, out)
+}
+
+// exportOffline prints the CronJob in the --filename file as a built-in one,
+// if there is a file.
+func exportOffline(out io.Writer) (bool, error) {
+	if filename == "" {
+		return false, nil
+	}
+	var cronJob batch.CronJob
+	if err := decodeFile(filename, &cronJob); err != nil {
+		return true, err
+	}
+	return true, printExported(context.Background(), nil, &cronJob, out)
+}
+
+// printExported prints the CronJob as a built-in one, in YAML, with whatever
+// had to be left out as warnings.
+func printExported(ctx context.Context, reader client.Reader, cronJob *batch.CronJob, out io.Writer) error {
+	native, dropped, err := controllers.ExportCronJob(ctx, reader, cronJob)
+	if err != nil {
+		return err
+	}
+	for _, msg := range dropped {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+	}
+	return json.NewYAMLSerializer(json.DefaultMetaFactory, nil, nil).Encode(native, out)
+}
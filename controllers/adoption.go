@@ -0,0 +1,85 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Jobs can lose their owner without being deleted, say when a CronJob is deleted
+with `--cascade=orphan` and then applied again.  Like the built-in controllers, we
+adopt any jobs that look like ours: ones without a controller, labeled with our
+name by our webhook, and carrying our scheduled time annotation.  Adopting them
+means that they count towards our status and our history limits again.
+*/
+
+// adoptOrphanedJobs adopts the jobs that have lost their controller but look
+// like the CronJob's, returning the ones it adopted.
+func (r *CronJobReconciler) adoptOrphanedJobs(ctx context.Context, cronJob *batch.CronJob) ([]kbatch.Job, error) {
+	if cronJob.DeletionTimestamp != nil {
+		// it's about to let go of its jobs, not take on more
+		return nil, nil
+	}
+
+	var candidates kbatch.JobList
+	if err := r.List(ctx, &candidates, client.InNamespace(cronJob.Namespace), client.MatchingLabels{
+		batch.ManagedByLabel:   batch.ManagedByValue,
+		batch.CronJobNameLabel: cronJob.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	var adopted []kbatch.Job
+	for i := range candidates.Items {
+		job := &candidates.Items[i]
+		if metav1.GetControllerOf(job) != nil || job.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := job.Annotations[scheduledTimeAnnotation]; !ok {
+			continue
+		}
+		if err := r.adoptJob(ctx, cronJob, job); err != nil {
+			return adopted, err
+		}
+		adopted = append(adopted, *job)
+	}
+	return adopted, nil
+}
+
+// adoptJob makes the CronJob the controller of a job that has none.  We
+// patch with an optimistic lock, so that we can't take a job out from under
+// anyone else who got there first.
+func (r *CronJobReconciler) adoptJob(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job) error {
+	patch := client.MergeFromWithOptions(job.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if err := ctrl.SetControllerReference(cronJob, job, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, job, patch); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(cronJob, corev1.EventTypeNormal, "AdoptedJob", "Adopted existing job %s", job.Name)
+	return nil
+}
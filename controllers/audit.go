@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/audit"
+)
+
+// audit records a scheduling decision about the CronJob's run at the given
+// time, if we have somewhere to record it.  Failing to record a decision
+// doesn't change it, so errors are only logged.
+func (r *CronJobReconciler) audit(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time, decision audit.Decision, reason string, jobs ...string) {
+	if r.Audit == nil {
+		return
+	}
+	record := audit.Record{
+		Time:          r.Now().UTC(),
+		Namespace:     cronJob.Namespace,
+		CronJob:       cronJob.Name,
+		ScheduledTime: scheduledTime.UTC(),
+		Decision:      decision,
+		Reason:        reason,
+		Jobs:          jobs,
+	}
+	if err := r.Audit.Record(ctx, record); err != nil {
+		r.Log.Error(err, "unable to record scheduling decision", "cronjob", cronJob.Name, "namespace", cronJob.Namespace, "decision", decision)
+	}
+}
+
+// jobNames lists the names of the given jobs.
+func jobNames(jobs []*kbatch.Job) []string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	return names
+}
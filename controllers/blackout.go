@@ -0,0 +1,81 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+// blackoutWindowFor returns the first of the given windows that contains t,
+// or nil if t falls outside all of them.
+func blackoutWindowFor(windows []batch.BlackoutWindow, t time.Time) (*batch.BlackoutWindow, error) {
+	for i := range windows {
+		inside, err := blackoutWindowContains(windows[i], t)
+		if err != nil {
+			return nil, err
+		}
+		if inside {
+			return &windows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// blackoutWindowContains checks whether t falls inside the window, treating
+// the window as half-open: a run at the start is skipped, one at the end is not.
+func blackoutWindowContains(window batch.BlackoutWindow, t time.Time) (bool, error) {
+	if start, err := time.Parse(time.RFC3339, window.Start); err == nil {
+		end, err := time.Parse(time.RFC3339, window.End)
+		if err != nil {
+			return false, fmt.Errorf("Unparseable blackout window end %q: %v", window.End, err)
+		}
+		return !t.Before(start) && t.Before(end), nil
+	}
+
+	startSched, err := schedule.Parse(window.Start)
+	if err != nil {
+		return false, fmt.Errorf("Unparseable blackout window start %q: %v", window.Start, err)
+	}
+	endSched, err := schedule.Parse(window.End)
+	if err != nil {
+		return false, fmt.Errorf("Unparseable blackout window end %q: %v", window.End, err)
+	}
+
+	// schedules can only look forward, but that's enough: we're inside a
+	// recurring window exactly when it closes again before it next opens.
+	nextEnd, nextStart := endSched.Next(t), startSched.Next(t)
+	if nextEnd.IsZero() {
+		return false, nil
+	}
+	return nextStart.IsZero() || nextEnd.Before(nextStart), nil
+}
+
+// blackoutWindowEnd returns when the window, which must contain t, closes.
+func blackoutWindowEnd(window batch.BlackoutWindow, t time.Time) (time.Time, error) {
+	if end, err := time.Parse(time.RFC3339, window.End); err == nil {
+		return end, nil
+	}
+	endSched, err := schedule.Parse(window.End)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Unparseable blackout window end %q: %v", window.End, err)
+	}
+	return endSched.Next(t), nil
+}
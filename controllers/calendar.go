@@ -0,0 +1,271 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/ical"
+)
+
+/*
+The people who decide when batch jobs run often keep that in a calendar outside
+Kubernetes altogether, in Google Calendar or Outlook say.  A CronJob can run on
+such a calendar instead of a schedule: every so often we read its iCalendar
+feed, and keep the start times of its events in status, where they stand in for
+the schedule.  Feeds are cached, so that CronJobs sharing a calendar don't each
+fetch it, and servers only send them again once they've changed.  A calendar we
+can't read isn't fatal: we carry on with the runs we read last, and say so.
+*/
+
+const (
+	// defaultCalendarRefreshInterval is how often we sync a calendar, unless
+	// it says otherwise.
+	defaultCalendarRefreshInterval = time.Hour
+
+	// calendarRetryInterval is how soon we try a calendar again after
+	// failing to read it, unless it's synced more often than that anyway.
+	calendarRetryInterval = 5 * time.Minute
+
+	// calendarFetchTimeout is how long we wait for a feed to download.
+	calendarFetchTimeout = 30 * time.Second
+
+	// staleCalendarFeedAge is how long we hang on to a feed that no CronJob
+	// has asked for.
+	staleCalendarFeedAge = 24 * time.Hour
+
+	// maxCalendarRuns is how many of a calendar's runs we keep in status.
+	maxCalendarRuns = 100
+)
+
+// calendarRefreshInterval is how often the calendar gets synced.
+func calendarRefreshInterval(ref *batch.CalendarRef) time.Duration {
+	if ref.RefreshIntervalSeconds != nil {
+		return time.Duration(*ref.RefreshIntervalSeconds) * time.Second
+	}
+	return defaultCalendarRefreshInterval
+}
+
+// calendarFeeds remembers the feeds we've fetched lately, by URL.
+type calendarFeeds struct {
+	fetcher ical.Fetcher
+
+	mu    sync.Mutex
+	feeds map[string]cachedFeed
+}
+
+type cachedFeed struct {
+	feed *ical.Feed
+	at   time.Time
+}
+
+// fetch returns the iCalendar data at the URL, as fetched within maxAge if
+// we've got it, or as fetched again if not.
+func (c *calendarFeeds) fetch(ctx context.Context, feedURL string, now time.Time, maxAge time.Duration) (string, error) {
+	c.mu.Lock()
+	cached, ok := c.feeds[feedURL]
+	c.mu.Unlock()
+	if ok && now.Sub(cached.at) < maxAge {
+		return cached.feed.Data, nil
+	}
+
+	// we don't hold on to the lock while fetching: at worst, a feed gets
+	// fetched twice
+	fetchCtx, cancel := context.WithTimeout(ctx, calendarFetchTimeout)
+	defer cancel()
+	feed, err := c.fetcher.Fetch(fetchCtx, feedURL, cached.feed)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.feeds == nil {
+		c.feeds = make(map[string]cachedFeed)
+	}
+	for other, stale := range c.feeds {
+		if now.Sub(stale.at) > staleCalendarFeedAge {
+			delete(c.feeds, other)
+		}
+	}
+	c.feeds[feedURL] = cachedFeed{feed: feed, at: now}
+	return feed.Data, nil
+}
+
+// syncCalendar reads the CronJob's calendar into its status if it's due a
+// sync, describing how that went as a CalendarSynced condition.  It returns
+// when the next sync is due, or the zero time if the CronJob isn't on a
+// calendar.  Runs closer together than minInterval are left out.
+func (r *CronJobReconciler) syncCalendar(ctx context.Context, cronJob *batch.CronJob, minInterval time.Duration) time.Time {
+	ref := cronJob.Spec.CalendarRef
+	if ref == nil {
+		cronJob.Status.Calendar = nil
+		// our version of RemoveStatusCondition can't cope with there being
+		// no conditions at all
+		if meta.FindStatusCondition(cronJob.Status.Conditions, batch.CalendarSyncedCondition) != nil {
+			meta.RemoveStatusCondition(&cronJob.Status.Conditions, batch.CalendarSyncedCondition)
+		}
+		return time.Time{}
+	}
+	if cronJob.Status.Calendar == nil {
+		cronJob.Status.Calendar = &batch.CalendarStatus{}
+	}
+	status := cronJob.Status.Calendar
+
+	// a failed sync is retried sooner, and a changed spec may well point
+	// somewhere else, so it's read again straight away
+	refresh := calendarRefreshInterval(ref)
+	retry := refresh
+	if calendarRetryInterval < retry {
+		retry = calendarRetryInterval
+	}
+	if status.LastAttemptTime != nil && cronJob.Generation == cronJob.Status.ObservedGeneration {
+		wait := refresh
+		if !meta.IsStatusConditionTrue(cronJob.Status.Conditions, batch.CalendarSyncedCondition) {
+			wait = retry
+		}
+		if due := status.LastAttemptTime.Add(wait); r.Now().Before(due) {
+			return due
+		}
+	}
+
+	now := r.Now()
+	status.LastAttemptTime = &metav1.Time{Time: now}
+	data, err := r.readCalendar(ctx, cronJob, refresh)
+	if err != nil {
+		r.calendarSyncFailed(cronJob, "ReadFailed", err)
+		return now.Add(retry)
+	}
+	events, err := ical.Parse(data)
+	if err != nil {
+		r.calendarSyncFailed(cronJob, "InvalidCalendar", err)
+		return now.Add(retry)
+	}
+
+	status.LastSyncTime = &metav1.Time{Time: now}
+	status.RunTimes = calendarRuns(cronJob, events, minInterval)
+	r.setCondition(cronJob, metav1.Condition{
+		Type:    batch.CalendarSyncedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Synced",
+		Message: fmt.Sprintf("The calendar has %d runs to come", len(status.RunTimes)),
+	})
+	return now.Add(refresh)
+}
+
+// calendarSyncFailed says that the CronJob's calendar couldn't be read, and
+// that it's running on the runs read from it last.
+func (r *CronJobReconciler) calendarSyncFailed(cronJob *batch.CronJob, reason string, err error) {
+	message := fmt.Sprintf("Unable to read the calendar, so running on the %d runs read from it before: %v", len(cronJob.Status.Calendar.RunTimes), err)
+	if cronJob.Status.Calendar.LastSyncTime == nil {
+		message = fmt.Sprintf("Unable to read the calendar, which has never been read, so nothing will run: %v", err)
+	}
+	if r.setCondition(cronJob, metav1.Condition{
+		Type:    batch.CalendarSyncedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}) {
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "CalendarSyncFailed", "%s", message)
+	}
+}
+
+// readCalendar returns the iCalendar data of the CronJob's calendar, which
+// its Secret may hold itself, rather than the URL to fetch it from.  A feed
+// fetched within maxAge will do.
+func (r *CronJobReconciler) readCalendar(ctx context.Context, cronJob *batch.CronJob, maxAge time.Duration) (string, error) {
+	ref := cronJob.Spec.CalendarRef
+	feedURL := ref.URL
+	if secretRef := ref.SecretKeyRef; secretRef != nil {
+		optional := secretRef.Optional != nil && *secretRef.Optional
+		var secret corev1.Secret
+		if err := r.apiReader.Get(ctx, types.NamespacedName{Namespace: cronJob.Namespace, Name: secretRef.Name}, &secret); err != nil {
+			if apierrors.IsNotFound(err) && optional {
+				return "", nil
+			}
+			return "", fmt.Errorf("unable to get Secret %s: %w", secretRef.Name, err)
+		}
+		value, ok := secret.Data[secretRef.Key]
+		if !ok {
+			if optional {
+				return "", nil
+			}
+			return "", fmt.Errorf("Secret %s has no key %s", secretRef.Name, secretRef.Key)
+		}
+		feedURL = strings.TrimSpace(string(value))
+		if !strings.HasPrefix(feedURL, "http://") && !strings.HasPrefix(feedURL, "https://") {
+			return string(value), nil
+		}
+	}
+	return r.calendars.fetch(ctx, feedURL, r.Now(), maxAge)
+}
+
+// calendarRuns lists the start times of the calendar's events since the
+// CronJob's last scheduled run, or its creation, oldest first and up to
+// maxCalendarRuns of them.  All-day events start at midnight in the CronJob's
+// time zone, rather than in UTC.  A run that starts less than minInterval
+// after the one before it, the last scheduled one included, is left out.
+func calendarRuns(cronJob *batch.CronJob, events []ical.Event, minInterval time.Duration) []metav1.Time {
+	since := cronJob.ObjectMeta.CreationTimestamp.Time
+	var previous time.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		since = cronJob.Status.LastScheduleTime.Time
+		previous = since
+	}
+	loc := scheduleLocation(cronJob)
+
+	var starts []time.Time
+	for _, event := range events {
+		start := event.Start
+		if event.AllDay {
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		}
+		if start.After(since) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	var runs []metav1.Time
+	for _, start := range starts {
+		// events starting together still only start the one run
+		if len(runs) > 0 && runs[len(runs)-1].Time.Equal(start) {
+			continue
+		}
+		if !previous.IsZero() && start.Sub(previous) < minInterval {
+			continue
+		}
+		if len(runs) == maxCalendarRuns {
+			break
+		}
+		runs = append(runs, metav1.NewTime(start))
+		previous = start
+	}
+	return runs
+}
@@ -0,0 +1,269 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+A ClusterCronJob doesn't schedule anything itself: it stamps out an ordinary
+CronJob in each namespace it selects, and leaves the rest to the CronJob
+controller.  That way, every namespace gets its own jobs, history and status,
+with nothing to reimplement.
+*/
+
+// ClusterCronJobReconciler reconciles a ClusterCronJob object
+type ClusterCronJobReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=clustercronjobs,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=clustercronjobs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+func (r *ClusterCronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("clustercronjob", req.Name)
+
+	var clusterCronJob batch.ClusterCronJob
+	if err := r.Get(ctx, req.NamespacedName, &clusterCronJob); err != nil {
+		// our CronJobs are garbage collected along with us
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	namespaces, err := r.selectedNamespaces(ctx, &clusterCronJob)
+	if err != nil {
+		log.Error(err, "unable to list selected namespaces")
+		return ctrl.Result{}, err
+	}
+
+	var cronJobs batch.CronJobList
+	if err := r.List(ctx, &cronJobs, client.MatchingLabels{batch.ClusterCronJobNameLabel: clusterCronJob.Name}); err != nil {
+		log.Error(err, "unable to list CronJobs")
+		return ctrl.Result{}, err
+	}
+
+	/*
+		Namespaces that are no longer selected lose their CronJob, along with its
+		jobs.  We only ever touch CronJobs that we control: the label alone could
+		have been copied by hand.
+	*/
+	for i := range cronJobs.Items {
+		cronJob := &cronJobs.Items[i]
+		if namespaces[cronJob.Namespace] || !metav1.IsControlledBy(cronJob, &clusterCronJob) {
+			continue
+		}
+		if err := r.Delete(ctx, cronJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to delete CronJob from deselected namespace", "namespace", cronJob.Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	var synced, conflicts []string
+	for namespace := range namespaces {
+		owned, err := r.syncCronJob(ctx, &clusterCronJob, namespace)
+		if err != nil {
+			log.Error(err, "unable to sync CronJob", "namespace", namespace)
+			return ctrl.Result{}, err
+		}
+		if owned {
+			synced = append(synced, namespace)
+		} else {
+			conflicts = append(conflicts, namespace)
+		}
+	}
+	sort.Strings(synced)
+	sort.Strings(conflicts)
+
+	// we only send what's changed, so that we don't trip over anyone else
+	// touching the ClusterCronJob while we were busy with its namespaces
+	patch := client.MergeFrom(clusterCronJob.DeepCopy())
+	clusterCronJob.Status.ObservedGeneration = clusterCronJob.Generation
+	clusterCronJob.Status.Namespaces = synced
+	clusterCronJob.Status.NamespaceCount = int32(len(synced))
+	meta.SetStatusCondition(&clusterCronJob.Status.Conditions, syncedCondition(&clusterCronJob, conflicts))
+	if err := r.Status().Patch(ctx, &clusterCronJob, patch); err != nil {
+		log.Error(err, "unable to update ClusterCronJob status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// selectedNamespaces returns the set of namespaces the ClusterCronJob should
+// run in.  Namespaces on their way out are left alone, since nothing new can
+// be created in them.
+func (r *ClusterCronJobReconciler) selectedNamespaces(ctx context.Context, clusterCronJob *batch.ClusterCronJob) (map[string]bool, error) {
+	selector := labels.Everything()
+	if clusterCronJob.Spec.NamespaceSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(clusterCronJob.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := r.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, namespace := range namespaces.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		selected[namespace.Name] = true
+	}
+	return selected, nil
+}
+
+// syncCronJob creates or updates the ClusterCronJob's CronJob in the given
+// namespace.  It returns false, without touching anything, if a CronJob of
+// the same name already exists there that isn't ours.
+func (r *ClusterCronJobReconciler) syncCronJob(ctx context.Context, clusterCronJob *batch.ClusterCronJob, namespace string) (bool, error) {
+	desired := desiredCronJob(clusterCronJob, namespace)
+	if err := ctrl.SetControllerReference(clusterCronJob, desired, r.Scheme); err != nil {
+		return false, err
+	}
+
+	var existing batch.CronJob
+	err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: desired.Name}, &existing)
+	if apierrors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil && !apierrors.IsAlreadyExists(err) {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !metav1.IsControlledBy(&existing, clusterCronJob) {
+		return false, nil
+	}
+
+	// the CronJob webhook fills in defaults, so compare against those to
+	// avoid rewriting an unchanged CronJob on every pass
+	desired.Default()
+	if equality.Semantic.DeepEqual(existing.Spec, desired.Spec) &&
+		equality.Semantic.DeepDerivative(desired.Labels, existing.Labels) &&
+		equality.Semantic.DeepDerivative(desired.Annotations, existing.Annotations) {
+		return true, nil
+	}
+
+	existing.Spec = desired.Spec
+	if existing.Labels == nil {
+		existing.Labels = make(map[string]string)
+	}
+	for k, v := range desired.Labels {
+		existing.Labels[k] = v
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = make(map[string]string)
+	}
+	for k, v := range desired.Annotations {
+		existing.Annotations[k] = v
+	}
+	return true, r.Update(ctx, &existing)
+}
+
+// desiredCronJob builds the CronJob that the ClusterCronJob's template
+// describes for the given namespace.
+func desiredCronJob(clusterCronJob *batch.ClusterCronJob, namespace string) *batch.CronJob {
+	template := clusterCronJob.Spec.Template.DeepCopy()
+	cronJob := &batch.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        clusterCronJob.Name,
+			Namespace:   namespace,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	if cronJob.Labels == nil {
+		cronJob.Labels = make(map[string]string)
+	}
+	cronJob.Labels[batch.ClusterCronJobNameLabel] = clusterCronJob.Name
+	return cronJob
+}
+
+// syncedCondition describes whether the ClusterCronJob has a CronJob in every
+// namespace it selects.
+func syncedCondition(clusterCronJob *batch.ClusterCronJob, conflicts []string) metav1.Condition {
+	if len(conflicts) > 0 {
+		return metav1.Condition{
+			Type:               batch.SyncedCondition,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: clusterCronJob.Generation,
+			Reason:             "NameConflict",
+			Message:            fmt.Sprintf("A CronJob named %s that isn't ours already exists in: %s", clusterCronJob.Name, strings.Join(conflicts, ", ")),
+		}
+	}
+	return metav1.Condition{
+		Type:               batch.SyncedCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: clusterCronJob.Generation,
+		Reason:             "Synced",
+		Message:            fmt.Sprintf("CronJobs are up to date in %d namespaces", len(clusterCronJob.Status.Namespaces)),
+	}
+}
+
+// allClusterCronJobs requeues every ClusterCronJob, for when a namespace
+// changes in a way that might change which of them select it.
+func (r *ClusterCronJobReconciler) allClusterCronJobs(obj client.Object) []reconcile.Request {
+	var clusterCronJobs batch.ClusterCronJobList
+	if err := r.List(context.Background(), &clusterCronJobs); err != nil {
+		r.Log.Error(err, "unable to list ClusterCronJobs")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(clusterCronJobs.Items))
+	for i, clusterCronJob := range clusterCronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: clusterCronJob.Name}}
+	}
+	return requests
+}
+
+func (r *ClusterCronJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batch.ClusterCronJob{}).
+		Owns(&batch.CronJob{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.allClusterCronJobs)).
+		Complete(r)
+}
@@ -0,0 +1,61 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// concurrencyBlocked checks whether the CronJob's concurrency settings keep a
+// new run from starting alongside the given active jobs.
+func concurrencyBlocked(cronJob *batch.CronJob, activeJobs []*kbatch.Job) bool {
+	if cronJob.Spec.ConcurrencyPolicy == batch.ReplaceConcurrent {
+		return false
+	}
+	if cronJob.Spec.MaxConcurrentRuns != nil {
+		return len(activeJobs) >= int(*cronJob.Spec.MaxConcurrentRuns)
+	}
+	switch cronJob.Spec.ConcurrencyPolicy {
+	case batch.ForbidConcurrent, batch.QueueConcurrent:
+		return len(activeJobs) > 0
+	default:
+		return false
+	}
+}
+
+// queuedRuns returns the missed runs that are still waiting to be started
+// under the Queue concurrency policy, oldest first.  Runs are only ever
+// skipped from the head of the queue, so those up to the last one skipped
+// there are done with, even once they've dropped out of skippedRuns.
+func queuedRuns(cronJob *batch.CronJob, missedRuns []time.Time) []metav1.Time {
+	var queue []metav1.Time
+	for _, missedRun := range missedRuns {
+		if upTo := cronJob.Status.QueueSkippedUpTo; upTo != nil && !missedRun.After(upTo.Time) {
+			continue
+		}
+		if skippedRunAt(cronJob, missedRun) != nil {
+			continue
+		}
+		queue = append(queue, metav1.NewTime(missedRun))
+	}
+	return queue
+}
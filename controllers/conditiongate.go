@@ -0,0 +1,137 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/promquery"
+)
+
+/*
+Some jobs are only worth running when there's something for them to do: a
+reindex once enough changes have queued up, a compaction once there's enough to
+compact.  The numbers that say so are usually in Prometheus already, so a
+CronJob's condition gate asks it, when each scheduled run comes due, and the run
+only goes ahead if the answer passes the threshold.  Otherwise it's skipped, or
+held back and asked about again, as the gate says.
+
+Queries run with the controller's access to Prometheus, which sees every
+namespace's metrics, so the gate's condition only says whether it was met, and
+never what the query returned.
+*/
+
+const (
+	// defaultGateRecheckInterval is how often a deferred run checks its
+	// gate again, unless the gate says otherwise.
+	defaultGateRecheckInterval = time.Minute
+
+	// gateQueryTimeout is how long we wait for Prometheus to answer.
+	gateQueryTimeout = 10 * time.Second
+)
+
+// gateRecheckInterval is how often a run the CronJob's gate deferred checks
+// it again.
+func gateRecheckInterval(gate *batch.ConditionGate) time.Duration {
+	if gate.RecheckIntervalSeconds != nil {
+		return time.Duration(*gate.RecheckIntervalSeconds) * time.Second
+	}
+	return defaultGateRecheckInterval
+}
+
+// checkConditionGate evaluates the CronJob's condition gate, describing how
+// it came out as a ConditionGateMet condition.  Only a met gate gives a true
+// condition: a query that fails counts as the gate not being met.
+func (r *CronJobReconciler) checkConditionGate(ctx context.Context, cronJob *batch.CronJob) metav1.Condition {
+	gate := cronJob.Spec.ConditionGate
+	condition := metav1.Condition{
+		Type:   batch.ConditionGateMetCondition,
+		Status: metav1.ConditionFalse,
+	}
+	if r.Prometheus == nil {
+		condition.Reason = "NoPrometheus"
+		condition.Message = "The controller has no Prometheus to query: run it with --prometheus-url"
+		return condition
+	}
+	threshold, err := gate.ThresholdValue()
+	if err != nil {
+		condition.Reason = "InvalidThreshold"
+		condition.Message = fmt.Sprintf("Invalid threshold %q: %v", gate.Threshold, err)
+		return condition
+	}
+	operator := gate.Operator
+	if operator == "" {
+		operator = batch.GreaterThan
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, gateQueryTimeout)
+	defer cancel()
+	value, err := r.Prometheus.Query(queryCtx, gate.Query, r.Now())
+	switch {
+	case errors.Is(err, promquery.ErrNoData):
+		condition.Reason = "NoData"
+		condition.Message = "The query returned no data"
+		return condition
+	case err != nil:
+		condition.Reason = "QueryFailed"
+		condition.Message = fmt.Sprintf("Unable to evaluate the query: %v", err)
+		return condition
+	}
+
+	met, err := compareGate(value, operator, threshold)
+	if err != nil {
+		condition.Reason = "InvalidOperator"
+		condition.Message = err.Error()
+		return condition
+	}
+	wants := fmt.Sprintf("%s %s", operator, strconv.FormatFloat(threshold, 'g', -1, 64))
+	if met {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ConditionMet"
+		condition.Message = fmt.Sprintf("The query returned a value that is %s", wants)
+	} else {
+		condition.Reason = "ConditionNotMet"
+		condition.Message = fmt.Sprintf("The query didn't return a value that is %s", wants)
+	}
+	return condition
+}
+
+// compareGate compares the query's result with the threshold.
+func compareGate(value float64, operator batch.GateOperator, threshold float64) (bool, error) {
+	switch operator {
+	case batch.GreaterThan:
+		return value > threshold, nil
+	case batch.GreaterThanOrEqual:
+		return value >= threshold, nil
+	case batch.LessThan:
+		return value < threshold, nil
+	case batch.LessThanOrEqual:
+		return value <= threshold, nil
+	case batch.EqualTo:
+		return value == threshold, nil
+	case batch.NotEqualTo:
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", operator)
+}
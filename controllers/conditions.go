@@ -0,0 +1,163 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// activeCondition describes whether any of the given jobs are running, or
+// whether there are only jobs waiting in a queue.
+func activeCondition(activeJobs []*kbatch.Job, queued int) metav1.Condition {
+	if len(activeJobs) == 0 && queued > 0 {
+		return metav1.Condition{
+			Type:    batch.ActiveCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "JobsQueued",
+			Message: fmt.Sprintf("%d jobs are waiting to be admitted from their queue", queued),
+		}
+	}
+	if len(activeJobs) == 0 {
+		return metav1.Condition{
+			Type:    batch.ActiveCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoActiveJobs",
+			Message: "No jobs are running",
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.ActiveCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "JobsRunning",
+		Message: fmt.Sprintf("%d jobs are running", len(activeJobs)),
+	}
+}
+
+// scheduleValidCondition describes whether the CronJob's schedule parses, and
+// its time zone, if any, exists.
+func scheduleValidCondition(cronJob *batch.CronJob) metav1.Condition {
+	if _, err := parseSchedule(cronJob); err != nil {
+		return metav1.Condition{
+			Type:    batch.ScheduleValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidSchedule",
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.ScheduleValidCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ValidSchedule",
+		Message: "The schedule is valid",
+	}
+}
+
+// failedRunsExceededCondition describes whether the given number of
+// consecutive failed runs is as many as the CronJob's failure policy allows.
+func failedRunsExceededCondition(cronJob *batch.CronJob, consecutiveFailures int32) metav1.Condition {
+	limit := int32(1)
+	if policy := cronJob.Spec.FailurePolicy; policy != nil && policy.SuspendAfterConsecutiveFailures != nil {
+		limit = *policy.SuspendAfterConsecutiveFailures
+	}
+	if consecutiveFailures < limit {
+		return metav1.Condition{
+			Type:    batch.FailedRunsExceededCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "WithinLimit",
+			Message: fmt.Sprintf("%d runs in a row have failed", consecutiveFailures),
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.FailedRunsExceededCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ConsecutiveFailures",
+		Message: fmt.Sprintf("%d runs in a row have failed", consecutiveFailures),
+	}
+}
+
+// replacingCondition describes whether jobs replaced under the Replace
+// concurrency policy are still being deleted.  Only the controller sets it,
+// when it replaces jobs; it clears once none of the given jobs are going away
+// any more.
+func replacingCondition(cronJob *batch.CronJob, jobs []kbatch.Job) metav1.Condition {
+	if c := meta.FindStatusCondition(cronJob.Status.Conditions, batch.ReplacingCondition); c != nil && c.Status == metav1.ConditionTrue {
+		for i := range jobs {
+			if jobs[i].DeletionTimestamp != nil {
+				return *c
+			}
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.ReplacingCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NotReplacing",
+		Message: "No replaced jobs are being deleted",
+	}
+}
+
+// replacedJobsCondition describes the controller having just deleted the
+// given number of jobs to replace them.
+func replacedJobsCondition(replaced int) metav1.Condition {
+	return metav1.Condition{
+		Type:    batch.ReplacingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "DeletingReplacedJobs",
+		Message: fmt.Sprintf("%d replaced jobs are being deleted", replaced),
+	}
+}
+
+// readyCondition sums up the CronJob's other conditions: it's ready so long
+// as its schedule is valid and its runs aren't failing, or haven't failed
+// often enough to get it suspended.
+func readyCondition(cronJob *batch.CronJob) metav1.Condition {
+	if c := meta.FindStatusCondition(cronJob.Status.Conditions, batch.ScheduleValidCondition); c != nil && c.Status == metav1.ConditionFalse {
+		return metav1.Condition{
+			Type:    batch.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  c.Reason,
+			Message: c.Message,
+		}
+	}
+	if c := meta.FindStatusCondition(cronJob.Status.Conditions, batch.FailedRunsExceededCondition); c != nil && c.Status == metav1.ConditionTrue {
+		return metav1.Condition{
+			Type:    batch.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "FailedRunsExceeded",
+			Message: c.Message,
+		}
+	}
+	if c := meta.FindStatusCondition(cronJob.Status.Conditions, batch.AutoSuspendedCondition); c != nil && c.Status == metav1.ConditionTrue {
+		return metav1.Condition{
+			Type:    batch.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AutoSuspended",
+			Message: c.Message,
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.ReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Ready",
+		Message: "The schedule is valid and runs are succeeding",
+	}
+}
@@ -23,20 +23,39 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/robfig/cron"
 	kbatch "k8s.io/api/batch/v1"
+	kbatchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	ref "k8s.io/client-go/tools/reference"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	batch "tutorial.kubebuilder.io/project/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/audit"
+	"kubebuilder-tutorial/pkg/clock"
+	"kubebuilder-tutorial/pkg/egress"
+	"kubebuilder-tutorial/pkg/externalsecrets"
+	"kubebuilder-tutorial/pkg/imagesig"
+	"kubebuilder-tutorial/pkg/logarchive"
+	"kubebuilder-tutorial/pkg/promquery"
+	"kubebuilder-tutorial/pkg/runadmission"
 )
 
 /*
@@ -46,24 +65,111 @@ Next, we'll need a Clock, which will allow us to fake timing in our tests.
 // CronJobReconciler reconciles a CronJob object
 type CronJobReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 	Clock
+
+	// Audit, if set, is where we record every scheduling decision we make.
+	Audit audit.Sink
+
+	// Notifier, if set, delivers the notifications CronJobs ask for.
+	Notifier Notifier
+
+	// LogStore, if set, is where we archive the logs of jobs before cleaning
+	// them up, fetching them through PodLogs.
+	LogStore logarchive.Store
+	PodLogs  corev1client.PodsGetter
+
+	// Workers tunes how many CronJobs we reconcile at once, and how quickly.
+	Workers WorkerOptions
+
+	// Shard is the share of namespaces whose CronJobs we look after.
+	Shard Shard
+
+	// Namespaces narrows down the namespaces whose CronJobs we look after.
+	Namespaces NamespaceFilter
+
+	// Namespaced says we only have permissions within the namespaces we
+	// watch, so we mustn't read or watch anything cluster-scoped.
+	Namespaced bool
+
+	// Tenants holds tenants to their budgets of active jobs.  No budgets if
+	// nil.
+	Tenants *TenantScheduler
+
+	// EventTriggers runs CronJobs' event triggers.  They don't run if nil.
+	EventTriggers *EventTriggers
+
+	// Impersonator, if set, has jobs created as the service accounts they run
+	// as, rather than as the controller.
+	Impersonator *Impersonator
+
+	// ImageVerifier checks image signatures for CronJobPolicies that require
+	// them.  Defaults to checking cosign signatures in the images' registries.
+	ImageVerifier imagesig.Verifier
+	// verifiedImages remembers the images we've checked lately.
+	verifiedImages imageVerifications
+
+	// RunAdmission asks CronJobPolicies' endpoints whether runs may go ahead.
+	RunAdmission runadmission.Client
+
+	// SecretProviders are the stores CronJobs can have secrets injected from.
+	SecretProviders externalsecrets.Providers
+
+	// SecretPaths are the paths in those stores each namespace's CronJobs may
+	// have secrets injected from.
+	SecretPaths externalsecrets.PathAllowlist
+
+	// Prometheus, if set, is what CronJobs' condition gates query.  Gates
+	// are never met if nil.
+	Prometheus *promquery.Client
+
+	// MeshQuitImage is the image of the container that has a service mesh
+	// sidecar quit once a job's own containers have finished.  Defaults to
+	// busybox.
+	MeshQuitImage string
+
+	// ClockSkewTolerance is how far ahead of their scheduled time runs may
+	// start, unless a CronJob says otherwise.
+	ClockSkewTolerance time.Duration
+
+	// Features turns our optional behaviours on and off.
+	Features FeatureGates
+
+	// Live, if set, holds the settings that may change while we're running.
+	Live *LiveSettings
+
+	// hotObjects keeps any one CronJob from being reconciled too often.
+	hotObjects *objectRateLimiter
+
+	// workloads reads the workloads of our backends from the manager's
+	// cache, which, unlike our client, has them indexed by owner.
+	workloads client.Reader
+
+	// apiReader reads straight from the API server: Secrets, rather than have
+	// our client cache every Secret in the cluster, and objects we need the
+	// latest of, where the cache may lag behind.
+	apiReader client.Reader
+
+	// InternalHosts are the hosts on the cluster's network that CronJobs'
+	// calendars may be fetched from.  Anywhere else, they have to be at
+	// public addresses.
+	InternalHosts egress.Allowlist
+
+	// calendars holds the calendar feeds we've fetched lately.
+	calendars calendarFeeds
 }
 
 /*
 We'll mock out the clock to make it easier to jump around in time while testing,
-the "real" clock just calls `time.Now`.
+the "real" clock just calls `time.Now`.  Both live in `pkg/clock`, so that tests
+outside this package can jump around in time too.
 */
-type realClock struct{}
 
-func (_ realClock) Now() time.Time { return time.Now() }
-
-// clock knows how to get the current time.
-// It can be used to fake out timing for testing.
-type Clock interface {
-	Now() time.Time
-}
+// Clock knows how to get the current time.
+// It can be used to fake out timing for testing, with a clock.FakeClock.
+type Clock = clock.Clock
 
 // +kubebuilder:docs-gen:collapse=Clock
 
@@ -75,8 +181,31 @@ a couple more [markers](/reference/markers/rbac.md).
 
 //+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobruns,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobruns/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobtriggers,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobtriggers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=cronjobgroups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=maintenancewindows,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=scheduleoverrides,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=jobtemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=holidaycalendars,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=runquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=runquotas/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=create;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+//+kubebuilder:rbac:groups="",resources=groups,verbs=impersonate
+//+kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns,verbs=get;list;watch;create;patch;delete
 
 /*
 Now, we get to the heart of the controller -- the reconciler logic.
@@ -85,9 +214,32 @@ var (
 	scheduledTimeAnnotation = "batch.tutorial.kubebuilder.io/scheduled-at"
 )
 
-func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := r.Log.WithValues("cronjob", req.NamespacedName)
 
+	// the context we're given is cancelled when the manager shuts down; we
+	// pass it (or our own, shorter-lived one) to everything that might block
+	if r.Workers.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Workers.ReconcileTimeout)
+		defer cancel()
+	}
+
+	// each reconcile is a trace of its own, with a span for each step that
+	// talks to the API server
+	ctx, span := startSpan(ctx, "Reconcile", req.Namespace, req.Name)
+	defer func() { endSpan(span, err) }()
+
+	// changes to cluster-scoped objects bring us CronJobs from every shard,
+	// and from namespaces we leave alone
+	if !r.Shard.Owns(req.Namespace) || !r.Namespaces.Allows(req.Namespace) {
+		return ctrl.Result{}, nil
+	}
+	if delay := r.hotObjects.delay(req.NamespacedName); delay > 0 {
+		log.V(1).Info("reconciling too often, putting it off", "delay", delay)
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
 	/*
 		### 1: Load the CronJob by name
 
@@ -101,50 +253,73 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		Many client methods also take variadic options at the end.
 	*/
 	var cronJob batch.CronJob
-	if err := r.Get(ctx, req.NamespacedName, &cronJob); err != nil {
+	fetchCtx, fetchSpan := startSpan(ctx, "FetchCronJob", req.Namespace, req.Name)
+	err = r.Get(fetchCtx, req.NamespacedName, &cronJob)
+	endSpan(fetchSpan, client.IgnoreNotFound(err))
+	if err != nil {
 		log.Error(err, "unable to fetch CronJob")
+		if apierrors.IsNotFound(err) {
+			forgetMetrics(req.NamespacedName)
+			r.hotObjects.forget(req.NamespacedName)
+			r.Tenants.Forget(req.NamespacedName, r.Now())
+			r.EventTriggers.Forget(req.NamespacedName)
+		}
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// however we leave the CronJob, that's what its health is based on
+	defer cronJobHealth.observe(&cronJob)
+	// what the status was when we started, so we can tell if it needs writing
+	observedStatus := cronJob.Status.DeepCopy()
 
 	/*
-		### 2: List all active jobs, and update the status
-
-		To fully update our status, we'll need to list all child jobs in this namespace that belong to this CronJob.
-		Similarly to Get, we can use the List method to list the child jobs.  Notice that we use variadic options to
-		set the namespace and field match (which is actually an index lookup that we set up below).
+		Owner references take care of everything we create in the cluster, but not
+		of what we put elsewhere, like logs archived outside it.  For that, we hold
+		on to the CronJob with a finalizer until we've cleaned up after it.
 	*/
-	var childJobs kbatch.JobList
-	if err := r.List(ctx, &childJobs, client.InNamespace(req.Namespace), client.MatchingFields{jobOwnerKey: req.Name}); err != nil {
-		log.Error(err, "unable to list child Jobs")
+	if cronJob.DeletionTimestamp != nil {
+		if err := r.finalize(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to clean up after deleted CronJob")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.ensureFinalizer(ctx, &cronJob); err != nil {
+		log.Error(err, "unable to add cleanup finalizer")
 		return ctrl.Result{}, err
 	}
 
 	/*
+		### 2: List all active jobs, and update the status
+
+		To fully update our status, we'll need to list all child jobs in this namespace that belong to this CronJob.
+		CronJobs that run often and keep a long history can own a great many jobs, so rather than list them all
+		at once, we'll go through them a page at a time, and sort each job out as its page arrives.
 
 		<aside class="note">
 
 		<h1>What is this index about?</h1>
 
-		<p>The reconciler fetches all jobs owned by the cronjob for the status. As our number of cronjobs increases,
-		looking these up can become quite slow as we have to filter through all of them. For a more efficient lookup,
-		these jobs will be indexed locally on the controller's name. A jobOwnerKey field is added to the
-		cached job objects. This key references the owning controller and functions as the index. Later in this
-		document we will configure the manager to actually index this field.</p>
+		<p>Elsewhere, the reconciler fetches all jobs owned by some other cronjob, such as one it depends on. As our
+		number of cronjobs increases, looking these up can become quite slow as we have to filter through all of them.
+		For a more efficient lookup, these jobs will be indexed locally on the controller's name. A jobOwnerKey field
+		is added to the cached job objects. This key references the owning controller and functions as the index.
+		Later in this document we will configure the manager to actually index this field.</p>
 
 		</aside>
 
-		Once we have all the jobs we own, we'll split them into active, successful,
-		and failed jobs, keeping track of the most recent run so that we can record it
-		in status.  Remember, status should be able to be reconstituted from the state
-		of the world, so it's generally not a good idea to read from the status of the
-		root object.  Instead, you should reconstruct it every run.  That's what we'll
-		do here.
+		We'll split our jobs into active, successful, and failed jobs, keeping track of the
+		most recent run so that we can record it in status.  Remember, status should be able
+		to be reconstituted from the state of the world, so it's generally not a good idea to
+		read from the status of the root object.  Instead, you should reconstruct it every
+		run.  That's what we'll do here.
 
 		We can check if a job is "finished" and whether it succeeded or failed using status
-		conditions.  We'll put that logic in a helper to make our code cleaner.
+		conditions.  We'll put that logic in a helper to make our code cleaner.  Pods, Argo
+		Workflows and Tekton PipelineRuns that runs created instead of jobs come to us as
+		views, with conditions of the same kind, so they're classified just the same.
 	*/
 
 	// find the active list of jobs
@@ -170,40 +345,31 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	// +kubebuilder:docs-gen:collapse=isJobFinished
 
 	/*
-		We'll use a helper to extract the scheduled time from the annotation that
-		we added during job creation.
+		We'll use a helper, getScheduledTimeForJob, to extract the scheduled time from
+		the annotation that we added during job creation.  Jobs are sorted by where they
+		are in the list, since its items move as it grows, and only picked out once it's
+		complete.
 	*/
-	getScheduledTimeForJob := func(job *kbatch.Job) (*time.Time, error) {
-		timeRaw := job.Annotations[scheduledTimeAnnotation]
-		if len(timeRaw) == 0 {
-			return nil, nil
-		}
-
-		timeParsed, err := time.Parse(time.RFC3339, timeRaw)
-		if err != nil {
-			return nil, err
-		}
-		return &timeParsed, nil
-	}
-	// +kubebuilder:docs-gen:collapse=getScheduledTimeForJob
-
-	for i, job := range childJobs.Items {
-		_, finishedType := isJobFinished(&job)
+	var childJobs kbatch.JobList
+	var activeAt, successfulAt, failedAt []int
+	classifyJob := func(i int) {
+		job := &childJobs.Items[i]
+		_, finishedType := isJobFinished(job)
 		switch finishedType {
 		case "": // ongoing
-			activeJobs = append(activeJobs, &childJobs.Items[i])
+			activeAt = append(activeAt, i)
 		case kbatch.JobFailed:
-			failedJobs = append(failedJobs, &childJobs.Items[i])
+			failedAt = append(failedAt, i)
 		case kbatch.JobComplete:
-			successfulJobs = append(successfulJobs, &childJobs.Items[i])
+			successfulAt = append(successfulAt, i)
 		}
 
 		// We'll store the launch time in an annotation, so we'll reconstitute that from
 		// the active jobs themselves.
-		scheduledTimeForJob, err := getScheduledTimeForJob(&job)
+		scheduledTimeForJob, err := getScheduledTimeForJob(job)
 		if err != nil {
-			log.Error(err, "unable to parse schedule time for child job", "job", &job)
-			continue
+			log.Error(err, "unable to parse schedule time for child job", "job", job)
+			return
 		}
 		if scheduledTimeForJob != nil {
 			if mostRecentTime == nil {
@@ -214,28 +380,192 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	if mostRecentTime != nil {
+	listCtx, listSpan := startSpan(ctx, "ListChildJobs", req.Namespace, req.Name)
+	err = r.listChildJobs(listCtx, &cronJob, &childJobs, classifyJob)
+	endSpan(listSpan, err)
+	if err != nil {
+		log.Error(err, "unable to list child Jobs")
+		return ctrl.Result{}, err
+	}
+	// we only list jobs we already control
+	if r.Features.Enabled(AdoptOrphanedJobs) {
+		adopted, err := r.adoptOrphanedJobs(ctx, &cronJob)
+		if err != nil {
+			log.Error(err, "unable to adopt orphaned Jobs")
+			return ctrl.Result{}, err
+		}
+		for i := range adopted {
+			childJobs.Items = append(childJobs.Items, adopted[i])
+			classifyJob(len(childJobs.Items) - 1)
+		}
+	}
+	// runs that created something other than a Job show up as the jobs they
+	// stand in for
+	workloads, err := r.listWorkloads(ctx, req.Namespace, req.Name)
+	if err != nil {
+		log.Error(err, "unable to list child workloads")
+		return ctrl.Result{}, err
+	}
+	for i := range workloads {
+		childJobs.Items = append(childJobs.Items, workloads[i])
+		classifyJob(len(childJobs.Items) - 1)
+	}
+	for _, i := range activeAt {
+		activeJobs = append(activeJobs, &childJobs.Items[i])
+	}
+	for _, i := range successfulAt {
+		successfulJobs = append(successfulJobs, &childJobs.Items[i])
+	}
+	for _, i := range failedAt {
+		failedJobs = append(failedJobs, &childJobs.Items[i])
+	}
+
+	// this only ever moves forward, so that it survives our jobs being cleaned
+	// up, and so that a CronJob imported from a built-in one doesn't repeat runs
+	// its original made
+	if last := cronJob.Status.LastScheduleTime; mostRecentTime != nil && (last == nil || last.Time.Before(*mostRecentTime)) {
 		cronJob.Status.LastScheduleTime = &metav1.Time{Time: *mostRecentTime}
-	} else {
-		cronJob.Status.LastScheduleTime = nil
 	}
+	/*
+		We'll also note when a run last succeeded, and when it was scheduled, which is what
+		CronJobs that depend on us check once our jobs are gone.  Unlike the rest of our
+		status, we can't always reconstitute these from our jobs, since we clean up old
+		ones, so we only ever move them forward.
+	*/
+	for _, job := range successfulJobs {
+		if !isFinalStep(&cronJob, job) || job.Status.CompletionTime == nil {
+			continue
+		}
+		if last := cronJob.Status.LastSuccessfulTime; last == nil || last.Before(job.Status.CompletionTime) {
+			cronJob.Status.LastSuccessfulTime = job.Status.CompletionTime.DeepCopy()
+		}
+		if scheduledTime, err := getScheduledTimeForJob(job); err == nil && scheduledTime != nil {
+			if last := cronJob.Status.LastSuccessfulScheduleTime; last == nil || last.Time.Before(*scheduledTime) {
+				cronJob.Status.LastSuccessfulScheduleTime = &metav1.Time{Time: *scheduledTime}
+			}
+		}
+	}
+	if last := cronJob.Status.LastSuccessfulTime; last != nil {
+		lastSuccessAge.set(req.NamespacedName, last.Time)
+	}
+
+	// secrets injected into jobs only live as long as the jobs run
+	if err := r.cleanUpInjectedSecrets(ctx, append(append([]*kbatch.Job(nil), successfulJobs...), failedJobs...)); err != nil {
+		log.Error(err, "unable to clean up injected secrets")
+	}
+
+	/*
+		Jobs that were active last time around but have finished since are worth a note
+		on the CronJob, so that `kubectl describe` tells the story of each run.
+	*/
+	wasActive := make(map[types.UID]bool)
+	for _, jobRef := range append(append([]corev1.ObjectReference(nil), cronJob.Status.Active...), cronJob.Status.QueuedJobs...) {
+		wasActive[jobRef.UID] = true
+	}
+	for _, job := range successfulJobs {
+		if wasActive[job.UID] {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "SawCompletedJob", "Saw completed job: %s, status: %s", job.Name, kbatch.JobComplete)
+		}
+	}
+	for _, job := range failedJobs {
+		if wasActive[job.UID] {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "SawCompletedJob", "Saw completed job: %s, status: %s", job.Name, kbatch.JobFailed)
+		}
+	}
+
+	/*
+		Jobs still waiting for Kueue to admit them aren't running yet, so they're
+		listed apart from the active ones.
+	*/
 	cronJob.Status.Active = nil
+	cronJob.Status.QueuedJobs = nil
+	running := runningJobs(activeJobs)
 	for _, activeJob := range activeJobs {
 		jobRef, err := ref.GetReference(r.Scheme, activeJob)
 		if err != nil {
 			log.Error(err, "unable to make reference to active job", "job", activeJob)
 			continue
 		}
-		cronJob.Status.Active = append(cronJob.Status.Active, *jobRef)
+		if isQueuedJob(activeJob) {
+			cronJob.Status.QueuedJobs = append(cronJob.Status.QueuedJobs, *jobRef)
+		} else {
+			cronJob.Status.Active = append(cronJob.Status.Active, *jobRef)
+		}
+	}
+	cronJob.Status.ActiveCount = int32(len(running))
+	activeJobsGauge.WithLabelValues(cronJob.Namespace, cronJob.Name).Set(float64(len(running)))
+	r.Tenants.Observe(&cronJob, len(running), r.Now())
+
+	/*
+		We'll also sum up some of this in standard status conditions, so that tooling can tell
+		how we're doing without having to understand the rest of our status.  A schedule we
+		can't parse is worth calling out straight away, since nothing will run until it's fixed.
+		The same goes for breaking one of the CronJobPolicies in our namespace.
+
+		Which schedule we're on depends on whether there's a ScheduleOverride in effect,
+		so we'll work that out first.
+	*/
+	policies, err := batch.PoliciesFor(ctx, r, cronJob.Namespace)
+	if err != nil {
+		log.Error(err, "unable to list CronJobPolicies")
+		return ctrl.Result{}, err
+	}
+	override, err := r.activeScheduleOverride(ctx, &cronJob, r.Now())
+	if err != nil {
+		log.Error(err, "unable to list ScheduleOverrides")
+		return ctrl.Result{}, err
+	}
+	if setScheduleOverride(&cronJob, override, r.Now()) {
+		if override != nil {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "ScheduleOverridden", "Running on schedule %q from ScheduleOverride %s until %s", override.Spec.Schedule, override.Name, override.Spec.ExpiresAt.Format(time.RFC3339))
+		} else {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "ScheduleRestored", "Back on schedule %q", cronJob.Spec.Schedule)
+		}
+	}
+
+	// CronJobs on a calendar need its latest runs before anything works out
+	// when they're due
+	nextCalendarSync := r.syncCalendar(ctx, &cronJob, minRunInterval(policies))
+
+	r.setCondition(&cronJob, activeCondition(running, len(activeJobs)-len(running)))
+	// a schedule we can't parse means the CronJob will never run, which
+	// deserves more than a log line of ours
+	if validCondition := scheduleValidCondition(&cronJob); r.setCondition(&cronJob, validCondition) && validCondition.Status == metav1.ConditionFalse {
+		r.Recorder.Eventf(&cronJob, corev1.EventTypeWarning, "InvalidSchedule", "Will not run: %s", validCondition.Message)
+	}
+	r.setCondition(&cronJob, replacingCondition(&cronJob, childJobs.Items))
+	r.setCondition(&cronJob, pausedCondition(&cronJob))
+	if setPausedSince(&cronJob, r.Now()) {
+		if cronJob.Spec.Paused {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "Paused", "Paused: %s", meta.FindStatusCondition(cronJob.Status.Conditions, batch.PausedCondition).Message)
+		} else {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "Unpaused", "No longer paused")
+		}
+	}
+	r.setCondition(&cronJob, policyCompliantCondition(&cronJob, policies, r.Now()))
+	if podSecurity := r.podSecurityCondition(ctx, &cronJob); r.setCondition(&cronJob, podSecurity) && podSecurity.Status == metav1.ConditionFalse {
+		r.Recorder.Eventf(&cronJob, corev1.EventTypeWarning, "PodSecurityViolation", "Pods may be rejected: %s", podSecurity.Message)
+	}
+
+	// a deferral is over once its maintenance window closes, whether or not
+	// the run made it
+	if deferred := cronJob.Status.DeferredRun; deferred != nil && !r.Now().Before(deferred.Until.Time) {
+		cronJob.Status.DeferredRun = nil
 	}
 
+	/*
+		Finally, we'll note which generation of the spec we've seen, so that clients (and
+		GitOps tools in particular) can tell whether we've caught up with their latest change.
+	*/
+	cronJob.Status.ObservedGeneration = cronJob.Generation
+
 	/*
 		Here, we'll log how many jobs we observed at a slightly higher logging level,
 		for debugging.  Notice how instead of using a format string, we use a fixed message,
 		and attach key-value pairs with the extra information.  This makes it easier to
 		filter and query log lines.
 	*/
-	log.V(1).Info("job count", "active jobs", len(activeJobs), "successful jobs", len(successfulJobs), "failed jobs", len(failedJobs))
+	log.V(1).Info("job count", "active jobs", len(running), "queued jobs", len(activeJobs)-len(running), "successful jobs", len(successfulJobs), "failed jobs", len(failedJobs))
 
 	/*
 		Using the date we've gathered, we'll update the status of our CRD.
@@ -245,10 +575,16 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 		The status subresource ignores changes to spec, so it's less likely to conflict
 		with any other updates, and can have separate permissions.
+
+		Most of the time, nothing's changed since we last looked, and there's no point
+		writing the same status back: every write is another watch event for everyone
+		watching CronJobs, ourselves included.
 	*/
-	if err := r.Status().Update(ctx, &cronJob); err != nil {
-		log.Error(err, "unable to update CronJob status")
-		return ctrl.Result{}, err
+	if !equality.Semantic.DeepEqual(&cronJob.Status, observedStatus) {
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
 	}
 
 	/*
@@ -258,8 +594,60 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		### 3: Clean up old jobs according to the history limit
 
 		First, we'll try to clean up old jobs, so that we don't leave too many lying
-		around.
+		around.  If we've got a retry policy, we'll hang on to failed jobs that are
+		still due a retry, since they're our only record of what needs retrying.  The
+		same goes for finished steps of runs with hooks that are due their next step.
+	*/
+	retries := pendingRetries(cronJob.Spec.RetryPolicy, childJobs.Items)
+	hookSteps := pendingHookSteps(cronJob.Spec.Hooks, childJobs.Items)
+
+	/*
+		Along the way, we'll run into things that need our attention at some later time,
+		besides the next scheduled run.  We'll keep track of the earliest of those, so
+		that we can make sure to requeue in time for it.
 	*/
+	var nextWakeup *time.Time
+	wakeUpAt := func(t time.Time) {
+		if nextWakeup == nil || t.Before(*nextWakeup) {
+			nextWakeup = &t
+		}
+	}
+	wakeupResult := func() ctrl.Result {
+		if nextWakeup == nil {
+			return ctrl.Result{}
+		}
+		return ctrl.Result{RequeueAfter: requeueDelay(req.NamespacedName, r.Now(), *nextWakeup, requeueJitter(&cronJob))}
+	}
+
+	// we go back to our own schedule when an override expires, without
+	// anything else changing
+	if override := cronJob.Status.ScheduleOverride; override != nil {
+		wakeUpAt(override.ExpiresAt.Time)
+	}
+	// ...and read our calendar again every so often, in the same way
+	if !nextCalendarSync.IsZero() {
+		wakeUpAt(nextCalendarSync)
+	}
+
+	/*
+		If the CronJob asks for it, we'll archive the logs of the jobs we're about to
+		delete, since they go with the jobs' pods.  We'd rather keep a job past our
+		history limit than lose its logs, so if archiving fails, we hang on to it until
+		the next time around.  Where the logs went ends up in the run history.
+	*/
+	archivedLogs := make(map[string]string)
+	archiveLogs := func(job *kbatch.Job, failed bool) bool {
+		if !r.wantsLogsArchived(&cronJob, failed) {
+			return true
+		}
+		location, err := r.archiveJobLogs(ctx, &cronJob, job)
+		if err != nil {
+			log.Error(err, "unable to archive logs of old job, keeping it for now", "job", job)
+			return false
+		}
+		archivedLogs[job.Name] = location
+		return true
+	}
 
 	// NB: deleting these is "best effort" -- if we fail on a particular one,
 	// we won't requeue just to finish the deleting.
@@ -274,7 +662,14 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			if int32(i) >= int32(len(failedJobs))-*cronJob.Spec.FailedJobsHistoryLimit {
 				break
 			}
-			if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			if isAwaitingRetry(retries, job) {
+				// we'll still need this one to know what to retry
+				continue
+			}
+			if !archiveLogs(job, true) {
+				continue
+			}
+			if err := r.Delete(ctx, workloadOf(job), client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
 				log.Error(err, "unable to delete old failed job", "job", job)
 			} else {
 				log.V(0).Info("deleted old failed job", "job", job)
@@ -293,7 +688,14 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			if int32(i) >= int32(len(successfulJobs))-*cronJob.Spec.SuccessfulJobsHistoryLimit {
 				break
 			}
-			if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); (err) != nil {
+			if isAwaitingHookStep(hookSteps, job) {
+				// we'll still need this one to know what to run next
+				continue
+			}
+			if !archiveLogs(job, false) {
+				continue
+			}
+			if err := r.Delete(ctx, workloadOf(job), client.PropagationPolicy(metav1.DeletePropagationBackground)); (err) != nil {
 				log.Error(err, "unable to delete old successful job", "job", job)
 			} else {
 				log.V(0).Info("deleted old successful job", "job", job)
@@ -301,112 +703,655 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
+	/*
+		We'll also keep an eye on how long our active jobs have been running, since a
+		hung job would otherwise block a Forbid concurrency policy forever.  Rather than
+		deleting jobs that run past our run deadline, we lower their own active deadline
+		to match, so that the job controller tears down their pods and marks them failed
+		with `DeadlineExceeded` -- that way they count as failed runs like any other.
+		Jobs that never even started don't have anything for an active deadline to
+		measure from, so those we just delete.  Workloads other than jobs are failed
+		whichever way their backend knows how.  Jobs waiting in a Kueue queue haven't
+		started running, so their time isn't up yet.
+	*/
+	if cronJob.Spec.RunDeadlineSeconds != nil {
+		runDeadlineSeconds := *cronJob.Spec.RunDeadlineSeconds
+		runDeadline := time.Duration(runDeadlineSeconds) * time.Second
+		for _, activeJob := range running {
+			startedAt := activeJob.CreationTimestamp.Time
+			if activeJob.Status.StartTime != nil {
+				startedAt = activeJob.Status.StartTime.Time
+			}
+			if deadline := startedAt.Add(runDeadline); deadline.After(r.Now()) {
+				wakeUpAt(deadline)
+				continue
+			}
+			if ads := activeJob.Spec.ActiveDeadlineSeconds; ads != nil && *ads <= runDeadlineSeconds {
+				// we've already done this, the job controller just hasn't caught up
+				continue
+			}
+
+			var err error
+			switch {
+			case isWorkload(activeJob):
+				err = r.Patch(ctx, workloadOf(activeJob), backendOf(activeJob).expire(runDeadlineSeconds))
+			case activeJob.Status.StartTime == nil:
+				err = r.Delete(ctx, activeJob, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			default:
+				patch := client.MergeFrom(activeJob.DeepCopy())
+				activeJob.Spec.ActiveDeadlineSeconds = &runDeadlineSeconds
+				err = r.Patch(ctx, activeJob, patch)
+			}
+			if client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to enforce run deadline", "job", activeJob)
+				continue
+			}
+			log.V(0).Info("job exceeded run deadline, failing it", "job", activeJob)
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeWarning, "RunDeadlineExceeded", "Job %s ran for longer than %s", activeJob.Name, runDeadline)
+		}
+	}
+
+	/*
+		We'll also keep count of how many runs in a row have failed, so that if we've got a
+		failure policy, we can suspend ourselves instead of failing again every interval.
+		Each finished run only gets counted once: we remember in status how far we've got.
+		We keep running totals of failures and of the jobs that made up each run too, for
+		the benefit of alerting rules, since the jobs themselves get cleaned up.
+	*/
+	var countedUpTo *time.Time
+	if cronJob.Status.LastFinishedRunTime != nil {
+		countedUpTo = &cronJob.Status.LastFinishedRunTime.Time
+	}
+	finishedRuns := finishedRunsSince(&cronJob, childJobs.Items, retries, hookSteps, countedUpTo)
+	consecutiveFailures, totalFailures, newFailures := cronJob.Status.ConsecutiveFailures, cronJob.Status.TotalFailures, false
+	jobsSucceeded, jobsFailed := cronJob.Status.JobsSucceeded, cronJob.Status.JobsFailed
+	for _, run := range finishedRuns {
+		jobsSucceeded += run.succeededJobs
+		jobsFailed += run.failedJobs
+		if run.succeeded {
+			consecutiveFailures = 0
+		} else {
+			consecutiveFailures++
+			totalFailures++
+			newFailures = true
+		}
+	}
+
+	suspended := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+	autoSuspendedCondition := metav1.Condition{
+		Type:    batch.AutoSuspendedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NotSuspended",
+		Message: "The CronJob is not suspended",
+	}
+	if policy := cronJob.Spec.FailurePolicy; policy != nil && policy.SuspendAfterConsecutiveFailures != nil &&
+		newFailures && !suspended && consecutiveFailures >= *policy.SuspendAfterConsecutiveFailures {
+		// suspend first, so that if that fails, we'll count these failures again next time
+		suspended = true
+		cronJob.Spec.Suspend = &suspended
+		if err := r.Update(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to suspend CronJob")
+			return ctrl.Result{}, err
+		}
+		log.V(0).Info("suspended CronJob after consecutive failures", "failures", consecutiveFailures)
+		r.Recorder.Eventf(&cronJob, corev1.EventTypeWarning, "AutoSuspended", "Suspended after %d runs in a row failed", consecutiveFailures)
+
+		autoSuspendedCondition.Status = metav1.ConditionTrue
+		autoSuspendedCondition.Reason = "ConsecutiveFailures"
+		autoSuspendedCondition.Message = fmt.Sprintf("Suspended after %d runs in a row failed. Investigate, then set .spec.suspend to false to resume.", consecutiveFailures)
+		// start counting afresh once we're resumed
+		consecutiveFailures = 0
+	}
+
+	/*
+		Each run also has a CronJobRun of its own, which we'll bring up to date with its jobs.
+		The same finished runs go into a short history in status, which outlives the jobs
+		themselves once our history limits have had them cleaned up.
+	*/
+	if err := r.syncRuns(ctx, &cronJob, childJobs.Items, retries, hookSteps); err != nil {
+		log.Error(err, "unable to update CronJobRuns")
+		return ctrl.Result{}, err
+	}
+
+	wasAutoSuspended := meta.IsStatusConditionTrue(cronJob.Status.Conditions, batch.AutoSuspendedCondition)
+	statusChanged := len(finishedRuns) > 0
+	var droppedRuns []batch.RunRecord
+	if len(finishedRuns) > 0 {
+		cronJob.Status.ConsecutiveFailures = consecutiveFailures
+		cronJob.Status.TotalFailures = totalFailures
+		cronJob.Status.JobsSucceeded = jobsSucceeded
+		cronJob.Status.JobsFailed = jobsFailed
+		cronJob.Status.LastFinishedRunTime = &metav1.Time{Time: finishedRuns[len(finishedRuns)-1].scheduledTime}
+		droppedRuns = recordRecentRuns(&cronJob, finishedRuns)
+		cronJob.Status.LastRunResult = cronJob.Status.RecentRuns[len(cronJob.Status.RecentRuns)-1].Result
+	}
+	unrecordedLogs, logsRecorded := recordLogLocations(&cronJob, archivedLogs)
+	if logsRecorded {
+		statusChanged = true
+	}
+	if autoSuspendedCondition.Status == metav1.ConditionTrue || !suspended {
+		// leave the condition be while suspended, so it's clear why
+		statusChanged = r.setCondition(&cronJob, autoSuspendedCondition) || statusChanged
+	}
+	statusChanged = r.setCondition(&cronJob, failedRunsExceededCondition(&cronJob, consecutiveFailures)) || statusChanged
+	statusChanged = r.setCondition(&cronJob, readyCondition(&cronJob)) || statusChanged
+	if statusChanged {
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
+	}
+	// archives go once status has stopped pointing at them
+	r.pruneArchivedLogs(ctx, &cronJob, droppedRuns, unrecordedLogs)
+	// only once they're in status, so that we don't count or notify about
+	// them twice
+	observeRunDurations(req.NamespacedName, finishedRuns)
+	for _, run := range finishedRuns {
+		if !run.succeeded {
+			r.notify(&cronJob, batch.NotifyRunFailed, run.scheduledTime, run.jobName, "")
+		}
+	}
+	if wasAutoSuspended && !suspended {
+		r.notify(&cronJob, batch.NotifyResumed, time.Time{}, "", "")
+	}
+
 	/* ### 4: Check if we're suspended
 
 	If this object is suspended, we don't want to run any jobs, so we'll stop now.
 	This is useful if something's broken with the job we're running and we want to
 	pause runs to investigate or putz with the cluster, without deleting the object.
+
+	Pausing the CronJob, rather than suspending it, has the same effect here.
+
+	We'll still record the runs we're not starting, so that it's clear later on why
+	they never happened, which means waking up for each of them as it comes due.
+	A suspension with an end time works the same way, except that we know exactly
+	when to come back and pick up the schedule again.  So does suspending one of the
+	CronJobGroups we belong to.  The exception is a CronJob whose resume policy is to
+	run what it missed: its runs aren't skipped, just held over until it's resumed.
 	*/
 
-	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
-		log.V(1).Info("cronjob suspended, skipping")
-		return ctrl.Result{}, nil
+	groups, err := r.groupsFor(ctx, &cronJob)
+	if err != nil {
+		log.Error(err, "unable to list CronJobGroups")
+		return ctrl.Result{}, err
+	}
+	suspendedBy := suspendingGroup(groups)
+	suspendedUntilLater := cronJob.Spec.SuspendUntil != nil && r.Now().Before(cronJob.Spec.SuspendUntil.Time)
+	r.EventTriggers.Sync(&cronJob, !cronJob.Spec.Paused && !suspended && suspendedBy == nil && !suspendedUntilLater)
+	if cronJob.Spec.Paused || suspended || suspendedBy != nil || suspendedUntilLater {
+		skipReason := batch.SuspendedSkip
+		if cronJob.Spec.Paused {
+			log.V(1).Info("cronjob paused, skipping", "reason", cronJob.Spec.PauseReason)
+			skipReason = batch.PausedSkip
+		} else if suspended {
+			log.V(1).Info("cronjob suspended, skipping")
+		} else if suspendedBy != nil {
+			log.V(1).Info("cronjob suspended by CronJobGroup, skipping", "group", suspendedBy.Name)
+		} else {
+			log.V(1).Info("cronjob suspended until later, skipping", "suspend until", cronJob.Spec.SuspendUntil.Time)
+			wakeUpAt(cronJob.Spec.SuspendUntil.Time)
+		}
+
+		missedRun, nextRun, err := latestMissedRun(&cronJob, r.Now())
+		if err != nil {
+			// we'll report this once we're resumed
+			return wakeupResult(), nil
+		}
+
+		// nothing runs until we're resumed, which we only know the time of
+		// for a suspension with an end
+		var nextScheduled time.Time
+		if !cronJob.Spec.Paused && !suspended && suspendedBy == nil && cronJob.Spec.SchedulesRuns() {
+			if sched, err := parseSchedule(&cronJob); err == nil {
+				nextScheduled = sched.Next(cronJob.Spec.SuspendUntil.Time)
+			}
+		}
+		newlySuspended := noteSuspended(&cronJob, r.Now())
+		if setNextScheduleTime(&cronJob, nextScheduled) || newlySuspended {
+			if err := r.updateStatus(ctx, &cronJob); err != nil {
+				log.Error(err, "unable to update CronJob status")
+				return ctrl.Result{}, err
+			}
+		}
+		// runs held over for when we're resumed aren't skipped yet
+		if !missedRun.IsZero() && cronJob.Spec.ResumePolicy != batch.RunMissedOnResume {
+			if err := r.recordSkippedRun(ctx, &cronJob, missedRun, skipReason); err != nil {
+				log.Error(err, "unable to record skipped run")
+				return ctrl.Result{}, err
+			}
+		}
+		if !nextRun.IsZero() {
+			wakeUpAt(nextRun)
+		}
+		return wakeupResult(), nil
+	}
+	if noteResumed(&cronJob, r.Now()) {
+		log.V(1).Info("cronjob resumed", "resume policy", cronJob.Spec.ResumePolicy)
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
 	}
 
 	/*
-		### 5: Get the next scheduled run
+		### 5: Run any one-off or triggered runs that have come due
 
-		If we're not paused, we'll need to calculate the next scheduled run, and whether
-		or not we've got a run that we haven't processed yet.
+		Besides the cron schedule, users can ask for runs at explicit times using
+		`.spec.runAt`.  Each of those gets exactly one job, so we remember in status
+		which ones we've already started.  We'll also keep track of the earliest one
+		that's still to come, so that we wake up in time for it.
 	*/
 
 	/*
-		We'll calculate the next scheduled time using our helpful cron library.
-		We'll start calculating appropriate times from our last run, or the creation
-		of the CronJob if we can't find a last run.
+		However a run comes about, it counts against its tenant's budget, so
+		one-off, triggered and retried runs wait for their tenant just as scheduled
+		ones do: for room in the tenant's budget, or under the total, or for their
+		turn.  We're woken up when it's our turn, but check back now and then
+		anyway, to keep our place in line.
+	*/
+	tenantBlocked := func(scheduledTime time.Time) (bool, error) {
+		block := r.Tenants.Admit(&cronJob, r.Now())
+		if block == nil {
+			if cond := meta.FindStatusCondition(cronJob.Status.Conditions, batch.QuotaExceededCondition); cond != nil && isTenantBlockReason(cond.Reason) {
+				// persisted along with whatever the run changes
+				r.setCondition(&cronJob, quotaExceededCondition(nil, scheduledTime))
+			}
+			return false, nil
+		}
+		if r.setCondition(&cronJob, tenantBlockedCondition(block, scheduledTime)) {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "TenantBudgetExceeded", "Deferred run scheduled at %s, since the controller allows %s", scheduledTime.Format(time.RFC3339), block.limit)
+			if err := r.updateStatus(ctx, &cronJob); err != nil {
+				log.Error(err, "unable to update CronJob status")
+				return true, err
+			}
+		}
+		log.V(1).Info("deferring run for tenant", "tenant", block.tenant, "reason", block.reason, "scheduled time", scheduledTime)
+		wakeUpAt(r.Now().Add(block.recheck))
+		return true, nil
+	}
 
-		If there are too many missed runs and we don't have any deadlines set, we'll
-		bail so that we don't cause issues on controller restarts or wedges.
+	dueOneOffRuns, nextOneOffRun := pendingOneOffRuns(&cronJob, r.Now())
+	if nextOneOffRun != nil {
+		wakeUpAt(*nextOneOffRun)
+	}
+	consumedRunAt := consumedOneOffRuns(&cronJob)
+	for _, runAt := range dueOneOffRuns {
+		// one-off runs were asked for explicitly, so rather than skipping them
+		// when we forbid concurrent runs, we'll wait for the active jobs to finish
+		if concurrencyBlocked(&cronJob, activeJobs) {
+			log.V(1).Info("concurrency policy blocks one-off run, waiting", "run at", runAt)
+			break
+		}
+		if blocked, err := tenantBlocked(runAt); err != nil {
+			return ctrl.Result{}, err
+		} else if blocked {
+			break
+		}
 
-		Otherwise, we'll just return the missed runs (of which we'll just use the latest),
-		and the next run, so that we can know when it's time to reconcile again.
-	*/
-	getNextSchedule := func(cronJob *batch.CronJob, now time.Time) (lastMissed time.Time, next time.Time, err error) {
-		sched, err := cron.ParseStandard(cronJob.Spec.Schedule)
+		job, err := r.constructRunJob(ctx, &cronJob, runAt)
 		if err != nil {
-			return time.Time{}, time.Time{}, fmt.Errorf("Unparseable schedule %q: %v", cronJob.Spec.Schedule, err)
+			log.Error(err, "unable to construct job from template")
+			break
+		}
+		// a job for this exact time may already exist from the regular schedule,
+		// in which case it counts as our one-off run too
+		err = r.createRunJob(ctx, &cronJob, job)
+		if reason, skip := runSkipReason(err); skip {
+			log.V(1).Info("not starting one-off run, skipping", "run at", runAt, "reason", err.Error())
+			if err := r.recordSkippedRun(ctx, &cronJob, runAt, reason); err != nil {
+				log.Error(err, "unable to record skipped run")
+				return ctrl.Result{}, err
+			}
+			consumedRunAt = append(consumedRunAt, metav1.NewTime(runAt))
+			continue
+		} else if err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create Job for one-off run", "job", job)
+			return ctrl.Result{}, err
 		}
+		log.V(1).Info("created Job for one-off run", "job", job)
 
-		// for optimization purposes, cheat a bit and start from our last observed run time
-		// we could reconstitute this here, but there's not much point, since we've
-		// just updated it.
-		var earliestTime time.Time
-		if cronJob.Status.LastScheduleTime != nil {
-			earliestTime = cronJob.Status.LastScheduleTime.Time
-		} else {
-			earliestTime = cronJob.ObjectMeta.CreationTimestamp.Time
+		activeJobs = append(activeJobs, job)
+		consumedRunAt = append(consumedRunAt, metav1.NewTime(runAt))
+	}
+	if !equalTimes(consumedRunAt, cronJob.Status.ConsumedRunAt) {
+		cronJob.Status.ConsumedRunAt = consumedRunAt
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
 		}
-		if cronJob.Spec.StartingDeadlineSeconds != nil {
-			// controller is not going to schedule anything below this point
-			schedulingDeadline := now.Add(-time.Second * time.Duration(*cronJob.Spec.StartingDeadlineSeconds))
+	}
 
-			if schedulingDeadline.After(earliestTime) {
-				earliestTime = schedulingDeadline
+	/*
+		Runs can also be triggered by hand, either by creating a CronJobTrigger, or by
+		setting the trigger-at annotation to the time the run should happen (usually
+		"now").  Creating triggers can be allowed separately from changing CronJobs, so
+		firing a run doesn't take the rights to reconfigure it, nor show up as a change
+		to its spec.  Triggered runs go through the same concurrency policy as scheduled
+		ones, and we note which triggers we've acted on -- in status of their own for
+		CronJobTriggers, and in ours for the annotation -- so that each one only fires
+		once.  Whoever triggered the run, as our webhook recorded them, goes on its job.
+		Runs fired by our event triggers come through CronJobTriggers too.  We clear
+		CronJobTriggers away a while after they fire, those of events sooner than
+		those people create.  A triggered run we won't start, say because its images
+		aren't signed, is skipped like a scheduled one, and its trigger counts as
+		acted on all the same.
+	*/
+	startTriggeredRun := func(triggerTime time.Time, trigger, initiator string) (job *kbatch.Job, skipped bool, err error) {
+		if cronJob.Spec.ConcurrencyPolicy == batch.ReplaceConcurrent && len(activeJobs) > 0 {
+			for _, activeJob := range activeJobs {
+				if err := r.Delete(ctx, workloadOf(activeJob), client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+					log.Error(err, "unable to delete active job", "job", activeJob)
+					return nil, false, err
+				}
 			}
+			// persisted along with the new job's count below
+			r.setCondition(&cronJob, replacedJobsCondition(len(activeJobs)))
+			r.audit(ctx, &cronJob, triggerTime, audit.Replaced, "", jobNames(activeJobs)...)
+			activeJobs = nil
 		}
-		if earliestTime.After(now) {
-			return time.Time{}, sched.Next(now), nil
+
+		job, err = r.constructRunJob(ctx, &cronJob, triggerTime)
+		if err != nil {
+			log.Error(err, "unable to construct job from template")
+			return nil, false, nil
+		}
+		setRunTrigger(&cronJob, job, triggerTime, trigger)
+		if initiator != "" {
+			job.Annotations[triggeredByAnnotation] = initiator
+		}
+		err = r.createRunJob(ctx, &cronJob, job)
+		if reason, skip := runSkipReason(err); skip {
+			log.V(1).Info("not starting triggered run, skipping", "trigger time", triggerTime, "reason", err.Error())
+			if err := r.recordSkippedRun(ctx, &cronJob, triggerTime, reason); err != nil {
+				log.Error(err, "unable to record skipped run")
+				return nil, false, err
+			}
+			return nil, true, nil
+		} else if err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create Job for triggered run", "job", job)
+			return nil, false, err
 		}
+		log.V(1).Info("created Job for triggered run", "job", job)
+		activeJobs = append(activeJobs, job)
+		return job, false, nil
+	}
 
-		starts := 0
-		for t := sched.Next(earliestTime); !t.After(now); t = sched.Next(t) {
-			lastMissed = t
-			// An object might miss several starts. For example, if
-			// controller gets wedged on Friday at 5:01pm when everyone has
-			// gone home, and someone comes in on Tuesday AM and discovers
-			// the problem and restarts the controller, then all the hourly
-			// jobs, more than 80 of them for one hourly scheduledJob, should
-			// all start running with no further intervention (if the scheduledJob
-			// allows concurrency and late starts).
-			//
-			// However, if there is a bug somewhere, or incorrect clock
-			// on controller's server or apiservers (for setting creationTimestamp)
-			// then there could be so many missed start times (it could be off
-			// by decades or more), that it would eat up all the CPU and memory
-			// of this controller. In that case, we want to not try to list
-			// all the missed start times.
-			starts++
-			if starts > 100 {
-				// We can't get the most recent times so just return an empty slice
-				return time.Time{}, time.Time{}, fmt.Errorf("Too many missed start times (> 100). Set or decrease .spec.startingDeadlineSeconds or check clock skew.")
+	// the jobs we create from here on are counted in status all at once,
+	// after the last of them
+	jobsCreated := cronJob.Status.JobsCreated
+	triggers, err := r.pendingTriggers(ctx, &cronJob)
+	if err != nil {
+		log.Error(err, "unable to list CronJobTriggers")
+		return ctrl.Result{}, err
+	}
+	for i := range triggers {
+		trigger := &triggers[i]
+		if concurrencyBlocked(&cronJob, activeJobs) {
+			log.V(1).Info("concurrency policy blocks triggered run, waiting", "trigger", trigger.Name)
+			break
+		}
+		if blocked, err := tenantBlocked(trigger.CreationTimestamp.Time); err != nil {
+			return ctrl.Result{}, err
+		} else if blocked {
+			break
+		}
+		job, skipped, err := startTriggeredRun(trigger.CreationTimestamp.Time, trigger.Name, triggerInitiator(&cronJob, trigger))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if skipped {
+			if err := r.markTriggerSkipped(ctx, trigger, r.Now()); err != nil {
+				log.Error(err, "unable to update CronJobTrigger status", "trigger", trigger.Name)
+				return ctrl.Result{}, err
 			}
+			continue
+		}
+		if job == nil {
+			break
+		}
+		if err := r.markTriggerStarted(ctx, trigger, job.Name, r.Now()); err != nil {
+			log.Error(err, "unable to update CronJobTrigger status", "trigger", trigger.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	nextPrune, err := r.pruneTriggers(ctx, &cronJob)
+	if err != nil {
+		log.Error(err, "unable to clean up fired CronJobTriggers")
+		return ctrl.Result{}, err
+	}
+	if !nextPrune.IsZero() {
+		wakeUpAt(nextPrune)
+	}
+
+	triggerTime, err := getTriggerTime(&cronJob)
+	if err != nil {
+		log.Error(err, "unable to figure out manual trigger")
+	}
+	switch {
+	case triggerTime == nil:
+		// nothing to do
+	case triggerTime.After(r.Now()):
+		wakeUpAt(*triggerTime)
+	case concurrencyBlocked(&cronJob, activeJobs):
+		log.V(1).Info("concurrency policy blocks triggered run, waiting", "trigger time", *triggerTime)
+	default:
+		if blocked, err := tenantBlocked(*triggerTime); err != nil {
+			return ctrl.Result{}, err
+		} else if blocked {
+			break
+		}
+		job, skipped, err := startTriggeredRun(*triggerTime, "", cronJob.Annotations[triggeredByAnnotation])
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if job == nil && !skipped {
+			break
+		}
+		cronJob.Status.LastTriggerTime = &metav1.Time{Time: *triggerTime}
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
+		jobsCreated = cronJob.Status.JobsCreated
+	}
+
+	/*
+		Failed runs may be retried as a whole according to our retry policy, on top of
+		whatever retries the job does for its own pods.  Each retry gets its own job,
+		named after the run with a retry-index suffix, once the backoff has passed.  If
+		we won't start a retry, say because its images aren't signed, the run ends
+		with the attempt that failed.
+	*/
+	for _, retry := range retries {
+		if retry.retryAt.After(r.Now()) {
+			wakeUpAt(retry.retryAt)
+			continue
+		}
+		if concurrencyBlocked(&cronJob, activeJobs) {
+			log.V(1).Info("concurrency policy blocks retry, waiting", "failed job", retry.failedJob.Name)
+			break
+		}
+		if blocked, err := tenantBlocked(retry.scheduledTime); err != nil {
+			return ctrl.Result{}, err
+		} else if blocked {
+			break
+		}
+
+		job, err := r.constructRetryJob(ctx, &cronJob, retry)
+		if err != nil {
+			log.Error(err, "unable to construct job from template")
+			break
+		}
+		err = r.createRunJob(ctx, &cronJob, job)
+		if reason, skip := runSkipReason(err); skip {
+			log.V(1).Info("not starting retry, skipping", "failed job", retry.failedJob.Name, "reason", err.Error())
+			if err := r.skipNextStep(ctx, &cronJob, retry.failedJob, retry.scheduledTime, reason); err != nil {
+				log.Error(err, "unable to record skipped retry")
+				return ctrl.Result{}, err
+			}
+			continue
+		} else if err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create Job for retry", "job", job)
+			return ctrl.Result{}, err
+		}
+		log.V(1).Info("created Job to retry failed run", "job", job, "failed job", retry.failedJob.Name, "attempt", retry.attempt)
+
+		activeJobs = append(activeJobs, job)
+	}
+
+	/*
+		Runs with hooks are made up of several jobs, started one after the other: once a
+		run's pre-run hook has succeeded we start its main job, and once that's succeeded
+		we start its post-run hook.  If a step fails, or we won't start the next one,
+		the run stops there.  These steps belong to runs that have already started, so
+		the concurrency policy doesn't hold them up.
+	*/
+	for _, step := range hookSteps {
+		job, err := r.constructHookStep(ctx, &cronJob, step)
+		if err != nil {
+			log.Error(err, "unable to construct job from template")
+			break
+		}
+		err = r.createRunJob(ctx, &cronJob, job)
+		if reason, skip := runSkipReason(err); skip {
+			log.V(1).Info("not starting next step of run, skipping", "finished job", step.finishedJob.Name, "reason", err.Error())
+			if err := r.skipNextStep(ctx, &cronJob, step.finishedJob, step.scheduledTime, reason); err != nil {
+				log.Error(err, "unable to record skipped step")
+				return ctrl.Result{}, err
+			}
+			continue
+		} else if err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create Job for next step of run", "job", job)
+			return ctrl.Result{}, err
+		}
+		log.V(1).Info("created Job for next step of run", "job", job, "finished job", step.finishedJob.Name)
+
+		activeJobs = append(activeJobs, job)
+	}
+	if cronJob.Status.JobsCreated != jobsCreated {
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
 		}
-		return lastMissed, sched.Next(now), nil
 	}
-	// +kubebuilder:docs-gen:collapse=getNextSchedule
+
+	/*
+		### 6: Get the next scheduled run
+
+		If we're not paused, we'll need to calculate the next scheduled run, and whether
+		or not we've got a run that we haven't processed yet.
+	*/
+
+	/*
+		Holidays can move runs to the next business day, so if we've got a HolidayCalendar,
+		we'll need it before we can work out when our runs are.
+	*/
+	holidays, err := r.holidaysFor(ctx, &cronJob)
+	if err != nil {
+		log.Error(err, "unable to get holidays")
+		return ctrl.Result{}, err
+	}
+
+	/*
+		We'll calculate the next scheduled time with getNextSchedule, which uses our
+		helpful cron library.  It starts calculating appropriate times from our last run,
+		or the creation of the CronJob if we can't find a last run.
+
+		If there are too many missed runs and we don't have any deadlines set, it'll
+		stop counting them so that we don't cause issues on controller restarts or wedges,
+		and just jump ahead to the most recent one.
+
+		Otherwise, it just returns the missed runs (of which we'll usually just use the
+		latest), and the next run, so that we can know when it's time to reconcile again.
+	*/
 
 	// figure out the next times that we need to create
-	// jobs at (or anything we missed).
-	missedRun, nextRun, err := getNextSchedule(&cronJob, r.Now())
+	// jobs at (or anything we missed).  Runs due within our clock skew
+	// tolerance count as due already: our clock may well be the one that's
+	// behind.
+	skewTolerance := r.clockSkewTolerance(&cronJob)
+	missedRuns, nextRun, tooManyMissed, err := getNextSchedule(&cronJob, holidays, r.Now().Add(skewTolerance))
 	if err != nil {
 		log.Error(err, "unable to figure out CronJob schedule")
+		// the ScheduleValid condition already says why, but there's no next
+		// run to show any more
+		if setNextScheduleTime(&cronJob, time.Time{}) {
+			if err := r.updateStatus(ctx, &cronJob); err != nil {
+				log.Error(err, "unable to update CronJob status")
+				return ctrl.Result{}, err
+			}
+		}
 		// we don't really care about requeuing until we get an update that
 		// fixes the schedule, so don't return an error
 		return ctrl.Result{}, nil
 	}
+	var missedRun time.Time
+	if len(missedRuns) > 0 {
+		missedRun = missedRuns[len(missedRuns)-1]
+	}
+
+	/*
+		If we had to give up on listing all the missed runs, we'll say so in a status
+		condition -- it usually means something is off with the deadline or the clocks,
+		and that shouldn't only be visible in our logs.
+	*/
+	missedRunsCondition := metav1.Condition{
+		Type:    batch.MissedRunsExceededCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "WithinLimit",
+		Message: "The number of missed runs is within .spec.maxMissedRuns",
+	}
+	if tooManyMissed {
+		log.V(1).Info("too many missed start times, only considering the most recent")
+		missedRunsCondition.Status = metav1.ConditionTrue
+		missedRunsCondition.Reason = "TooManyMissedRuns"
+		missedRunsCondition.Message = "Too many missed start times, so only the most recent was considered. Set or decrease .spec.startingDeadlineSeconds or check clock skew."
+	}
+	// we also keep the next run in status, mostly for `kubectl get` to show
+	nextScheduleChanged := setNextScheduleTime(&cronJob, nextRun)
+	if r.setCondition(&cronJob, missedRunsCondition) || nextScheduleChanged {
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
+	}
 
 	/*
 		We'll prep our eventual request to requeue until the next job, and then figure
 		out if we actually need to run.
 	*/
-	scheduledResult := ctrl.Result{RequeueAfter: nextRun.Sub(r.Now())} // save this so we can re-use it elsewhere
+	if !nextRun.IsZero() {
+		wakeUpAt(nextRun.Add(-skewTolerance))
+	}
+	scheduledResult := wakeupResult() // save this so we can re-use it elsewhere
 	log = log.WithValues("now", r.Now(), "next run", nextRun)
 
 	/*
-		### 6: Run a new job if it's on schedule, not past the deadline, and not blocked by our concurrency policy
+		### 7: Run a new job if it's on schedule, not past the deadline, and not blocked by our concurrency policy
 
 		If we've missed a run, and we're still within the deadline to start it, we'll need to run a job.
+
+		With the Queue concurrency policy, though, we don't just consider the latest missed run.
+		Every missed run gets queued up in status instead, to be started in order, one at a time,
+		as the previous one finishes.  Runs that we've already skipped don't need queueing.
 	*/
+	var queue []metav1.Time
+	if cronJob.Spec.ConcurrencyPolicy == batch.QueueConcurrent {
+		queue = queuedRuns(&cronJob, missedRuns)
+		missedRun = time.Time{}
+		if len(queue) > 0 {
+			missedRun = queue[0].Time
+		}
+	}
+	if !equalTimes(queue, cronJob.Status.QueuedRuns) {
+		cronJob.Status.QueuedRuns = queue
+		if err := r.updateStatus(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to update CronJob status")
+			return ctrl.Result{}, err
+		}
+	}
+
 	if missedRun.IsZero() {
 		log.V(1).Info("no upcoming scheduled times, sleeping until next")
 		return scheduledResult, nil
@@ -416,98 +1361,314 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	log = log.WithValues("current run", missedRun)
 	tooLate := false
 	if cronJob.Spec.StartingDeadlineSeconds != nil {
-		tooLate = missedRun.Add(time.Duration(*cronJob.Spec.StartingDeadlineSeconds) * time.Second).Before(r.Now())
+		tooLate = startingDeadlineFrom(&cronJob, missedRun).Add(time.Duration(*cronJob.Spec.StartingDeadlineSeconds) * time.Second).Before(r.Now())
 	}
 	if tooLate {
 		log.V(1).Info("missed starting deadline for last run, sleeping till next")
-		// TODO(directxman12): events
+		newlyMissed := skippedRunAt(&cronJob, missedRun) == nil
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.DeadlineExceededSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		if newlyMissed {
+			missedSchedules.WithLabelValues(cronJob.Namespace, cronJob.Name).Inc()
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeWarning, "MissedSchedule", "Missed scheduled time to start a job: %s", missedRun.Format(time.RFC3339))
+			r.notify(&cronJob, batch.NotifyRunMissed, missedRun, "", "missed its starting deadline")
+		}
 		return scheduledResult, nil
 	}
 
 	/*
-		If we actually have to run a job, we'll need to either wait till existing ones finish,
-		replace the existing ones, or just add new ones.  If our information is out of date due
-		to cache delay, we'll get a requeue when we get up-to-date information.
+		Runs scheduled inside one of our blackout windows, or one forbidden by a policy,
+		are skipped outright.  Since no job will exist to tell the story later, we record
+		the skip in status.  Runs of a CronJob that breaks a policy are skipped the same way,
+		as are runs on a holiday, unless they've been moved to the next business day.
 	*/
-	// figure out how to run this job -- concurrency policy might forbid us from running
-	// multiple at the same time...
-	if cronJob.Spec.ConcurrencyPolicy == batch.ForbidConcurrent && len(activeJobs) > 0 {
-		log.V(1).Info("concurrency policy blocks concurrent runs, skipping", "num active", len(activeJobs))
+	blackoutWindow, err := blackoutWindowFor(blackoutWindows(&cronJob, policies), missedRun)
+	if err != nil {
+		log.Error(err, "unable to evaluate blackout windows")
+		// like an unparseable schedule, this needs a spec change to fix
+		return scheduledResult, nil
+	}
+	if blackoutWindow != nil {
+		log.V(1).Info("run falls inside blackout window, skipping", "window start", blackoutWindow.Start, "window end", blackoutWindow.End)
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.BlackoutWindowSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+	}
+	if cronJob.Spec.HolidayPolicy == batch.SkipHolidays && onHoliday(&cronJob, holidays, missedRun) {
+		log.V(1).Info("run falls on a holiday, skipping")
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.HolidaySkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+	}
+	if !meta.IsStatusConditionTrue(cronJob.Status.Conditions, batch.PolicyCompliantCondition) {
+		log.V(1).Info("CronJob breaks a CronJobPolicy, skipping")
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.PolicyViolationSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
 		return scheduledResult, nil
 	}
 
-	// ...or instruct us to replace existing ones...
-	if cronJob.Spec.ConcurrencyPolicy == batch.ReplaceConcurrent {
-		for _, activeJob := range activeJobs {
-			// we don't care if the job was already deleted
-			if err := r.Delete(ctx, activeJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
-				log.Error(err, "unable to delete active job", "job", activeJob)
+	/*
+		MaintenanceWindows are managed centrally rather than on each CronJob, and by
+		default they hold runs back until they close, rather than skipping them.  We
+		note the deferral in status, and wake up again once the window closes.  A
+		deferred run still has to start within its starting deadline, like any other.
+	*/
+	maintenance, closesAt, err := r.maintenanceWindowAt(ctx, &cronJob, missedRun)
+	if err != nil {
+		log.Error(err, "unable to check maintenance windows")
+		return ctrl.Result{}, err
+	}
+	if maintenance != nil && maintenance.Spec.Action == batch.SkipDuringMaintenance {
+		log.V(1).Info("run falls inside maintenance window, skipping", "window", maintenance.Name)
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.MaintenanceWindowSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+	}
+	if maintenance != nil && closesAt.After(r.Now()) {
+		log.V(1).Info("run falls inside maintenance window, deferring", "window", maintenance.Name, "until", closesAt)
+		if setDeferredRun(&cronJob, &batch.RunDeferral{
+			ScheduledTime:     metav1.NewTime(missedRun),
+			MaintenanceWindow: maintenance.Name,
+			Until:             metav1.NewTime(closesAt),
+		}) {
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "Deferred", "Run scheduled at %s deferred by MaintenanceWindow %s until %s", missedRun.Format(time.RFC3339), maintenance.Name, closesAt.Format(time.RFC3339))
+			if err := r.updateStatus(ctx, &cronJob); err != nil {
+				log.Error(err, "unable to update CronJob status")
 				return ctrl.Result{}, err
 			}
 		}
+		wakeUpAt(closesAt)
+		return wakeupResult(), nil
 	}
 
 	/*
-		Once we've figured out what to do with existing jobs, we'll actually create our desired job
+		If this CronJob depends on others, we'll hold off until their runs for the same
+		window have succeeded.  We watch our dependencies (see below), so we'll hear about
+		it when they finish.  The member before us in a CronJobGroup's sequence counts as
+		a dependency too.
 	*/
+	deps := append(groupDependencies(groups, &cronJob), cronJob.Spec.DependsOn...)
+	unmetDep, err := r.unmetDependency(ctx, &cronJob, deps, missedRun)
+	if err != nil {
+		log.Error(err, "unable to check CronJob dependencies")
+		return ctrl.Result{}, err
+	}
+	if unmetDep != nil {
+		log.V(1).Info("waiting for dependency to succeed", "dependency", dependencyName(&cronJob, *unmetDep))
+		return scheduledResult, nil
+	}
 
 	/*
-		We need to construct a job based on our CronJob's template.  We'll copy over the spec
-		from the template and copy some basic object meta.
-
-		Then, we'll set the "scheduled time" annotation so that we can reconstitute our
-		`LastScheduleTime` field each reconcile.
+		If we actually have to run a job, we'll need to either wait till existing ones finish,
+		replace the existing ones, or just add new ones.  If our information is out of date due
+		to cache delay, we'll get a requeue when we get up-to-date information.
+	*/
+	// figure out how to run this job -- concurrency policy might forbid us from running
+	// multiple at the same time, or more than maxConcurrentRuns...
+	if concurrencyBlocked(&cronJob, activeJobs) {
+		log.V(1).Info("concurrency policy blocks concurrent runs, skipping", "num active", len(activeJobs))
+		if cronJob.Spec.ConcurrencyPolicy == batch.QueueConcurrent {
+			// the run stays queued until there's room for it; repeats of this
+			// event get aggregated by the recorder
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "ConcurrencyBlocked", "Run scheduled at %s is queued behind %d active jobs", missedRun.Format(time.RFC3339), len(activeJobs))
+			return scheduledResult, nil
+		}
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.ConcurrencySkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "ConcurrencyBlocked", "Skipped run scheduled at %s, since %d jobs are still active", missedRun.Format(time.RFC3339), len(activeJobs))
+		return scheduledResult, nil
+	}
 
-		Finally, we'll need to set an owner reference.  This allows the Kubernetes garbage collector
-		to clean up jobs when we delete the CronJob, and allows controller-runtime to figure out
-		which cronjob needs to be reconciled when a given job changes (is added, deleted, completes, etc).
+	/*
+		A run we've skipped on request stays skipped, even though the request has been
+		counted down since.  Runs skipped for anything else get another chance, should
+		whatever held them back have gone away.
 	*/
-	constructJobForCronJob := func(cronJob *batch.CronJob, scheduledTime time.Time) (*kbatch.Job, error) {
-		// We want job names for a given nominal start time to have a deterministic name to avoid the same job being created twice
-		name := fmt.Sprintf("%s-%d", cronJob.Name, scheduledTime.Unix())
+	if skipped := skippedRunAt(&cronJob, missedRun); skipped != nil && skipped.Reason == batch.RequestedSkip {
+		log.V(1).Info("run already skipped on request, sleeping till next")
+		return scheduledResult, nil
+	}
 
-		job := &kbatch.Job{
-			ObjectMeta: metav1.ObjectMeta{
-				Labels:      make(map[string]string),
-				Annotations: make(map[string]string),
-				Name:        name,
-				Namespace:   cronJob.Namespace,
-			},
-			Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	/*
+		If the user asked us to skip some upcoming runs, this is one of them.  We count
+		down the request in the spec itself, so it's obvious how many skips remain, and
+		record the skip in status so that we don't count the same run twice.
+	*/
+	if cronJob.Spec.SkipNextRuns != nil && *cronJob.Spec.SkipNextRuns > 0 {
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.RequestedSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
 		}
-		for k, v := range cronJob.Spec.JobTemplate.Annotations {
-			job.Annotations[k] = v
+		*cronJob.Spec.SkipNextRuns--
+		if err := r.Update(ctx, &cronJob); err != nil {
+			log.Error(err, "unable to count down skipped runs")
+			return ctrl.Result{}, err
 		}
-		job.Annotations[scheduledTimeAnnotation] = scheduledTime.Format(time.RFC3339)
-		for k, v := range cronJob.Spec.JobTemplate.Labels {
-			job.Labels[k] = v
+		log.V(1).Info("skipping run on request", "remaining skips", *cronJob.Spec.SkipNextRuns)
+		r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "SkippedRun", "Skipped run scheduled at %s on request, %d more to skip", missedRun.Format(time.RFC3339), *cronJob.Spec.SkipNextRuns)
+		return scheduledResult, nil
+	}
+
+	/*
+		A condition gate asks Prometheus whether the run is worth doing.  We note the
+		answer in a status condition either way; a run it holds back is skipped, or
+		waits for us to ask again, as the gate says.
+	*/
+	if gate := cronJob.Spec.ConditionGate; gate != nil {
+		gateCondition := r.checkConditionGate(ctx, &cronJob)
+		gateChanged := r.setCondition(&cronJob, gateCondition)
+		if gateCondition.Status != metav1.ConditionTrue {
+			if gateChanged {
+				r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "ConditionNotMet", "Run scheduled at %s held back by its condition gate: %s", missedRun.Format(time.RFC3339), gateCondition.Message)
+			}
+			if gate.Action == batch.DeferUntilMet {
+				log.V(1).Info("condition gate not met, deferring", "reason", gateCondition.Reason)
+				if gateChanged {
+					if err := r.updateStatus(ctx, &cronJob); err != nil {
+						log.Error(err, "unable to update CronJob status")
+						return ctrl.Result{}, err
+					}
+				}
+				wakeUpAt(r.Now().Add(gateRecheckInterval(gate)))
+				return wakeupResult(), nil
+			}
+			log.V(1).Info("condition gate not met, skipping", "reason", gateCondition.Reason)
+			if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.ConditionGateSkip); err != nil {
+				log.Error(err, "unable to record skipped run")
+				return ctrl.Result{}, err
+			}
+			return scheduledResult, nil
 		}
-		if err := ctrl.SetControllerReference(cronJob, job, r.Scheme); err != nil {
-			return nil, err
+		// a met gate is persisted along with the new job below
+	} else {
+		meta.RemoveStatusCondition(&cronJob.Status.Conditions, batch.ConditionGateMetCondition)
+	}
+
+	/*
+		The namespace's RunQuotas may not have room for another run just now.  If so,
+		the run waits, rather than being skipped: we check back once there's room, and
+		start it then, as long as it's still within its starting deadline.  Any runs
+		that come due while it waits are treated like any other missed runs.
+	*/
+	quotas, err := r.runQuotas(ctx, &cronJob)
+	if err != nil {
+		log.Error(err, "unable to list RunQuotas")
+		return ctrl.Result{}, err
+	}
+	block, err := r.quotaBlockFor(ctx, &cronJob, quotas, r.Now())
+	if err != nil {
+		log.Error(err, "unable to check RunQuotas")
+		return ctrl.Result{}, err
+	}
+	if block != nil {
+		if r.setCondition(&cronJob, quotaExceededCondition(block, missedRun)) {
+			quotaDeferrals.WithLabelValues(cronJob.Namespace, cronJob.Name).Inc()
+			r.Recorder.Eventf(&cronJob, corev1.EventTypeNormal, "QuotaExceeded", "Deferred run scheduled at %s, since RunQuota %s allows %s", missedRun.Format(time.RFC3339), block.quota.Name, block.limit)
+			if err := r.updateStatus(ctx, &cronJob); err != nil {
+				log.Error(err, "unable to update CronJob status")
+				return ctrl.Result{}, err
+			}
+		}
+		log.V(1).Info("deferring run over quota", "quota", block.quota.Name, "reason", block.reason)
+		if block.retryAt.IsZero() {
+			// we don't hear about other CronJobs' jobs finishing
+			wakeUpAt(r.Now().Add(quotaRecheckInterval))
+		} else {
+			wakeUpAt(block.retryAt)
 		}
+		return wakeupResult(), nil
+	}
 
-		return job, nil
+	// within its RunQuotas, the run may still have to wait for its tenant
+	if blocked, err := tenantBlocked(missedRun); err != nil {
+		return ctrl.Result{}, err
+	} else if blocked {
+		return wakeupResult(), nil
 	}
-	// +kubebuilder:docs-gen:collapse=constructJobForCronJob
+	r.setCondition(&cronJob, quotaExceededCondition(nil, missedRun))
+
+	// ...or instruct us to replace existing ones...
+	if cronJob.Spec.ConcurrencyPolicy == batch.ReplaceConcurrent && len(activeJobs) > 0 {
+		for _, activeJob := range activeJobs {
+			// we don't care if the job was already deleted
+			if err := r.Delete(ctx, workloadOf(activeJob), client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to delete active job", "job", activeJob)
+				return ctrl.Result{}, err
+			}
+		}
+		// until they're gone, the webhook won't let the schedule change under
+		// us; this is persisted along with the new job's count below
+		r.setCondition(&cronJob, replacedJobsCondition(len(activeJobs)))
+		r.audit(ctx, &cronJob, missedRun, audit.Replaced, "", jobNames(activeJobs)...)
+	}
+
+	/*
+		Once we've figured out what to do with existing jobs, we'll actually create our desired job
+	*/
 
 	// actually make the job...
-	job, err := constructJobForCronJob(&cronJob, missedRun)
+	job, err := r.constructRunJob(ctx, &cronJob, missedRun)
 	if err != nil {
 		log.Error(err, "unable to construct job from template")
-		// don't bother requeuing until we get a change to the spec
+		// don't bother requeuing until we get a change to the spec, or to the
+		// JobTemplate it refers to
 		return scheduledResult, nil
 	}
 
-	// ...and create it on the cluster
-	if err := r.Create(ctx, job); err != nil {
+	// ...and create it on the cluster, unless we already had
+	var (
+		unverified *imagesUnverifiedError
+		denied     *runDeniedError
+	)
+	if err := r.createRunJob(ctx, &cronJob, job); apierrors.IsAlreadyExists(err) {
+		log.V(1).Info("Job for CronJob run already exists", "job", job)
+		return scheduledResult, nil
+	} else if errors.As(err, &unverified) {
+		// retrying won't sign the images
+		log.V(1).Info("images not verified, skipping", "reason", err.Error())
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.ImageVerificationSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+	} else if errors.As(err, &denied) {
+		log.V(1).Info("run denied by policy endpoint, skipping", "policy", denied.policy, "reason", denied.reason)
+		if err := r.recordSkippedRun(ctx, &cronJob, missedRun, batch.RunDeniedSkip); err != nil {
+			log.Error(err, "unable to record skipped run")
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+	} else if err != nil {
 		log.Error(err, "unable to create Job for CronJob", "job", job)
 		return ctrl.Result{}, err
 	}
 
 	log.V(1).Info("created Job for CronJob run", "job", job)
+	if err := r.updateStatus(ctx, &cronJob); err != nil {
+		log.Error(err, "unable to update CronJob status")
+		return ctrl.Result{}, err
+	}
+
+	// the job's already been created, so we'd rather undercount the run than
+	// retry and count it twice
+	if err := r.countQuotaRun(ctx, quotas, r.Now()); err != nil {
+		log.Error(err, "unable to count run against RunQuotas")
+	}
 
 	/*
-		### 7: Requeue when we either see a running job or it's time for the next scheduled run
+		### 8: Requeue when we either see a running job or it's time for the next scheduled run
 
 		Finally, we'll return the result that we prepped above, that says we want to requeue
 		when our next run would need to occur.  This is taken as a maximum deadline -- if something
@@ -518,6 +1679,92 @@ func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return scheduledResult, nil
 }
 
+/*
+We need to construct a job based on our CronJob's template, or the shared JobTemplate it
+refers to.  We'll copy over the spec from the template and copy some basic object meta.
+
+Then, we'll set the "scheduled time" annotation so that we can reconstitute our
+`LastScheduleTime` field each reconcile, and pass the standard labels from the
+template down to the job's pods.  If we've been asked to, we'll also set a
+TTL on the job, so the cluster will clean it up even if we're not around to.
+
+Finally, we'll need to set an owner reference.  This allows the Kubernetes garbage collector
+to clean up jobs when we delete the CronJob, and allows controller-runtime to figure out
+which cronjob needs to be reconciled when a given job changes (is added, deleted, completes, etc).
+
+Looking up the template, and the groups the CronJob is in, takes the API server; the rest
+doesn't, so it's a function of its own, which we can test without one.
+*/
+func (r *CronJobReconciler) constructJobForCronJob(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time) (*kbatch.Job, error) {
+	template, err := r.jobTemplateFor(ctx, cronJob)
+	if err != nil {
+		return nil, err
+	}
+	// groups only matter for the priority class of pods that don't have one
+	var groups []batch.CronJobGroup
+	if template.Spec.Template.Spec.PriorityClassName == "" {
+		if groups, err = r.groupsFor(ctx, cronJob); err != nil {
+			return nil, err
+		}
+	}
+	return newJobForCronJob(cronJob, template, groups, scheduledTime, r.Scheme)
+}
+
+// newJobForCronJob builds the job for the CronJob's run at the scheduled time,
+// from the job template and the groups it's in.
+func newJobForCronJob(cronJob *batch.CronJob, template *kbatchv1beta1.JobTemplateSpec, groups []batch.CronJobGroup, scheduledTime time.Time, scheme *runtime.Scheme) (*kbatch.Job, error) {
+	// We want job names for a given nominal start time to have a deterministic name to avoid the same job being created twice
+	name := runName(cronJob, scheduledTime, "")
+
+	job := &kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+			Name:        name,
+			Namespace:   cronJob.Namespace,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	for k, v := range template.Annotations {
+		job.Annotations[k] = v
+	}
+	// always in UTC, whatever time zone the schedule is in, so that the
+	// annotation reads the same however the time was worked out
+	job.Annotations[scheduledTimeAnnotation] = scheduledTime.UTC().Format(time.RFC3339)
+	for k, v := range template.Labels {
+		job.Labels[k] = v
+	}
+	// the standard labels stamped on by our webhook go on the pods too
+	for _, k := range batch.StandardLabels {
+		v, ok := template.Labels[k]
+		if !ok {
+			continue
+		}
+		if job.Spec.Template.Labels == nil {
+			job.Spec.Template.Labels = make(map[string]string)
+		}
+		job.Spec.Template.Labels[k] = v
+	}
+	if cronJob.Spec.JobTTLSecondsAfterFinished != nil {
+		ttl := *cronJob.Spec.JobTTLSecondsAfterFinished
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	// jobs for a queue are created suspended, for Kueue to start
+	if cronJob.Spec.QueueName != "" {
+		job.Labels[kueueQueueLabel] = cronJob.Spec.QueueName
+	}
+	if job.Spec.Template.Spec.PriorityClassName == "" {
+		job.Spec.Template.Spec.PriorityClassName = groupPriorityClass(groups)
+	}
+	if err := ctrl.SetControllerReference(cronJob, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// +kubebuilder:docs-gen:collapse=constructJobForCronJob
+
 /*
 ### Setup
 
@@ -530,23 +1777,38 @@ a CronJob owner.
 
 Additionally, we'll inform the manager that this controller owns some Jobs, so that it
 will automatically call Reconcile on the underlying CronJob when a Job changes, is
-deleted, etc.
+deleted, etc.  We don't need to hear about our own status updates to CronJobs, though.
+Once a CronJob's status shows we've observed its current generation, we filter those
+out.  We'll also watch CronJobs on behalf of the CronJobs that depend on them, so that
+dependents waiting on a run get reconciled when it finishes.
 */
 var (
 	jobOwnerKey = ".metadata.controller"
 	apiGVStr    = batch.GroupVersion.String()
 )
 
-func (r *CronJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// set up a real clock, since we're not in a test
+// Prepare readies the reconciler to reconcile: it fills in defaults for
+// whatever it hasn't been given, and registers the indexes it looks things
+// up by with the indexer.  It reads our backends' workloads through the
+// cache, which must be the one with those indexes, and Secrets, among other
+// things, through the API reader.  SetupWithManager prepares it with the manager's own, but
+// tests can prepare it with fakes, and call Reconcile themselves.
+func (r *CronJobReconciler) Prepare(ctx context.Context, indexer client.FieldIndexer, cache, apiReader client.Reader) error {
+	// set up a real clock, unless a test has given us a fake one
 	if r.Clock == nil {
-		r.Clock = realClock{}
+		r.Clock = clock.RealClock{}
 	}
-
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &kbatch.Job{}, jobOwnerKey, func(rawObj client.Object) []string {
-		// grab the job object, extract the owner...
-		job := rawObj.(*kbatch.Job)
-		owner := metav1.GetControllerOf(job)
+	if r.ImageVerifier == nil {
+		r.ImageVerifier = &imagesig.CosignVerifier{}
+	}
+	r.hotObjects = r.Workers.newObjectRateLimiter()
+	r.apiReader = apiReader
+	// calendars' addresses come from CronJobs, so may point anywhere
+	r.calendars.fetcher.HTTP = r.InternalHosts.PublicClient(0)
+
+	indexByOwner := func(rawObj client.Object) []string {
+		// grab the owner of the job (or run)...
+		owner := metav1.GetControllerOf(rawObj)
 		if owner == nil {
 			return nil
 		}
@@ -557,12 +1819,113 @@ func (r *CronJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 		// ...and if so, return it
 		return []string{owner.Name}
+	}
+	if err := indexer.IndexField(ctx, &kbatch.Job{}, jobOwnerKey, indexByOwner); err != nil {
+		return err
+	}
+	// CronJobRuns get the same index, since we look them up the same way.
+	if err := indexer.IndexField(ctx, &batch.CronJobRun{}, jobOwnerKey, indexByOwner); err != nil {
+		return err
+	}
+	// So do the workloads of the backends we've enabled, which we read
+	// straight from the cache: our client would go to the API server for
+	// those that aren't built-in types, and it knows nothing of our index.
+	r.workloads = cache
+	for _, backend := range r.enabledBackends() {
+		if err := indexer.IndexField(ctx, backend.newObject(), jobOwnerKey, indexByOwner); err != nil {
+			return err
+		}
+	}
+
+	// We'll also index CronJobs by their dependencies, so that we can requeue
+	// dependents whenever one of them changes.
+	if err := indexer.IndexField(ctx, &batch.CronJob{}, dependsOnKey, func(rawObj client.Object) []string {
+		cronJob := rawObj.(*batch.CronJob)
+		var deps []string
+		for _, dep := range cronJob.Spec.DependsOn {
+			deps = append(deps, dependencyName(cronJob, dep).String())
+		}
+		return deps
 	}); err != nil {
 		return err
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&batch.CronJob{}).
-		Owns(&kbatch.Job{}).
+	// ...and by the JobTemplate they refer to, for the same reason.
+	if err := indexer.IndexField(ctx, &batch.CronJob{}, jobTemplateRefKey, func(rawObj client.Object) []string {
+		cronJob := rawObj.(*batch.CronJob)
+		if cronJob.Spec.JobTemplateRef == nil {
+			return nil
+		}
+		return []string{cronJob.Spec.JobTemplateRef.Name}
+	}); err != nil {
+		return err
+	}
+
+	// ...and by the HolidayCalendar they refer to.
+	if err := indexer.IndexField(ctx, &batch.CronJob{}, holidayCalendarRefKey, func(rawObj client.Object) []string {
+		cronJob := rawObj.(*batch.CronJob)
+		if cronJob.Spec.HolidayCalendarRef == nil {
+			return nil
+		}
+		return []string{cronJob.Spec.HolidayCalendarRef.Name}
+	}); err != nil {
+		return err
+	}
+
+	// ScheduleOverrides get indexed by the CronJob they override, so that we
+	// can look them up quickly.
+	if err := indexer.IndexField(ctx, &batch.ScheduleOverride{}, scheduleOverrideKey, func(rawObj client.Object) []string {
+		return []string{rawObj.(*batch.ScheduleOverride).Spec.CronJobName}
+	}); err != nil {
+		return err
+	}
+
+	// and so do CronJobTriggers
+	if err := indexer.IndexField(ctx, &batch.CronJobTrigger{}, cronJobTriggerKey, func(rawObj client.Object) []string {
+		return []string{rawObj.(*batch.CronJobTrigger).Spec.CronJobName}
+	}); err != nil {
+		return err
+	}
+
+	// Finally, the indexes for the questions we, and others sharing our
+	// cache, ask about lots of CronJobs at once.
+	if err := IndexCronJobs(ctx, indexer); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *CronJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := r.Prepare(context.Background(), mgr.GetFieldIndexer(), mgr.GetCache(), mgr.GetAPIReader()); err != nil {
+		return err
+	}
+
+	bldr := ctrl.NewControllerManagedBy(mgr)
+	if r.Tenants != nil {
+		// CronJobs whose turn has come for their tenant
+		bldr = bldr.Watches(&source.Channel{Source: r.Tenants.WakeUps()}, &handler.EnqueueRequestForObject{})
+	}
+	if !r.Namespaced {
+		bldr = bldr.Watches(&source.Kind{Type: &batch.MaintenanceWindow{}}, handler.EnqueueRequestsFromMapFunc(r.coveredBy))
+	}
+	for _, backend := range r.enabledBackends() {
+		bldr = bldr.Owns(backend.newObject(), builder.WithPredicates(r.Shard.predicate()))
+	}
+	// only our CronJobs and their jobs are filtered by shard: the other
+	// watches can map to CronJobs in any namespace, dependents among them,
+	// and Reconcile turns away those that aren't ours
+	return bldr.
+		For(&batch.CronJob{}, builder.WithPredicates(cronJobChangedPredicate{}, r.Shard.predicate())).
+		Owns(&kbatch.Job{}, builder.WithPredicates(jobTransitionPredicate{}, r.Shard.predicate())).
+		Watches(&source.Kind{Type: &batch.CronJob{}}, handler.EnqueueRequestsFromMapFunc(r.dependentsOf)).
+		Watches(&source.Kind{Type: &batch.CronJob{}}, handler.EnqueueRequestsFromMapFunc(r.successorsOf)).
+		Watches(&source.Kind{Type: &batch.CronJobGroup{}}, handler.EnqueueRequestsFromMapFunc(r.membersOf)).
+		Watches(&source.Kind{Type: &batch.CronJobPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.governedBy)).
+		Watches(&source.Kind{Type: &batch.JobTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.usersOf)).
+		Watches(&source.Kind{Type: &batch.ScheduleOverride{}}, handler.EnqueueRequestsFromMapFunc(r.overriddenBy)).
+		Watches(&source.Kind{Type: &batch.CronJobTrigger{}}, handler.EnqueueRequestsFromMapFunc(r.triggeredBy)).
+		Watches(&source.Kind{Type: &batch.RunQuota{}}, handler.EnqueueRequestsFromMapFunc(r.quotaUsersOf)).
+		Watches(&source.Kind{Type: &batch.HolidayCalendar{}}, handler.EnqueueRequestsFromMapFunc(r.holidayCalendarUsersOf)).
+		WithOptions(r.Workers.controllerOptions()).
 		Complete(r)
 }
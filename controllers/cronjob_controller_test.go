@@ -0,0 +1,287 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kbatch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batchv1 "kubebuilder-tutorial/api/v1"
+)
+
+/*
+These tests run the controller against a real API server, but there's no job
+controller to run the Jobs it creates: they stay active until a test finishes
+them itself.  Nor is there a garbage collector, so the Jobs of deleted CronJobs
+stay behind, which is why each test gets a namespace of its own.
+
+Time only moves when a test moves the fake clock.  Every CronJob runs hourly,
+on the hour in UTC, and starts out with the clock at the time it was created.
+*/
+
+const (
+	timeout  = 10 * time.Second
+	interval = 250 * time.Millisecond
+	// settle is how long we watch for something not to happen.
+	settle = 2 * time.Second
+)
+
+var _ = Describe("CronJob controller", func() {
+	var (
+		ctx       = context.Background()
+		namespace string
+		cronJob   *batchv1.CronJob
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "cronjob-test-"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		// nothing's due yet, for the CronJob about to be created
+		fakeClock.SetTime(time.Now())
+		cronJob = newHourlyCronJob(namespace)
+	})
+
+	AfterEach(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cronJob))).To(Succeed())
+	})
+
+	// create creates the CronJob, and returns the time of its first run.
+	create := func() time.Time {
+		Expect(k8sClient.Create(ctx, cronJob)).To(Succeed())
+		return nextHour(cronJob.CreationTimestamp.Time)
+	}
+
+	// scheduledTimes lists the scheduled times of the CronJob's jobs that
+	// the given filter keeps, earliest first.
+	scheduledTimes := func(keep func(*kbatch.Job) bool) func() []time.Time {
+		return func() []time.Time {
+			var jobs kbatch.JobList
+			Expect(k8sClient.List(ctx, &jobs, client.InNamespace(namespace))).To(Succeed())
+			var times []time.Time
+			for i := range jobs.Items {
+				job := &jobs.Items[i]
+				if job.DeletionTimestamp != nil || !keep(job) {
+					continue
+				}
+				t, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation])
+				Expect(err).ToNot(HaveOccurred())
+				times = append(times, t)
+			}
+			sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+			return times
+		}
+	}
+	allJobs := func(*kbatch.Job) bool { return true }
+	activeJobs := func(job *kbatch.Job) bool { return !jobFinished(job) }
+	succeededJobs := func(job *kbatch.Job) bool { return jobHasCondition(job, kbatch.JobComplete) }
+	failedJobs := func(job *kbatch.Job) bool { return jobHasCondition(job, kbatch.JobFailed) }
+
+	// finishActiveJobs gives the CronJob's active jobs the status the job
+	// controller would once they'd finished.
+	finishActiveJobs := func(conditionType kbatch.JobConditionType) {
+		var jobs kbatch.JobList
+		Expect(k8sClient.List(ctx, &jobs, client.InNamespace(namespace))).To(Succeed())
+		for i := range jobs.Items {
+			if job := &jobs.Items[i]; !jobFinished(job) {
+				Expect(finishJob(ctx, job, conditionType)).To(Succeed())
+			}
+		}
+	}
+
+	Context("on its schedule", func() {
+		It("creates a Job for each run, as it comes due", func() {
+			first := create()
+			Consistently(scheduledTimes(allJobs), settle, interval).Should(BeEmpty())
+
+			By("reaching the first run")
+			fakeClock.SetTime(first)
+			Eventually(scheduledTimes(allJobs), timeout, interval).Should(Equal([]time.Time{first}))
+
+			By("reaching the second run")
+			second := first.Add(time.Hour)
+			fakeClock.SetTime(second)
+			Eventually(scheduledTimes(allJobs), timeout, interval).Should(Equal([]time.Time{first, second}))
+
+			Eventually(func() (time.Time, error) {
+				var cj batchv1.CronJob
+				if err := k8sClient.Get(ctx, keyOf(cronJob), &cj); err != nil || cj.Status.LastScheduleTime == nil {
+					return time.Time{}, err
+				}
+				return cj.Status.LastScheduleTime.Time, nil
+			}, timeout, interval).Should(BeTemporally("==", second))
+		})
+
+		It("doesn't create Jobs between runs", func() {
+			first := create()
+			fakeClock.SetTime(first)
+			Eventually(scheduledTimes(allJobs), timeout, interval).Should(HaveLen(1))
+
+			fakeClock.SetTime(first.Add(59 * time.Minute))
+			Consistently(scheduledTimes(allJobs), settle, interval).Should(HaveLen(1))
+		})
+	})
+
+	Context("with a concurrency policy", func() {
+		It("runs concurrently when allowed", func() {
+			cronJob.Spec.ConcurrencyPolicy = batchv1.AllowConcurrent
+			first := create()
+			fakeClock.SetTime(first)
+			Eventually(scheduledTimes(activeJobs), timeout, interval).Should(HaveLen(1))
+
+			fakeClock.SetTime(first.Add(time.Hour))
+			Eventually(scheduledTimes(activeJobs), timeout, interval).Should(HaveLen(2))
+		})
+
+		It("skips runs while one is still going when forbidden", func() {
+			cronJob.Spec.ConcurrencyPolicy = batchv1.ForbidConcurrent
+			first := create()
+			fakeClock.SetTime(first)
+			Eventually(scheduledTimes(activeJobs), timeout, interval).Should(Equal([]time.Time{first}))
+
+			fakeClock.SetTime(first.Add(time.Hour))
+			Consistently(scheduledTimes(allJobs), settle, interval).Should(Equal([]time.Time{first}))
+
+			By("finishing the run that's still going")
+			finishActiveJobs(kbatch.JobComplete)
+			third := first.Add(2 * time.Hour)
+			fakeClock.SetTime(third)
+			Eventually(scheduledTimes(activeJobs), timeout, interval).Should(Equal([]time.Time{third}))
+		})
+
+		It("replaces the run that's still going when told to", func() {
+			cronJob.Spec.ConcurrencyPolicy = batchv1.ReplaceConcurrent
+			first := create()
+			fakeClock.SetTime(first)
+			Eventually(scheduledTimes(allJobs), timeout, interval).Should(Equal([]time.Time{first}))
+
+			second := first.Add(time.Hour)
+			fakeClock.SetTime(second)
+			Eventually(scheduledTimes(allJobs), timeout, interval).Should(Equal([]time.Time{second}))
+		})
+	})
+
+	Context("with history limits", func() {
+		It("prunes the oldest finished Jobs beyond them", func() {
+			one, two := int32(1), int32(2)
+			cronJob.Spec.SuccessfulJobsHistoryLimit = &two
+			cronJob.Spec.FailedJobsHistoryLimit = &one
+			first := create()
+
+			var runs []time.Time
+			for i, conditionType := range []kbatch.JobConditionType{
+				kbatch.JobComplete, kbatch.JobFailed, kbatch.JobComplete, kbatch.JobFailed, kbatch.JobComplete,
+			} {
+				run := first.Add(time.Duration(i) * time.Hour)
+				runs = append(runs, run)
+				fakeClock.SetTime(run)
+				Eventually(scheduledTimes(activeJobs), timeout, interval).Should(Equal([]time.Time{run}))
+				finishActiveJobs(conditionType)
+			}
+
+			Eventually(scheduledTimes(succeededJobs), timeout, interval).Should(Equal([]time.Time{runs[2], runs[4]}))
+			Eventually(scheduledTimes(failedJobs), timeout, interval).Should(Equal([]time.Time{runs[3]}))
+		})
+	})
+})
+
+// newHourlyCronJob returns a CronJob, yet to be created, that runs on the
+// hour in UTC.
+func newHourlyCronJob(namespace string) *batchv1.CronJob {
+	utc := "UTC"
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cronjob",
+			Namespace: namespace,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "0 * * * *",
+			TimeZone: &utc,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				Spec: kbatch.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name:  "test-container",
+								Image: "test-image",
+							}},
+							RestartPolicy: corev1.RestartPolicyOnFailure,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// nextHour returns the first time on the hour after t.
+func nextHour(t time.Time) time.Time {
+	return t.Truncate(time.Hour).Add(time.Hour)
+}
+
+// keyOf returns the object's key.
+func keyOf(obj client.Object) client.ObjectKey {
+	return client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+// jobHasCondition checks whether the job has the condition, and it's true.
+func jobHasCondition(job *kbatch.Job, conditionType kbatch.JobConditionType) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == conditionType && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// jobFinished checks whether the job has finished, one way or the other.
+func jobFinished(job *kbatch.Job) bool {
+	return jobHasCondition(job, kbatch.JobComplete) || jobHasCondition(job, kbatch.JobFailed)
+}
+
+// finishJob gives the job the status the job controller would once it's
+// finished, as of the fake clock's time.
+func finishJob(ctx context.Context, job *kbatch.Job, conditionType kbatch.JobConditionType) error {
+	now := metav1.NewTime(fakeClock.Now())
+	if job.Status.StartTime == nil {
+		job.Status.StartTime = &now
+	}
+	if conditionType == kbatch.JobComplete {
+		job.Status.CompletionTime = &now
+		job.Status.Succeeded = 1
+	} else {
+		job.Status.Failed = 1
+	}
+	job.Status.Active = 0
+	job.Status.Conditions = append(job.Status.Conditions, kbatch.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	return k8sClient.Status().Update(ctx, job)
+}
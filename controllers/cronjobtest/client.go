@@ -0,0 +1,256 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjobtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// fakeClient makes up for where controller-runtime's fake client falls short
+// of the API server, for what the reconciler needs: it answers field
+// selectors on the indexes registered with it, which the fake client
+// ignores, and it server-side applies, after a fashion.
+type fakeClient struct {
+	client.Client
+
+	mu      sync.Mutex
+	indexes map[indexKey]client.IndexerFunc
+}
+
+var _ client.FieldIndexer = &fakeClient{}
+
+type indexKey struct {
+	gvk   schema.GroupVersionKind
+	field string
+}
+
+// IndexField implements client.FieldIndexer.
+func (c *fakeClient) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.indexes == nil {
+		c.indexes = make(map[indexKey]client.IndexerFunc)
+	}
+	c.indexes[indexKey{gvk: gvk, field: field}] = extractValue
+	return nil
+}
+
+// List implements client.Reader, filtering what the fake client lists by the
+// field selector itself.
+func (c *fakeClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	selector := listOpts.FieldSelector
+	listOpts.FieldSelector = nil
+	if err := c.Client.List(ctx, list, &listOpts); err != nil {
+		return err
+	}
+	if selector == nil || selector.Empty() {
+		return nil
+	}
+
+	gvk, err := apiutil.GVKForObject(list, c.Scheme())
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var kept []runtime.Object
+	for _, item := range items {
+		matches, err := c.matches(gvk, item.(client.Object), selector.Requirements())
+		if err != nil {
+			return err
+		}
+		if matches {
+			kept = append(kept, item)
+		}
+	}
+	return meta.SetList(list, kept)
+}
+
+// matches checks the object against every requirement of a field selector.
+func (c *fakeClient) matches(gvk schema.GroupVersionKind, obj client.Object, requirements fields.Requirements) (bool, error) {
+	for _, requirement := range requirements {
+		var values []string
+		switch requirement.Field {
+		case "metadata.name":
+			values = []string{obj.GetName()}
+		case "metadata.namespace":
+			values = []string{obj.GetNamespace()}
+		default:
+			c.mu.Lock()
+			extractValue, ok := c.indexes[indexKey{gvk: gvk, field: requirement.Field}]
+			c.mu.Unlock()
+			if !ok {
+				return false, fmt.Errorf("no index on field %s of %s", requirement.Field, gvk.Kind)
+			}
+			values = extractValue(obj)
+		}
+
+		found := false
+		for _, value := range values {
+			if value == requirement.Value {
+				found = true
+				break
+			}
+		}
+		switch requirement.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			if !found {
+				return false, nil
+			}
+		case selection.NotEquals:
+			if found {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported operator %s in field selector", requirement.Operator)
+		}
+	}
+	return true, nil
+}
+
+// Patch implements client.Writer, applying apply patches itself.
+func (c *fakeClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	return c.apply(ctx, obj, patch)
+}
+
+// Status implements client.StatusClient.
+func (c *fakeClient) Status() client.StatusWriter {
+	return fakeStatusWriter{c}
+}
+
+// fakeStatusWriter applies apply patches to status itself.  The fake client
+// doesn't tell status apart from the rest of the object anyway.
+type fakeStatusWriter struct {
+	c *fakeClient
+}
+
+// Update implements client.StatusWriter.
+func (w fakeStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return w.c.Client.Status().Update(ctx, obj, opts...)
+}
+
+// Patch implements client.StatusWriter.
+func (w fakeStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return w.c.Client.Status().Patch(ctx, obj, patch, opts...)
+	}
+	return w.c.apply(ctx, obj, patch)
+}
+
+// apply creates the object from the patch if it doesn't exist, and otherwise
+// replaces the parts of it that the patch has, merging in its labels and
+// annotations.  That's what server-side apply comes to when, as in tests,
+// there's only the one field manager, and it always applies all of its fields
+// together.  The object is refreshed from the result.
+func (c *fakeClient) apply(ctx context.Context, obj client.Object, patch client.Patch) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	var applied map[string]interface{}
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return err
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	existing, err := c.Scheme().New(gvk)
+	if err != nil {
+		return err
+	}
+	err = c.Client.Get(ctx, key, existing.(client.Object))
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := json.Unmarshal(data, obj); err != nil {
+			return err
+		}
+		obj.SetResourceVersion("")
+		return c.Client.Create(ctx, obj)
+	case err != nil:
+		return err
+	}
+
+	raw, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return err
+	}
+	for field, value := range applied {
+		if field != "metadata" {
+			merged[field] = value
+			continue
+		}
+		metadata, _ := merged["metadata"].(map[string]interface{})
+		appliedMetadata, _ := value.(map[string]interface{})
+		for _, field := range []string{"labels", "annotations"} {
+			values, _ := appliedMetadata[field].(map[string]interface{})
+			if len(values) == 0 {
+				continue
+			}
+			existingValues, _ := metadata[field].(map[string]interface{})
+			if existingValues == nil {
+				existingValues = make(map[string]interface{})
+			}
+			for k, v := range values {
+				existingValues[k] = v
+			}
+			metadata[field] = existingValues
+		}
+	}
+	if raw, err = json.Marshal(merged); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return err
+	}
+	if err := c.Client.Update(ctx, obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
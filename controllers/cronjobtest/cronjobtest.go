@@ -0,0 +1,209 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cronjobtest runs a CronJobReconciler without a cluster: against a
+// fake API server, on a fake clock that only moves when the test moves it.
+// Tests can put a CronJob through days of its schedule in no time, and get
+// the same runs every time, without sleeping or depending on when they run.
+//
+// Nothing runs the jobs the reconciler creates: they stay active until the
+// test finishes them, with CompleteJob or FailJob.  Nor is there anything
+// on the fake API server but what the test puts there, so a CronJob's
+// namespace has to be among the objects it starts out with.
+package cronjobtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/controllers"
+	"kubebuilder-tutorial/pkg/clock"
+)
+
+// maxReconciles is how many times in a row RunUntil will reconcile a CronJob
+// before deciding that it's never going to settle down.
+const maxReconciles = 10000
+
+// Harness is a CronJobReconciler wired up to fakes.
+type Harness struct {
+	// Client is the fake API server's client.  Unlike the fake client it
+	// wraps, it answers field selectors on the reconciler's indexes, and
+	// takes apply patches.
+	Client client.Client
+	// Clock is the reconciler's clock.
+	Clock *clock.FakeClock
+	// Recorder keeps the events the reconciler records.
+	Recorder *Recorder
+	// Reconciler is the reconciler under test.
+	Reconciler *controllers.CronJobReconciler
+}
+
+// New returns a harness whose clock starts at the given time, and whose API
+// server starts out with the given objects.  If configure isn't nil, it's
+// given the reconciler to set up, before it's prepared: that's the place to
+// turn on feature gates, say.
+func New(start time.Time, configure func(*controllers.CronJobReconciler), objs ...client.Object) (*Harness, error) {
+	// a scheme of our own, rather than adding our types to client-go's for
+	// whoever else uses it
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		return nil, err
+	}
+	if err := batch.AddToScheme(s); err != nil {
+		return nil, err
+	}
+	c := &fakeClient{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()}
+	h := &Harness{
+		Client:   c,
+		Clock:    clock.NewFakeClock(start),
+		Recorder: &Recorder{},
+	}
+	h.Reconciler = &controllers.CronJobReconciler{
+		Client:   c,
+		Log:      logr.Discard(),
+		Scheme:   s,
+		Recorder: h.Recorder,
+		Clock:    h.Clock,
+	}
+	if configure != nil {
+		configure(h.Reconciler)
+	}
+	if err := h.Reconciler.Prepare(context.Background(), c, c, c); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Reconcile reconciles the CronJob once, at the clock's time.
+func (h *Harness) Reconcile(ctx context.Context, key types.NamespacedName) (ctrl.Result, error) {
+	return h.Reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+}
+
+// RunUntil reconciles the CronJob, then moves the clock on to whenever it
+// asked to be reconciled again and does so, for as long as that's no later
+// than until, where it leaves the clock.  That's what the controller would
+// do if nothing but the passage of time woke it up.
+func (h *Harness) RunUntil(ctx context.Context, key types.NamespacedName, until time.Time) error {
+	for i := 0; ; i++ {
+		if i == maxReconciles {
+			return fmt.Errorf("reconciled %s %d times without getting to %s", key, maxReconciles, until.Format(time.RFC3339))
+		}
+		result, err := h.Reconcile(ctx, key)
+		if err != nil {
+			return err
+		}
+		if result.RequeueAfter <= 0 && !result.Requeue {
+			break
+		}
+		next := h.Clock.Now().Add(result.RequeueAfter)
+		if next.After(until) {
+			break
+		}
+		h.Clock.SetTime(next)
+	}
+	if until.After(h.Clock.Now()) {
+		h.Clock.SetTime(until)
+	}
+	return nil
+}
+
+// CronJob gets the CronJob as it stands.
+func (h *Harness) CronJob(ctx context.Context, key types.NamespacedName) (*batch.CronJob, error) {
+	var cronJob batch.CronJob
+	if err := h.Client.Get(ctx, key, &cronJob); err != nil {
+		return nil, err
+	}
+	return &cronJob, nil
+}
+
+// Jobs lists the CronJob's jobs, finished or not.
+func (h *Harness) Jobs(ctx context.Context, key types.NamespacedName) ([]kbatch.Job, error) {
+	return controllers.ListJobsOf(ctx, h.Client, key.Namespace, key.Name)
+}
+
+// ActiveJobs lists the CronJob's jobs that haven't finished.
+func (h *Harness) ActiveJobs(ctx context.Context, key types.NamespacedName) ([]kbatch.Job, error) {
+	jobs, err := h.Jobs(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var active []kbatch.Job
+	for _, job := range jobs {
+		if !finished(&job) {
+			active = append(active, job)
+		}
+	}
+	return active, nil
+}
+
+// CompleteJob has the job succeed, as of the clock's time.
+func (h *Harness) CompleteJob(ctx context.Context, job *kbatch.Job) error {
+	return h.finishJob(ctx, job, kbatch.JobComplete, "", "")
+}
+
+// FailJob has the job fail, as of the clock's time, for the given reason,
+// like "BackoffLimitExceeded" or "DeadlineExceeded".
+func (h *Harness) FailJob(ctx context.Context, job *kbatch.Job, reason string) error {
+	return h.finishJob(ctx, job, kbatch.JobFailed, reason, "Job failed in a test")
+}
+
+// finishJob gives the job the status the job controller would once it's
+// finished.
+func (h *Harness) finishJob(ctx context.Context, job *kbatch.Job, conditionType kbatch.JobConditionType, reason, message string) error {
+	now := metav1.NewTime(h.Clock.Now())
+	if job.Status.StartTime == nil {
+		job.Status.StartTime = &now
+	}
+	if conditionType == kbatch.JobComplete {
+		job.Status.CompletionTime = &now
+		job.Status.Succeeded++
+	} else {
+		job.Status.Failed++
+	}
+	job.Status.Active = 0
+	job.Status.Conditions = append(job.Status.Conditions, kbatch.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+	return h.Client.Status().Update(ctx, job)
+}
+
+// finished checks whether the job has a condition saying it's finished.
+func finished(job *kbatch.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == kbatch.JobComplete || c.Type == kbatch.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjobtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/controllers/cronjobtest"
+)
+
+// BenchmarkReconcile reconciles CronJobs in their steady state, between
+// runs, with a fake API server holding cronJobs CronJobs of jobsEach
+// finished jobs apiece.  The time and allocations it reports include the
+// fake client's, which lists by copying everything in the namespace rather
+// than reading from an indexed cache, so they're for comparing one version
+// of the controller with another, not for predicting what it'll do in a
+// cluster.  Each CronJob gets a namespace of its own, so that its lists copy
+// no more than the cache's index would hand back.
+func BenchmarkReconcile(b *testing.B) {
+	for _, size := range []struct{ cronJobs, jobsEach int }{
+		{1, 10},
+		{1, 100},
+		{1, 1000},
+		{100, 10},
+		{100, 100},
+		{100, 1000},
+	} {
+		b.Run(fmt.Sprintf("cronjobs=%d/jobs=%d", size.cronJobs, size.jobsEach), func(b *testing.B) {
+			benchmarkReconcile(b, size.cronJobs, size.jobsEach)
+		})
+	}
+}
+
+func benchmarkReconcile(b *testing.B, cronJobs, jobsEach int) {
+	ctx := context.Background()
+	// the CronJobs were created before their first job, and it's half past
+	// the hour after their last one
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(time.Duration(jobsEach)*time.Hour + 30*time.Minute)
+
+	var objs []client.Object
+	keys := make([]types.NamespacedName, cronJobs)
+	for i := range keys {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("bench-%d", i)}}
+		cronJob := newBenchCronJob(namespace.Name, "bench", created)
+		keys[i] = types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}
+		objs = append(objs, namespace, cronJob)
+		for j := 1; j <= jobsEach; j++ {
+			objs = append(objs, newBenchJob(cronJob, created.Add(time.Duration(j)*time.Hour)))
+		}
+	}
+	h, err := cronjobtest.New(now, nil, objs...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// the first reconcile of each catches its status up with its jobs
+	for _, key := range keys {
+		if _, err := h.Reconcile(ctx, key); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.Reconcile(ctx, keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newBenchCronJob returns an hourly CronJob, created at the given time.
+func newBenchCronJob(namespace, name string, created time.Time) *batch.CronJob {
+	utc := "UTC"
+	return &batch.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			UID:               types.UID(namespace + "-" + name),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: batch.CronJobSpec{
+			Schedule: "0 * * * *",
+			TimeZone: &utc,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				Spec: kbatch.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name:  "bench",
+								Image: "busybox",
+							}},
+							RestartPolicy: corev1.RestartPolicyOnFailure,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newBenchJob returns the CronJob's job for the run at the given time, which
+// succeeded a minute later.
+func newBenchJob(cronJob *batch.CronJob, scheduledTime time.Time) *kbatch.Job {
+	started, finished := metav1.NewTime(scheduledTime), metav1.NewTime(scheduledTime.Add(time.Minute))
+	isController := true
+	return &kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", cronJob.Name, scheduledTime.Unix()),
+			Namespace: cronJob.Namespace,
+			Annotations: map[string]string{
+				"batch.tutorial.kubebuilder.io/scheduled-at": scheduledTime.Format(time.RFC3339),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: batch.GroupVersion.String(),
+				Kind:       "CronJob",
+				Name:       cronJob.Name,
+				UID:        cronJob.UID,
+				Controller: &isController,
+			}},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+		Status: kbatch.JobStatus{
+			StartTime:      &started,
+			CompletionTime: &finished,
+			Succeeded:      1,
+			Conditions: []kbatch.JobCondition{{
+				Type:               kbatch.JobComplete,
+				Status:             corev1.ConditionTrue,
+				LastProbeTime:      finished,
+				LastTransitionTime: finished,
+			}},
+		},
+	}
+}
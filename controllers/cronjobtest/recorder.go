@@ -0,0 +1,76 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjobtest
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event is an event the reconciler recorded.
+type Event struct {
+	// Object is what the event is about.
+	Object runtime.Object
+	// Type is corev1.EventTypeNormal or corev1.EventTypeWarning.
+	Type string
+	// Reason and Message are as the reconciler gave them.
+	Reason  string
+	Message string
+}
+
+// String formats the event the way record.FakeRecorder does.
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s %s", e.Type, e.Reason, e.Message)
+}
+
+// Recorder keeps the events it's given, in order.  Unlike
+// record.FakeRecorder, it never blocks, however many there are.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+var _ record.EventRecorder = &Recorder{}
+
+// Event implements record.EventRecorder.
+func (r *Recorder) Event(object runtime.Object, eventType, reason, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{Object: object, Type: eventType, Reason: reason, Message: message})
+}
+
+// Eventf implements record.EventRecorder.
+func (r *Recorder) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf implements record.EventRecorder.
+func (r *Recorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+// Events returns the events recorded since the last call, oldest first.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+var (
+	// dependsOnKey indexes CronJobs by the CronJobs they depend on, so that
+	// we can find the dependents to requeue when a dependency changes.
+	dependsOnKey = ".spec.dependsOn"
+)
+
+// dependencyName returns the namespaced name of the dependency, defaulting
+// its namespace to the dependent's.
+func dependencyName(cronJob *batch.CronJob, dep batch.CronJobDependency) types.NamespacedName {
+	namespace := dep.Namespace
+	if namespace == "" {
+		namespace = cronJob.Namespace
+	}
+	return types.NamespacedName{Namespace: namespace, Name: dep.Name}
+}
+
+// unmetDependency returns the first of the given dependencies whose most
+// recent run scheduled at or before the given time hasn't succeeded (yet),
+// or nil if they all have.
+func (r *CronJobReconciler) unmetDependency(ctx context.Context, cronJob *batch.CronJob, deps []batch.CronJobDependency, scheduledTime time.Time) (*batch.CronJobDependency, error) {
+	for i, dep := range deps {
+		name := dependencyName(cronJob, dep)
+		var depCronJob batch.CronJob
+		if err := r.Get(ctx, name, &depCronJob); err != nil {
+			if apierrors.IsNotFound(err) {
+				// a missing dependency never succeeds
+				return &deps[i], nil
+			}
+			return nil, err
+		}
+		var depJobs kbatch.JobList
+		if err := r.List(ctx, &depJobs, client.InNamespace(name.Namespace), client.MatchingFields{jobOwnerKey: name.Name}); err != nil {
+			return nil, err
+		}
+		if !dependencySucceeded(&depCronJob, depJobs.Items, scheduledTime) {
+			return &deps[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// dependencySucceeded checks whether the latest of the dependency's runs
+// scheduled at or before the given time succeeded.  Its jobs only tell us as
+// long as they're around, so a success it has on record that's later than
+// any of them counts too.
+func dependencySucceeded(dep *batch.CronJob, jobs []kbatch.Job, scheduledTime time.Time) bool {
+	var latest time.Time
+	var latestRun []kbatch.Job
+	for _, job := range jobs {
+		jobTime, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation])
+		if err != nil || jobTime.After(scheduledTime) || jobTime.Before(latest) {
+			continue
+		}
+		if jobTime.After(latest) {
+			latest, latestRun = jobTime, nil
+		}
+		latestRun = append(latestRun, job)
+	}
+	if last := dep.Status.LastSuccessfulScheduleTime; last != nil && !last.Time.After(scheduledTime) && last.Time.After(latest) {
+		return true
+	}
+	return runSucceeded(dep, latestRun)
+}
+
+// dependentsOf maps a CronJob to requests for the CronJobs that depend on it,
+// so that runs waiting on it are reconsidered as its runs finish.
+func (r *CronJobReconciler) dependentsOf(obj client.Object) []reconcile.Request {
+	var dependents batch.CronJobList
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}.String()
+	if err := r.List(context.Background(), &dependents, client.MatchingFields{dependsOnKey: key}); err != nil {
+		r.Log.Error(err, "unable to list dependent CronJobs", "cronjob", key)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(dependents.Items))
+	for i, dependent := range dependents.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: dependent.Namespace,
+			Name:      dependent.Name,
+		}}
+	}
+	return requests
+}
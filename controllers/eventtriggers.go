@@ -0,0 +1,371 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/awssig"
+	"kubebuilder-tutorial/pkg/egress"
+	"kubebuilder-tutorial/pkg/eventsource"
+)
+
+/*
+CronJobs can run when something happens, as well as on their schedule: each of
+a CronJob's event triggers gets a goroutine of its own, receiving from its source
+and creating a CronJobTrigger for every event that arrives.  From there, the run
+goes just like one triggered by hand, concurrency policy and all, and the
+CronJobTrigger is a record of the event that's there to see.
+
+Sources that redeliver events do so with the same ID, so events with IDs get a
+CronJobTrigger named after them, and a redelivered event finds its trigger there
+already.  Events are only acknowledged once their CronJobTrigger exists: if we
+can't create it, the source gets to try again.
+
+Brokers are only connected to if they're among the hosts the controller's
+configuration allows, so that triggers can't be used to reach whatever else
+the controller can.
+
+A source that fails is reconnected to with exponential backoff.  Triggers stop
+while their CronJob is suspended or paused, leaving events with the brokers that
+keep them until it's resumed.
+*/
+
+const (
+	// minTriggerBackoff and maxTriggerBackoff bound how long we wait before
+	// reconnecting to a source that failed.
+	minTriggerBackoff = time.Second
+	maxTriggerBackoff = 5 * time.Minute
+
+	// triggerHealthyAfter is how long a source has to have been receiving
+	// for its backoff to start over.
+	triggerHealthyAfter = time.Minute
+
+	// eventTriggerRetention is how long the CronJobTriggers of events are
+	// kept after their runs start: long enough to catch the event being
+	// redelivered, without piling up behind CronJobs with busy triggers.
+	eventTriggerRetention = time.Hour
+)
+
+var triggerEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cronjob_trigger_events_total",
+	Help: "Number of events received by CronJobs' event triggers, by source and result",
+}, []string{"source", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(triggerEvents)
+}
+
+// EventTriggers runs the event triggers of the CronJobs we reconcile.  The
+// reconciler tells it which CronJobs have which triggers; it runs them until
+// the manager stops.
+type EventTriggers struct {
+	Client   client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+
+	// CloudEvents, if set, is the receiver CloudEvents triggers listen at.
+	// CloudEvents triggers can't run if it's nil.
+	CloudEvents *eventsource.Receiver
+
+	// AWS, if set, signs our requests to SQS.  SQS triggers can't run if
+	// it's nil.
+	AWS *awssig.Credentials
+
+	// Hosts are the brokers NATS, Kafka and SQS triggers may connect to.
+	// Those triggers can't run if it's empty.
+	Hosts egress.Allowlist
+
+	mu      sync.Mutex
+	running map[types.NamespacedName]map[string]*runningTrigger
+	// ctx is the parent of every trigger's context, done once we're stopped
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// runningTrigger is an event trigger that's running, or that we couldn't
+// start.
+type runningTrigger struct {
+	uid    types.UID
+	spec   batch.EventTrigger
+	cancel context.CancelFunc
+}
+
+func (t *EventTriggers) init() {
+	if t.running == nil {
+		t.running = make(map[types.NamespacedName]map[string]*runningTrigger)
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// Start implements manager.Runnable, stopping every trigger once the
+// manager's done.  Triggers only run on the leader, which is the only one
+// reconciling.
+func (t *EventTriggers) Start(ctx context.Context) error {
+	<-ctx.Done()
+	t.mu.Lock()
+	t.init()
+	t.stopped = true
+	t.cancel()
+	t.running = nil
+	t.mu.Unlock()
+	t.wg.Wait()
+	return nil
+}
+
+// Sync brings the CronJob's running triggers in line with its spec: starting
+// new ones, restarting changed ones and stopping removed ones.  All of them
+// are stopped unless enabled.
+func (t *EventTriggers) Sync(cronJob *batch.CronJob, enabled bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	t.init()
+
+	key := types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}
+	running := t.running[key]
+	if running == nil {
+		running = make(map[string]*runningTrigger)
+	}
+	wanted := make(map[string]*batch.EventTrigger)
+	if enabled {
+		for i := range cronJob.Spec.Triggers {
+			wanted[cronJob.Spec.Triggers[i].Name] = &cronJob.Spec.Triggers[i]
+		}
+	}
+
+	for name, trigger := range running {
+		if spec, ok := wanted[name]; !ok || trigger.uid != cronJob.UID || !equality.Semantic.DeepEqual(*spec, trigger.spec) {
+			trigger.cancel()
+			delete(running, name)
+		}
+	}
+	for name, spec := range wanted {
+		if _, ok := running[name]; ok {
+			continue
+		}
+		trigger := &runningTrigger{uid: cronJob.UID, spec: *spec.DeepCopy(), cancel: func() {}}
+		running[name] = trigger
+		source, err := t.sourceFor(cronJob, spec)
+		if err != nil {
+			// kept as running, so that we only complain once per change
+			t.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "TriggerUnavailable", "Event trigger %s can't run: %v", name, err)
+			continue
+		}
+
+		var ctx context.Context
+		ctx, trigger.cancel = context.WithCancel(t.ctx)
+		// just enough of the CronJob to own its triggers and events
+		owner := &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: cronJob.Namespace, Name: cronJob.Name, UID: cronJob.UID}}
+		t.wg.Add(1)
+		go func(name string, kind string) {
+			defer t.wg.Done()
+			t.run(ctx, owner, name, kind, source)
+		}(name, sourceKind(spec))
+	}
+
+	if len(running) == 0 {
+		delete(t.running, key)
+	} else {
+		t.running[key] = running
+	}
+}
+
+// Forget stops the triggers of a CronJob that's gone.
+func (t *EventTriggers) Forget(cronJob types.NamespacedName) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, trigger := range t.running[cronJob] {
+		trigger.cancel()
+	}
+	delete(t.running, cronJob)
+}
+
+// run receives from the source until the context is done, reconnecting with
+// backoff whenever it fails.
+func (t *EventTriggers) run(ctx context.Context, cronJob *batch.CronJob, name, kind string, source eventsource.Source) {
+	log := t.Log.WithValues("cronjob", types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}, "trigger", name)
+	backoff := minTriggerBackoff
+	for {
+		started := time.Now()
+		err := source.Receive(ctx, func(ctx context.Context, event eventsource.Event) error {
+			err := t.fire(ctx, cronJob, name, event)
+			result := "fired"
+			switch {
+			case apierrors.IsAlreadyExists(err):
+				result, err = "duplicate", nil
+			case err != nil:
+				result = "error"
+			}
+			triggerEvents.WithLabelValues(kind, result).Inc()
+			log.V(1).Info("received event", "id", event.ID, "type", event.Type, "source", event.Source, "result", result)
+			return err
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("source closed")
+		}
+		if time.Since(started) > triggerHealthyAfter {
+			backoff = minTriggerBackoff
+		}
+		log.Error(err, "event trigger failed, reconnecting", "backoff", backoff)
+		t.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "TriggerFailed", "Event trigger %s failed, reconnecting in %s: %v", name, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxTriggerBackoff {
+			backoff = maxTriggerBackoff
+		}
+	}
+}
+
+// fire creates a CronJobTrigger for the event, owned by the CronJob.
+func (t *EventTriggers) fire(ctx context.Context, cronJob *batch.CronJob, name string, event eventsource.Event) error {
+	trigger := &batch.CronJobTrigger{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       cronJob.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cronJob, batch.GroupVersion.WithKind("CronJob"))},
+		},
+		Spec: batch.CronJobTriggerSpec{
+			CronJobName: cronJob.Name,
+			Event: &batch.TriggerEvent{
+				Trigger: name,
+				ID:      event.ID,
+				Type:    event.Type,
+				Source:  event.Source,
+			},
+		},
+	}
+	if event.ID != "" {
+		trigger.Name = eventTriggerName(cronJob.Name, name, event)
+	} else {
+		trigger.GenerateName = fmt.Sprintf("%s-%s-", cronJob.Name, name)
+	}
+	return t.Client.Create(ctx, trigger)
+}
+
+// eventTriggerName names the CronJobTrigger of an event with an ID, the same
+// way every time the event's delivered.
+func eventTriggerName(cronJobName, triggerName string, event eventsource.Event) string {
+	sum := sha256.Sum256([]byte(event.Source + "\x00" + event.ID))
+	return fmt.Sprintf("%s-%s-%s", cronJobName, triggerName, hex.EncodeToString(sum[:])[:10])
+}
+
+// sourceFor sets up the trigger's source.
+func (t *EventTriggers) sourceFor(cronJob *batch.CronJob, trigger *batch.EventTrigger) (eventsource.Source, error) {
+	consumer := fmt.Sprintf("cronjob-%s-%s-%s", cronJob.Namespace, cronJob.Name, trigger.Name)
+	switch {
+	case trigger.NATS != nil:
+		if err := t.Hosts.CheckURL(trigger.NATS.URL); err != nil {
+			return nil, err
+		}
+		return &eventsource.NATS{
+			URL:     trigger.NATS.URL,
+			Subject: trigger.NATS.Subject,
+			Queue:   trigger.NATS.Queue,
+			Name:    consumer,
+		}, nil
+	case trigger.Kafka != nil:
+		group := trigger.Kafka.ConsumerGroup
+		if group == "" {
+			group = consumer
+		}
+		if err := t.Hosts.CheckURL(trigger.Kafka.RESTProxyURL); err != nil {
+			return nil, err
+		}
+		return &eventsource.Kafka{
+			URL:      trigger.Kafka.RESTProxyURL,
+			Topic:    trigger.Kafka.Topic,
+			Group:    group,
+			Instance: consumer,
+			HTTP:     t.Hosts.Client(),
+		}, nil
+	case trigger.SQS != nil:
+		if t.AWS == nil {
+			return nil, errors.New("the controller has no AWS credentials")
+		}
+		// our requests are signed with the controller's own credentials
+		if err := t.Hosts.CheckURL(trigger.SQS.QueueURL); err != nil {
+			return nil, err
+		}
+		return &eventsource.SQS{
+			QueueURL:    trigger.SQS.QueueURL,
+			Region:      trigger.SQS.Region,
+			Credentials: *t.AWS,
+			HTTP:        t.Hosts.Client(),
+		}, nil
+	case trigger.CloudEvents != nil:
+		if t.CloudEvents == nil {
+			return nil, errors.New("the controller's CloudEvents receiver isn't turned on")
+		}
+		return t.CloudEvents.Source(cloudEventsPath(cronJob, trigger.Name), trigger.CloudEvents.Types), nil
+	}
+	return nil, errors.New("no source set")
+}
+
+// cloudEventsPath is where the receiver takes CloudEvents for the trigger.
+func cloudEventsPath(cronJob *batch.CronJob, triggerName string) string {
+	return fmt.Sprintf("/%s/%s/%s", cronJob.Namespace, cronJob.Name, triggerName)
+}
+
+// sourceKind names the kind of the trigger's source, for our metrics.
+func sourceKind(trigger *batch.EventTrigger) string {
+	switch {
+	case trigger.NATS != nil:
+		return "nats"
+	case trigger.Kafka != nil:
+		return "kafka"
+	case trigger.SQS != nil:
+		return "sqs"
+	case trigger.CloudEvents != nil:
+		return "cloudevents"
+	}
+	return "unknown"
+}
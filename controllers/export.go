@@ -0,0 +1,173 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kbatchv1beta1 "k8s.io/api/batch/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+// lastAppliedAnnotation is kubectl's record of the CronJob's last applied
+// configuration, which means nothing for the exported one.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// ExportCronJob renders the CronJob as a built-in batch/v1 CronJob, ready to
+// apply, with its JobTemplateRef resolved through the reader, which may be
+// nil if it hasn't got one.  Defaults, CronJobPolicies' among them, are
+// already on the CronJob once it's been admitted.
+//
+// Settings the built-in CronJob has no equivalent for are left out, and
+// listed alongside, so that nobody loses them without knowing.
+func ExportCronJob(ctx context.Context, reader client.Reader, cronJob *batch.CronJob) (*unstructured.Unstructured, []string, error) {
+	spec := &cronJob.Spec
+	if spec.ScheduleGranularity == batch.SecondGranularity {
+		return nil, nil, fmt.Errorf("spec.scheduleGranularity: schedules to the second have no equivalent")
+	}
+	if schedule.IsRepeatingInterval(spec.Schedule) {
+		return nil, nil, fmt.Errorf("spec.schedule: repeating intervals have no equivalent")
+	}
+	if spec.CalendarRef != nil {
+		return nil, nil, fmt.Errorf("spec.calendarRef: runs on a calendar have no equivalent")
+	}
+	if spec.WorkloadRef != nil {
+		return nil, nil, fmt.Errorf("spec.workloadRef: runs of anything but a Job have no equivalent")
+	}
+	if spec.JobTemplateRef != nil && reader == nil {
+		return nil, nil, fmt.Errorf("spec.jobTemplateRef: JobTemplate %s can't be resolved without a cluster", spec.JobTemplateRef.Name)
+	}
+	template, err := resolveJobTemplate(ctx, reader, cronJob.Namespace, spec.JobTemplateRef, &spec.JobTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+	template = template.DeepCopy()
+
+	var dropped []string
+	leftOut := func(field string, set bool) {
+		if set {
+			dropped = append(dropped, fmt.Sprintf("spec.%s has no equivalent, and was left out", field))
+		}
+	}
+	leftOut("dstPolicy", spec.DSTPolicy != "" && spec.DSTPolicy != batch.RunEarlierOnDST)
+	leftOut("maxMissedRuns", spec.MaxMissedRuns != nil)
+	leftOut("clockSkewToleranceSeconds", spec.ClockSkewToleranceSeconds != nil)
+	leftOut("maxConcurrentRuns", spec.MaxConcurrentRuns != nil)
+	leftOut("skipNextRuns", spec.SkipNextRuns != nil && *spec.SkipNextRuns > 0)
+	leftOut("suspendUntil", spec.SuspendUntil != nil)
+	leftOut("resumePolicy", spec.ResumePolicy == batch.RunMissedOnResume)
+	leftOut("runAt", len(spec.RunAt) > 0)
+	leftOut("blackoutWindows", len(spec.BlackoutWindows) > 0)
+	leftOut("holidayCalendarRef", spec.HolidayCalendarRef != nil)
+	leftOut("retryPolicy", spec.RetryPolicy != nil)
+	leftOut("failurePolicy", spec.FailurePolicy != nil)
+	leftOut("dependsOn", len(spec.DependsOn) > 0)
+	leftOut("hooks", spec.Hooks != nil)
+	leftOut("notifications", len(spec.Notifications) > 0)
+	leftOut("archiveLogs", spec.ArchiveLogs != "" && spec.ArchiveLogs != batch.ArchiveNever)
+	leftOut("triggers", len(spec.Triggers) > 0)
+	leftOut("secretInjection", spec.SecretInjection != nil)
+	leftOut("networkProfile", spec.NetworkProfile != nil)
+	leftOut("meshSidecar", spec.MeshSidecar == batch.QuitMeshSidecar)
+	leftOut("conditionGate", spec.ConditionGate != nil)
+	leftOut("queueName", spec.QueueName != "")
+
+	concurrencyPolicy := kbatchv1beta1.ConcurrencyPolicy(spec.ConcurrencyPolicy)
+	if spec.ConcurrencyPolicy == batch.QueueConcurrent {
+		concurrencyPolicy = kbatchv1beta1.ForbidConcurrent
+		dropped = append(dropped, "spec.concurrencyPolicy: Queue has no equivalent, and was exported as Forbid")
+	}
+	suspend := spec.Suspend
+	if spec.Paused {
+		paused := true
+		suspend = &paused
+		dropped = append(dropped, "spec.paused: exported as spec.suspend")
+	}
+	// a CronJob that only runs when triggered would run on its schedule
+	// without the triggers
+	if !spec.SchedulesRuns() {
+		notOnSchedule := true
+		suspend = &notOnSchedule
+		dropped = append(dropped, "spec.runOnSchedule: false was exported as spec.suspend")
+	}
+
+	// our run deadline works by lowering the job's own active deadline, so
+	// setting that up front comes to the same thing
+	if spec.RunDeadlineSeconds != nil {
+		if ads := template.Spec.ActiveDeadlineSeconds; ads == nil || *ads > *spec.RunDeadlineSeconds {
+			runDeadlineSeconds := *spec.RunDeadlineSeconds
+			template.Spec.ActiveDeadlineSeconds = &runDeadlineSeconds
+		}
+	}
+	if spec.JobTTLSecondsAfterFinished != nil && template.Spec.TTLSecondsAfterFinished == nil {
+		ttl := *spec.JobTTLSecondsAfterFinished
+		template.Spec.TTLSecondsAfterFinished = &ttl
+	}
+
+	var annotations map[string]string
+	for k, v := range cronJob.Annotations {
+		if k == lastAppliedAnnotation {
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[k] = v
+	}
+
+	// the v1beta1 types are all we have, but batch/v1 CronJobs have the same
+	// shape, bar the time zone
+	native := &kbatchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cronJob.Name,
+			Namespace:   cronJob.Namespace,
+			Labels:      cronJob.Labels,
+			Annotations: annotations,
+		},
+		Spec: kbatchv1beta1.CronJobSpec{
+			Schedule:                   spec.Schedule,
+			StartingDeadlineSeconds:    spec.StartingDeadlineSeconds,
+			ConcurrencyPolicy:          concurrencyPolicy,
+			Suspend:                    suspend,
+			JobTemplate:                *template,
+			SuccessfulJobsHistoryLimit: spec.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     spec.FailedJobsHistoryLimit,
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(native)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := &unstructured.Unstructured{Object: content}
+	out.SetAPIVersion("batch/v1")
+	out.SetKind("CronJob")
+	unstructured.RemoveNestedField(out.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(out.Object, "status")
+	if spec.TimeZone != nil {
+		if err := unstructured.SetNestedField(out.Object, *spec.TimeZone, "spec", "timeZone"); err != nil {
+			return nil, nil, err
+		}
+	}
+	return out, dropped, nil
+}
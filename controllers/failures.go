@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// finishedRun is a run, made up of all of its attempts and hooks, that has
+// nothing left to do.
+type finishedRun struct {
+	// the run's nominal schedule time
+	scheduledTime time.Time
+	// whether the run as a whole succeeded
+	succeeded bool
+	// when the run's first job started, and its last job finished
+	startTime, completionTime *metav1.Time
+	// the run's last job to finish
+	jobName string
+	// the number of the run's jobs that succeeded and failed
+	succeededJobs, failedJobs int64
+	// who triggered the run by hand, if anyone did
+	triggeredBy string
+}
+
+// finishedRunsSince finds the runs scheduled after the given time that have
+// finished, oldest first.  Runs that are still going, or are due a retry or
+// their next hook, haven't finished yet.
+func finishedRunsSince(cronJob *batch.CronJob, jobs []kbatch.Job, retries []pendingRetry, hookSteps []pendingHookStep, since *time.Time) []finishedRun {
+	runs := make(map[runKey][]kbatch.Job)
+	for _, job := range jobs {
+		key, ok := jobRunKey(&job)
+		if !ok || (since != nil && !key.time().After(*since)) {
+			continue
+		}
+		runs[key] = append(runs[key], job)
+	}
+	for _, retry := range retries {
+		key, _ := jobRunKey(retry.failedJob)
+		delete(runs, key)
+	}
+	for _, step := range hookSteps {
+		key, _ := jobRunKey(step.finishedJob)
+		delete(runs, key)
+	}
+
+	var finished []finishedRun
+	for key, run := range runs {
+		scheduledTime := key.time()
+		if runSucceeded(cronJob, run) {
+			finished = append(finished, summarizeRun(scheduledTime, true, run))
+			continue
+		}
+		stillGoing, failed := false, false
+		for i := range run {
+			switch {
+			case jobFailure(&run[i]) != nil, nextStepSkipped(&run[i]):
+				failed = true
+			case !jobCompleted(&run[i]):
+				stillGoing = true
+			}
+		}
+		if failed && !stillGoing {
+			finished = append(finished, summarizeRun(scheduledTime, false, run))
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].scheduledTime.Before(finished[j].scheduledTime)
+	})
+	return finished
+}
+
+// summarizeRun works out when the finished run made up of the given jobs
+// started and finished, which of its jobs finished last, and how many of them
+// succeeded and failed.
+func summarizeRun(scheduledTime time.Time, succeeded bool, jobs []kbatch.Job) finishedRun {
+	run := finishedRun{scheduledTime: scheduledTime, succeeded: succeeded}
+	for i := range jobs {
+		job := &jobs[i]
+		if start := job.Status.StartTime; start != nil && (run.startTime == nil || start.Before(run.startTime)) {
+			run.startTime = start.DeepCopy()
+		}
+		if finish := jobFinishTime(job); finish != nil && (run.completionTime == nil || run.completionTime.Before(finish)) {
+			run.completionTime = finish.DeepCopy()
+			run.jobName = job.Name
+		}
+		switch {
+		case jobFailure(job) != nil:
+			run.failedJobs++
+		case jobCompleted(job):
+			run.succeededJobs++
+		}
+		if initiator := job.Annotations[triggeredByAnnotation]; initiator != "" {
+			run.triggeredBy = initiator
+		}
+	}
+	return run
+}
+
+// jobFinishTime returns when the job completed or failed, or nil if it
+// hasn't finished.
+func jobFinishTime(job *kbatch.Job) *metav1.Time {
+	if failure := jobFailure(job); failure != nil {
+		return &failure.LastTransitionTime
+	}
+	if jobCompleted(job) {
+		return job.Status.CompletionTime
+	}
+	return nil
+}
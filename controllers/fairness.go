@@ -0,0 +1,464 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+RunQuotas limit each namespace on its own, but nothing stops one tenant whose
+CronJobs run every minute from taking up all of the room on the cluster, and
+everybody else's nightly batches from waiting behind it.  So on top of them, the
+controller can hold each tenant -- a namespace, or a team -- to a budget of
+active jobs, and all of them together to a total.
+
+Every run counts, whether it was scheduled, asked for with runAt, triggered or
+retried.  Runs that would go over a budget wait in line for their tenant.  When
+room under the total is short, it goes to tenants in turn, rather than to
+whichever CronJob happens to be reconciled first, which would almost always be
+the one that runs most often: a run only goes ahead if there's room left for the
+runs ahead of it, which are those waiting for its own tenant before it, and one
+from each tenant whose turn comes first.  Tenants never wait for each other
+otherwise, and a CronJob that doesn't take its turn promptly when woken loses
+it.  We only hear about the jobs of the CronJob being reconciled, so we keep
+count of every CronJob's active jobs as we go, and wake the CronJobs whose turn
+it is as soon as there's room for them.
+
+The counts live in memory: after a restart, they're built back up as CronJobs
+are reconciled, which they all are as the cache fills.
+*/
+
+// TenantKind says what the CronJobs' tenants are.
+type TenantKind string
+
+const (
+	// TenantByNamespace makes each namespace a tenant.
+	TenantByNamespace TenantKind = "Namespace"
+
+	// TenantByTeam makes each team a tenant, as named by the CronJobs' team
+	// label.  CronJobs without one belong to their namespace.
+	TenantByTeam TenantKind = "Team"
+)
+
+// pendingRunTimeout is how long a run we've let through counts against its
+// tenant before we see its job.  Jobs normally turn up long before then; the
+// run may have failed to start after all.
+const pendingRunTimeout = time.Minute
+
+// staleWaitTimeout is how long a CronJob stays in line without asking again.
+// Waiting CronJobs check back every quotaRecheckInterval; one that hasn't has
+// gone, or no longer has a run waiting.
+const staleWaitTimeout = 3 * quotaRecheckInterval
+
+// turnTimeout is how long a CronJob we've woken for its turn has to take it,
+// before it loses its place in line to those behind it.  CronJobs waiting
+// behind it check back this often.
+const turnTimeout = 15 * time.Second
+
+var (
+	tenantActiveJobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_tenant_active_jobs",
+		Help: "Number of jobs a tenant's CronJobs have running, as counted against its budget",
+	}, []string{"tenant"})
+
+	tenantDeferrals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_tenant_deferrals_total",
+		Help: "Number of runs that had to wait for room in their tenant's budget, or for their tenant's turn",
+	}, []string{"tenant"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(tenantActiveJobs, tenantDeferrals)
+}
+
+// cronJobUsage is what a CronJob counts against its tenant's budget.
+type cronJobUsage struct {
+	tenant string
+	// active is how many of its jobs were running when last reconciled
+	active int32
+	// pending is when runs we've let through, whose jobs we're yet to see,
+	// were let through
+	pending []time.Time
+}
+
+// waiter is a CronJob with a run waiting for room.
+type waiter struct {
+	cronJob types.NamespacedName
+	// lastAsked is when it last asked for room
+	lastAsked time.Time
+	// wokenAt is when we woke it for its turn, if we have since it last asked
+	wokenAt time.Time
+}
+
+// TenantScheduler holds tenants to their budgets of active jobs, sharing out
+// room between them in turn.  A nil TenantScheduler lets every run through.
+type TenantScheduler struct {
+	// By says what the tenants are.  Defaults to TenantByNamespace.
+	By TenantKind
+	// MaxActiveJobs is the most jobs any one tenant may have running at once.
+	// Unlimited if zero.
+	MaxActiveJobs int32
+	// Budgets overrides MaxActiveJobs for particular tenants.
+	Budgets map[string]int32
+	// TotalMaxActiveJobs is the most jobs all tenants together may have
+	// running at once.  Unlimited if zero.
+	TotalMaxActiveJobs int32
+
+	mu      sync.Mutex
+	usage   map[types.NamespacedName]*cronJobUsage
+	waiting map[string][]waiter
+	// turns lists the tenants with CronJobs waiting, whoever's turn is next
+	// first
+	turns  []string
+	wakeUp chan event.GenericEvent
+}
+
+// tenantBlock describes why a run has to wait for its tenant.
+type tenantBlock struct {
+	tenant string
+	reason string
+	limit  string
+	// recheck is how soon to check back, in case we're not woken up
+	recheck time.Duration
+}
+
+// isTenantBlockReason reports whether a QuotaExceeded condition's reason
+// is one of a tenantBlock's.
+func isTenantBlockReason(reason string) bool {
+	switch reason {
+	case "TenantBudget", "TotalBudget", "TenantTurn":
+		return true
+	}
+	return false
+}
+
+// TenantOf returns the tenant the CronJob belongs to.
+func (s *TenantScheduler) TenantOf(cronJob metav1.Object) string {
+	if s.By == TenantByTeam {
+		if team := cronJob.GetLabels()[batch.TeamLabel]; team != "" {
+			return "team:" + team
+		}
+	}
+	return "namespace:" + cronJob.GetNamespace()
+}
+
+// WakeUps returns the channel of CronJobs whose turn has come, to be watched
+// by their controller.
+func (s *TenantScheduler) WakeUps() <-chan event.GenericEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	return s.wakeUp
+}
+
+func (s *TenantScheduler) init() {
+	if s.usage == nil {
+		s.usage = make(map[types.NamespacedName]*cronJobUsage)
+		s.waiting = make(map[string][]waiter)
+	}
+	if s.wakeUp == nil {
+		// a full channel only delays wakeups until the waiters check back
+		s.wakeUp = make(chan event.GenericEvent, 100)
+	}
+}
+
+// budget returns the tenant's budget, or zero if it hasn't got one.
+func (s *TenantScheduler) budget(tenant string) int32 {
+	if budget, ok := s.Budgets[tenant]; ok {
+		return budget
+	}
+	return s.MaxActiveJobs
+}
+
+// Observe records how many jobs the CronJob has running, waking whoever's
+// next in line if that's made room.
+func (s *TenantScheduler) Observe(cronJob metav1.Object, active int, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	key := types.NamespacedName{Namespace: cronJob.GetNamespace(), Name: cronJob.GetName()}
+	tenant := s.TenantOf(cronJob)
+	usage, ok := s.usage[key]
+	if !ok || usage.tenant != tenant {
+		if ok {
+			s.updateGauge(usage.tenant)
+		}
+		usage = &cronJobUsage{tenant: tenant}
+		s.usage[key] = usage
+	}
+	// the jobs we've turned up since last time are the runs we let through
+	if started := int32(active) - usage.active; started > 0 {
+		if int(started) >= len(usage.pending) {
+			usage.pending = nil
+		} else {
+			usage.pending = usage.pending[started:]
+		}
+	}
+	usage.active = int32(active)
+	s.expire(now)
+	s.updateGauge(tenant)
+	s.wakeNext(now)
+}
+
+// Forget drops a CronJob that's gone, waking whoever's next in line if that's
+// made room.
+func (s *TenantScheduler) Forget(cronJob types.NamespacedName, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	if usage, ok := s.usage[cronJob]; ok {
+		delete(s.usage, cronJob)
+		s.updateGauge(usage.tenant)
+		s.dropWaiter(usage.tenant, cronJob)
+	}
+	s.wakeNext(now)
+}
+
+// Admit checks whether the CronJob may start a run now, of whatever kind.  If
+// so, the run counts against its tenant from then on; if not, the CronJob
+// waits in line for its tenant, and is woken up when it's its turn.
+func (s *TenantScheduler) Admit(cronJob metav1.Object, now time.Time) *tenantBlock {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	s.expire(now)
+
+	key := types.NamespacedName{Namespace: cronJob.GetNamespace(), Name: cronJob.GetName()}
+	tenant := s.TenantOf(cronJob)
+
+	var block *tenantBlock
+	switch {
+	case s.overBudget(tenant):
+		block = &tenantBlock{tenant: tenant, reason: "TenantBudget", limit: fmt.Sprintf("at most %d jobs running at once", s.budget(tenant)), recheck: quotaRecheckInterval}
+	case s.TotalMaxActiveJobs > 0 && s.totalActive() >= s.TotalMaxActiveJobs:
+		block = &tenantBlock{tenant: tenant, reason: "TotalBudget", limit: fmt.Sprintf("at most %d jobs running at once across all tenants", s.TotalMaxActiveJobs), recheck: quotaRecheckInterval}
+	case !s.hasTurn(tenant, key):
+		block = &tenantBlock{tenant: tenant, reason: "TenantTurn", limit: "room to each tenant in turn", recheck: turnTimeout}
+	}
+	if block != nil {
+		s.addWaiter(tenant, key, now)
+		return block
+	}
+
+	s.dropWaiter(tenant, key)
+	// whoever goes next, it isn't this tenant
+	s.moveToBack(tenant)
+	usage, ok := s.usage[key]
+	if !ok || usage.tenant != tenant {
+		usage = &cronJobUsage{tenant: tenant}
+		s.usage[key] = usage
+	}
+	usage.pending = append(usage.pending, now)
+	s.updateGauge(tenant)
+	// there may be room for the next in line too
+	s.wakeNext(now)
+	return nil
+}
+
+// Load returns how many jobs the CronJob's tenant, and all tenants, have
+// running.
+func (s *TenantScheduler) Load(cronJob metav1.Object) (tenant, total int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active(s.TenantOf(cronJob)), s.totalActive()
+}
+
+// active returns how many jobs the tenant has running, counting runs we've
+// let through but haven't seen the jobs of yet.
+func (s *TenantScheduler) active(tenant string) int32 {
+	var active int32
+	for _, usage := range s.usage {
+		if usage.tenant == tenant {
+			active += usage.active + int32(len(usage.pending))
+		}
+	}
+	return active
+}
+
+// totalActive returns how many jobs all tenants have running.
+func (s *TenantScheduler) totalActive() int32 {
+	var active int32
+	for _, usage := range s.usage {
+		active += usage.active + int32(len(usage.pending))
+	}
+	return active
+}
+
+// overBudget reports whether the tenant has no room for another job.
+func (s *TenantScheduler) overBudget(tenant string) bool {
+	budget := s.budget(tenant)
+	return budget > 0 && s.active(tenant) >= budget
+}
+
+// hasTurn reports whether there's room for a run of the CronJob once the
+// runs ahead of it have gone: those waiting for its tenant before it, and,
+// if room under the total is shared out, one from each tenant with room of
+// its own whose turn comes first.  A CronJob that isn't in line yet goes at
+// the back.
+func (s *TenantScheduler) hasTurn(tenant string, cronJob types.NamespacedName) bool {
+	waiters := s.waiting[tenant]
+	ahead := int32(len(waiters))
+	for i := range waiters {
+		if waiters[i].cronJob == cronJob {
+			ahead = int32(i)
+			break
+		}
+	}
+	if budget := s.budget(tenant); budget > 0 && s.active(tenant)+ahead >= budget {
+		return false
+	}
+	if s.TotalMaxActiveJobs == 0 {
+		return true
+	}
+	for _, t := range s.turns {
+		if t == tenant {
+			break
+		}
+		if !s.overBudget(t) {
+			ahead++
+		}
+	}
+	return s.totalActive()+ahead < s.TotalMaxActiveJobs
+}
+
+// wakeNext wakes the first CronJob in line for each tenant, if it's its turn.
+func (s *TenantScheduler) wakeNext(now time.Time) {
+	if s.TotalMaxActiveJobs > 0 && s.totalActive() >= s.TotalMaxActiveJobs {
+		return
+	}
+	for _, tenant := range s.turns {
+		next := &s.waiting[tenant][0]
+		if !next.wokenAt.IsZero() || s.overBudget(tenant) || !s.hasTurn(tenant, next.cronJob) {
+			continue
+		}
+		select {
+		case s.wakeUp <- event.GenericEvent{Object: &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: next.cronJob.Namespace, Name: next.cronJob.Name}}}:
+			next.wokenAt = now
+		default:
+		}
+	}
+}
+
+// addWaiter puts the CronJob in line for its tenant, or notes that it's
+// still there.
+func (s *TenantScheduler) addWaiter(tenant string, cronJob types.NamespacedName, now time.Time) {
+	waiters := s.waiting[tenant]
+	for i := range waiters {
+		if waiters[i].cronJob == cronJob {
+			waiters[i].lastAsked = now
+			waiters[i].wokenAt = time.Time{}
+			return
+		}
+	}
+	if len(waiters) == 0 {
+		s.turns = append(s.turns, tenant)
+	}
+	s.waiting[tenant] = append(waiters, waiter{cronJob: cronJob, lastAsked: now})
+	tenantDeferrals.WithLabelValues(tenant).Inc()
+}
+
+// dropWaiter takes the CronJob out of line for its tenant.
+func (s *TenantScheduler) dropWaiter(tenant string, cronJob types.NamespacedName) {
+	waiters := s.waiting[tenant]
+	for i := range waiters {
+		if waiters[i].cronJob == cronJob {
+			s.setWaiters(tenant, append(waiters[:i:i], waiters[i+1:]...))
+			return
+		}
+	}
+}
+
+// setWaiters replaces the line for the tenant, taking the tenant out of turn
+// once nobody's left in it.
+func (s *TenantScheduler) setWaiters(tenant string, waiters []waiter) {
+	if len(waiters) > 0 {
+		s.waiting[tenant] = waiters
+		return
+	}
+	delete(s.waiting, tenant)
+	for i, t := range s.turns {
+		if t == tenant {
+			s.turns = append(s.turns[:i:i], s.turns[i+1:]...)
+			return
+		}
+	}
+}
+
+// moveToBack gives the tenant the last turn.
+func (s *TenantScheduler) moveToBack(tenant string) {
+	for i, t := range s.turns {
+		if t == tenant {
+			s.turns = append(append(s.turns[:i:i], s.turns[i+1:]...), tenant)
+			return
+		}
+	}
+}
+
+// expire stops counting runs whose jobs never turned up, and drops waiters
+// that have stopped asking, or haven't taken their turn.
+func (s *TenantScheduler) expire(now time.Time) {
+	for _, usage := range s.usage {
+		for len(usage.pending) > 0 && now.Sub(usage.pending[0]) > pendingRunTimeout {
+			usage.pending = usage.pending[1:]
+		}
+	}
+	for tenant, waiters := range s.waiting {
+		var current []waiter
+		for _, w := range waiters {
+			if now.Sub(w.lastAsked) <= staleWaitTimeout && (w.wokenAt.IsZero() || now.Sub(w.wokenAt) <= turnTimeout) {
+				current = append(current, w)
+			}
+		}
+		s.setWaiters(tenant, current)
+	}
+}
+
+func (s *TenantScheduler) updateGauge(tenant string) {
+	tenantActiveJobs.WithLabelValues(tenant).Set(float64(s.active(tenant)))
+}
+
+// tenantBlockedCondition describes a run waiting for its tenant.
+func tenantBlockedCondition(block *tenantBlock, scheduledTime time.Time) metav1.Condition {
+	return metav1.Condition{
+		Type:    batch.QuotaExceededCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  block.reason,
+		Message: fmt.Sprintf("Run scheduled at %s is waiting for tenant %s, since the controller allows %s", scheduledTime.Format(time.RFC3339), block.tenant, block.limit),
+	}
+}
@@ -0,0 +1,117 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The controller's feature gates.
+const (
+	// AdoptOrphanedJobs has CronJobs adopt the jobs they made that have lost
+	// their owner, say after the CronJob was deleted with orphaning and
+	// recreated.
+	AdoptOrphanedJobs = "AdoptOrphanedJobs"
+
+	// ImpersonateJobCreation has jobs created as the service accounts they
+	// run as, so that admission policies and quotas apply to them as if the
+	// tenant had created them.
+	ImpersonateJobCreation = "ImpersonateJobCreation"
+
+	// PodWorkloads lets CronJobs run bare Pods instead of Jobs.  It has us
+	// watch every Pod in the namespaces we look after.
+	PodWorkloads = "PodWorkloads"
+
+	// ArgoWorkflows lets CronJobs run Argo Workflows instead of Jobs.  Argo
+	// Workflows has to be installed in the cluster.
+	ArgoWorkflows = "ArgoWorkflows"
+
+	// TektonPipelineRuns lets CronJobs run Tekton PipelineRuns instead of
+	// Jobs.  Tekton Pipelines has to be installed in the cluster.
+	TektonPipelineRuns = "TektonPipelineRuns"
+)
+
+// defaultFeatureGates lists every feature gate, and whether it's on unless
+// set otherwise.
+var defaultFeatureGates = map[string]bool{
+	AdoptOrphanedJobs:      true,
+	ImpersonateJobCreation: false,
+	PodWorkloads:           false,
+	ArgoWorkflows:          false,
+	TektonPipelineRuns:     false,
+}
+
+// FeatureGates turns the controller's optional behaviours on and off, by
+// name.  Gates that aren't set keep their defaults.  It doubles as a flag,
+// set like --feature-gates=AdoptOrphanedJobs=false,...
+type FeatureGates map[string]bool
+
+// Enabled reports whether the named feature is on.
+func (g FeatureGates) Enabled(name string) bool {
+	if on, ok := g[name]; ok {
+		return on
+	}
+	return defaultFeatureGates[name]
+}
+
+// Validate rejects gates we don't know about, which are most likely typos.
+func (g FeatureGates) Validate() error {
+	for name := range g {
+		if _, ok := defaultFeatureGates[name]; !ok {
+			return fmt.Errorf("unknown feature gate %q", name)
+		}
+	}
+	return nil
+}
+
+// String implements flag.Value.
+func (g *FeatureGates) String() string {
+	if g == nil {
+		return ""
+	}
+	var pairs []string
+	for name, on := range *g {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, on))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value, adding to the gates already set.
+func (g *FeatureGates) Set(value string) error {
+	if *g == nil {
+		*g = make(FeatureGates)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("feature gate %q isn't of the form Name=true|false", pair)
+		}
+		on, err := strconv.ParseBool(parts[1])
+		if err != nil {
+			return fmt.Errorf("feature gate %q isn't of the form Name=true|false", pair)
+		}
+		(*g)[strings.TrimSpace(parts[0])] = on
+	}
+	return nil
+}
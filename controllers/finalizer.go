@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/logarchive"
+)
+
+// cleanupFinalizer holds on to CronJobs until we've cleaned up whatever they
+// left where owner references can't reach.
+const cleanupFinalizer = "batch.tutorial.kubebuilder.io/cleanup"
+
+// needsCleanup checks whether anything we do for CronJobs outlives them
+// without our help.  Only log stores that keep archives outside the cluster
+// do: the ConfigMaps of the built-in store are garbage collected along with
+// their CronJob.  If nothing does, we don't bother with the finalizer, since
+// it would only get in the way of deleting CronJobs once we're gone.
+func (r *CronJobReconciler) needsCleanup() bool {
+	_, ok := r.LogStore.(logarchive.Sweeper)
+	return ok
+}
+
+// ensureFinalizer adds our finalizer to the CronJob, if it needs one.
+func (r *CronJobReconciler) ensureFinalizer(ctx context.Context, cronJob *batch.CronJob) error {
+	if !r.needsCleanup() || controllerutil.ContainsFinalizer(cronJob, cleanupFinalizer) {
+		return nil
+	}
+	patch := client.MergeFromWithOptions(cronJob.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.AddFinalizer(cronJob, cleanupFinalizer)
+	return r.Patch(ctx, cronJob, patch)
+}
+
+// finalize cleans up after a CronJob that's being deleted, then lets it go.
+// If cleaning up fails, we keep the finalizer and try again.  A CronJob that
+// got the finalizer under a store we've since been configured away from is
+// let go all the same.
+func (r *CronJobReconciler) finalize(ctx context.Context, cronJob *batch.CronJob) error {
+	if !controllerutil.ContainsFinalizer(cronJob, cleanupFinalizer) {
+		return nil
+	}
+	if sweeper, ok := r.LogStore.(logarchive.Sweeper); ok {
+		if err := sweeper.DeleteAll(ctx, cronJob.Namespace, map[string]string{batch.CronJobNameLabel: cronJob.Name}); err != nil {
+			return err
+		}
+	}
+	patch := client.MergeFromWithOptions(cronJob.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(cronJob, cleanupFinalizer)
+	return r.Patch(ctx, cronJob, patch)
+}
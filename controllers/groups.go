@@ -0,0 +1,132 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// groupsFor returns the CronJobGroups the CronJob belongs to, sorted by name.
+func (r *CronJobReconciler) groupsFor(ctx context.Context, cronJob *batch.CronJob) ([]batch.CronJobGroup, error) {
+	var groups batch.CronJobGroupList
+	if err := r.List(ctx, &groups, client.InNamespace(cronJob.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var member []batch.CronJobGroup
+	for _, group := range groups.Items {
+		// the webhook keeps out broken selectors, and one that slipped
+		// through shouldn't take over every CronJob in the namespace
+		if matches, err := selectorMatches(&group.Spec.Selector, cronJob.Labels); err == nil && matches {
+			member = append(member, group)
+		}
+	}
+	return member, nil
+}
+
+// suspendingGroup returns the first of the groups that's suspended, or nil
+// if none are.
+func suspendingGroup(groups []batch.CronJobGroup) *batch.CronJobGroup {
+	for i := range groups {
+		if suspend := groups[i].Spec.Suspend; suspend != nil && *suspend {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+// groupPriorityClass returns the priority class the first of the groups
+// with one asks for, if any.
+func groupPriorityClass(groups []batch.CronJobGroup) string {
+	for _, group := range groups {
+		if group.Spec.PriorityClassName != "" {
+			return group.Spec.PriorityClassName
+		}
+	}
+	return ""
+}
+
+// groupDependencies returns the members that the CronJob's runs wait for
+// because of the groups' sequences: the one before it in each sequence it's
+// in.
+func groupDependencies(groups []batch.CronJobGroup, cronJob *batch.CronJob) []batch.CronJobDependency {
+	var deps []batch.CronJobDependency
+	for _, group := range groups {
+		for i, name := range group.Spec.Sequence {
+			if name == cronJob.Name && i > 0 {
+				deps = append(deps, batch.CronJobDependency{Name: group.Spec.Sequence[i-1]})
+			}
+		}
+	}
+	return deps
+}
+
+// membersOf maps a CronJobGroup to requests for its members, so that they
+// notice it being suspended or resumed straight away.
+func (r *CronJobReconciler) membersOf(obj client.Object) []reconcile.Request {
+	group, ok := obj.(*batch.CronJobGroup)
+	if !ok {
+		return nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(&group.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+
+	var cronJobs batch.CronJobList
+	if err := r.List(context.Background(), &cronJobs, client.InNamespace(group.Namespace), client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		r.Log.Error(err, "unable to list members of CronJobGroup", "group", group.Name)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs.Items))
+	for i, cronJob := range cronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
+
+// successorsOf maps a CronJob to requests for the members that come after
+// it in a group's sequence, so that runs waiting on it are reconsidered as
+// its runs finish.
+func (r *CronJobReconciler) successorsOf(obj client.Object) []reconcile.Request {
+	var groups batch.CronJobGroupList
+	if err := r.List(context.Background(), &groups, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list CronJobGroups", "cronjob", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, group := range groups.Items {
+		for i, name := range group.Spec.Sequence {
+			if name == obj.GetName() && i+1 < len(group.Spec.Sequence) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: obj.GetNamespace(),
+					Name:      group.Spec.Sequence[i+1],
+				}})
+			}
+		}
+	}
+	return requests
+}
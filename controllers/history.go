@@ -0,0 +1,52 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// maxRecentRuns bounds the number of finished runs kept in status.
+const maxRecentRuns = 10
+
+// recordRecentRuns adds the given newly finished runs, oldest first, to the
+// run history in status, dropping the oldest entries beyond our bound.  It
+// returns the entries it dropped.
+func recordRecentRuns(cronJob *batch.CronJob, runs []finishedRun) []batch.RunRecord {
+	for _, run := range runs {
+		result := batch.RunFailed
+		if run.succeeded {
+			result = batch.RunSucceeded
+		}
+		cronJob.Status.RecentRuns = append(cronJob.Status.RecentRuns, batch.RunRecord{
+			ScheduledTime:  metav1.NewTime(run.scheduledTime),
+			StartTime:      run.startTime,
+			CompletionTime: run.completionTime,
+			Result:         result,
+			JobName:        run.jobName,
+			TriggeredBy:    run.triggeredBy,
+		})
+	}
+	var dropped []batch.RunRecord
+	if excess := len(cronJob.Status.RecentRuns) - maxRecentRuns; excess > 0 {
+		dropped = cronJob.Status.RecentRuns[:excess]
+		cronJob.Status.RecentRuns = cronJob.Status.RecentRuns[excess:]
+	}
+	return dropped
+}
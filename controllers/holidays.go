@@ -0,0 +1,86 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+// holidayCalendarRefKey indexes CronJobs by the HolidayCalendar they refer
+// to.
+const holidayCalendarRefKey = ".spec.holidayCalendarRef.name"
+
+// holidaysFor returns the dates of the holidays in the CronJob's
+// HolidayCalendar, as YYYY-MM-DD, or nil if it doesn't have one.  A calendar
+// that's missing is an error rather than an empty one, since running on a
+// holiday is usually worse than running late.
+func (r *CronJobReconciler) holidaysFor(ctx context.Context, cronJob *batch.CronJob) (map[string]bool, error) {
+	ref := cronJob.Spec.HolidayCalendarRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	var calendar batch.HolidayCalendar
+	if err := r.Get(ctx, types.NamespacedName{Namespace: cronJob.Namespace, Name: ref.Name}, &calendar); err != nil {
+		return nil, fmt.Errorf("unable to get HolidayCalendar %s: %w", ref.Name, err)
+	}
+	return calendar.Dates()
+}
+
+// onHoliday checks whether t falls on one of the holidays, going by the date
+// in the CronJob's time zone.
+func onHoliday(cronJob *batch.CronJob, holidays map[string]bool, t time.Time) bool {
+	return holidays[t.In(scheduleLocation(cronJob)).Format("2006-01-02")]
+}
+
+// withHolidayPolicy wraps the CronJob's schedule so that runs falling on a
+// holiday move to the next business day, if that's its holiday policy.
+// Runs that are skipped instead stay in the schedule, so that the skip gets
+// recorded like any other.
+func withHolidayPolicy(cronJob *batch.CronJob, sched schedule.Schedule, holidays map[string]bool) schedule.Schedule {
+	if holidays == nil || cronJob.Spec.HolidayPolicy != batch.NextBusinessDayOnHolidays {
+		return sched
+	}
+	return schedule.NextBusinessDay(sched, func(t time.Time) bool {
+		return onHoliday(cronJob, holidays, t)
+	})
+}
+
+// holidayCalendarUsersOf maps a HolidayCalendar to requests for the CronJobs
+// that refer to it, so that they pick up changes to it straight away.
+func (r *CronJobReconciler) holidayCalendarUsersOf(obj client.Object) []reconcile.Request {
+	var cronJobs batch.CronJobList
+	if err := r.List(context.Background(), &cronJobs, client.InNamespace(obj.GetNamespace()), client.MatchingFields{holidayCalendarRefKey: obj.GetName()}); err != nil {
+		r.Log.Error(err, "unable to list CronJobs using HolidayCalendar", "calendar", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs.Items))
+	for i, cronJob := range cronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
@@ -0,0 +1,224 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/audit"
+)
+
+var (
+	// hookAnnotation marks the jobs we create for a run's hooks with which
+	// hook they are.  A run's main job doesn't have it.
+	hookAnnotation = "batch.tutorial.kubebuilder.io/hook"
+
+	// nextStepSkippedAnnotation marks a finished job whose run was due
+	// another job, a retry or its next step, that we skipped instead, with
+	// why.  The run stops there.
+	nextStepSkippedAnnotation = "batch.tutorial.kubebuilder.io/next-step-skipped"
+)
+
+const (
+	mainRun     = ""
+	preRunHook  = "pre-run"
+	postRunHook = "post-run"
+)
+
+// pendingHookStep is a run whose latest step succeeded, and which has
+// another step still to start.
+type pendingHookStep struct {
+	// the step that succeeded
+	finishedJob *kbatch.Job
+	// the run's nominal schedule time
+	scheduledTime time.Time
+	// the step to create next: the main job or the post-run hook
+	next string
+}
+
+// jobCompleted checks whether the job has completed successfully.
+func jobCompleted(job *kbatch.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == kbatch.JobComplete && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingHookSteps finds the runs that are due their main job because their
+// pre-run hook succeeded, or due their post-run hook because their main job
+// succeeded.
+func pendingHookSteps(hooks *batch.RunHooks, jobs []kbatch.Job) []pendingHookStep {
+	if hooks == nil || (hooks.PreRun == nil && hooks.PostRun == nil) {
+		return nil
+	}
+
+	// group the steps we've already started by run
+	runs := make(map[runKey]map[string]*kbatch.Job)
+	for i := range jobs {
+		job := &jobs[i]
+		key, ok := jobRunKey(job)
+		if !ok {
+			continue
+		}
+		if runs[key] == nil {
+			runs[key] = make(map[string]*kbatch.Job)
+		}
+		step := job.Annotations[hookAnnotation]
+		if other, ok := runs[key][step]; !ok || (jobCompleted(job) && !jobCompleted(other)) {
+			// any one successful attempt at a step will do
+			runs[key][step] = job
+		}
+	}
+
+	var steps []pendingHookStep
+	for key, run := range runs {
+		scheduledTime := key.time()
+		pre, main, post := run[preRunHook], run[mainRun], run[postRunHook]
+		switch {
+		case pre != nil && nextStepSkipped(pre), main != nil && nextStepSkipped(main):
+			// the run stopped short
+		case pre != nil && main == nil && jobCompleted(pre):
+			steps = append(steps, pendingHookStep{finishedJob: pre, scheduledTime: scheduledTime, next: mainRun})
+		case hooks.PostRun != nil && main != nil && post == nil && jobCompleted(main):
+			steps = append(steps, pendingHookStep{finishedJob: main, scheduledTime: scheduledTime, next: postRunHook})
+		}
+	}
+	return steps
+}
+
+// isAwaitingHookStep checks if the job is a finished step of a run that's due
+// its next step, in which case we don't want to prune it from history just yet.
+func isAwaitingHookStep(steps []pendingHookStep, job *kbatch.Job) bool {
+	for _, step := range steps {
+		if step.finishedJob.Name == job.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// nextStepSkipped checks whether the job's run was stopped short after it.
+func nextStepSkipped(job *kbatch.Job) bool {
+	_, skipped := job.Annotations[nextStepSkippedAnnotation]
+	return skipped
+}
+
+// skipNextStep stops the job's run short of its next job, a retry or its next
+// step, noting why on the job so that we don't try again.
+func (r *CronJobReconciler) skipNextStep(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, scheduledTime time.Time, reason batch.SkipReason) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, nextStepSkippedAnnotation, reason))
+	if err := r.Patch(ctx, workloadOf(job), client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return err
+	}
+	if job.Annotations == nil {
+		job.Annotations = make(map[string]string)
+	}
+	job.Annotations[nextStepSkippedAnnotation] = string(reason)
+	r.audit(ctx, cronJob, scheduledTime, audit.Skipped, string(reason))
+	return nil
+}
+
+// runSucceeded checks whether the run made up of the given jobs, which all
+// belong to the same scheduled time, succeeded: its main job must have
+// completed, and so must its post-run hook if it has one.
+func runSucceeded(cronJob *batch.CronJob, jobs []kbatch.Job) bool {
+	for i := range jobs {
+		if isFinalStep(cronJob, &jobs[i]) && jobCompleted(&jobs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFirstStep checks whether the job is the first attempt at the first step
+// of its run, the one started at the run's scheduled time.
+func isFirstStep(cronJob *batch.CronJob, job *kbatch.Job) bool {
+	first := mainRun
+	if cronJob.Spec.Hooks != nil && cronJob.Spec.Hooks.PreRun != nil {
+		first = preRunHook
+	}
+	_, retried := job.Annotations[retryIndexAnnotation]
+	return job.Annotations[hookAnnotation] == first && !retried
+}
+
+// isFinalStep checks whether the job is the last step of its run, whose
+// success makes for the run's success.
+func isFinalStep(cronJob *batch.CronJob, job *kbatch.Job) bool {
+	last := mainRun
+	if cronJob.Spec.Hooks != nil && cronJob.Spec.Hooks.PostRun != nil {
+		last = postRunHook
+	}
+	return job.Annotations[hookAnnotation] == last
+}
+
+// constructRunJob builds the first job of a new run: its pre-run hook if it
+// has one, or else its main job.
+func (r *CronJobReconciler) constructRunJob(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time) (*kbatch.Job, error) {
+	if cronJob.Spec.Hooks != nil && cronJob.Spec.Hooks.PreRun != nil {
+		return r.constructHookJob(ctx, cronJob, scheduledTime, preRunHook)
+	}
+	return r.constructJobForCronJob(ctx, cronJob, scheduledTime)
+}
+
+// constructHookStep builds the next job of a run that's due one.
+func (r *CronJobReconciler) constructHookStep(ctx context.Context, cronJob *batch.CronJob, step pendingHookStep) (*kbatch.Job, error) {
+	var job *kbatch.Job
+	var err error
+	if step.next == mainRun {
+		job, err = r.constructJobForCronJob(ctx, cronJob, step.scheduledTime)
+	} else {
+		job, err = r.constructHookJob(ctx, cronJob, step.scheduledTime, step.next)
+	}
+	if err != nil {
+		return nil, err
+	}
+	setRunTrigger(cronJob, job, step.scheduledTime, step.finishedJob.Annotations[triggerAnnotation])
+	return job, nil
+}
+
+// constructHookJob builds a hook job for a run: it's the job we'd make for
+// the run itself, running the hook's pod template instead, with a suffix to
+// tell it apart from the main job.
+func (r *CronJobReconciler) constructHookJob(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time, hook string) (*kbatch.Job, error) {
+	var template *corev1.PodTemplateSpec
+	var suffix string
+	switch hook {
+	case preRunHook:
+		template, suffix = cronJob.Spec.Hooks.PreRun, "pre"
+	case postRunHook:
+		template, suffix = cronJob.Spec.Hooks.PostRun, "post"
+	}
+
+	job, err := r.constructJobForCronJob(ctx, cronJob, scheduledTime)
+	if err != nil {
+		return nil, err
+	}
+	job.Name = fmt.Sprintf("%s-%s", job.Name, suffix)
+	job.Annotations[hookAnnotation] = hook
+	job.Spec.Template = *template.DeepCopy()
+	return job, nil
+}
@@ -0,0 +1,194 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/imagesig"
+)
+
+/*
+CronJobPolicies can require the images of their CronJobs' jobs to be signed.
+We check the signatures just before creating each job, and pin its images to
+the digests whose signatures we checked, so that a tag moved in the meantime
+can't slip another image in.  A job with an image we can't vouch for isn't
+created at all: its CronJob says why in a condition and an event, and the run
+is skipped.  A registry that's down or busy doesn't count against the image,
+though: we hold off on the run and try again.
+
+Checking means a few requests to the image's registry, so we remember what
+we've checked for a while.  Not for too long, though: the tag may move, and a
+policy's keys may change.
+*/
+
+// imageVerificationTTL is how long we trust an image we've checked before
+// checking it again.
+const imageVerificationTTL = 10 * time.Minute
+
+// imagesUnverifiedError reports a job whose images couldn't be verified.
+type imagesUnverifiedError struct {
+	reasons []string
+}
+
+func (e *imagesUnverifiedError) Error() string {
+	return "unable to verify image signatures: " + strings.Join(e.reasons, "; ")
+}
+
+// verifiedImage is an image whose signature we've checked.
+type verifiedImage struct {
+	digest string
+	at     time.Time
+}
+
+// imageVerifications remembers the images whose signatures we've checked,
+// by image and the keys we checked them against.
+type imageVerifications struct {
+	mu       sync.Mutex
+	verified map[string]verifiedImage
+}
+
+func (v *imageVerifications) get(key string, now time.Time) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	verified, ok := v.verified[key]
+	if !ok || now.Sub(verified.at) > imageVerificationTTL {
+		delete(v.verified, key)
+		return "", false
+	}
+	return verified.digest, true
+}
+
+func (v *imageVerifications) set(key, digest string, now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.verified == nil {
+		v.verified = make(map[string]verifiedImage)
+	}
+	v.verified[key] = verifiedImage{digest: digest, at: now}
+}
+
+// verifyImages checks the signatures of the job's images against the
+// CronJobPolicies of its namespace, pinning the images to the digests it
+// checked.  If any of them fail, it says so on the CronJob, and returns an
+// imagesUnverifiedError; if a registry can't be reached, it just returns the
+// error, so that we try again.
+func (r *CronJobReconciler) verifyImages(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job) error {
+	policies, err := batch.PoliciesFor(ctx, r, cronJob.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var reasons []string
+	checked := false
+	spec := &job.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			for _, policy := range policies {
+				signatures := policy.Spec.ImageSignatures
+				if signatures == nil || !signatures.Covers(container.Image) {
+					continue
+				}
+				checked = true
+				digest, err := r.verifyImage(ctx, container.Image, signatures.PublicKeys)
+				if imagesig.IsTransient(err) {
+					// try again later rather than skip the run
+					return fmt.Errorf("container %s: %w", container.Name, err)
+				}
+				if err != nil {
+					reasons = append(reasons, fmt.Sprintf("container %s, as required by CronJobPolicy %s: %v", container.Name, policy.Name, err))
+					break
+				}
+				container.Image = pinImage(container.Image, digest)
+			}
+		}
+	}
+
+	switch {
+	case len(reasons) > 0:
+		err := &imagesUnverifiedError{reasons: reasons}
+		if r.setCondition(cronJob, metav1.Condition{
+			Type:    batch.ImagesVerifiedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "SignatureNotVerified",
+			Message: err.Error(),
+		}) {
+			r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "ImageNotVerified", "Will not create job %s: %v", job.Name, err)
+		}
+		return err
+	case checked:
+		r.setCondition(cronJob, metav1.Condition{
+			Type:    batch.ImagesVerifiedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "SignaturesVerified",
+			Message: "The images of the latest job were signed by trusted keys",
+		})
+	default:
+		r.setCondition(cronJob, metav1.Condition{
+			Type:    batch.ImagesVerifiedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "NotRequired",
+			Message: "No CronJobPolicy requires the images to be signed",
+		})
+	}
+	return nil
+}
+
+// verifyImage checks the image's signature against the keys, returning the
+// digest it checked.
+func (r *CronJobReconciler) verifyImage(ctx context.Context, image string, publicKeys []string) (string, error) {
+	key := image + "\x00" + strings.Join(publicKeys, "\x00")
+	if digest, ok := r.verifiedImages.get(key, r.Now()); ok {
+		return digest, nil
+	}
+
+	var keys []crypto.PublicKey
+	for _, data := range publicKeys {
+		// the webhook keeps out keys that don't parse
+		if key, err := imagesig.ParsePublicKey(data); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	digest, err := r.ImageVerifier.Verify(ctx, image, keys)
+	if err != nil {
+		return "", err
+	}
+	r.verifiedImages.set(key, digest, r.Now())
+	return digest, nil
+}
+
+// pinImage replaces the image's tag, if it has one, with the digest.
+func pinImage(image, digest string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		image = image[:i]
+	}
+	return image + "@" + digest
+}
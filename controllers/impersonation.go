@@ -0,0 +1,119 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Left to itself, the controller creates every job under its own identity, which
+can create jobs anywhere, so admission policies and quotas written with the
+tenants in mind never see who the job is really for.  With the
+ImpersonateJobCreation feature gate on, we create jobs as the service account
+they'll run as instead, so that they're admitted exactly as if the tenant had
+created them.  That also means the service account needs to be allowed to:
+create and patch jobs, and, where the OwnerReferencesPermissionEnforcement
+admission plugin is on, update the finalizers of cronjobs and cronjobruns, since
+our jobs block their owners' deletion.
+
+We impersonate the groups the API server puts every service account's token in
+along with it, so that bindings and admission rules written against those
+groups apply to our jobs too.  Groups aren't namespaced, so impersonating them
+takes a ClusterRole, which a manager run with --namespaced has to be granted on
+its own.
+
+Everything else, including looking up jobs and cleaning them up, is still done
+as the controller.
+*/
+
+const (
+	// maxImpersonatedClients and impersonatedClientTTL bound the clients we
+	// keep around, so that service accounts that have stopped running jobs,
+	// or been deleted, don't hold on to theirs for good.
+	maxImpersonatedClients = 256
+	impersonatedClientTTL  = time.Hour
+)
+
+// Impersonator hands out clients that act as the service accounts jobs run
+// as.
+type Impersonator struct {
+	// Config is how to reach the API server as the controller.
+	Config *rest.Config
+	Scheme *runtime.Scheme
+	Mapper meta.RESTMapper
+
+	mu      sync.Mutex
+	clients *cache.LRUExpireCache
+}
+
+// serviceAccountUser returns the user name of a service account.
+func serviceAccountUser(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// serviceAccountGroups returns the groups a service account in the namespace
+// authenticates with.
+func serviceAccountGroups(namespace string) []string {
+	return []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"}
+}
+
+// For returns a client acting as the service account the job runs as.
+func (i *Impersonator) For(job *kbatch.Job) (client.Client, error) {
+	serviceAccount := job.Spec.Template.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		// what the ServiceAccount admission plugin will give it
+		serviceAccount = "default"
+	}
+	user := serviceAccountUser(job.Namespace, serviceAccount)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.clients == nil {
+		i.clients = cache.NewLRUExpireCache(maxImpersonatedClients)
+	}
+	if c, ok := i.clients.Get(user); ok {
+		return c.(client.Client), nil
+	}
+
+	config := rest.CopyConfig(i.Config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: user, Groups: serviceAccountGroups(job.Namespace)}
+	c, err := client.New(config, client.Options{Scheme: i.Scheme, Mapper: i.Mapper})
+	if err != nil {
+		return nil, err
+	}
+	i.clients.Add(user, c, impersonatedClientTTL)
+	return c, nil
+}
+
+// jobWriter returns the client to create the job with: one acting as its
+// service account, if we're impersonating them, or our own otherwise.
+func (r *CronJobReconciler) jobWriter(job *kbatch.Job) (client.Writer, error) {
+	if r.Impersonator == nil {
+		return r.Client, nil
+	}
+	return r.Impersonator.For(job)
+}
@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+
+	kbatch "k8s.io/api/batch/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Some questions about CronJobs come up often enough, across enough of them, that
+it's worth having the cache index the answers rather than listing every CronJob
+and checking each one.  The indexes get registered along with the controller,
+and anything else sharing the manager's cache, like a dashboard, can list through
+them with the helpers here.
+*/
+
+const (
+	// scheduleHashKey indexes CronJobs by the hash of their schedule.
+	scheduleHashKey = ".spec.schedule.hash"
+
+	// suspendedKey indexes CronJobs by whether they're suspended or paused.
+	suspendedKey = ".spec.suspend"
+
+	// teamKey indexes CronJobs by their team label.
+	teamKey = ".metadata.labels.team"
+)
+
+// IndexCronJobs registers the indexes the ListCronJobs helpers rely on with
+// the indexer, which has to be done before the manager starts.
+func IndexCronJobs(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &batch.CronJob{}, scheduleHashKey, func(rawObj client.Object) []string {
+		return []string{batch.ScheduleHash(rawObj.(*batch.CronJob).Spec.Schedule)}
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &batch.CronJob{}, suspendedKey, func(rawObj client.Object) []string {
+		return []string{strconv.FormatBool(isSuspended(rawObj.(*batch.CronJob)))}
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &batch.CronJob{}, teamKey, func(rawObj client.Object) []string {
+		if team, ok := rawObj.GetLabels()[batch.TeamLabel]; ok {
+			return []string{team}
+		}
+		return nil
+	})
+}
+
+// isSuspended reports whether the CronJob is suspended or paused until
+// someone says otherwise.  Suspensions until a time don't count, since an
+// index can't notice them running out, and nor do suspensions by a group,
+// which are the group's doing rather than the CronJob's.
+func isSuspended(cronJob *batch.CronJob) bool {
+	return cronJob.Spec.Paused || (cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend)
+}
+
+// ListCronJobsBySchedule lists the CronJobs in the namespace, or in all of
+// them if it's empty, that have the given schedule.
+func ListCronJobsBySchedule(ctx context.Context, reader client.Reader, namespace, schedule string) ([]batch.CronJob, error) {
+	return listCronJobs(ctx, reader, namespace, scheduleHashKey, batch.ScheduleHash(schedule), func(cronJob *batch.CronJob) bool {
+		// hashes can collide
+		return cronJob.Spec.Schedule == schedule
+	})
+}
+
+// ListSuspendedCronJobs lists the CronJobs in the namespace, or in all of
+// them if it's empty, that are suspended or paused, or if suspended is false,
+// those that aren't.
+func ListSuspendedCronJobs(ctx context.Context, reader client.Reader, namespace string, suspended bool) ([]batch.CronJob, error) {
+	return listCronJobs(ctx, reader, namespace, suspendedKey, strconv.FormatBool(suspended), nil)
+}
+
+// ListTeamCronJobs lists the CronJobs in the namespace, or in all of them if
+// it's empty, that belong to the team.
+func ListTeamCronJobs(ctx context.Context, reader client.Reader, namespace, team string) ([]batch.CronJob, error) {
+	return listCronJobs(ctx, reader, namespace, teamKey, team, nil)
+}
+
+// ListJobsOf lists the jobs of the named CronJob, through the index by owner
+// that the controller registers.
+func ListJobsOf(ctx context.Context, reader client.Reader, namespace, name string) ([]kbatch.Job, error) {
+	var jobs kbatch.JobList
+	if err := reader.List(ctx, &jobs, client.InNamespace(namespace), client.MatchingFields{jobOwnerKey: name}); err != nil {
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+// listCronJobs lists the CronJobs with the given value for an index, keeping
+// only those that pass the filter, if there is one.
+func listCronJobs(ctx context.Context, reader client.Reader, namespace, key, value string, filter func(*batch.CronJob) bool) ([]batch.CronJob, error) {
+	var cronJobs batch.CronJobList
+	opts := []client.ListOption{client.MatchingFields{key: value}}
+	if namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+	if err := reader.List(ctx, &cronJobs, opts...); err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return cronJobs.Items, nil
+	}
+	var matching []batch.CronJob
+	for i := range cronJobs.Items {
+		if filter(&cronJobs.Items[i]) {
+			matching = append(matching, cronJobs.Items[i])
+		}
+	}
+	return matching, nil
+}
@@ -0,0 +1,190 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	kbatchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	batchv1 "kubebuilder-tutorial/api/v1"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden files in testdata with what the tests produce")
+
+// TestNewJobForCronJob snapshots the jobs we build for a few representative
+// CronJobs in testdata/jobs, so that changes to their labels, annotations or
+// owner references show up in review.  Run it with -update-golden to take
+// new snapshots after a deliberate change.
+func TestNewJobForCronJob(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := batchv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheduledTime := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := int32(3600)
+
+	testCases := []struct {
+		name          string
+		mutate        func(*batchv1.CronJob)
+		groups        []batchv1.CronJobGroup
+		scheduledTime time.Time
+	}{{
+		name: "basic",
+	}, {
+		name: "standard-labels",
+		mutate: func(cronJob *batchv1.CronJob) {
+			cronJob.Spec.JobTemplate.Labels = map[string]string{
+				batchv1.ManagedByLabel:    batchv1.ManagedByValue,
+				batchv1.CronJobNameLabel:  cronJob.Name,
+				batchv1.ScheduleHashLabel: "5d7c8bb9f4",
+				"team":                    "reporting",
+			}
+			cronJob.Spec.JobTemplate.Annotations = map[string]string{
+				"example.com/owner": "reporting@example.com",
+			}
+			cronJob.Spec.JobTemplate.Spec.Template.Labels = map[string]string{
+				"app": "report",
+			}
+		},
+	}, {
+		name: "ttl-and-queue",
+		mutate: func(cronJob *batchv1.CronJob) {
+			cronJob.Spec.JobTTLSecondsAfterFinished = &ttl
+			cronJob.Spec.QueueName = "batch"
+		},
+	}, {
+		name:   "group-priority",
+		groups: []batchv1.CronJobGroup{newPriorityGroup("reports", ""), newPriorityGroup("nightly", "nightly-batch")},
+	}, {
+		name: "own-priority",
+		mutate: func(cronJob *batchv1.CronJob) {
+			cronJob.Spec.JobTemplate.Spec.Template.Spec.PriorityClassName = "reporting"
+		},
+		groups: []batchv1.CronJobGroup{newPriorityGroup("nightly", "nightly-batch")},
+	}, {
+		name: "time-zone",
+		mutate: func(cronJob *batchv1.CronJob) {
+			timeZone := newYork.String()
+			cronJob.Spec.TimeZone = &timeZone
+		},
+		scheduledTime: time.Date(2024, 1, 1, 22, 0, 0, 0, newYork),
+	}, {
+		name: "long-name",
+		mutate: func(cronJob *batchv1.CronJob) {
+			cronJob.Name = "a-cronjob-with-a-name-long-enough-to-need-shortening-for-its-jobs"
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cronJob := newGoldenCronJob()
+			if tc.mutate != nil {
+				tc.mutate(cronJob)
+			}
+			when := scheduledTime
+			if !tc.scheduledTime.IsZero() {
+				when = tc.scheduledTime
+			}
+
+			job, err := newJobForCronJob(cronJob, &cronJob.Spec.JobTemplate, tc.groups, when, scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := yaml.Marshal(job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "jobs", tc.name+".yaml"), got)
+		})
+	}
+}
+
+// newGoldenCronJob returns the CronJob the golden jobs are built for, before
+// each test case makes it its own.
+func newGoldenCronJob() *batchv1.CronJob {
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nightly-report",
+			Namespace: "reports",
+			UID:       "6f1a4a4e-3d4b-4c1e-9b6a-2f0e8d7c5b3a",
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "0 3 * * *",
+			JobTemplate: kbatchv1beta1.JobTemplateSpec{
+				Spec: kbatch.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name:  "report",
+								Image: "example.com/report:1.0",
+								Args:  []string{"--since", "24h"},
+							}},
+							RestartPolicy: corev1.RestartPolicyOnFailure,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newPriorityGroup returns a CronJobGroup asking for the priority class, if
+// it's given one.
+func newPriorityGroup(name, priorityClassName string) batchv1.CronJobGroup {
+	return batchv1.CronJobGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "reports"},
+		Spec:       batchv1.CronJobGroupSpec{PriorityClassName: priorityClassName},
+	}
+}
+
+// checkGolden compares got with the golden file at path, or rewrites the
+// file with it if we've been asked to.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update-golden to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("doesn't match %s (run with -update-golden to update it, if that's intended):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
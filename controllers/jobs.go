@@ -0,0 +1,88 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// childJobPageSize is how many jobs we ask the API server for at a time when
+// listing a CronJob's jobs.
+const childJobPageSize = 500
+
+// listChildJobs lists the jobs the CronJob controls a page at a time, so that
+// CronJobs that keep thousands of finished jobs around don't need a single
+// enormous response.  We go straight to the API server, since the cache
+// ignores paging and hands everything back at once.  The API server can't
+// pick out jobs by their controller the way our index does, so we page
+// through the namespace's jobs and keep the CronJob's own, handing visit
+// where each one is in the list as it arrives.
+func (r *CronJobReconciler) listChildJobs(ctx context.Context, cronJob *batch.CronJob, jobs *kbatch.JobList, visit func(i int)) error {
+	jobs.Items = nil
+	continueFrom := ""
+	for {
+		var page kbatch.JobList
+		if err := r.apiReader.List(ctx, &page, client.InNamespace(cronJob.Namespace),
+			client.Limit(childJobPageSize), client.Continue(continueFrom)); err != nil {
+			return err
+		}
+		for i := range page.Items {
+			owner := metav1.GetControllerOf(&page.Items[i])
+			if owner == nil || owner.APIVersion != apiGVStr || owner.Kind != "CronJob" || owner.Name != cronJob.Name {
+				continue
+			}
+			jobs.Items = append(jobs.Items, page.Items[i])
+			visit(len(jobs.Items) - 1)
+		}
+		if len(page.Continue) == 0 {
+			jobs.ListMeta = page.ListMeta
+			return nil
+		}
+		continueFrom = page.Continue
+	}
+}
+
+// getScheduledTimeForJob extracts the scheduled time from the annotation that
+// we added during job creation.  Jobs without one, which we didn't create for
+// a run, have none.
+//
+// The annotation can be edited, and RFC 3339 parses times whose offset takes
+// them out of its own range of years.  We'd never manage to write those to
+// our status, so we reject them with the rest of the malformed ones.
+func getScheduledTimeForJob(job *kbatch.Job) (*time.Time, error) {
+	timeRaw := job.Annotations[scheduledTimeAnnotation]
+	if len(timeRaw) == 0 {
+		return nil, nil
+	}
+
+	timeParsed, err := time.Parse(time.RFC3339, timeRaw)
+	if err != nil {
+		return nil, err
+	}
+	if year := timeParsed.UTC().Year(); year < 0 || year > 9999 {
+		return nil, fmt.Errorf("scheduled time %q is out of range", timeRaw)
+	}
+	return &timeParsed, nil
+}
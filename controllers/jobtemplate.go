@@ -0,0 +1,91 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kbatchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// jobTemplateRefKey indexes CronJobs by the JobTemplate they refer to.
+const jobTemplateRefKey = ".spec.jobTemplateRef.name"
+
+// jobTemplateFor returns the template to build the CronJob's jobs from: its
+// own, or the JobTemplate it refers to, labelled and annotated with its own
+// template's metadata.  JobTemplates come from the cache, like everything
+// else we read, so this doesn't cost a request per job.
+func (r *CronJobReconciler) jobTemplateFor(ctx context.Context, cronJob *batch.CronJob) (*kbatchv1beta1.JobTemplateSpec, error) {
+	return resolveJobTemplate(ctx, r, cronJob.Namespace, cronJob.Spec.JobTemplateRef, &cronJob.Spec.JobTemplate)
+}
+
+// resolveJobTemplate returns own if ref is nil, and otherwise the referenced
+// JobTemplate from the given namespace, with own's labels and annotations
+// laid over its own.  own may be nil.
+func resolveJobTemplate(ctx context.Context, reader client.Reader, namespace string, ref *corev1.LocalObjectReference, own *kbatchv1beta1.JobTemplateSpec) (*kbatchv1beta1.JobTemplateSpec, error) {
+	if ref == nil {
+		return own, nil
+	}
+
+	var shared batch.JobTemplate
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &shared); err != nil {
+		return nil, fmt.Errorf("unable to get JobTemplate %s: %w", ref.Name, err)
+	}
+
+	template := shared.Template.DeepCopy()
+	if own == nil {
+		return template, nil
+	}
+	// the CronJob's own metadata wins, so that our standard labels always
+	// name the CronJob rather than whichever one the template was copied from
+	if len(own.Labels) > 0 && template.Labels == nil {
+		template.Labels = make(map[string]string)
+	}
+	for k, v := range own.Labels {
+		template.Labels[k] = v
+	}
+	if len(own.Annotations) > 0 && template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	for k, v := range own.Annotations {
+		template.Annotations[k] = v
+	}
+	return template, nil
+}
+
+// usersOf maps a JobTemplate to requests for the CronJobs that refer to it,
+// so that they pick up changes to it, or its creation, straight away.
+func (r *CronJobReconciler) usersOf(obj client.Object) []reconcile.Request {
+	var cronJobs batch.CronJobList
+	if err := r.List(context.Background(), &cronJobs, client.InNamespace(obj.GetNamespace()), client.MatchingFields{jobTemplateRefKey: obj.GetName()}); err != nil {
+		r.Log.Error(err, "unable to list CronJobs using JobTemplate", "template", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs.Items))
+	for i, cronJob := range cronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Clusters that share their batch capacity out with Kueue want jobs submitted
+to a queue, and left for Kueue to start.  CronJobs with a queue name label
+their jobs with it, and create them suspended; Kueue unsuspends each job once
+it admits it.  Until then, the job is pending rather than active: it counts
+towards the concurrency policy, since it's a run that's still to happen, but
+not towards our active jobs, tenant budgets or run deadlines, since nothing
+of it is running yet.  Should Kueue evict a job to make room for another, it
+suspends it again, and it goes back to pending.
+*/
+
+// kueueQueueLabel names the Kueue LocalQueue a job is submitted to.
+const kueueQueueLabel = "kueue.x-k8s.io/queue-name"
+
+// jobSuspended is the condition the job controller gives suspended jobs.
+const jobSuspended kbatch.JobConditionType = "Suspended"
+
+// isQueuedJob checks whether an unfinished job is waiting for Kueue to admit
+// it.  The job controller resets a suspended job's start time, and it won't
+// have one until the job controller first gets to it either.
+func isQueuedJob(job *kbatch.Job) bool {
+	if job.Labels[kueueQueueLabel] == "" || isWorkload(job) {
+		return false
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == jobSuspended {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return job.Status.StartTime == nil
+}
+
+// writeJob writes a new job with the given write, suspended if it's to wait
+// in a Kueue queue, and fills the job in from what was written.  Our copy of
+// the Job API predates spec.suspend, so queued jobs are written unstructured.
+func writeJob(job *kbatch.Job, write func(client.Object) error) error {
+	if job.Labels[kueueQueueLabel] == "" {
+		return write(job)
+	}
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{Object: raw}
+	obj.SetGroupVersionKind(kbatch.SchemeGroupVersion.WithKind("Job"))
+	if err := unstructured.SetNestedField(obj.Object, true, "spec", "suspend"); err != nil {
+		return err
+	}
+	if err := write(obj); err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job)
+}
+
+// runningJobs picks the active jobs that aren't waiting in a queue.
+func runningJobs(activeJobs []*kbatch.Job) []*kbatch.Job {
+	var running []*kbatch.Job
+	for _, job := range activeJobs {
+		if !isQueuedJob(job) {
+			running = append(running, job)
+		}
+	}
+	return running
+}
@@ -0,0 +1,141 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/logarchive"
+)
+
+// archivedLogTailLines is how much of each container's log we archive.
+const archivedLogTailLines = int64(1000)
+
+// wantsLogsArchived checks whether the CronJob wants the logs of the given
+// job, which is about to be cleaned up, archived first.
+func (r *CronJobReconciler) wantsLogsArchived(cronJob *batch.CronJob, failed bool) bool {
+	if r.LogStore == nil || r.PodLogs == nil {
+		return false
+	}
+	switch cronJob.Spec.ArchiveLogs {
+	case batch.ArchiveAlways:
+		return true
+	case batch.ArchiveFailed:
+		return failed
+	default:
+		return false
+	}
+}
+
+// archiveJobLogs collects the logs of every container of the job's pods and
+// stores them, returning where they went.  Containers whose logs can't be
+// fetched get a note saying so instead, so that one missing log doesn't cost
+// us the rest.  Workloads other than jobs have their backends pick out their
+// pods.
+func (r *CronJobReconciler) archiveJobLogs(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job) (string, error) {
+	selector := metav1.ListOptions{LabelSelector: "controller-uid=" + string(job.UID)}
+	if backend := backendOf(job); backend != nil {
+		selector = backend.podsOf(job)
+	}
+	pods, err := r.PodLogs.Pods(job.Namespace).List(ctx, selector)
+	if err != nil {
+		return "", err
+	}
+
+	var logs bytes.Buffer
+	tailLines := archivedLogTailLines
+	for _, pod := range pods.Items {
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			fmt.Fprintf(&logs, "==> pod %s, container %s <==\n", pod.Name, container.Name)
+			raw, err := r.PodLogs.Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+				TailLines: &tailLines,
+			}).DoRaw(ctx)
+			if err != nil {
+				fmt.Fprintf(&logs, "(unable to fetch logs: %v)\n", err)
+				continue
+			}
+			logs.Write(raw)
+		}
+	}
+
+	return r.LogStore.Put(ctx, logarchive.Archive{
+		Namespace: job.Namespace,
+		Name:      job.Name,
+		Labels:    map[string]string{batch.CronJobNameLabel: cronJob.Name},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: apiGVStr,
+			Kind:       "CronJob",
+			Name:       cronJob.Name,
+			UID:        cronJob.UID,
+		}},
+		Logs: logs.Bytes(),
+	})
+}
+
+// recordLogLocations notes in the run history where the logs of the given
+// jobs went, and reports whether that changed anything.  It also returns the
+// locations of logs whose runs are no longer in the history.
+func recordLogLocations(cronJob *batch.CronJob, locations map[string]string) (unrecorded []string, changed bool) {
+	recorded := make(map[string]bool)
+	for i := range cronJob.Status.RecentRuns {
+		run := &cronJob.Status.RecentRuns[i]
+		if location, ok := locations[run.JobName]; ok {
+			recorded[run.JobName] = true
+			if run.LogsLocation != location {
+				run.LogsLocation = location
+				changed = true
+			}
+		}
+	}
+	for jobName, location := range locations {
+		if !recorded[jobName] {
+			unrecorded = append(unrecorded, location)
+		}
+	}
+	return unrecorded, changed
+}
+
+// pruneArchivedLogs deletes archived logs that the run history no longer
+// points to: those of the given runs, which it has dropped, and those at the
+// given locations.  Keeping archives no longer than the history keeps its
+// runs stops them from piling up for as long as the CronJob lives.  Stores
+// that can't delete what they've archived are left to their own retention.
+func (r *CronJobReconciler) pruneArchivedLogs(ctx context.Context, cronJob *batch.CronJob, dropped []batch.RunRecord, locations []string) {
+	pruner, ok := r.LogStore.(logarchive.Pruner)
+	if !ok {
+		return
+	}
+	for _, run := range dropped {
+		if run.LogsLocation != "" {
+			locations = append(locations, run.LogsLocation)
+		}
+	}
+	for _, location := range locations {
+		if err := pruner.Delete(ctx, location); err != nil {
+			r.Log.Error(err, "unable to delete archived logs", "cronjob", cronJob.Name, "namespace", cronJob.Namespace, "location", location)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// maintenanceWindowAt returns the first MaintenanceWindow, by name, that
+// covers the CronJob and is open at t, along with when it closes.  It
+// returns nil if there's no such window, or if we can't see MaintenanceWindows
+// at all, with only namespaced permissions.
+func (r *CronJobReconciler) maintenanceWindowAt(ctx context.Context, cronJob *batch.CronJob, t time.Time) (*batch.MaintenanceWindow, time.Time, error) {
+	if r.Namespaced {
+		return nil, time.Time{}, nil
+	}
+	var windows batch.MaintenanceWindowList
+	if err := r.List(ctx, &windows); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var namespace *corev1.Namespace
+	for i := range windows.Items {
+		window := &windows.Items[i]
+		covers, err := selectorMatches(window.Spec.Selector, cronJob.Labels)
+		if err != nil || !covers {
+			continue
+		}
+		if window.Spec.NamespaceSelector != nil {
+			// we only need the namespace's labels if some window asks
+			if namespace == nil {
+				namespace = &corev1.Namespace{}
+				if err := r.Get(ctx, types.NamespacedName{Name: cronJob.Namespace}, namespace); err != nil {
+					return nil, time.Time{}, err
+				}
+			}
+			covers, err := selectorMatches(window.Spec.NamespaceSelector, namespace.Labels)
+			if err != nil || !covers {
+				continue
+			}
+		}
+
+		// the webhook keeps out broken periods, so one that slipped through
+		// shouldn't hold up every CronJob in the cluster
+		period, err := blackoutWindowFor(window.Spec.Windows, t)
+		if err != nil || period == nil {
+			continue
+		}
+		closesAt, err := blackoutWindowEnd(*period, t)
+		if err != nil {
+			continue
+		}
+		return window, closesAt, nil
+	}
+	return nil, time.Time{}, nil
+}
+
+// setDeferredRun records the run being held back in status, returning
+// whether that changed anything.
+func setDeferredRun(cronJob *batch.CronJob, deferral *batch.RunDeferral) bool {
+	if equality.Semantic.DeepEqual(cronJob.Status.DeferredRun, deferral) {
+		return false
+	}
+	cronJob.Status.DeferredRun = deferral
+	return true
+}
+
+// selectorMatches checks whether the labels match the selector, treating an
+// unset selector as matching everything.
+func selectorMatches(selector *metav1.LabelSelector, objLabels map[string]string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels.Set(objLabels)), nil
+}
+
+// coveredBy maps a MaintenanceWindow to requests for the CronJobs its label
+// selector picks out, so that they notice it opening or changing.  We don't
+// bother looking up namespaces here: requeuing a few CronJobs too many is
+// harmless.
+func (r *CronJobReconciler) coveredBy(obj client.Object) []reconcile.Request {
+	window, ok := obj.(*batch.MaintenanceWindow)
+	if !ok {
+		return nil
+	}
+	opts := []client.ListOption{}
+	if window.Spec.Selector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(window.Spec.Selector)
+		if err != nil {
+			return nil
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: sel})
+	}
+
+	var cronJobs batch.CronJobList
+	if err := r.List(context.Background(), &cronJobs, opts...); err != nil {
+		r.Log.Error(err, "unable to list CronJobs covered by MaintenanceWindow", "window", window.Name)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs.Items))
+	for i, cronJob := range cronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
@@ -0,0 +1,79 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/mesh"
+)
+
+/*
+Namespaces in a service mesh get a sidecar injected into every pod, jobs'
+pods included, and the sidecar doesn't know to stop when the job's containers
+do: the pod keeps running, the job never completes, and under the Forbid
+concurrency policy, no run ever starts again.  Unless a CronJob says
+otherwise, we tell whether its pods will get an Istio sidecar from the labels
+of its namespace and its pod template, and if so, add a container that tells
+the sidecar to quit once the rest of the pod is done.  Without permission to
+read namespaces, only the pod template's labels count.
+
+That only works for pods that don't restart their containers in place: while
+a container's waiting to restart under OnFailure, nothing else is running, and
+the sidecar would be told to quit with the job still to finish.  Those pods
+are left be, with a warning.  The container is added before the job's images
+are checked, so that its image needs a signature like any other.
+*/
+
+// quitMeshSidecar adds a container to the job's pods that has their service
+// mesh sidecar quit, if the CronJob wants it.
+func (r *CronJobReconciler) quitMeshSidecar(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job) error {
+	switch cronJob.Spec.MeshSidecar {
+	case batch.IgnoreMeshSidecar:
+		return nil
+	case batch.QuitMeshSidecar:
+	default:
+		var namespaceLabels map[string]string
+		if !r.Namespaced {
+			var namespace corev1.Namespace
+			if err := r.Get(ctx, types.NamespacedName{Name: job.Namespace}, &namespace); err != nil {
+				return err
+			}
+			namespaceLabels = namespace.Labels
+		}
+		if !mesh.SidecarInjected(namespaceLabels, &job.Spec.Template) {
+			return nil
+		}
+	}
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "MeshSidecarNotQuit",
+			"The pods of job %s restart their containers in place, so their mesh sidecar can't be told to quit: use restartPolicy Never", job.Name)
+		return nil
+	}
+
+	image := r.MeshQuitImage
+	if image == "" {
+		image = mesh.DefaultQuitImage
+	}
+	mesh.AddQuitter(&job.Spec.Template.Spec, image)
+	return nil
+}
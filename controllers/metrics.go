@@ -0,0 +1,289 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+We export a few metrics about how scheduling is going, alongside the ones
+controller-runtime already serves on the manager's metrics endpoint.  They're all
+labeled by the CronJob's namespace and name.  Run durations are also labeled by
+how the run turned out, so that slow failures can be told apart from slow
+successes.
+*/
+
+const (
+	outcomeComplete = "complete"
+	outcomeFailed   = "failed"
+)
+
+var (
+	jobCreations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_job_creations_total",
+		Help: "Number of jobs created for a CronJob",
+	}, []string{"namespace", "name"})
+
+	missedSchedules = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_missed_schedules_total",
+		Help: "Number of a CronJob's runs that missed their starting deadline",
+	}, []string{"namespace", "name"})
+
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "cronjob_run_duration_seconds",
+		Help: "How long a CronJob's finished runs took, from their first job starting to their last finishing, by outcome",
+		// from a second up to about three days
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"namespace", "name", "outcome"})
+
+	activeJobsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_active_jobs",
+		Help: "Number of a CronJob's jobs that are running",
+	}, []string{"namespace", "name"})
+
+	scheduleLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_schedule_lag_seconds",
+		Help: "How long after its scheduled time a CronJob's most recent run got its job created",
+	}, []string{"namespace", "name"})
+
+	quotaDeferrals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_quota_deferrals_total",
+		Help: "Number of a CronJob's runs that had to wait for room in a RunQuota",
+	}, []string{"namespace", "name"})
+
+	lastSuccessAge = newLastSuccessCollector()
+
+	cronJobHealth = newHealthCollector()
+)
+
+func init() {
+	metrics.Registry.MustRegister(jobCreations, missedSchedules, runDuration, activeJobsGauge, scheduleLag, quotaDeferrals, lastSuccessAge, cronJobHealth)
+}
+
+/*
+The age of the last successful run has to keep growing between reconciles, since
+a CronJob that has quietly stopped running won't get reconciled much, so rather
+than a gauge we set, it's worked out whenever the metrics are scraped.
+*/
+
+// lastSuccessCollector reports how long ago each CronJob's last successful
+// run finished.
+type lastSuccessCollector struct {
+	desc *prometheus.Desc
+
+	mu          sync.Mutex
+	lastSuccess map[types.NamespacedName]time.Time
+}
+
+func newLastSuccessCollector() *lastSuccessCollector {
+	return &lastSuccessCollector{
+		desc: prometheus.NewDesc("cronjob_last_success_age_seconds",
+			"How long ago a CronJob's last successful run finished",
+			[]string{"namespace", "name"}, nil),
+		lastSuccess: make(map[types.NamespacedName]time.Time),
+	}
+}
+
+// set records when the CronJob's last successful run finished.
+func (c *lastSuccessCollector) set(cronJob types.NamespacedName, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSuccess[cronJob] = t
+}
+
+// forget stops reporting the CronJob.
+func (c *lastSuccessCollector) forget(cronJob types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.lastSuccess, cronJob)
+}
+
+// Describe implements prometheus.Collector.
+func (c *lastSuccessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *lastSuccessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for cronJob, t := range c.lastSuccess {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(t).Seconds(), cronJob.Namespace, cronJob.Name)
+	}
+}
+
+// observeRunDurations records how long the given finished runs took, for
+// those we know both ends of.
+func observeRunDurations(cronJob types.NamespacedName, runs []finishedRun) {
+	for _, run := range runs {
+		if run.startTime == nil || run.completionTime == nil {
+			continue
+		}
+		outcome := outcomeFailed
+		if run.succeeded {
+			outcome = outcomeComplete
+		}
+		duration := run.completionTime.Sub(run.startTime.Time)
+		runDuration.WithLabelValues(cronJob.Namespace, cronJob.Name, outcome).Observe(duration.Seconds())
+	}
+}
+
+// forgetMetrics drops the metrics of a CronJob that's gone, so that it stops
+// being reported.
+func forgetMetrics(cronJob types.NamespacedName) {
+	jobCreations.DeleteLabelValues(cronJob.Namespace, cronJob.Name)
+	missedSchedules.DeleteLabelValues(cronJob.Namespace, cronJob.Name)
+	runDuration.DeleteLabelValues(cronJob.Namespace, cronJob.Name, outcomeComplete)
+	runDuration.DeleteLabelValues(cronJob.Namespace, cronJob.Name, outcomeFailed)
+	activeJobsGauge.DeleteLabelValues(cronJob.Namespace, cronJob.Name)
+	scheduleLag.DeleteLabelValues(cronJob.Namespace, cronJob.Name)
+	quotaDeferrals.DeleteLabelValues(cronJob.Namespace, cronJob.Name)
+	lastSuccessAge.forget(cronJob)
+	cronJobHealth.forget(cronJob)
+}
+
+/*
+Finally, we sum all of that up in a single health score, so that one alert can
+page on it: 1 when all's well, 0 when the CronJob isn't Ready (its schedule is
+invalid, or its runs have failed too often), and 0.5 -- degraded -- in between:
+when it's suspended, its last run failed, or its runs are starting late or
+not at all.  Paused CronJobs always count as healthy, since they're only held
+for the time being, and paging about them would be noise.  Like the age of the
+last success, it's worked out at scrape time, since a run that's overdue is
+exactly when we won't be reconciling.
+*/
+
+const (
+	healthy   = 1.0
+	degraded  = 0.5
+	unhealthy = 0.0
+)
+
+// maxHealthyLag is how late a run can start, or be overdue, before the
+// CronJob counts as degraded.
+const maxHealthyLag = 5 * time.Minute
+
+// healthInputs is what we know about a CronJob that goes into its health.
+type healthInputs struct {
+	ready               bool
+	paused              bool
+	suspended           bool
+	consecutiveFailures int32
+	lastLag             time.Duration
+	nextScheduleTime    *time.Time
+}
+
+// score works out the health of a CronJob.
+func (h healthInputs) score(now time.Time) float64 {
+	switch {
+	case h.paused:
+		// held on purpose, and not to be alerted on
+		return healthy
+	case !h.ready:
+		return unhealthy
+	case h.suspended, h.consecutiveFailures > 0, h.lastLag > maxHealthyLag:
+		return degraded
+	case h.nextScheduleTime != nil && now.Sub(*h.nextScheduleTime) > maxHealthyLag:
+		return degraded
+	default:
+		return healthy
+	}
+}
+
+// healthCollector reports the health of each CronJob.
+type healthCollector struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	inputs map[types.NamespacedName]*healthInputs
+}
+
+func newHealthCollector() *healthCollector {
+	return &healthCollector{
+		desc: prometheus.NewDesc("cronjob_health",
+			"Health of a CronJob: 1 if healthy, 0.5 if degraded, 0 if unhealthy",
+			[]string{"namespace", "name"}, nil),
+		inputs: make(map[types.NamespacedName]*healthInputs),
+	}
+}
+
+// get returns the inputs for the CronJob, creating them if need be.  The
+// caller must hold the lock.
+func (c *healthCollector) get(cronJob types.NamespacedName) *healthInputs {
+	inputs, ok := c.inputs[cronJob]
+	if !ok {
+		inputs = &healthInputs{}
+		c.inputs[cronJob] = inputs
+	}
+	return inputs
+}
+
+// observe records the state of the CronJob as of the end of a reconcile.
+func (c *healthCollector) observe(cronJob *batch.CronJob) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inputs := c.get(types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name})
+	inputs.ready = meta.IsStatusConditionTrue(cronJob.Status.Conditions, batch.ReadyCondition)
+	inputs.paused = cronJob.Spec.Paused
+	inputs.suspended = (cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend) ||
+		(cronJob.Spec.SuspendUntil != nil && time.Now().Before(cronJob.Spec.SuspendUntil.Time))
+	inputs.consecutiveFailures = cronJob.Status.ConsecutiveFailures
+	inputs.nextScheduleTime = nil
+	if next := cronJob.Status.NextScheduleTime; next != nil && !inputs.suspended {
+		t := next.Time
+		inputs.nextScheduleTime = &t
+	}
+}
+
+// observeLag records how late the CronJob's latest run started.
+func (c *healthCollector) observeLag(cronJob types.NamespacedName, lag time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(cronJob).lastLag = lag
+}
+
+// forget stops reporting the CronJob.
+func (c *healthCollector) forget(cronJob types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inputs, cronJob)
+}
+
+// Describe implements prometheus.Collector.
+func (c *healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *healthCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for cronJob, inputs := range c.inputs {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, inputs.score(now), cronJob.Namespace, cronJob.Name)
+	}
+}
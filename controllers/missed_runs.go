@@ -0,0 +1,165 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+// defaultMaxMissedRuns is the number of missed start times we're willing to
+// walk through when .spec.maxMissedRuns isn't set.
+const defaultMaxMissedRuns = 100
+
+// mostRecentScheduleTime finds the latest activation of the schedule that is
+// no later than now, given a known activation at or before now.  Instead of
+// walking every activation in between, it searches ever larger windows back
+// from now, so the work done is proportional to the schedule's frequency
+// rather than to how far behind we are.
+func mostRecentScheduleTime(sched schedule.Schedule, known, now time.Time) time.Time {
+	// the window never needs to reach back past the known activation, and
+	// stopping there keeps the doubling from overflowing
+	span := now.Sub(known)
+	for lookback := time.Minute; ; {
+		from := now.Add(-lookback)
+		if lookback >= span || !from.After(known) {
+			from = known
+		}
+
+		mostRecent := time.Time{}
+		for t := sched.Next(from); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+			mostRecent = t
+		}
+		if !mostRecent.IsZero() {
+			return mostRecent
+		}
+		if from.Equal(known) {
+			// nothing between the known activation and now
+			return known
+		}
+		if lookback > span/2 {
+			lookback = span
+		} else {
+			lookback *= 2
+		}
+	}
+}
+
+// getNextSchedule lists the runs of the CronJob's schedule, moved off the
+// given holidays, that have come due by now and that we haven't started yet,
+// along with its next run after now.  If there are more than the CronJob's
+// maximum of missed runs, it jumps ahead to the most recent one, and says
+// there were too many.  The next run is the zero time if there's none.
+func getNextSchedule(cronJob *batch.CronJob, holidays map[string]bool, now time.Time) (missed []time.Time, next time.Time, tooManyMissed bool, err error) {
+	sched, err := parseSchedule(cronJob)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	// CronJobs that only run when triggered have no schedule to keep
+	if !cronJob.Spec.SchedulesRuns() {
+		return nil, time.Time{}, false, nil
+	}
+	sched = withHolidayPolicy(cronJob, sched, holidays)
+
+	// for optimization purposes, cheat a bit and start from our last observed run time
+	// we could reconstitute this here, but there's not much point, since we've
+	// just updated it.
+	var earliestTime time.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		earliestTime = cronJob.Status.LastScheduleTime.Time
+	} else {
+		earliestTime = cronJob.ObjectMeta.CreationTimestamp.Time
+	}
+	// nor do we start runs a schedule had due before we switched to it
+	if changed := cronJob.Status.ScheduleChangeTime; changed != nil && changed.Time.After(earliestTime) {
+		earliestTime = changed.Time
+	}
+	// nor do we start runs our resume policy skipped
+	if skipUntil := skipRunsUntil(cronJob); skipUntil.After(earliestTime) {
+		earliestTime = skipUntil
+	}
+	if cronJob.Spec.StartingDeadlineSeconds != nil {
+		// controller is not going to schedule anything below this point
+		schedulingDeadline := schedulingDeadline(cronJob, now, time.Second*time.Duration(*cronJob.Spec.StartingDeadlineSeconds))
+
+		if schedulingDeadline.After(earliestTime) {
+			earliestTime = schedulingDeadline
+		}
+	}
+	if earliestTime.After(now) {
+		return nil, sched.Next(now), false, nil
+	}
+
+	maxMissedRuns := defaultMaxMissedRuns
+	if cronJob.Spec.MaxMissedRuns != nil {
+		maxMissedRuns = int(*cronJob.Spec.MaxMissedRuns)
+	}
+
+	starts := 0
+	// schedules that have run their course (like a repeating interval with a
+	// fixed number of repetitions) hand back the zero time.
+	for t := sched.Next(earliestTime); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		missed = append(missed, t)
+		// An object might miss several starts. For example, if
+		// controller gets wedged on Friday at 5:01pm when everyone has
+		// gone home, and someone comes in on Tuesday AM and discovers
+		// the problem and restarts the controller, then all the hourly
+		// jobs, more than 80 of them for one hourly scheduledJob, should
+		// all start running with no further intervention (if the scheduledJob
+		// allows concurrency and late starts).
+		//
+		// However, if there is a bug somewhere, or incorrect clock
+		// on controller's server or apiservers (for setting creationTimestamp)
+		// then there could be so many missed start times (it could be off
+		// by decades or more), that it would eat up all the CPU and memory
+		// of this controller. In that case, we want to not try to list
+		// all the missed start times, and just skip ahead to the most
+		// recent one instead.
+		starts++
+		if starts > maxMissedRuns {
+			return []time.Time{mostRecentScheduleTime(sched, t, now)}, sched.Next(now), true, nil
+		}
+	}
+	return missed, sched.Next(now), false, nil
+}
+
+// latestMissedRun finds the most recent activation of the CronJob's schedule,
+// at or before now, that we haven't started a job for yet, along with the
+// next activation after now.  Either may be the zero time if there's none.
+func latestMissedRun(cronJob *batch.CronJob, now time.Time) (missed, next time.Time, err error) {
+	sched, err := parseSchedule(cronJob)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !cronJob.Spec.SchedulesRuns() {
+		return time.Time{}, time.Time{}, nil
+	}
+
+	earliestTime := cronJob.ObjectMeta.CreationTimestamp.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		earliestTime = cronJob.Status.LastScheduleTime.Time
+	}
+	if changed := cronJob.Status.ScheduleChangeTime; changed != nil && changed.Time.After(earliestTime) {
+		earliestTime = changed.Time
+	}
+	if first := sched.Next(earliestTime); !first.IsZero() && !first.After(now) {
+		missed = mostRecentScheduleTime(sched, first, now)
+	}
+	return missed, sched.Next(now), nil
+}
@@ -0,0 +1,67 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+/*
+Clusters shared between teams may run a controller per team, each looking after
+its own namespaces, or one for everybody but the teams that run their own.  The
+manager's cache can be limited to the included namespaces, but not kept out of
+excluded ones, so it's the reconcilers that skip those.
+*/
+
+// NamespaceFilter picks out the namespaces a controller looks after.  The
+// zero value has all of them.
+type NamespaceFilter struct {
+	// Include, if not empty, lists the only namespaces to look after.
+	Include []string
+	// Exclude lists namespaces to leave alone, even if they're included.
+	Exclude []string
+}
+
+// Allows reports whether the controller looks after the namespace.
+// Cluster-scoped objects, which have no namespace, are always looked after.
+func (f NamespaceFilter) Allows(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	for _, ns := range f.Exclude {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, ns := range f.Include {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// predicate filters out events for objects in namespaces we leave alone.
+func (f NamespaceFilter) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return f.Allows(obj.GetNamespace())
+	})
+}
@@ -0,0 +1,104 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Batch pods tend to get whatever network policies their namespace has, which
+are usually written with long-running services in mind, if there are any at
+all.  CronJobs with a network profile get a NetworkPolicy for each job
+instead, selecting just that job's pods: nothing may reach them, and they may
+only reach what the profile allows.
+
+Like injected secrets, the policy has to be in place before the job's pods
+start, so it's created before the job, owned by the run, and handed over to
+the job once it exists.  It's cleaned up along with the job.
+*/
+
+// jobPodLabel picks out the pods of one of our jobs, for its NetworkPolicy to
+// select.  The job controller's own labels need the job to exist first.
+const jobPodLabel = "batch.tutorial.kubebuilder.io/job"
+
+// applyNetworkPolicy creates the NetworkPolicy for the job's pods, if the
+// CronJob has a network profile, and labels the pods for it to select.  It
+// returns the policy, or nil if there's no profile.
+func (r *CronJobReconciler) applyNetworkPolicy(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, run *batch.CronJobRun) (*networkingv1.NetworkPolicy, error) {
+	profile := cronJob.Spec.NetworkProfile
+	if profile == nil {
+		return nil, nil
+	}
+	if job.Spec.Template.Labels == nil {
+		job.Spec.Template.Labels = make(map[string]string)
+	}
+	job.Spec.Template.Labels[jobPodLabel] = job.Name
+
+	policy := networkPolicyFor(job, profile)
+	policy.OwnerReferences = []metav1.OwnerReference{runOwnerReference(run)}
+	// an apply creates the policy, or brings the one left behind by an
+	// earlier attempt at the same job up to date
+	if err := r.Patch(ctx, policy, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// networkPolicyFor builds the NetworkPolicy that fences the job's pods in.
+func networkPolicyFor(job *kbatch.Job, profile *batch.NetworkProfile) *networkingv1.NetworkPolicy {
+	egress := make([]networkingv1.NetworkPolicyEgressRule, 0, len(profile.Egress)+1)
+	for i := range profile.Egress {
+		egress = append(egress, *profile.Egress[i].DeepCopy())
+	}
+	if profile.DNSAllowed() {
+		dns := intstr.FromInt(53)
+		udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dns},
+				{Protocol: &tcp, Port: &dns},
+			},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      job.Name,
+			Labels:    job.Labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{jobPodLabel: job.Name},
+			},
+			// no ingress rules, so no ingress
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+}
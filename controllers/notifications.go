@@ -0,0 +1,84 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/notify"
+)
+
+// notificationTimeout bounds how long we keep trying to deliver a single
+// notification, retries included.
+const notificationTimeout = time.Minute
+
+// Notifier delivers notifications to HTTP endpoints.
+type Notifier interface {
+	Send(ctx context.Context, target notify.Target, msg notify.Message) error
+}
+
+// notify sends the event to each of the CronJob's notification endpoints that
+// asked for it.  Delivery happens in the background, so that a slow endpoint
+// doesn't hold up reconciling, and failures are only logged: notifications
+// are best-effort.  Paused CronJobs don't send any.
+func (r *CronJobReconciler) notify(cronJob *batch.CronJob, event batch.NotificationEvent, scheduledTime time.Time, job, reason string) {
+	// nobody wants to hear about a CronJob that's been paused on purpose
+	if r.Notifier == nil || cronJob.Spec.Paused {
+		return
+	}
+	msg := notify.Message{
+		Event:         string(event),
+		Namespace:     cronJob.Namespace,
+		CronJob:       cronJob.Name,
+		ScheduledTime: scheduledTime,
+		Job:           job,
+		Reason:        reason,
+	}
+	// the controller's own notifications go out for every CronJob
+	internal := r.Live.Notifications()
+	notifications := append(internal, cronJob.Spec.Notifications...)
+	for i, notification := range notifications {
+		if !notifiesAbout(notification, event) {
+			continue
+		}
+		target := notify.Target{
+			URL:      notification.URL,
+			Format:   notify.Format(notification.Format),
+			Template: notification.Template,
+			Internal: i < len(internal),
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+			defer cancel()
+			if err := r.Notifier.Send(ctx, target, msg); err != nil {
+				r.Log.Error(err, "unable to send notification", "cronjob", msg.CronJob, "namespace", msg.Namespace, "event", msg.Event, "url", target.URL)
+			}
+		}()
+	}
+}
+
+// notifiesAbout checks whether the notification asked for the event.
+func notifiesAbout(notification batch.Notification, event batch.NotificationEvent) bool {
+	for _, e := range notification.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
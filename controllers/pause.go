@@ -0,0 +1,70 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Suspending a CronJob says it isn't meant to run; pausing it says it's being held
+for now, and will be back.  Scheduling-wise, the two are the same: no runs start.
+The difference is in what we tell people about it.  A pause gets a condition
+with its reason and a time it started, and while it lasts, we hold back
+notifications and don't count the CronJob as degraded for the runs it skips.
+*/
+
+// pausedCondition describes whether the CronJob is paused, and why.
+func pausedCondition(cronJob *batch.CronJob) metav1.Condition {
+	if !cronJob.Spec.Paused {
+		return metav1.Condition{
+			Type:    batch.PausedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NotPaused",
+			Message: "The CronJob is not paused",
+		}
+	}
+	message := cronJob.Spec.PauseReason
+	if message == "" {
+		message = "The CronJob is paused"
+	}
+	return metav1.Condition{
+		Type:    batch.PausedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Paused",
+		Message: message,
+	}
+}
+
+// setPausedSince records in status since when the CronJob has been paused,
+// or clears it once it isn't, and reports whether that changed anything.
+func setPausedSince(cronJob *batch.CronJob, now time.Time) bool {
+	switch {
+	case cronJob.Spec.Paused && cronJob.Status.PausedSince == nil:
+		cronJob.Status.PausedSince = &metav1.Time{Time: now}
+		return true
+	case !cronJob.Spec.Paused && cronJob.Status.PausedSince != nil:
+		cronJob.Status.PausedSince = nil
+		return true
+	default:
+		return false
+	}
+}
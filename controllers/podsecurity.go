@@ -0,0 +1,92 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/podsecurity"
+)
+
+/*
+A pod template that breaks the Pod Security Standards level its namespace
+enforces doesn't stop its job from being created: it's the job's pods that get
+rejected, over and over, and all the user sees is a job that never gets going.
+So we check the template ourselves, and say so in a condition, and an event,
+while it breaks the level.  The webhook warns about the same thing when the
+CronJob is written, but namespaces can tighten their level later on.
+
+It's only a warning: runs still go ahead.  The API server may let the pods in
+all the same, since it can be configured to exempt some users, runtime classes
+or namespaces, and namespaces can pin the version of the level they enforce,
+neither of which we can see.
+*/
+
+// podSecurityCondition describes whether the CronJob's pod template meets the
+// level its namespace enforces.  If we can't tell, the condition is unknown,
+// and runs go ahead as they would without the check.
+func (r *CronJobReconciler) podSecurityCondition(ctx context.Context, cronJob *batch.CronJob) metav1.Condition {
+	if r.Namespaced {
+		return metav1.Condition{
+			Type:    batch.PodSecurityCompliantCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "NamespaceUnavailable",
+			Message: "Pod Security levels aren't checked without permission to read namespaces",
+		}
+	}
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: cronJob.Namespace}, &namespace); err != nil {
+		return metav1.Condition{
+			Type:    batch.PodSecurityCompliantCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "NamespaceUnavailable",
+			Message: fmt.Sprintf("Unable to get the namespace's Pod Security level: %v", err),
+		}
+	}
+	template, err := r.jobTemplateFor(ctx, cronJob)
+	if err != nil {
+		return metav1.Condition{
+			Type:    batch.PodSecurityCompliantCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "TemplateUnavailable",
+			Message: err.Error(),
+		}
+	}
+
+	level := podsecurity.EnforcedLevel(namespace.Labels)
+	if violations := podsecurity.Check(level, &template.Spec.Template); len(violations) > 0 {
+		return metav1.Condition{
+			Type:    batch.PodSecurityCompliantCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "PodSecurityViolated",
+			Message: fmt.Sprintf("The pod template breaks the namespace's %q Pod Security level: %s", level, strings.Join(violations, "; ")),
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.PodSecurityCompliantCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "PodSecurityMet",
+		Message: fmt.Sprintf("The pod template meets the namespace's %q Pod Security level", level),
+	}
+}
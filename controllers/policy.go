@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// policyCompliantCondition describes whether the CronJob meets the rules of
+// the given CronJobPolicies.  The webhook keeps out CronJobs that don't, but
+// a policy can turn up after the CronJobs it covers.
+func policyCompliantCondition(cronJob *batch.CronJob, policies []batch.CronJobPolicy, now time.Time) metav1.Condition {
+	var violations []string
+	for i := range policies {
+		for _, violation := range policies[i].Violations(cronJob, now) {
+			violations = append(violations, violation.Error())
+		}
+	}
+	if len(violations) > 0 {
+		return metav1.Condition{
+			Type:    batch.PolicyCompliantCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "PolicyViolated",
+			Message: strings.Join(violations, "; "),
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.PolicyCompliantCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "PolicyMet",
+		Message: "The CronJob meets every CronJobPolicy in its namespace",
+	}
+}
+
+// blackoutWindows lists the windows in which the CronJob mustn't start runs:
+// its own, and those forbidden by the given CronJobPolicies.
+func blackoutWindows(cronJob *batch.CronJob, policies []batch.CronJobPolicy) []batch.BlackoutWindow {
+	windows := append([]batch.BlackoutWindow(nil), cronJob.Spec.BlackoutWindows...)
+	for _, policy := range policies {
+		windows = append(windows, policy.Spec.ForbiddenWindows...)
+	}
+	return windows
+}
+
+// minRunInterval is the shortest interval the given CronJobPolicies allow
+// between two runs, or zero if they don't say.
+func minRunInterval(policies []batch.CronJobPolicy) time.Duration {
+	var interval time.Duration
+	for _, policy := range policies {
+		if min := policy.Spec.MinScheduleInterval; min != nil && min.Duration > interval {
+			interval = min.Duration
+		}
+	}
+	return interval
+}
+
+// governedBy maps a CronJobPolicy to requests for every CronJob in its
+// namespace, so that they're checked against it as soon as it changes.
+func (r *CronJobReconciler) governedBy(obj client.Object) []reconcile.Request {
+	var cronJobs batch.CronJobList
+	if err := r.List(context.Background(), &cronJobs, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list CronJobs governed by CronJobPolicy", "policy", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs.Items))
+	for i, cronJob := range cronJobs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
@@ -0,0 +1,86 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// cronJobChangedPredicate lets through CronJob updates that we have yet to act
+// on: spec changes, which bump the generation, annotation changes, which
+// carry manual triggers, label changes, which move it into or out of groups,
+// maintenance windows and teams, and deletions, which we may have to clean
+// up after.  Updates that only touch status are dropped, unless the status
+// shows we haven't caught up with the current generation.
+type cronJobChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (cronJobChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldCronJob, ok := e.ObjectOld.(*batch.CronJob)
+	if !ok {
+		return true
+	}
+	newCronJob, ok := e.ObjectNew.(*batch.CronJob)
+	if !ok {
+		return true
+	}
+
+	if newCronJob.Generation != oldCronJob.Generation ||
+		newCronJob.Status.ObservedGeneration != newCronJob.Generation ||
+		!newCronJob.DeletionTimestamp.Equal(oldCronJob.DeletionTimestamp) {
+		return true
+	}
+	return !reflect.DeepEqual(newCronJob.Annotations, oldCronJob.Annotations) ||
+		!reflect.DeepEqual(newCronJob.Labels, oldCronJob.Labels)
+}
+
+// jobTransitionPredicate lets through updates to our jobs that move them along:
+// starting, finishing, or being deleted, along with changes to their metadata
+// or spec.  Jobs are updated every time one of their pods comes or goes, which
+// on its own tells us nothing, so those updates are dropped.
+type jobTransitionPredicate struct {
+	predicate.Funcs
+}
+
+func (jobTransitionPredicate) Update(e event.UpdateEvent) bool {
+	oldJob, ok := e.ObjectOld.(*kbatch.Job)
+	if !ok {
+		return true
+	}
+	newJob, ok := e.ObjectNew.(*kbatch.Job)
+	if !ok {
+		return true
+	}
+
+	if newJob.Generation != oldJob.Generation ||
+		!reflect.DeepEqual(newJob.Annotations, oldJob.Annotations) ||
+		!reflect.DeepEqual(newJob.Labels, oldJob.Labels) ||
+		!newJob.DeletionTimestamp.Equal(oldJob.DeletionTimestamp) {
+		return true
+	}
+	return !equality.Semantic.DeepEqual(newJob.Status.StartTime, oldJob.Status.StartTime) ||
+		!equality.Semantic.DeepEqual(newJob.Status.CompletionTime, oldJob.Status.CompletionTime) ||
+		!equality.Semantic.DeepEqual(newJob.Status.Conditions, oldJob.Status.Conditions)
+}
@@ -0,0 +1,222 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// quotaRecheckInterval is how often we check back on a run waiting for
+// other CronJobs' jobs to finish, which we don't hear about.
+const quotaRecheckInterval = time.Minute
+
+// quotaBlock describes why a run can't start without going over a RunQuota.
+type quotaBlock struct {
+	quota  *batch.RunQuota
+	reason string
+	limit  string
+	// when there'll be room again, or the zero time if that depends on
+	// jobs finishing
+	retryAt time.Time
+}
+
+// defaultRunQuotaName names the RunQuota standing in for the controller's
+// default limit, in namespaces without any of their own.
+const defaultRunQuotaName = "controller-default"
+
+// runQuotas lists the RunQuotas in the CronJob's namespace, or if it hasn't
+// got any, one standing in for the controller's default limit, if it has one.
+// That one only limits concurrent jobs, so there are never runs to count in
+// its status, which it hasn't got.
+func (r *CronJobReconciler) runQuotas(ctx context.Context, cronJob *batch.CronJob) ([]batch.RunQuota, error) {
+	var quotas batch.RunQuotaList
+	if err := r.List(ctx, &quotas, client.InNamespace(cronJob.Namespace)); err != nil {
+		return nil, err
+	}
+	if max := r.Live.DefaultMaxConcurrentJobs(); len(quotas.Items) == 0 && max != nil {
+		return []batch.RunQuota{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cronJob.Namespace, Name: defaultRunQuotaName},
+			Spec:       batch.RunQuotaSpec{MaxConcurrentJobs: max},
+		}}, nil
+	}
+	return quotas.Items, nil
+}
+
+// quotaBlockFor checks whether starting a run of the CronJob now would go
+// over any of the quotas, returning the first one it would go over.
+func (r *CronJobReconciler) quotaBlockFor(ctx context.Context, cronJob *batch.CronJob, quotas []batch.RunQuota, now time.Time) (*quotaBlock, error) {
+	if len(quotas) == 0 {
+		return nil, nil
+	}
+
+	active, err := r.namespaceActiveJobs(ctx, cronJob.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range quotas {
+		quota := &quotas[i]
+		if max := quota.Spec.MaxConcurrentJobs; max != nil && active >= *max {
+			return &quotaBlock{quota: quota, reason: "ConcurrentJobs", limit: fmt.Sprintf("at most %d jobs running at once", *max)}, nil
+		}
+		if max := quota.Spec.MaxRunsPerHour; max != nil {
+			if retryAt, full := quotaWindowFull(quota, time.Hour, *max, now); full {
+				return &quotaBlock{quota: quota, reason: "RunsPerHour", limit: fmt.Sprintf("at most %d runs an hour", *max), retryAt: retryAt}, nil
+			}
+		}
+		if max := quota.Spec.MaxRunsPerDay; max != nil {
+			if retryAt, full := quotaWindowFull(quota, 24*time.Hour, *max, now); full {
+				return &quotaBlock{quota: quota, reason: "RunsPerDay", limit: fmt.Sprintf("at most %d runs a day", *max), retryAt: retryAt}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// namespaceActiveJobs counts the jobs running in the namespace.  Only jobs
+// belonging to CronJobs count, but all of them do, whichever CronJob they
+// belong to.
+func (r *CronJobReconciler) namespaceActiveJobs(ctx context.Context, namespace string) (int32, error) {
+	var jobs kbatch.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(namespace)); err != nil {
+		return 0, err
+	}
+	active := int32(0)
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.APIVersion != apiGVStr || owner.Kind != "CronJob" {
+			continue
+		}
+		if !jobCompleted(job) && jobFailure(job) == nil {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// quotaWindowFull checks whether the quota's recent runs over the given
+// window leave no room for another, and if so, when the oldest of the ones
+// in the way drops out of the window.
+func quotaWindowFull(quota *batch.RunQuota, window time.Duration, max int32, now time.Time) (time.Time, bool) {
+	var inWindow []time.Time
+	for _, run := range quota.Status.RecentRuns {
+		if run.Add(window).After(now) {
+			inWindow = append(inWindow, run.Time)
+		}
+	}
+	if int32(len(inWindow)) < max {
+		return time.Time{}, false
+	}
+	if max == 0 {
+		// nothing will ever make room
+		return time.Time{}, true
+	}
+	// recent runs are oldest first, so room opens up once enough of the
+	// oldest have dropped out
+	return inWindow[len(inWindow)-int(max)].Add(window), true
+}
+
+// quotaExceededCondition describes whether a run is waiting on a RunQuota.
+func quotaExceededCondition(block *quotaBlock, scheduledTime time.Time) metav1.Condition {
+	if block == nil {
+		return metav1.Condition{
+			Type:    batch.QuotaExceededCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "WithinQuota",
+			Message: "Runs are within the namespace's RunQuotas",
+		}
+	}
+	return metav1.Condition{
+		Type:    batch.QuotaExceededCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  block.reason,
+		Message: fmt.Sprintf("Run scheduled at %s is waiting for RunQuota %s, which allows %s", scheduledTime.Format(time.RFC3339), block.quota.Name, block.limit),
+	}
+}
+
+// countQuotaRun records a run starting now in the status of each quota with
+// hourly or daily limits, dropping runs that have aged out of the last day.
+func (r *CronJobReconciler) countQuotaRun(ctx context.Context, quotas []batch.RunQuota, now time.Time) error {
+	for i := range quotas {
+		quota := &quotas[i]
+		if quota.Spec.MaxRunsPerHour == nil && quota.Spec.MaxRunsPerDay == nil {
+			continue
+		}
+		// unlike most of our status writes, this one needs to be an update:
+		// CronJobs all over the namespace append to the same list, and a
+		// patch would quietly drop whatever they added in the meantime.  On a
+		// busy namespace that conflicts often, so we start over from a fresh
+		// copy rather than giving up on counting the run, which has to come
+		// from the API server: the cache may not have caught up yet.
+		first := true
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if !first {
+				if err := r.apiReader.Get(ctx, client.ObjectKeyFromObject(quota), quota); err != nil {
+					return err
+				}
+			}
+			first = false
+			countRun(quota, now)
+			return r.Status().Update(ctx, quota)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countRun adds a run at the given time to the RunQuota's status, dropping
+// those that have fallen out of the longest window.
+func countRun(quota *batch.RunQuota, now time.Time) {
+	var recent []metav1.Time
+	for _, run := range quota.Status.RecentRuns {
+		if run.Add(24 * time.Hour).After(now) {
+			recent = append(recent, run)
+		}
+	}
+	quota.Status.RecentRuns = append(recent, metav1.NewTime(now))
+}
+
+// quotaUsersOf maps a RunQuota to requests for the CronJobs in its
+// namespace, so that runs waiting on it are reconsidered when it changes.
+// Suspended CronJobs have no runs waiting.
+func (r *CronJobReconciler) quotaUsersOf(obj client.Object) []reconcile.Request {
+	cronJobs, err := ListSuspendedCronJobs(context.Background(), r, obj.GetNamespace(), false)
+	if err != nil {
+		r.Log.Error(err, "unable to list CronJobs subject to RunQuota", "quota", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(cronJobs))
+	for i, cronJob := range cronJobs {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}}
+	}
+	return requests
+}
@@ -0,0 +1,185 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	batch "kubebuilder-tutorial/api/v1"
+	configv1alpha1 "kubebuilder-tutorial/api/v1alpha1"
+)
+
+/*
+Restarting the manager holds up every CronJob that comes due in the meantime, so
+the settings that we can change on the fly, we do: we read the config file every
+so often, and apply it whenever it's changed.  ConfigMaps mounted as files change
+by swapping symlinks, which file watches don't reliably catch, so polling it is.
+*/
+
+// defaultReloadInterval is how often ConfigReloader reads the config file,
+// unless told otherwise.
+const defaultReloadInterval = 10 * time.Second
+
+var (
+	configInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_controller_config_info",
+		Help: "The SHA-256 of the controller's active config file, with a value of 1",
+	}, []string{"sha256"})
+
+	configReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_controller_config_reloads_total",
+		Help: "Number of times the controller's config file was reloaded after changing, by result",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(configInfo, configReloads)
+}
+
+// LiveSettings holds the settings from the config file that the controller
+// picks up as they change.  The zero value has none of them set.
+type LiveSettings struct {
+	mu                       sync.RWMutex
+	notifications            []batch.Notification
+	defaultMaxConcurrentJobs *int32
+}
+
+// Set replaces the settings with those from the config.
+func (s *LiveSettings) Set(config *configv1alpha1.ControllerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = config.Notifications
+	s.defaultMaxConcurrentJobs = config.DefaultMaxConcurrentJobs
+}
+
+// Notifications returns a copy of the notifications to send for every
+// CronJob.
+func (s *LiveSettings) Notifications() []batch.Notification {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]batch.Notification(nil), s.notifications...)
+}
+
+// DefaultMaxConcurrentJobs returns the most jobs the CronJobs of a namespace
+// without any RunQuotas may have running at once, or nil if there's no limit.
+func (s *LiveSettings) DefaultMaxConcurrentJobs() *int32 {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.defaultMaxConcurrentJobs
+}
+
+// ConfigReloader reads the manager's config file every so often, handing it
+// to Apply whenever it's changed.  It runs on every replica, leader or not,
+// so that they all agree on the settings.
+type ConfigReloader struct {
+	// Path is the config file.
+	Path string
+	// Scheme knows the config file's kinds.
+	Scheme *runtime.Scheme
+	// Apply applies the settings that can change without a restart.
+	Apply func(config *configv1alpha1.ControllerConfig) error
+	// Interval is how often to read the file, 10 seconds if zero.
+	Interval time.Duration
+	Log      logr.Logger
+
+	// sum is the SHA-256 of the file as last applied.
+	sum string
+}
+
+// Start implements manager.Runnable.  The file as it was when the manager
+// started is taken to have been applied already.
+func (c *ConfigReloader) Start(ctx context.Context) error {
+	if content, err := ioutil.ReadFile(c.Path); err == nil {
+		c.setSum(content)
+	}
+
+	interval := c.Interval
+	if interval == 0 {
+		interval = defaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.reload()
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (c *ConfigReloader) NeedLeaderElection() bool {
+	return false
+}
+
+// reload applies the config file if it's changed.  A file that can't be
+// read or applied leaves the settings as they were, and is tried again next
+// time.
+func (c *ConfigReloader) reload() {
+	content, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		c.Log.Error(err, "unable to read config file", "file", c.Path)
+		return
+	}
+	if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) == c.sum {
+		return
+	}
+
+	config := &configv1alpha1.ControllerConfig{}
+	err = runtime.DecodeInto(serializer.NewCodecFactory(c.Scheme).UniversalDecoder(), content, config)
+	if err != nil {
+		err = fmt.Errorf("unable to decode config file: %v", err)
+	} else {
+		err = c.Apply(config)
+	}
+	if err != nil {
+		c.Log.Error(err, "unable to reload config file, keeping the settings we have", "file", c.Path)
+		configReloads.WithLabelValues("failure").Inc()
+		return
+	}
+	c.setSum(content)
+	configReloads.WithLabelValues("success").Inc()
+	c.Log.Info("reloaded config file", "file", c.Path, "sha256", c.sum)
+}
+
+// setSum records the file's content as applied.
+func (c *ConfigReloader) setSum(content []byte) {
+	sum := sha256.Sum256(content)
+	c.sum = hex.EncodeToString(sum[:])
+	configInfo.Reset()
+	configInfo.WithLabelValues(c.sum).Set(1)
+}
@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+Lots of CronJobs share a schedule -- there's no shortage of `0 * * * *` -- so left
+to themselves, they'd all come back for their next run at the very same instant,
+and hit the API server together.  We spread them out a little by adding some
+jitter to when we requeue, a tenth of the wait at most, and never more than a
+couple of seconds, so that runs aren't noticeably late.  The share of that we
+add depends only on the object, so it's always late by the same amount for
+waits of the same length, and by at most those couple of seconds.  Schedules
+to the second get no jitter at all: being on time is the whole point of them.
+
+We also keep the wait within bounds.  Below, so that a wakeup that's already
+passed (say, because our clock jumped) doesn't have us spinning.  Above, because
+the requeue is a timer that knows nothing of the wall clock: if the clock is
+stepped while we're waiting, a wait of a month would go off at the wrong time.
+Coming back at least hourly bounds how late that can make a run.  That bound is
+all we do about it: we don't detect our clock drifting or skewed from the API
+server's, let alone correct for it.
+*/
+
+const (
+	minRequeueDelay  = time.Second
+	maxRequeueDelay  = time.Hour
+	maxRequeueJitter = 2 * time.Second
+)
+
+// clockSkewTolerance returns how far ahead of their scheduled time the
+// CronJob's runs may start.
+func (r *CronJobReconciler) clockSkewTolerance(cronJob *batch.CronJob) time.Duration {
+	if seconds := cronJob.Spec.ClockSkewToleranceSeconds; seconds != nil {
+		return time.Duration(*seconds) * time.Second
+	}
+	return r.ClockSkewTolerance
+}
+
+// requeueJitter returns the most jitter the CronJob's requeues can have.
+func requeueJitter(cronJob *batch.CronJob) time.Duration {
+	if cronJob.Spec.ScheduleGranularity == batch.SecondGranularity {
+		return 0
+	}
+	return maxRequeueJitter
+}
+
+// requeueDelay works out how long to wait before reconciling the object
+// again, to be in time for something that needs doing at the given time, with
+// at most the given jitter.
+func requeueDelay(obj types.NamespacedName, now, at time.Time, maxJitter time.Duration) time.Duration {
+	delay := at.Sub(now)
+	if delay > maxRequeueDelay {
+		return maxRequeueDelay
+	}
+
+	jitter := delay / 10
+	if jitter > maxJitter {
+		jitter = maxJitter
+	}
+	if jitter > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(obj.String()))
+		delay += time.Duration(float64(jitter) * float64(h.Sum32()) / math.MaxUint32)
+	}
+
+	if delay < minRequeueDelay {
+		delay = minRequeueDelay
+	}
+	return delay
+}
@@ -0,0 +1,106 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+What happens to the runs that came due while a CronJob was suspended is up to its
+resume policy.  To tell which runs those were, we note in status when we first
+see the CronJob suspended, and when we first see it resumed again.  Either way,
+runs from before the resume time are never started late just because they fell
+out of the skipped run history, or because the starting deadline happened to be
+long enough.
+*/
+
+// suspendedSinceResume checks whether the CronJob's status has it suspended,
+// that is, whether we've seen it suspended since we last saw it resumed.
+func suspendedSinceResume(cronJob *batch.CronJob) bool {
+	suspended, resumed := cronJob.Status.LastSuspendTime, cronJob.Status.LastResumeTime
+	return suspended != nil && (resumed == nil || suspended.After(resumed.Time))
+}
+
+// noteSuspended records that the CronJob is suspended, if we hadn't already,
+// and reports whether that changed anything.
+func noteSuspended(cronJob *batch.CronJob, now time.Time) bool {
+	if suspendedSinceResume(cronJob) {
+		return false
+	}
+	cronJob.Status.LastSuspendTime = &metav1.Time{Time: now}
+	return true
+}
+
+// noteResumed records that the CronJob has been resumed, if it was suspended,
+// and reports whether that changed anything.
+func noteResumed(cronJob *batch.CronJob, now time.Time) bool {
+	if !suspendedSinceResume(cronJob) {
+		return false
+	}
+	cronJob.Status.LastResumeTime = &metav1.Time{Time: now}
+	return true
+}
+
+// heldOver checks whether the run scheduled at the given time came due during
+// the CronJob's last suspension, and is to be run now that it's over.
+func heldOver(cronJob *batch.CronJob, scheduledTime time.Time) bool {
+	if cronJob.Spec.ResumePolicy != batch.RunMissedOnResume || suspendedSinceResume(cronJob) {
+		return false
+	}
+	suspended, resumed := cronJob.Status.LastSuspendTime, cronJob.Status.LastResumeTime
+	return suspended != nil && resumed != nil &&
+		scheduledTime.After(suspended.Time) && !scheduledTime.After(resumed.Time)
+}
+
+// startingDeadlineFrom returns when the starting deadline of the run
+// scheduled at the given time counts from: the resume time for a held over
+// run, or the scheduled time itself otherwise.
+func startingDeadlineFrom(cronJob *batch.CronJob, scheduledTime time.Time) time.Time {
+	if heldOver(cronJob, scheduledTime) {
+		return cronJob.Status.LastResumeTime.Time
+	}
+	return scheduledTime
+}
+
+// skipRunsUntil returns the time up to which runs are skipped because they
+// came due while the CronJob was suspended, or the zero time if none are.
+func skipRunsUntil(cronJob *batch.CronJob) time.Time {
+	if cronJob.Spec.ResumePolicy == batch.RunMissedOnResume || cronJob.Status.LastResumeTime == nil {
+		return time.Time{}
+	}
+	return cronJob.Status.LastResumeTime.Time
+}
+
+// schedulingDeadline returns the earliest scheduled time that can still be
+// started at the given time, given the starting deadline.  That reaches back
+// to the start of the last suspension while its held over runs are in time.
+func schedulingDeadline(cronJob *batch.CronJob, now time.Time, deadline time.Duration) time.Time {
+	earliest := now.Add(-deadline)
+	if cronJob.Spec.ResumePolicy != batch.RunMissedOnResume || suspendedSinceResume(cronJob) {
+		return earliest
+	}
+	suspended, resumed := cronJob.Status.LastSuspendTime, cronJob.Status.LastResumeTime
+	if suspended != nil && resumed != nil && resumed.Add(deadline).After(now) && suspended.Time.Before(earliest) {
+		return suspended.Time
+	}
+	return earliest
+}
@@ -0,0 +1,166 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+var (
+	// retryIndexAnnotation records which attempt at its run a job is: absent
+	// for the original attempt, then 1 for the first retry, and so on.
+	retryIndexAnnotation = "batch.tutorial.kubebuilder.io/retry-index"
+)
+
+// maxRetryBackoff caps the wait between retries, however many there have been.
+const maxRetryBackoff = 24 * time.Hour
+
+// pendingRetry is a run whose latest attempt failed, and which our retry
+// policy says to try again.
+type pendingRetry struct {
+	// the attempt that failed
+	failedJob *kbatch.Job
+	// the run's nominal schedule time
+	scheduledTime time.Time
+	// the index of the attempt to create
+	attempt int
+	// when the attempt may be created, after backoff
+	retryAt time.Time
+}
+
+// getRetryIndex returns which attempt at its run a job is, treating anything
+// unparseable as the original attempt.
+func getRetryIndex(job *kbatch.Job) int {
+	index, err := strconv.Atoi(job.Annotations[retryIndexAnnotation])
+	if err != nil || index < 0 {
+		return 0
+	}
+	return index
+}
+
+// jobFailure returns the job's Failed condition if the job has failed.
+func jobFailure(job *kbatch.Job) *kbatch.JobCondition {
+	for i, c := range job.Status.Conditions {
+		if c.Type == kbatch.JobFailed && c.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// pendingRetries finds the runs whose latest attempt failed for a reason the
+// policy retries on, and which haven't used up their retries yet.
+func pendingRetries(policy *batch.RetryPolicy, jobs []kbatch.Job) []pendingRetry {
+	if policy == nil || policy.MaxRetries <= 0 {
+		return nil
+	}
+
+	// find the latest attempt at each run
+	latest := make(map[runKey]*kbatch.Job)
+	for i := range jobs {
+		job := &jobs[i]
+		key, ok := jobRunKey(job)
+		if !ok || job.Annotations[hookAnnotation] != mainRun {
+			// we only retry the main job of a run, not its hooks
+			continue
+		}
+		if other, ok := latest[key]; !ok || getRetryIndex(job) > getRetryIndex(other) {
+			latest[key] = job
+		}
+	}
+
+	var retries []pendingRetry
+	for key, job := range latest {
+		failure := jobFailure(job)
+		if failure == nil || !retriesOn(policy, failure.Reason) || nextStepSkipped(job) {
+			continue
+		}
+		attempt := getRetryIndex(job) + 1
+		if attempt > int(policy.MaxRetries) {
+			continue
+		}
+		retryAt := failure.LastTransitionTime.Time
+		if policy.Backoff != nil {
+			retryAt = retryAt.Add(retryBackoff(policy.Backoff.Duration, attempt))
+		}
+
+		retries = append(retries, pendingRetry{
+			failedJob:     job,
+			scheduledTime: key.time(),
+			attempt:       attempt,
+			retryAt:       retryAt,
+		})
+	}
+	return retries
+}
+
+// retryBackoff backs off exponentially, doubling the wait after every
+// attempt, up to maxRetryBackoff.
+func retryBackoff(backoff time.Duration, attempt int) time.Duration {
+	for i := 1; i < attempt && backoff > 0 && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+func retriesOn(policy *batch.RetryPolicy, reason string) bool {
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, retryOn := range policy.RetryOn {
+		if retryOn == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// isAwaitingRetry checks if the job is a failed attempt that's due a retry,
+// in which case we don't want to prune it from history just yet.
+func isAwaitingRetry(retries []pendingRetry, job *kbatch.Job) bool {
+	for _, retry := range retries {
+		if retry.failedJob.Name == job.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// constructRetryJob builds the next attempt at a failed run: it's the same
+// job we'd have made for the run in the first place, with a suffix to tell
+// the attempts apart.
+func (r *CronJobReconciler) constructRetryJob(ctx context.Context, cronJob *batch.CronJob, retry pendingRetry) (*kbatch.Job, error) {
+	job, err := r.constructJobForCronJob(ctx, cronJob, retry.scheduledTime)
+	if err != nil {
+		return nil, err
+	}
+	setRunTrigger(cronJob, job, retry.scheduledTime, retry.failedJob.Annotations[triggerAnnotation])
+	job.Name = fmt.Sprintf("%s-r%d", job.Name, retry.attempt)
+	job.Annotations[retryIndexAnnotation] = strconv.Itoa(retry.attempt)
+	return job, nil
+}
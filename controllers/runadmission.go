@@ -0,0 +1,121 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/runadmission"
+)
+
+/*
+Some decisions about whether a run should go ahead can't be written down ahead
+of time: the cluster's too busy, a change freeze has just been called, the
+thing the job talks to is down.  A CronJobPolicy can name an endpoint to make
+those decisions, which we ask just before creating the first job of each run,
+telling it about the run and how busy things are.  If it says no, the run is
+skipped, like any other run a policy forbids.  A run it's let go ahead isn't
+asked about again for its retries or later steps.
+*/
+
+// defaultRunAdmissionTimeout is how long we wait for a policy endpoint,
+// unless the policy says otherwise.
+const defaultRunAdmissionTimeout = 5 * time.Second
+
+// runDeniedError reports a run vetoed by a policy endpoint.
+type runDeniedError struct {
+	policy string
+	reason string
+}
+
+func (e *runDeniedError) Error() string {
+	return fmt.Sprintf("run denied by CronJobPolicy %s: %s", e.policy, e.reason)
+}
+
+// admitRun asks the policy endpoints of the namespace's CronJobPolicies, in
+// order, whether the run the job is for may go ahead, returning a
+// runDeniedError if one of them says no.
+func (r *CronJobReconciler) admitRun(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, scheduledTime time.Time) error {
+	policies, err := batch.PoliciesFor(ctx, r, cronJob.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var review *runadmission.Review
+	for _, policy := range policies {
+		hook := policy.Spec.RunAdmission
+		if hook == nil {
+			continue
+		}
+		if review == nil {
+			if review, err = r.runReview(ctx, cronJob, job, scheduledTime); err != nil {
+				return err
+			}
+		}
+
+		timeout := defaultRunAdmissionTimeout
+		if hook.TimeoutSeconds != nil {
+			timeout = time.Duration(*hook.TimeoutSeconds) * time.Second
+		}
+		response, err := r.RunAdmission.Review(ctx, hook.URL, timeout, review)
+		var denied *runDeniedError
+		switch {
+		case err != nil && hook.FailurePolicy == batch.RunAdmissionIgnore:
+			r.Log.Error(err, "unable to reach run admission endpoint, letting run go ahead", "policy", policy.Name)
+		case err != nil:
+			denied = &runDeniedError{policy: policy.Name, reason: fmt.Sprintf("unable to reach policy endpoint: %v", err)}
+		case !response.Allowed:
+			denied = &runDeniedError{policy: policy.Name, reason: response.Reason}
+		}
+		if denied != nil {
+			r.Recorder.Eventf(cronJob, corev1.EventTypeNormal, "RunDenied", "Will not create job %s: %v", job.Name, denied)
+			return denied
+		}
+	}
+	return nil
+}
+
+// runReview describes the run the job is for, for policy endpoints.
+func (r *CronJobReconciler) runReview(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, scheduledTime time.Time) (*runadmission.Review, error) {
+	namespaceActive, err := r.namespaceActiveJobs(ctx, cronJob.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	review := &runadmission.Review{
+		Namespace:           cronJob.Namespace,
+		CronJob:             cronJob.Name,
+		Labels:              cronJob.Labels,
+		Job:                 job.Name,
+		ScheduledTime:       scheduledTime,
+		ConsecutiveFailures: cronJob.Status.ConsecutiveFailures,
+		Load: runadmission.Load{
+			CronJobActiveJobs:   cronJob.Status.ActiveCount,
+			NamespaceActiveJobs: namespaceActive,
+		},
+	}
+	if r.Tenants != nil {
+		tenant, total := r.Tenants.Load(cronJob)
+		review.Load.TenantActiveJobs, review.Load.TotalActiveJobs = &tenant, &total
+	}
+	return review, nil
+}
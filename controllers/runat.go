@@ -0,0 +1,81 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// pendingOneOffRuns returns the .spec.runAt entries that have come due but
+// haven't been run yet, oldest first, along with the earliest entry that is
+// still in the future (if any).
+func pendingOneOffRuns(cronJob *batch.CronJob, now time.Time) (due []time.Time, next *time.Time) {
+	for _, runAt := range cronJob.Spec.RunAt {
+		if containsTime(cronJob.Status.ConsumedRunAt, runAt.Time) {
+			continue
+		}
+		if runAt.Time.After(now) {
+			if next == nil || runAt.Time.Before(*next) {
+				t := runAt.Time
+				next = &t
+			}
+			continue
+		}
+		due = append(due, runAt.Time)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Before(due[j]) })
+	return due, next
+}
+
+// consumedOneOffRuns returns the consumed entries from status that are still
+// listed in the spec, so that removing an entry from .spec.runAt also forgets
+// that it was run.
+func consumedOneOffRuns(cronJob *batch.CronJob) []metav1.Time {
+	var consumed []metav1.Time
+	for _, runAt := range cronJob.Status.ConsumedRunAt {
+		if containsTime(cronJob.Spec.RunAt, runAt.Time) {
+			consumed = append(consumed, runAt)
+		}
+	}
+	return consumed
+}
+
+func containsTime(times []metav1.Time, t time.Time) bool {
+	for _, candidate := range times {
+		if candidate.Time.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalTimes(a, b []metav1.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Time.Equal(b[i].Time) {
+			return false
+		}
+	}
+	return true
+}
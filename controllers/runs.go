@@ -0,0 +1,499 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/audit"
+)
+
+// runName returns the name shared by the CronJobRun for the run scheduled at
+// the given time and its main job.  It's deterministic, so that the same run
+// is never created twice.  The CronJob's name is shortened as needed to leave
+// room for the scheduled time, and for the suffixes of the run's other jobs
+// within the 63 characters a job's name may have, so it's followed by a hash
+// of the full name, to keep CronJobs whose names only differ in the part
+// that's cut off apart.  CronJobTriggers created in the same second share a
+// time, so the runs they fire hash the trigger's name in too.
+func runName(cronJob *batch.CronJob, scheduledTime time.Time, trigger string) string {
+	h := fnv.New32a()
+	h.Write([]byte(cronJob.Name))
+	if trigger != "" {
+		h.Write([]byte("/" + trigger))
+	}
+	suffix := fmt.Sprintf("-%d-%08x", scheduledTime.Unix(), h.Sum32())
+
+	prefix := cronJob.Name
+	if maxLength := validation.DNS1035LabelMaxLength - cronJob.JobNameSuffixLength() - len(suffix); len(prefix) > maxLength {
+		prefix = strings.TrimRight(prefix[:maxLength], "-.")
+	}
+	return prefix + suffix
+}
+
+// legacyRunName returns the name runName used to return, before it started
+// adding a hash.  We still look for runs and jobs by these names, so that
+// runs that were in flight across an upgrade aren't started a second time;
+// once none of those are left, this can go.
+func legacyRunName(cronJob *batch.CronJob, scheduledTime time.Time) string {
+	return fmt.Sprintf("%s-%d", cronJob.Name, scheduledTime.Unix())
+}
+
+// runKey tells a CronJob's runs apart: by their scheduled time, and by the
+// CronJobTrigger that fired them, if one did.
+type runKey struct {
+	scheduledTime int64
+	trigger       string
+}
+
+// jobRunKey returns the key of the run the job belongs to, if it belongs to
+// one.
+func jobRunKey(job *kbatch.Job) (runKey, bool) {
+	// times parsed from annotations and read back from the API server end up
+	// in different locations, so we key these by instant instead
+	scheduledTime, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation])
+	if err != nil {
+		return runKey{}, false
+	}
+	return runKey{scheduledTime: scheduledTime.Unix(), trigger: job.Annotations[triggerAnnotation]}, true
+}
+
+// time returns the run's scheduled time.
+func (k runKey) time() time.Time {
+	return time.Unix(k.scheduledTime, 0).UTC()
+}
+
+// setRunTrigger makes the job one for the run fired by the named
+// CronJobTrigger, renaming it to match.  It leaves jobs for other runs alone.
+func setRunTrigger(cronJob *batch.CronJob, job *kbatch.Job, scheduledTime time.Time, trigger string) {
+	if trigger == "" {
+		return
+	}
+	job.Name = runName(cronJob, scheduledTime, trigger) + strings.TrimPrefix(job.Name, runName(cronJob, scheduledTime, ""))
+	job.Annotations[triggerAnnotation] = trigger
+}
+
+// getOrCreateRun returns the CronJobRun for the run scheduled at the given
+// time, or fired by the named CronJobTrigger, creating it if it doesn't exist
+// yet, as triggered by the initiator if someone triggered it by hand.
+func (r *CronJobReconciler) getOrCreateRun(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time, trigger, initiator string) (*batch.CronJobRun, error) {
+	if trigger == "" {
+		var legacy batch.CronJobRun
+		err := r.Get(ctx, types.NamespacedName{Namespace: cronJob.Namespace, Name: legacyRunName(cronJob, scheduledTime)}, &legacy)
+		if err == nil {
+			return &legacy, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	run := &batch.CronJobRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runName(cronJob, scheduledTime, trigger),
+			Namespace: cronJob.Namespace,
+		},
+		Spec: batch.CronJobRunSpec{
+			CronJobName:   cronJob.Name,
+			ScheduledTime: metav1.NewTime(scheduledTime),
+			Trigger:       trigger,
+			TriggeredBy:   initiator,
+		},
+	}
+	if err := ctrl.SetControllerReference(cronJob, run, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	err := r.Create(ctx, run)
+	if apierrors.IsAlreadyExists(err) {
+		err = r.Get(ctx, types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, run)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// createRunJob creates a job for one of the CronJob's runs, and counts it in
+// the CronJob's status for the caller to write back.  Along the way it:
+//
+//   - gives pods that get a service mesh sidecar a container to have it quit
+//   - checks the signatures CronJobPolicies require of the job's images, and
+//     for a new run, asks the policies' endpoints, if any, to let it go ahead
+//   - makes the job a dependent of the run's CronJobRun as well as of the
+//     CronJob itself
+//   - creates the CronJob's other kind of workload instead, if its runs have
+//     one, with the job filled in as its view
+//
+// If the job turns out to exist already, say because we're re-deriving a run
+// we started just before a restart, we take that one as the run's job, and
+// report it as already existing.  If something else has taken its name, we
+// fall back to a generated one.
+func (r *CronJobReconciler) createRunJob(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job) (err error) {
+	ctx, span := startSpan(ctx, "CreateJob", cronJob.Namespace, cronJob.Name)
+	defer func() { endSpan(span, err) }()
+
+	backend, err := r.backendFor(cronJob, job)
+	if err != nil {
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "FailedCreate", "Error creating job: %v", err)
+		return err
+	}
+	// the container that has a mesh sidecar quit is checked along with the
+	// job's own
+	if backend == nil || cronJob.Spec.WorkloadRef.RunsPodTemplate() {
+		if err := r.quitMeshSidecar(ctx, cronJob, job); err != nil {
+			return err
+		}
+	}
+	if err := r.verifyImages(ctx, cronJob, job); err != nil {
+		return err
+	}
+
+	scheduledTime, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation])
+	if err != nil {
+		return err
+	}
+	if isFirstStep(cronJob, job) {
+		// once a run's been let go ahead, its later steps and retries are too
+		if err := r.admitRun(ctx, cronJob, job, scheduledTime); err != nil {
+			return err
+		}
+	}
+	trigger := job.Annotations[triggerAnnotation]
+	run, err := r.getOrCreateRun(ctx, cronJob, scheduledTime, trigger, job.Annotations[triggeredByAnnotation])
+	if err != nil {
+		return err
+	}
+	annotateRun(ctx, job, run.Name)
+
+	// what the job needs in place before it starts, handed over to it once
+	// it exists
+	var forJob []client.Object
+	secret, err := r.injectSecrets(ctx, cronJob, job, run)
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		forJob = append(forJob, secret)
+	}
+	policy, err := r.applyNetworkPolicy(ctx, cronJob, job, run)
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		forJob = append(forJob, policy)
+	}
+
+	job.OwnerReferences = append(job.OwnerReferences, metav1.OwnerReference{
+		APIVersion: apiGVStr,
+		Kind:       "CronJobRun",
+		Name:       run.Name,
+		UID:        run.UID,
+	})
+	// runs fired by CronJobTriggers never had legacy names
+	legacyName := job.Name
+	if trigger == "" {
+		legacyName = legacyRunName(cronJob, scheduledTime) + strings.TrimPrefix(job.Name, runName(cronJob, scheduledTime, ""))
+	}
+	if backend != nil {
+		err = r.createWorkload(ctx, cronJob, backend, job)
+		if apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	} else if err = r.applyJob(ctx, job, legacyName); apierrors.IsAlreadyExists(err) {
+		var claimed bool
+		claimed, err = r.claimExistingJob(ctx, cronJob, job, legacyName)
+		if err == nil && claimed {
+			return apierrors.NewAlreadyExists(kbatch.Resource("jobs"), job.Name)
+		}
+		if err == nil {
+			r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "NameTaken", "Job name %s is taken by something else, generating one instead", job.Name)
+			job.GenerateName = job.Name + "-"
+			job.Name = ""
+			var writer client.Writer
+			if writer, err = r.jobWriter(job); err == nil {
+				err = writeJob(job, func(obj client.Object) error {
+					return writer.Create(ctx, obj, client.FieldOwner(fieldManager))
+				})
+			}
+		}
+	}
+	if err != nil {
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "FailedCreate", "Error creating job: %v", err)
+		return err
+	}
+	for _, obj := range forJob {
+		if err := r.handOverToJob(ctx, obj, job); err != nil {
+			return err
+		}
+	}
+	jobCreations.WithLabelValues(cronJob.Namespace, cronJob.Name).Inc()
+	if isFirstStep(cronJob, job) {
+		// later steps and retries are late by design
+		lag := r.Now().Sub(scheduledTime)
+		scheduleLag.WithLabelValues(cronJob.Namespace, cronJob.Name).Set(lag.Seconds())
+		cronJobHealth.observeLag(types.NamespacedName{Namespace: cronJob.Namespace, Name: cronJob.Name}, lag)
+	}
+	r.Recorder.Eventf(cronJob, corev1.EventTypeNormal, "SuccessfulCreate", "Created job %s", job.Name)
+	r.audit(ctx, cronJob, scheduledTime, audit.Ran, "", job.Name)
+
+	cronJob.Status.JobsCreated++
+	return nil
+}
+
+// runSkipReason returns why createRunJob declined to start a run, for the
+// errors that mean the run should be skipped rather than tried again.
+func runSkipReason(err error) (batch.SkipReason, bool) {
+	var (
+		unverified *imagesUnverifiedError
+		denied     *runDeniedError
+	)
+	switch {
+	case errors.As(err, &unverified):
+		return batch.ImageVerificationSkip, true
+	case errors.As(err, &denied):
+		return batch.RunDeniedSkip, true
+	}
+	return "", false
+}
+
+// runOwnerReference makes the run an owner of something created for one of
+// its jobs before the job exists.  Not its controller, though: the job takes
+// that over once it's created, and applying the object again for the same job
+// mustn't give it a second one.
+func runOwnerReference(run *batch.CronJobRun) metav1.OwnerReference {
+	blockOwnerDeletion := true
+	return metav1.OwnerReference{
+		APIVersion:         apiGVStr,
+		Kind:               "CronJobRun",
+		Name:               run.Name,
+		UID:                run.UID,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+}
+
+// handOverToJob makes the job the owner of something created for it before it
+// existed, so that it goes along with the job.  If the job is a view, it's the
+// workload that takes it.
+func (r *CronJobReconciler) handOverToJob(ctx context.Context, obj client.Object, job *kbatch.Job) error {
+	gvk := kbatch.SchemeGroupVersion.WithKind("Job")
+	if isWorkload(job) {
+		gvk = job.GroupVersionKind()
+	}
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	obj.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(job, gvk)})
+	return r.Patch(ctx, obj, patch)
+}
+
+// claimExistingJob looks up the job that's already there under the given
+// job's name (or its legacy name), and checks whether it's the job for the
+// same run.  It is if we control it, or if nothing does and it's for the same
+// scheduled time, in which case we adopt it; the job is then filled in from
+// the existing one.
+func (r *CronJobReconciler) claimExistingJob(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, legacyName string) (bool, error) {
+	var existing kbatch.Job
+	err := r.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: job.Name}, &existing)
+	if apierrors.IsNotFound(err) {
+		err = r.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: legacyName}, &existing)
+	}
+	if apierrors.IsNotFound(err) {
+		// it was there a moment ago, so it's on its way out: we'll make
+		// another go of it next time round
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch owner := metav1.GetControllerOf(&existing); {
+	case owner != nil && owner.UID == cronJob.UID:
+	case owner == nil && existing.Annotations[scheduledTimeAnnotation] == job.Annotations[scheduledTimeAnnotation]:
+		if err := r.adoptJob(ctx, cronJob, &existing); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+	*job = existing
+	return true, nil
+}
+
+// applyJob creates a job with a server-side apply, under our own field
+// manager.  An apply would happily update a job that's already there, though,
+// and we only ever want to create each one once, so we still report jobs we
+// already know about as already existing, whether under the job's name or
+// the legacy name it would have had.  Should the cache not have caught up
+// yet, applying the same job again changes nothing.
+func (r *CronJobReconciler) applyJob(ctx context.Context, job *kbatch.Job, legacyName string) error {
+	for _, name := range []string{job.Name, legacyName} {
+		var existing kbatch.Job
+		err := r.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: name}, &existing)
+		if err == nil {
+			return apierrors.NewAlreadyExists(kbatch.Resource("jobs"), name)
+		}
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	writer, err := r.jobWriter(job)
+	if err != nil {
+		return err
+	}
+	job.APIVersion = kbatch.SchemeGroupVersion.String()
+	job.Kind = "Job"
+	return writeJob(job, func(obj client.Object) error {
+		return writer.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager))
+	})
+}
+
+// recordSkippedRunObject creates a CronJobRun for a run that was skipped
+// rather than started.
+func (r *CronJobReconciler) recordSkippedRunObject(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time, reason batch.SkipReason) error {
+	run, err := r.getOrCreateRun(ctx, cronJob, scheduledTime, "", "")
+	if err != nil {
+		return err
+	}
+	if run.Status.Phase == batch.CronJobRunSkipped {
+		return nil
+	}
+
+	patch := client.MergeFrom(run.DeepCopy())
+	run.Status.Phase = batch.CronJobRunSkipped
+	run.Status.SkipReason = reason
+	return r.Status().Patch(ctx, run, patch)
+}
+
+// syncRuns brings the status of the CronJob's CronJobRuns up to date with
+// their jobs, and prunes the oldest finished ones.  Runs whose jobs have all
+// been cleaned up keep the status they last had.
+func (r *CronJobReconciler) syncRuns(ctx context.Context, cronJob *batch.CronJob, jobs []kbatch.Job, retries []pendingRetry, hookSteps []pendingHookStep) error {
+	var runs batch.CronJobRunList
+	if err := r.List(ctx, &runs, client.InNamespace(cronJob.Namespace), client.MatchingFields{jobOwnerKey: cronJob.Name}); err != nil {
+		return err
+	}
+
+	jobsByRun := make(map[runKey][]kbatch.Job)
+	for _, job := range jobs {
+		if key, ok := jobRunKey(&job); ok {
+			jobsByRun[key] = append(jobsByRun[key], job)
+		}
+	}
+	pending := make(map[runKey]bool)
+	for _, retry := range retries {
+		key, _ := jobRunKey(retry.failedJob)
+		pending[key] = true
+	}
+	for _, step := range hookSteps {
+		key, _ := jobRunKey(step.finishedJob)
+		pending[key] = true
+	}
+
+	var finished []*batch.CronJobRun
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		key := runKey{scheduledTime: run.Spec.ScheduledTime.Unix(), trigger: run.Spec.Trigger}
+		if runJobs := jobsByRun[key]; len(run.Status.SkipReason) == 0 && len(runJobs) > 0 {
+			status := runStatus(cronJob, runJobs, pending[key])
+			if !equality.Semantic.DeepEqual(status, run.Status) {
+				patch := client.MergeFrom(run.DeepCopy())
+				run.Status = status
+				if err := r.Status().Patch(ctx, run, patch); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch run.Status.Phase {
+		case batch.CronJobRunSucceeded, batch.CronJobRunFailed, batch.CronJobRunSkipped:
+			finished = append(finished, run)
+		}
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Spec.ScheduledTime.Before(&finished[j].Spec.ScheduledTime)
+	})
+	for i := 0; i < len(finished)-maxRecentRuns; i++ {
+		if err := r.Delete(ctx, finished[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStatus works out the status of a run from its jobs, which all belong to
+// the same scheduled time.  A run that's due a retry or its next hook is
+// still running, even if none of its jobs are.
+func runStatus(cronJob *batch.CronJob, jobs []kbatch.Job, pending bool) batch.CronJobRunStatus {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTimestamp.Before(&jobs[j].CreationTimestamp)
+	})
+
+	stillGoing, failed := pending, false
+	for i := range jobs {
+		switch {
+		case jobFailure(&jobs[i]) != nil, nextStepSkipped(&jobs[i]):
+			failed = true
+		case !jobCompleted(&jobs[i]):
+			stillGoing = true
+		}
+	}
+
+	var phase batch.CronJobRunPhase
+	succeeded := runSucceeded(cronJob, jobs)
+	switch {
+	case succeeded:
+		phase = batch.CronJobRunSucceeded
+	case failed && !stillGoing:
+		phase = batch.CronJobRunFailed
+	default:
+		phase = batch.CronJobRunRunning
+	}
+
+	summary := summarizeRun(time.Time{}, succeeded, jobs)
+	status := batch.CronJobRunStatus{
+		Phase:     phase,
+		StartTime: summary.startTime,
+	}
+	if phase == batch.CronJobRunRunning && summary.startTime == nil {
+		status.Phase = batch.CronJobRunPending
+	}
+	if phase != batch.CronJobRunRunning {
+		status.CompletionTime = summary.completionTime
+	}
+	for _, job := range jobs {
+		status.Jobs = append(status.Jobs, job.Name)
+	}
+	return status
+}
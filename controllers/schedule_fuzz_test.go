@@ -0,0 +1,149 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	batchv1 "kubebuilder-tutorial/api/v1"
+)
+
+// These fuzz the schedule arithmetic the controller does on every reconcile,
+// with whatever schedules, time zones and timestamps users and clocks can
+// throw at it.  They need Go 1.18's fuzzing; without -fuzz, they just run
+// their seeds.
+
+// FuzzGetNextSchedule checks that getNextSchedule never panics, and that the
+// runs it says are due are ones we haven't started, in order, and due by now,
+// and the next run still to come.
+func FuzzGetNextSchedule(f *testing.F) {
+	created := time.Date(2021, 3, 14, 1, 59, 26, 0, time.UTC).Unix()
+	f.Add("*/5 * * * *", "", "", created, int64(0), created+3600, int64(-1), int32(-1))
+	f.Add("0 2 * * *", "America/New_York", "", created, created+86400, created+10*86400, int64(300), int32(-1))
+	f.Add("30 1 * * 0", "Europe/London", "", created, int64(0), created+400*86400, int64(-1), int32(5))
+	f.Add("*/10 * * * * *", "Asia/Kolkata", string(batchv1.SecondGranularity), created, int64(0), created+3600, int64(-1), int32(0))
+	f.Add("@every 1h30m", "", "", created, int64(0), created-3600, int64(60), int32(-1))
+	f.Add("0 0 29 2 *", "Pacific/Chatham", "", int64(0), int64(0), created, int64(-1), int32(-1))
+	f.Add("@hourly", "Bad/Zone", "", created, int64(0), created+7200, int64(-1), int32(-1))
+	f.Add("60 * * * *", "", "", created, int64(0), created+7200, int64(-1), int32(-1))
+	// repeating intervals from long ago used to overflow, and never finish
+	f.Add("R/1000-01-01T00:00:00Z/PT1H", "", "", created, int64(0), created+3600, int64(-1), int32(-1))
+	f.Add("R/0001-01-01T00:00:00Z/PT1S", "", "", created, int64(0), created+3600, int64(-1), int32(-1))
+
+	f.Fuzz(func(t *testing.T, spec, timeZone, granularity string, createdAt, lastScheduledAt, nowAt, startingDeadlineSeconds int64, maxMissedRuns int32) {
+		cronJob := &batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Unix(createdAt, 0)},
+			Spec: batchv1.CronJobSpec{
+				Schedule:            spec,
+				ScheduleGranularity: batchv1.ScheduleGranularity(granularity),
+			},
+		}
+		if timeZone != "" {
+			cronJob.Spec.TimeZone = &timeZone
+		}
+		if lastScheduledAt != 0 {
+			cronJob.Status.LastScheduleTime = &metav1.Time{Time: time.Unix(lastScheduledAt, 0)}
+		}
+		if startingDeadlineSeconds >= 0 {
+			cronJob.Spec.StartingDeadlineSeconds = &startingDeadlineSeconds
+		}
+		if maxMissedRuns >= 0 {
+			cronJob.Spec.MaxMissedRuns = &maxMissedRuns
+		}
+		now := time.Unix(nowAt, 0)
+
+		missed, next, tooManyMissed, err := getNextSchedule(cronJob, nil, now)
+		if err != nil {
+			return
+		}
+
+		earliest := cronJob.CreationTimestamp.Time
+		if last := cronJob.Status.LastScheduleTime; last != nil {
+			earliest = last.Time
+		}
+		for i, run := range missed {
+			if !run.After(earliest) {
+				t.Errorf("missed run %s isn't after %s, which we've already covered", run, earliest)
+			}
+			if i > 0 && !run.After(missed[i-1]) {
+				t.Errorf("missed run %s comes after %s", run, missed[i-1])
+			}
+		}
+		if len(missed) > 0 {
+			if lastMissed := missed[len(missed)-1]; lastMissed.After(now) {
+				t.Errorf("missed run %s isn't due yet at %s", lastMissed, now)
+			}
+		}
+		if !next.IsZero() && !next.After(now) {
+			t.Errorf("next run %s is already due at %s", next, now)
+		}
+		if tooManyMissed && len(missed) != 1 {
+			t.Errorf("too many missed runs, but %d of them listed rather than the most recent", len(missed))
+		}
+	})
+}
+
+// FuzzGetScheduledTimeForJob checks that getScheduledTimeForJob never panics,
+// whatever's been written to a job's scheduled time annotation, and that the
+// times it reads back are the ones written.
+func FuzzGetScheduledTimeForJob(f *testing.F) {
+	f.Add("2021-03-14T01:59:26Z")
+	f.Add("2021-03-14T01:59:26.535897932-08:00")
+	f.Add("")
+	f.Add("1615687166")
+	f.Add("2021-02-29T25:61:61Z")
+	f.Add("0000-01-01T00:00:00+23:59")
+	f.Add("9999-12-31T23:59:59-23:59")
+
+	f.Fuzz(func(t *testing.T, annotation string) {
+		job := &kbatch.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{scheduledTimeAnnotation: annotation},
+			},
+		}
+		scheduledTime, err := getScheduledTimeForJob(job)
+		switch {
+		case err != nil:
+			if scheduledTime != nil {
+				t.Errorf("got %s, along with error %v", scheduledTime, err)
+			}
+		case annotation == "":
+			if scheduledTime != nil {
+				t.Errorf("got %s for a job without a scheduled time", scheduledTime)
+			}
+		case scheduledTime == nil:
+			t.Errorf("got no scheduled time from %q", annotation)
+		default:
+			// what we write for the time we read must read back as the same time
+			job.Annotations[scheduledTimeAnnotation] = scheduledTime.UTC().Format(time.RFC3339Nano)
+			again, err := getScheduledTimeForJob(job)
+			if err != nil {
+				t.Fatalf("can't read back %q, read from %q: %v", job.Annotations[scheduledTimeAnnotation], annotation, err)
+			}
+			if !again.Equal(*scheduledTime) {
+				t.Errorf("read %s back from %q, read from %q as %s", again, job.Annotations[scheduledTimeAnnotation], annotation, scheduledTime)
+			}
+		}
+	})
+}
@@ -0,0 +1,95 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// scheduleOverrideKey indexes ScheduleOverrides by the CronJob they
+// override.
+const scheduleOverrideKey = ".spec.cronJobName"
+
+// activeScheduleOverride returns the ScheduleOverride for the CronJob that
+// hasn't expired yet, or nil if there's none.  If several haven't, the most
+// recently created one wins, since it's most likely the one meant.
+func (r *CronJobReconciler) activeScheduleOverride(ctx context.Context, cronJob *batch.CronJob, now time.Time) (*batch.ScheduleOverride, error) {
+	var overrides batch.ScheduleOverrideList
+	if err := r.List(ctx, &overrides, client.InNamespace(cronJob.Namespace), client.MatchingFields{scheduleOverrideKey: cronJob.Name}); err != nil {
+		return nil, err
+	}
+
+	var active *batch.ScheduleOverride
+	for i := range overrides.Items {
+		override := &overrides.Items[i]
+		if !override.Spec.ExpiresAt.After(now) {
+			continue
+		}
+		if active == nil || active.CreationTimestamp.Before(&override.CreationTimestamp) ||
+			(active.CreationTimestamp.Equal(&override.CreationTimestamp) && override.Name > active.Name) {
+			active = override
+		}
+	}
+	return active, nil
+}
+
+// setScheduleOverride records the ScheduleOverride the CronJob runs on in
+// status, which is where parseSchedule picks it up from, returning whether
+// that changed anything.  A change is stamped with the time it took effect,
+// so that the new schedule isn't held to runs it had due before then: now,
+// or when the override that's gone expired, if that was earlier.
+func setScheduleOverride(cronJob *batch.CronJob, override *batch.ScheduleOverride, now time.Time) bool {
+	var applied *batch.AppliedScheduleOverride
+	if override != nil {
+		applied = &batch.AppliedScheduleOverride{
+			Name:      override.Name,
+			Schedule:  override.Spec.Schedule,
+			ExpiresAt: override.Spec.ExpiresAt,
+		}
+	}
+	if equality.Semantic.DeepEqual(cronJob.Status.ScheduleOverride, applied) {
+		return false
+	}
+	changedAt := now
+	if previous := cronJob.Status.ScheduleOverride; previous != nil && previous.ExpiresAt.Time.Before(now) {
+		changedAt = previous.ExpiresAt.Time
+	}
+	cronJob.Status.ScheduleOverride = applied
+	cronJob.Status.ScheduleChangeTime = &metav1.Time{Time: changedAt}
+	return true
+}
+
+// overriddenBy maps a ScheduleOverride to a request for the CronJob it
+// overrides, so that the override takes effect straight away.
+func (r *CronJobReconciler) overriddenBy(obj client.Object) []reconcile.Request {
+	override, ok := obj.(*batch.ScheduleOverride)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: override.Namespace, Name: override.Spec.CronJobName}},
+	}
+}
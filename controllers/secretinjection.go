@@ -0,0 +1,183 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/externalsecrets"
+)
+
+/*
+CronJobs can have secrets resolved from a store outside the cluster each time
+one of their jobs is created, rather than keeping credentials in a Secret that
+lives for as long as they do.  We put what we resolve in a Secret of the job's
+own, mounted into each of its containers, and delete it as soon as the job
+finishes; should we miss that, it goes when the job does.
+
+The job doesn't exist until after its Secret has to, so the Secret starts out
+owned by the job's run, and is handed over to the job once it's created (see
+handOverToJob).  A store we can't reach holds the job up like any other failure
+to create it, and it's tried again with backoff.
+
+The controller's configuration says which paths in the stores each namespace
+may draw on, and we check every secret against that before resolving any of
+them: being able to create CronJobs in a namespace mustn't mean being able to
+read every secret the controller can.
+*/
+
+const (
+	// injectedSecretAnnotation names the Secret injected into a job.
+	injectedSecretAnnotation = "batch.tutorial.kubebuilder.io/injected-secret"
+
+	// injectedSecretVolume is the name of the volume the Secret is mounted
+	// from.
+	injectedSecretVolume = "injected-secrets"
+
+	// secretResolutionTimeout bounds how long we wait on a store for all of
+	// a job's secrets.
+	secretResolutionTimeout = 30 * time.Second
+)
+
+// injectSecrets resolves the CronJob's external secrets into a Secret owned by
+// the job's run, and mounts it into the job.  It returns the Secret, or nil if
+// the CronJob has no secrets to inject.
+func (r *CronJobReconciler) injectSecrets(ctx context.Context, cronJob *batch.CronJob, job *kbatch.Job, run *batch.CronJobRun) (*corev1.Secret, error) {
+	injection := cronJob.Spec.SecretInjection
+	if injection == nil {
+		return nil, nil
+	}
+	data, err := r.resolveSecrets(ctx, cronJob.Namespace, injection)
+	if err != nil {
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "FailedSecretInjection", "Unable to resolve secrets for job %s: %v", job.Name, err)
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       job.Namespace,
+			Name:            job.Name + "-injected",
+			Labels:          job.Labels,
+			OwnerReferences: []metav1.OwnerReference{runOwnerReference(run)},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	// an apply creates the Secret, or refreshes the one left behind by an
+	// earlier attempt at the same job
+	if err := r.Patch(ctx, secret, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return nil, err
+	}
+	mountInjectedSecret(job, secret.Name, injection)
+	return secret, nil
+}
+
+// resolveSecrets fetches each of the secrets from the store, so long as
+// they're all at paths the namespace may have secrets injected from.
+func (r *CronJobReconciler) resolveSecrets(ctx context.Context, namespace string, injection *batch.SecretInjection) (map[string][]byte, error) {
+	provider, ok := r.SecretProviders[injection.Provider]
+	if !ok {
+		return nil, fmt.Errorf("the controller has no secret store %q, only %v", injection.Provider, r.SecretProviders.Names())
+	}
+	for _, secret := range injection.Secrets {
+		if !r.SecretPaths.Allows(namespace, secret.Path) {
+			return nil, fmt.Errorf("secret %s: namespace %s may not have secrets injected from %s", secret.Key, namespace, secret.Path)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, secretResolutionTimeout)
+	defer cancel()
+	data := make(map[string][]byte, len(injection.Secrets))
+	for _, secret := range injection.Secrets {
+		value, err := provider.Resolve(ctx, externalsecrets.Ref{Path: secret.Path, Field: secret.Field})
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secret.Key, err)
+		}
+		data[secret.Key] = value
+	}
+	return data, nil
+}
+
+// mountInjectedSecret adds the Secret to the job's pods, mounted read-only
+// into every container, and notes it on the job for cleaning up later.
+func mountInjectedSecret(job *kbatch.Job, name string, injection *batch.SecretInjection) {
+	mountPath := injection.MountPath
+	if mountPath == "" {
+		mountPath = batch.DefaultSecretMountPath
+	}
+	spec := &job.Spec.Template.Spec
+	// the files belong to root, so containers running as anyone else can
+	// only read them through the pod's fsGroup, if it has one, or else as
+	// everyone, which is the default
+	var mode *int32
+	if spec.SecurityContext != nil && spec.SecurityContext.FSGroup != nil {
+		groupOnly := int32(0440)
+		mode = &groupOnly
+	}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: injectedSecretVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  name,
+				DefaultMode: mode,
+			},
+		},
+	})
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, corev1.VolumeMount{
+				Name:      injectedSecretVolume,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[injectedSecretAnnotation] = name
+}
+
+// cleanUpInjectedSecrets deletes the Secrets injected into the finished jobs,
+// and takes the note of them off the jobs, so that we only try once.
+func (r *CronJobReconciler) cleanUpInjectedSecrets(ctx context.Context, jobs []*kbatch.Job) error {
+	for _, job := range jobs {
+		name, ok := job.Annotations[injectedSecretAnnotation]
+		if !ok {
+			continue
+		}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: job.Namespace, Name: name}}
+		if err := r.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, injectedSecretAnnotation))
+		if err := r.Patch(ctx, workloadOf(job), client.RawPatch(types.MergePatchType, patch)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
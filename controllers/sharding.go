@@ -0,0 +1,59 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"hash/fnv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+/*
+One replica can only reconcile so many CronJobs before runs start going out
+late.  Past that point, we split namespaces between several shards by hashing
+their names, and run a replica (or a few, for failover) per shard.  Each shard
+has a leader election Lease of its own, so that exactly one replica is working
+on a shard at a time, and the Lease's holder records which replica that is.
+*/
+
+// Shard picks out the namespaces one of several controller shards is
+// responsible for.  The zero value is a single shard, which has all of them.
+type Shard struct {
+	// Index is which of the shards this is, counting from zero.
+	Index int
+	// Count is how many shards the namespaces are split between.
+	Count int
+}
+
+// Owns reports whether the namespace belongs to the shard.  Cluster-scoped
+// objects, which have no namespace, belong to every shard.
+func (s Shard) Owns(namespace string) bool {
+	if s.Count <= 1 || namespace == "" {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index
+}
+
+// predicate filters out events for objects in other shards' namespaces.
+func (s Shard) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return s.Owns(obj.GetNamespace())
+	})
+}
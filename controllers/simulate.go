@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+// maxSimulatedRuns caps how many runs SimulateRuns will list, so that a
+// schedule to the second over a long range can't run away with us.
+const maxSimulatedRuns = 10000
+
+// SimulatedRun is a run that SimulateRuns expects the CronJob to have.
+type SimulatedRun struct {
+	// ScheduledTime is when the run comes due.
+	ScheduledTime time.Time
+	// StartBy is the latest we expect its job to be created, allowing for
+	// the jitter in our requeues.
+	StartBy time.Time
+	// SkipReason is why the run will be skipped, if it will be.
+	SkipReason batch.SkipReason
+}
+
+// SimulateRuns lists the runs the CronJob would have between from and to,
+// working them out the way Reconcile does: in the CronJob's time zone, moved
+// or skipped for the given holidays as per its holiday policy, and skipped
+// inside its blackout windows, while it's suspended or paused, or on request.
+// A CronJob that doesn't run on its schedule has none.
+//
+// It only has the CronJob to go on, so it knows nothing of CronJobPolicies,
+// MaintenanceWindows, RunQuotas or the runs before from, and assumes that
+// every run starts in time.
+func SimulateRuns(cronJob *batch.CronJob, holidays map[string]bool, from, to time.Time) ([]SimulatedRun, error) {
+	sched, err := parseSchedule(cronJob)
+	if err != nil {
+		return nil, err
+	}
+	if !cronJob.Spec.SchedulesRuns() {
+		return nil, nil
+	}
+	sched = withHolidayPolicy(cronJob, sched, holidays)
+	windows := blackoutWindows(cronJob, nil)
+
+	skips := 0
+	if cronJob.Spec.SkipNextRuns != nil {
+		skips = int(*cronJob.Spec.SkipNextRuns)
+	}
+	suspended := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+
+	var runs []SimulatedRun
+	for t := sched.Next(from); !t.IsZero() && !t.After(to); t = sched.Next(t) {
+		if len(runs) == maxSimulatedRuns {
+			return runs, fmt.Errorf("more than %d runs between %s and %s", maxSimulatedRuns, from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+		run := SimulatedRun{ScheduledTime: t, StartBy: t.Add(requeueJitter(cronJob))}
+
+		window, err := blackoutWindowFor(windows, t)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case cronJob.Spec.Paused:
+			run.SkipReason = batch.PausedSkip
+		case suspended, cronJob.Spec.SuspendUntil != nil && t.Before(cronJob.Spec.SuspendUntil.Time):
+			run.SkipReason = batch.SuspendedSkip
+		case window != nil:
+			run.SkipReason = batch.BlackoutWindowSkip
+		case cronJob.Spec.HolidayPolicy == batch.SkipHolidays && onHoliday(cronJob, holidays, t):
+			run.SkipReason = batch.HolidaySkip
+		case skips > 0:
+			run.SkipReason = batch.RequestedSkip
+			skips--
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
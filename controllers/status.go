@@ -0,0 +1,147 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/audit"
+)
+
+// maxSkippedRuns bounds the number of skipped runs kept in status, so that a
+// long blackout on a frequent schedule can't grow the object without limit.
+const maxSkippedRuns = 10
+
+// recordSkippedRun notes in status, and in a CronJobRun, that the run
+// scheduled at the given time was not started.  The same run is considered
+// again on every reconcile until the next one comes due, so runs that are
+// already recorded are left alone.  A run skipped from the head of the queue
+// marks the queue as done with up to it.
+func (r *CronJobReconciler) recordSkippedRun(ctx context.Context, cronJob *batch.CronJob, scheduledTime time.Time, reason batch.SkipReason) error {
+	if skippedRunAt(cronJob, scheduledTime) != nil {
+		return nil
+	}
+	if err := r.recordSkippedRunObject(ctx, cronJob, scheduledTime, reason); err != nil {
+		return err
+	}
+
+	cronJob.Status.SkippedRuns = append(cronJob.Status.SkippedRuns, batch.SkippedRun{
+		ScheduledTime: metav1.NewTime(scheduledTime),
+		Reason:        reason,
+	})
+	if excess := len(cronJob.Status.SkippedRuns) - maxSkippedRuns; excess > 0 {
+		cronJob.Status.SkippedRuns = cronJob.Status.SkippedRuns[excess:]
+	}
+	cronJob.Status.JobsSkipped++
+	if queue := cronJob.Status.QueuedRuns; len(queue) > 0 && queue[0].Time.Equal(scheduledTime) {
+		cronJob.Status.QueueSkippedUpTo = &metav1.Time{Time: scheduledTime}
+	}
+
+	if err := r.updateStatus(ctx, cronJob); err != nil {
+		return err
+	}
+	if reason == batch.DeadlineExceededSkip {
+		r.audit(ctx, cronJob, scheduledTime, audit.DeadlineMissed, string(reason))
+	} else {
+		r.audit(ctx, cronJob, scheduledTime, audit.Skipped, string(reason))
+	}
+	return nil
+}
+
+// setNextScheduleTime records when the next run is scheduled in status, or
+// clears it for the zero time, and reports whether that changed anything.
+func setNextScheduleTime(cronJob *batch.CronJob, next time.Time) bool {
+	current := cronJob.Status.NextScheduleTime
+	switch {
+	case next.IsZero() && current == nil:
+		return false
+	case current != nil && current.Time.Equal(next):
+		return false
+	case next.IsZero():
+		cronJob.Status.NextScheduleTime = nil
+	default:
+		cronJob.Status.NextScheduleTime = &metav1.Time{Time: next}
+	}
+	return true
+}
+
+// fieldManager is who the API server records our changes as being made by.
+const fieldManager = "cronjob-controller"
+
+// updateStatus writes the CronJob's status back to the API server.  We
+// server-side apply it, rather than updating the whole object, so that we
+// don't conflict with anyone else changing the CronJob in the meantime: the
+// status is entirely ours, so we force our ownership of it.  The CronJob is
+// refreshed from the response, resource version and all.
+func (r *CronJobReconciler) updateStatus(ctx context.Context, cronJob *batch.CronJob) (err error) {
+	ctx, span := startSpan(ctx, "UpdateStatus", cronJob.Namespace, cronJob.Name)
+	defer func() { endSpan(span, err) }()
+
+	// only the status goes in the patch, since applying anything else would
+	// claim it as ours too
+	patch, err := json.Marshal(map[string]interface{}{
+		"apiVersion": apiGVStr,
+		"kind":       "CronJob",
+		"metadata": map[string]interface{}{
+			"name":      cronJob.Name,
+			"namespace": cronJob.Namespace,
+		},
+		"status": cronJob.Status,
+	})
+	if err != nil {
+		return err
+	}
+	return r.Status().Patch(ctx, cronJob, client.RawPatch(types.ApplyPatchType, patch), client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// skippedRunAt returns the recorded skip for the run scheduled at the given
+// time, if there is one.
+func skippedRunAt(cronJob *batch.CronJob, scheduledTime time.Time) *batch.SkippedRun {
+	for i, skipped := range cronJob.Status.SkippedRuns {
+		if skipped.ScheduledTime.Time.Equal(scheduledTime) {
+			return &cronJob.Status.SkippedRuns[i]
+		}
+	}
+	return nil
+}
+
+// setCondition sets the given condition in status, stamping the transition
+// time from our clock and the generation it was based on, and reports whether
+// that changed anything.
+func (r *CronJobReconciler) setCondition(cronJob *batch.CronJob, condition metav1.Condition) bool {
+	condition.ObservedGeneration = cronJob.Generation
+	existing := meta.FindStatusCondition(cronJob.Status.Conditions, condition.Type)
+	if existing != nil && existing.Status == condition.Status &&
+		existing.Reason == condition.Reason && existing.Message == condition.Message &&
+		existing.ObservedGeneration == condition.ObservedGeneration {
+		return false
+	}
+
+	condition.LastTransitionTime = metav1.NewTime(r.Now())
+	meta.SetStatusCondition(&cronJob.Status.Conditions, condition)
+	// older versions of SetStatusCondition leave this alone on existing conditions
+	meta.FindStatusCondition(cronJob.Status.Conditions, condition.Type).ObservedGeneration = condition.ObservedGeneration
+	return true
+}
@@ -17,13 +17,17 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
@@ -31,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	batchv1 "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/clock"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -41,6 +46,17 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// The CronJob controller runs in the suite's manager on a fake clock, which
+// only moves when a test moves it, so that tests can go through hours of
+// schedule in no time.
+var fakeClock *clock.FakeClock
+var stopManager context.CancelFunc
+
+// clockAnnotation is how the suite wakes the controller up when it moves the
+// clock: the controller asks to be requeued after however long it is until
+// its next run by the fake clock, which would be that long in real time.
+const clockAnnotation = "test.batch.tutorial.kubebuilder.io/clock"
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -50,7 +66,7 @@ func TestAPIs(t *testing.T) {
 }
 
 var _ = BeforeSuite(func(done Done) {
-	logf.SetLogger(zap.LoggerTo(GinkgoWriter, true))
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
@@ -71,11 +87,49 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	By("starting the CronJob controller on a fake clock")
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		MetricsBindAddress: "0",
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	fakeClock = clock.NewFakeClock(time.Now())
+	fakeClock.OnSet(func(now time.Time) {
+		// touch every CronJob, as their requeues would have come due
+		var cronJobs batchv1.CronJobList
+		Expect(k8sClient.List(context.Background(), &cronJobs)).To(Succeed())
+		for i := range cronJobs.Items {
+			patch := []byte(`{"metadata":{"annotations":{"` + clockAnnotation + `":"` + now.Format(time.RFC3339Nano) + `"}}}`)
+			err := k8sClient.Patch(context.Background(), &cronJobs.Items[i], client.RawPatch(types.MergePatchType, patch))
+			Expect(client.IgnoreNotFound(err)).To(Succeed())
+		}
+	})
+
+	err = (&CronJobReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("CronJob"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("cronjob-controller"),
+		Clock:    fakeClock,
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	var ctx context.Context
+	ctx, stopManager = context.WithCancel(context.Background())
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(ctx)).To(Succeed())
+	}()
+
 	close(done)
 }, 60)
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	if stopManager != nil {
+		stopManager()
+	}
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
@@ -0,0 +1,72 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+// parseSchedule parses the CronJob's schedule, evaluated in its time zone if
+// it has one.  Times from the schedule are then in that time zone too, which
+// is also what blackout windows with cron expressions get evaluated in.
+// While a ScheduleOverride applies, its schedule is used instead.  A CronJob
+// on a calendar has the runs we last read from it for a schedule.
+func parseSchedule(cronJob *batch.CronJob) (schedule.Schedule, error) {
+	policy := cronJob.Spec.DSTPolicy.SchedulePolicy()
+	// overrides are validated as standard schedules, whatever the CronJob's
+	// granularity
+	if override := cronJob.Status.ScheduleOverride; override != nil {
+		return parseScheduleIn(override.Schedule, batch.MinuteGranularity, cronJob.Spec.TimeZone, policy)
+	}
+	if cronJob.Spec.CalendarRef != nil {
+		return cronJob.CalendarSchedule(), nil
+	}
+	return parseScheduleIn(cronJob.Spec.Schedule, cronJob.Spec.ScheduleGranularity, cronJob.Spec.TimeZone, policy)
+}
+
+// parseScheduleIn parses a schedule at the given granularity, evaluated in
+// the given time zone if there is one, or the controller's own if not, with
+// daylight saving time transitions handled as per the policy.
+func parseScheduleIn(spec string, granularity batch.ScheduleGranularity, timeZone *string, policy schedule.DSTPolicy) (schedule.Schedule, error) {
+	sched, err := granularity.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Unparseable schedule %q: %v", spec, err)
+	}
+	loc := time.Local
+	if timeZone != nil {
+		loc, err = schedule.LoadLocation(*timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown time zone %q: %v", *timeZone, err)
+		}
+	}
+	return schedule.WithDSTPolicy(sched, loc, policy), nil
+}
+
+// scheduleLocation returns the time zone the CronJob's schedule is evaluated
+// in, falling back to the controller's own if it has none, or an unknown one.
+func scheduleLocation(cronJob *batch.CronJob) *time.Location {
+	if cronJob.Spec.TimeZone != nil {
+		if loc, err := schedule.LoadLocation(*cronJob.Spec.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
@@ -0,0 +1,75 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	kbatch "k8s.io/api/batch/v1"
+)
+
+var tracer = otel.Tracer("kubebuilder-tutorial/controllers")
+
+const (
+	// traceParentAnnotation carries the W3C trace context of the reconcile
+	// that created a job, on the job and its pods, so that whatever runs in
+	// the pod can continue the trace.
+	traceParentAnnotation = "batch.tutorial.kubebuilder.io/traceparent"
+
+	// runAnnotation names the CronJobRun a job (and its pods) belongs to.
+	runAnnotation = "batch.tutorial.kubebuilder.io/run"
+)
+
+// startSpan starts a span for one step of a reconcile, tagged with the
+// CronJob it's for.
+func startSpan(ctx context.Context, name, namespace, cronJobName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("cronjob.namespace", namespace),
+		attribute.String("cronjob.name", cronJobName),
+	))
+}
+
+// endSpan ends the span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// annotateRun marks the job and its pod template with the run they belong
+// to and the trace context in ctx, if any.
+func annotateRun(ctx context.Context, job *kbatch.Job, run string) {
+	carrier := propagation.HeaderCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	for _, annotations := range []*map[string]string{&job.Annotations, &job.Spec.Template.Annotations} {
+		if *annotations == nil {
+			*annotations = make(map[string]string)
+		}
+		(*annotations)[runAnnotation] = run
+		if traceParent := carrier.Get("traceparent"); traceParent != "" {
+			(*annotations)[traceParentAnnotation] = traceParent
+		}
+	}
+}
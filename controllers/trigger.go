@@ -0,0 +1,178 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+var (
+	// triggerAtAnnotation requests a run outside of the schedule at the
+	// given RFC 3339 time, e.g.
+	//
+	//   kubectl annotate cronjob my-job --overwrite \
+	//     batch.tutorial.kubebuilder.io/trigger-at=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+	triggerAtAnnotation = batch.TriggerAtAnnotation
+
+	// triggeredByAnnotation records who triggered a run, as the webhook has
+	// it, on the run's jobs.
+	triggeredByAnnotation = batch.TriggeredByAnnotation
+
+	// triggerAnnotation names the CronJobTrigger that fired a run on the
+	// run's jobs.  Triggers created in the same second share a time, so it
+	// takes this to tell their runs apart.
+	triggerAnnotation = "batch.tutorial.kubebuilder.io/trigger"
+)
+
+// triggerRetention is how long CronJobTriggers people create are kept after
+// they fire: long enough to look up what became of them, without piling up
+// behind CronJobs that are triggered often.  Those of events go sooner, after
+// eventTriggerRetention.
+const triggerRetention = 24 * time.Hour
+
+// getTriggerTime returns the time of the manual trigger requested on the
+// CronJob, or nil if there's no trigger we haven't already acted on.
+func getTriggerTime(cronJob *batch.CronJob) (*time.Time, error) {
+	timeRaw := cronJob.Annotations[triggerAtAnnotation]
+	if len(timeRaw) == 0 {
+		return nil, nil
+	}
+
+	timeParsed, err := time.Parse(time.RFC3339, timeRaw)
+	if err != nil {
+		return nil, fmt.Errorf("Unparseable %s annotation %q: %v", triggerAtAnnotation, timeRaw, err)
+	}
+	if last := cronJob.Status.LastTriggerTime; last != nil && !timeParsed.After(last.Time) {
+		return nil, nil
+	}
+	return &timeParsed, nil
+}
+
+// cronJobTriggerKey indexes CronJobTriggers by the CronJob they trigger.
+const cronJobTriggerKey = ".spec.cronJobName"
+
+// triggerFired checks whether we've acted on the trigger already, by starting
+// its run or skipping it.
+func triggerFired(trigger *batch.CronJobTrigger) bool {
+	return trigger.Status.Phase == batch.CronJobTriggerStarted || trigger.Status.Phase == batch.CronJobTriggerSkipped
+}
+
+// pendingTriggers returns the CronJob's CronJobTriggers that haven't started
+// a run yet, oldest first.
+func (r *CronJobReconciler) pendingTriggers(ctx context.Context, cronJob *batch.CronJob) ([]batch.CronJobTrigger, error) {
+	var triggers batch.CronJobTriggerList
+	if err := r.List(ctx, &triggers, client.InNamespace(cronJob.Namespace), client.MatchingFields{cronJobTriggerKey: cronJob.Name}); err != nil {
+		return nil, err
+	}
+
+	var pending []batch.CronJobTrigger
+	for _, trigger := range triggers.Items {
+		if !triggerFired(&trigger) && trigger.DeletionTimestamp == nil {
+			pending = append(pending, trigger)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].CreationTimestamp.Equal(&pending[j].CreationTimestamp) {
+			return pending[i].CreationTimestamp.Before(&pending[j].CreationTimestamp)
+		}
+		return pending[i].Name < pending[j].Name
+	})
+	return pending, nil
+}
+
+// triggerInitiator is who triggered the run: the event trigger, for the
+// CronJobTriggers we create for events, or else whoever created the
+// CronJobTrigger, as our webhook recorded them.
+func triggerInitiator(cronJob *batch.CronJob, trigger *batch.CronJobTrigger) string {
+	if trigger.Spec.Event != nil && metav1.IsControlledBy(trigger, cronJob) {
+		return "event:" + trigger.Spec.Event.Trigger
+	}
+	return trigger.Annotations[triggeredByAnnotation]
+}
+
+// markTriggerStarted records in the trigger's status that its run has
+// started, so that it doesn't fire again.
+func (r *CronJobReconciler) markTriggerStarted(ctx context.Context, trigger *batch.CronJobTrigger, jobName string, now time.Time) error {
+	patch := client.MergeFrom(trigger.DeepCopy())
+	trigger.Status.Phase = batch.CronJobTriggerStarted
+	trigger.Status.JobName = jobName
+	trigger.Status.StartTime = &metav1.Time{Time: now}
+	return r.Status().Patch(ctx, trigger, patch)
+}
+
+// markTriggerSkipped records in the trigger's status that its run was
+// skipped, so that it doesn't fire again.
+func (r *CronJobReconciler) markTriggerSkipped(ctx context.Context, trigger *batch.CronJobTrigger, now time.Time) error {
+	patch := client.MergeFrom(trigger.DeepCopy())
+	trigger.Status.Phase = batch.CronJobTriggerSkipped
+	trigger.Status.StartTime = &metav1.Time{Time: now}
+	return r.Status().Patch(ctx, trigger, patch)
+}
+
+// pruneTriggers deletes the CronJob's CronJobTriggers that fired long enough
+// ago, returning when the next of them is due to go.
+func (r *CronJobReconciler) pruneTriggers(ctx context.Context, cronJob *batch.CronJob) (time.Time, error) {
+	var triggers batch.CronJobTriggerList
+	if err := r.List(ctx, &triggers, client.InNamespace(cronJob.Namespace), client.MatchingFields{cronJobTriggerKey: cronJob.Name}); err != nil {
+		return time.Time{}, err
+	}
+
+	var next time.Time
+	for i := range triggers.Items {
+		trigger := &triggers.Items[i]
+		if !triggerFired(trigger) || trigger.Status.StartTime == nil {
+			continue
+		}
+		retention := triggerRetention
+		if trigger.Spec.Event != nil && metav1.IsControlledBy(trigger, cronJob) {
+			retention = eventTriggerRetention
+		}
+		expiry := trigger.Status.StartTime.Add(retention)
+		if expiry.After(r.Now()) {
+			if next.IsZero() || expiry.Before(next) {
+				next = expiry
+			}
+			continue
+		}
+		if err := r.Delete(ctx, trigger); client.IgnoreNotFound(err) != nil {
+			return time.Time{}, err
+		}
+	}
+	return next, nil
+}
+
+// triggeredBy maps a CronJobTrigger to a request for the CronJob it
+// triggers.
+func (r *CronJobReconciler) triggeredBy(obj client.Object) []reconcile.Request {
+	trigger, ok := obj.(*batch.CronJobTrigger)
+	if !ok || triggerFired(trigger) {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: trigger.Namespace, Name: trigger.Spec.CronJobName}},
+	}
+}
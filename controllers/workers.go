@@ -0,0 +1,135 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// WorkerOptions tunes how a controller works through its queue.  The zero
+// value leaves controller-runtime's defaults alone: a single worker, and its
+// usual rate limits.
+type WorkerOptions struct {
+	// MaxConcurrentReconciles is how many objects may be reconciled at once.
+	MaxConcurrentReconciles int
+
+	// BaseDelay and MaxDelay bound the exponential backoff for retrying an
+	// object whose reconcile failed.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// QPS and Burst limit how fast objects are let out of the queue overall.
+	QPS   float64
+	Burst int
+
+	// PerObjectQPS and PerObjectBurst limit how often any one object may be
+	// reconciled, so that one that's changing all the time can't hog the
+	// workers.  Zero means no limit.
+	PerObjectQPS   float64
+	PerObjectBurst int
+
+	// ReconcileTimeout bounds how long a single reconcile may take, so that a
+	// stuck call to the API server can't tie up a worker for good.  Zero means
+	// no limit.
+	ReconcileTimeout time.Duration
+}
+
+// controllerOptions turns the WorkerOptions into controller options.
+func (o WorkerOptions) controllerOptions() controller.Options {
+	opts := controller.Options{MaxConcurrentReconciles: o.MaxConcurrentReconciles}
+	if o.BaseDelay > 0 || o.MaxDelay > 0 || o.QPS > 0 {
+		// the same shape as workqueue.DefaultControllerRateLimiter, with our
+		// own numbers
+		opts.RateLimiter = workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(o.BaseDelay, o.MaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+		)
+	}
+	return opts
+}
+
+/*
+The queue's own rate limits only apply to retries: an object that changes over
+and over, say because one of its jobs is flapping or someone's editing it in a
+loop, gets added straight back each time.  So we keep a token bucket per object
+as well, and put off reconciling any that have used theirs up.  Putting one off
+costs next to nothing, and since the queue never holds an object twice, the
+events it keeps getting just land on the same entry.
+*/
+
+// objectRateLimiter limits how often each object may be reconciled.
+type objectRateLimiter struct {
+	qps   rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[types.NamespacedName]*rate.Limiter
+}
+
+// newObjectRateLimiter returns a limiter for the WorkerOptions' per-object
+// limits, or nil if there aren't any.
+func (o WorkerOptions) newObjectRateLimiter() *objectRateLimiter {
+	if o.PerObjectQPS <= 0 {
+		return nil
+	}
+	burst := o.PerObjectBurst
+	if burst < 1 {
+		burst = 1
+	}
+	return &objectRateLimiter{
+		qps:      rate.Limit(o.PerObjectQPS),
+		burst:    burst,
+		limiters: make(map[types.NamespacedName]*rate.Limiter),
+	}
+}
+
+// delay returns how long to put off reconciling the object for, or zero if
+// it can go ahead now.
+func (l *objectRateLimiter) delay(obj types.NamespacedName) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	limiter, ok := l.limiters[obj]
+	if !ok {
+		limiter = rate.NewLimiter(l.qps, l.burst)
+		l.limiters[obj] = limiter
+	}
+	l.mu.Unlock()
+
+	if limiter.Allow() {
+		return 0
+	}
+	// roughly when the next token comes in
+	return time.Duration(float64(time.Second) / float64(l.qps))
+}
+
+// forget drops the bucket of an object that's gone.
+func (l *objectRateLimiter) forget(obj types.NamespacedName) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.limiters, obj)
+}
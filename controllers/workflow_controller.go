@@ -0,0 +1,360 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+	"kubebuilder-tutorial/pkg/clock"
+	"kubebuilder-tutorial/pkg/schedule"
+)
+
+/*
+A Workflow is scheduled much like a CronJob, but each run is a DAG of jobs
+rather than a single one.  Runs don't overlap: a run that comes due while the
+previous one is still going waits for it to finish, subject to the starting
+deadline.  Only the current run is tracked, in status, and the jobs of older
+runs are cleaned up when a new one starts.
+*/
+
+var (
+	// workflowLabel marks the jobs we create for a Workflow with its name.
+	workflowLabel = "batch.tutorial.kubebuilder.io/workflow"
+
+	// workflowNodeLabel marks the jobs we create for a Workflow with the
+	// node they run.
+	workflowNodeLabel = "batch.tutorial.kubebuilder.io/workflow-node"
+)
+
+// WorkflowReconciler reconciles a Workflow object
+type WorkflowReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Clock
+
+	// Shard is the share of namespaces whose Workflows we look after.
+	Shard Shard
+
+	// Namespaces narrows down the namespaces whose Workflows we look after.
+	Namespaces NamespaceFilter
+}
+
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=workflows,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch.tutorial.kubebuilder.io,resources=workflows/status,verbs=get;update;patch
+
+func (r *WorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("workflow", req.NamespacedName)
+
+	var workflow batch.Workflow
+	if err := r.Get(ctx, req.NamespacedName, &workflow); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	status := workflow.Status.DeepCopy()
+	status.ObservedGeneration = workflow.Generation
+
+	var jobs kbatch.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(req.Namespace), client.MatchingLabels{workflowLabel: workflow.Name}); err != nil {
+		log.Error(err, "unable to list Workflow jobs")
+		return ctrl.Result{}, err
+	}
+
+	sched, err := parseScheduleIn(workflow.Spec.Schedule, batch.MinuteGranularity, workflow.Spec.TimeZone, schedule.RunEarlier)
+	if err != nil {
+		// the webhook keeps these out, and nothing will run until it's fixed
+		log.Error(err, "unable to parse schedule")
+		return ctrl.Result{}, nil
+	}
+	now := r.Now()
+	var result ctrl.Result
+	if next := sched.Next(now); !next.IsZero() {
+		result.RequeueAfter = requeueDelay(req.NamespacedName, now, next, maxRequeueJitter)
+	}
+
+	/*
+		First, we'll see whether a new run is due, and start it if the last one has
+		finished.  Starting a run just means resetting the node statuses: the jobs
+		get created below, like any others whose dependencies are met.
+	*/
+	suspended := workflow.Spec.Suspend != nil && *workflow.Spec.Suspend
+	if due := latestWorkflowRun(&workflow, sched, now); !due.IsZero() && !suspended && status.Phase != batch.WorkflowRunning {
+		tooLate := workflow.Spec.StartingDeadlineSeconds != nil &&
+			due.Add(time.Duration(*workflow.Spec.StartingDeadlineSeconds)*time.Second).Before(now)
+		if tooLate {
+			log.V(1).Info("missed starting deadline for run", "run", due)
+		} else {
+			log.V(1).Info("starting run", "run", due)
+			if err := r.deleteOldRuns(ctx, jobs.Items, due); err != nil {
+				log.Error(err, "unable to clean up jobs from earlier runs")
+				return ctrl.Result{}, err
+			}
+			jobs.Items = nil
+			status.LastScheduleTime = &metav1.Time{Time: due}
+			status.Phase = batch.WorkflowRunning
+			status.Nodes = nil
+			for _, node := range workflow.Spec.Nodes {
+				status.Nodes = append(status.Nodes, batch.WorkflowNodeStatus{Name: node.Name, Phase: batch.WorkflowPending})
+			}
+		}
+	}
+
+	/*
+		Then we'll move the current run along: each node is settled by its latest job,
+		retried if that job failed and it has retries left, or started once everything
+		it depends on has succeeded.
+	*/
+	if status.LastScheduleTime != nil && status.Phase == batch.WorkflowRunning {
+		if err := r.advanceRun(ctx, &workflow, status, jobs.Items); err != nil {
+			log.Error(err, "unable to advance run")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(status, &workflow.Status) {
+		patch := client.MergeFrom(workflow.DeepCopy())
+		workflow.Status = *status
+		if err := r.Status().Patch(ctx, &workflow, patch); err != nil {
+			log.Error(err, "unable to update Workflow status")
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// latestWorkflowRun returns the most recent time the Workflow was scheduled
+// to run since its current run started (or since it was created), or the
+// zero time if there's been none.  Like getNextSchedule, it only walks so many
+// missed runs before skipping ahead to the most recent one.
+func latestWorkflowRun(workflow *batch.Workflow, sched schedule.Schedule, now time.Time) time.Time {
+	earliest := workflow.CreationTimestamp.Time
+	if last := workflow.Status.LastScheduleTime; last != nil {
+		earliest = last.Time
+	}
+	var latest time.Time
+	starts := 0
+	for t := sched.Next(earliest); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		latest = t
+		starts++
+		if starts > defaultMaxMissedRuns {
+			return mostRecentScheduleTime(sched, t, now)
+		}
+	}
+	return latest
+}
+
+// advanceRun brings the node statuses of the current run up to date with
+// their jobs, creating jobs for the nodes that are ready for one, and works
+// out the phase of the run as a whole.
+func (r *WorkflowReconciler) advanceRun(ctx context.Context, workflow *batch.Workflow, status *batch.WorkflowStatus, jobs []kbatch.Job) error {
+	scheduledTime := status.LastScheduleTime.Time
+	latestJobs := make(map[string]*kbatch.Job)
+	for i := range jobs {
+		job := &jobs[i]
+		jobTime, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation])
+		if err != nil || !jobTime.Equal(scheduledTime) || !metav1.IsControlledBy(job, workflow) {
+			continue
+		}
+		node := job.Labels[workflowNodeLabel]
+		if latest := latestJobs[node]; latest == nil || getRetryIndex(job) > getRetryIndex(latest) {
+			latestJobs[node] = job
+		}
+	}
+
+	phases := make(map[string]batch.WorkflowPhase)
+	for _, nodeStatus := range status.Nodes {
+		phases[nodeStatus.Name] = nodeStatus.Phase
+	}
+
+	// every pass settles at least the nodes whose dependencies are settled,
+	// so going round once per node is enough for the whole DAG
+	for range workflow.Spec.Nodes {
+		for i := range workflow.Spec.Nodes {
+			node := &workflow.Spec.Nodes[i]
+			nodeStatus := workflowNodeStatus(status, node.Name)
+			switch nodeStatus.Phase {
+			case batch.WorkflowSucceeded, batch.WorkflowFailed, batch.WorkflowSkipped:
+				continue
+			}
+
+			if job := latestJobs[node.Name]; job != nil {
+				nodeStatus.Job = job.Name
+				nodeStatus.Attempts = int32(getRetryIndex(job)) + 1
+				switch {
+				case jobCompleted(job):
+					nodeStatus.Phase = batch.WorkflowSucceeded
+				case jobFailure(job) != nil:
+					retries := int32(0)
+					if node.Retries != nil {
+						retries = *node.Retries
+					}
+					if nodeStatus.Attempts > retries {
+						nodeStatus.Phase = batch.WorkflowFailed
+						r.Recorder.Eventf(workflow, corev1.EventTypeWarning, "NodeFailed", "Node %s failed after %d attempts", node.Name, nodeStatus.Attempts)
+						break
+					}
+					if err := r.createNodeJob(ctx, workflow, node, scheduledTime, int(nodeStatus.Attempts)); err != nil {
+						return err
+					}
+					nodeStatus.Attempts++
+					nodeStatus.Phase = batch.WorkflowRunning
+				default:
+					nodeStatus.Phase = batch.WorkflowRunning
+				}
+				phases[node.Name] = nodeStatus.Phase
+				continue
+			}
+
+			ready := true
+			for _, dep := range node.DependsOn {
+				switch phases[dep] {
+				case batch.WorkflowSucceeded:
+				case batch.WorkflowFailed, batch.WorkflowSkipped:
+					nodeStatus.Phase = batch.WorkflowSkipped
+					ready = false
+				default:
+					ready = false
+				}
+			}
+			if ready {
+				if err := r.createNodeJob(ctx, workflow, node, scheduledTime, 0); err != nil {
+					return err
+				}
+				nodeStatus.Attempts = 1
+				nodeStatus.Phase = batch.WorkflowRunning
+			}
+			phases[node.Name] = nodeStatus.Phase
+		}
+	}
+
+	status.Phase = batch.WorkflowSucceeded
+	for _, nodeStatus := range status.Nodes {
+		switch nodeStatus.Phase {
+		case batch.WorkflowPending, batch.WorkflowRunning:
+			status.Phase = batch.WorkflowRunning
+			return nil
+		case batch.WorkflowFailed, batch.WorkflowSkipped:
+			status.Phase = batch.WorkflowFailed
+		}
+	}
+	r.Recorder.Eventf(workflow, corev1.EventTypeNormal, "Run"+string(status.Phase), "Run scheduled at %s %s", scheduledTime.Format(time.RFC3339), status.Phase)
+	return nil
+}
+
+// workflowNodeStatus returns the status of the named node in the current
+// run, adding one if it's missing, as it is for nodes added mid-run.
+func workflowNodeStatus(status *batch.WorkflowStatus, name string) *batch.WorkflowNodeStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Name == name {
+			return &status.Nodes[i]
+		}
+	}
+	status.Nodes = append(status.Nodes, batch.WorkflowNodeStatus{Name: name, Phase: batch.WorkflowPending})
+	return &status.Nodes[len(status.Nodes)-1]
+}
+
+// createNodeJob creates the job for the given attempt at a node of the run
+// scheduled at the given time.
+func (r *WorkflowReconciler) createNodeJob(ctx context.Context, workflow *batch.Workflow, node *batch.WorkflowNode, scheduledTime time.Time, attempt int) error {
+	template, err := resolveJobTemplate(ctx, r, workflow.Namespace, node.JobTemplateRef, node.JobTemplate)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d-%s", workflow.Name, scheduledTime.Unix(), node.Name)
+	if attempt > 0 {
+		name = fmt.Sprintf("%s-r%d", name, attempt)
+	}
+	job := &kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   workflow.Namespace,
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	for k, v := range template.Labels {
+		job.Labels[k] = v
+	}
+	for k, v := range template.Annotations {
+		job.Annotations[k] = v
+	}
+	job.Labels[workflowLabel] = workflow.Name
+	job.Labels[workflowNodeLabel] = node.Name
+	job.Annotations[scheduledTimeAnnotation] = scheduledTime.UTC().Format(time.RFC3339)
+	if attempt > 0 {
+		job.Annotations[retryIndexAnnotation] = strconv.Itoa(attempt)
+	}
+	if err := ctrl.SetControllerReference(workflow, job, r.Scheme); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, job); apierrors.IsAlreadyExists(err) {
+		// we've just not seen it yet
+		return nil
+	} else if err != nil {
+		r.Recorder.Eventf(workflow, corev1.EventTypeWarning, "FailedCreate", "Error creating job for node %s: %v", node.Name, err)
+		return err
+	}
+	r.Recorder.Eventf(workflow, corev1.EventTypeNormal, "SuccessfulCreate", "Created job %s for node %s", job.Name, node.Name)
+	return nil
+}
+
+// deleteOldRuns deletes the jobs of every run but the one scheduled at the
+// given time.
+func (r *WorkflowReconciler) deleteOldRuns(ctx context.Context, jobs []kbatch.Job, keep time.Time) error {
+	for i := range jobs {
+		job := &jobs[i]
+		if jobTime, err := time.Parse(time.RFC3339, job.Annotations[scheduledTimeAnnotation]); err == nil && jobTime.Equal(keep) {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *WorkflowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// set up a real clock, since we're not in a test
+	if r.Clock == nil {
+		r.Clock = clock.RealClock{}
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batch.Workflow{}).
+		Owns(&kbatch.Job{}).
+		WithEventFilter(r.Shard.predicate()).
+		WithEventFilter(r.Namespaces.predicate()).
+		Complete(r)
+}
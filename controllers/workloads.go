@@ -0,0 +1,539 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	batch "kubebuilder-tutorial/api/v1"
+)
+
+/*
+A run's main step doesn't have to be a batch Job: CronJobs can have it create
+a bare Pod, an Argo Workflow or a Tekton PipelineRun instead, each made by a
+workload backend.  Rather than teach the rest of the controller about every
+kind of workload, each backend reads its workloads back as the jobs they
+stand in for -- views, with the workload's kind and metadata, and Complete or
+Failed conditions once it's finished, just like a job's.  From there on
+they're sorted into active, successful and failed runs, retried, counted and
+cleaned up along with our real jobs.  Only when something has to be written
+back do we go to the workload itself.
+
+Hooks always run as Jobs, since they're pod templates of their own.
+*/
+
+// workloadBackend creates one kind of workload in place of a run's job, and
+// reads them back as jobs.
+type workloadBackend interface {
+	// name is the backend's name in CronJobs' workloadRefs.
+	name() batch.WorkloadBackend
+	// gate is the feature gate the backend needs turned on.
+	gate() string
+	// kind is the kind of workload the backend creates.
+	kind() schema.GroupVersionKind
+	// newObject and newList make empty workloads, and lists of them, to
+	// read into.
+	newObject() client.Object
+	newList() client.ObjectList
+	// build makes the workload to create in place of the given job.
+	build(cronJob *batch.CronJob, job *kbatch.Job) (client.Object, error)
+	// view reads one of the backend's workloads as the job it stands in
+	// for.
+	view(obj client.Object) (kbatch.Job, error)
+	// expire has a running workload fail for going past its run deadline.
+	expire(deadlineSeconds int64) client.Patch
+	// podsOf selects a workload's pods, so that we can archive their logs.
+	podsOf(job *kbatch.Job) metav1.ListOptions
+}
+
+// workloadBackends lists every backend besides plain Jobs.
+var workloadBackends = []workloadBackend{
+	podBackend{},
+	argoWorkflowBackend{},
+	tektonPipelineRunBackend{},
+}
+
+// enabledBackends lists the backends whose feature gates are on.
+func (r *CronJobReconciler) enabledBackends() []workloadBackend {
+	var enabled []workloadBackend
+	for _, backend := range workloadBackends {
+		if r.Features.Enabled(backend.gate()) {
+			enabled = append(enabled, backend)
+		}
+	}
+	return enabled
+}
+
+// backendFor returns the backend that creates the workload for the given job,
+// or nil if the job is to be created as it is.
+func (r *CronJobReconciler) backendFor(cronJob *batch.CronJob, job *kbatch.Job) (workloadBackend, error) {
+	ref := cronJob.Spec.WorkloadRef
+	if ref == nil || ref.Backend == "" || ref.Backend == batch.JobBackend || job.Annotations[hookAnnotation] != mainRun {
+		return nil, nil
+	}
+	for _, backend := range workloadBackends {
+		if backend.name() != ref.Backend {
+			continue
+		}
+		if !r.Features.Enabled(backend.gate()) {
+			return nil, fmt.Errorf("the %s workload backend needs the %s feature gate turned on", ref.Backend, backend.gate())
+		}
+		return backend, nil
+	}
+	return nil, fmt.Errorf("unknown workload backend %q", ref.Backend)
+}
+
+// isWorkload checks whether the job is a view of some other workload, rather
+// than a real job.
+func isWorkload(job *kbatch.Job) bool {
+	return job.Kind != "" && job.Kind != "Job"
+}
+
+// backendOf returns the backend whose workload the job is a view of, or nil
+// for a real job.
+func backendOf(job *kbatch.Job) workloadBackend {
+	if !isWorkload(job) {
+		return nil
+	}
+	for _, backend := range workloadBackends {
+		if backend.kind() == job.GroupVersionKind() {
+			return backend
+		}
+	}
+	return nil
+}
+
+// workloadOf returns the object to write to on the job's behalf: the job
+// itself, or the workload it's a view of.
+func workloadOf(job *kbatch.Job) client.Object {
+	if !isWorkload(job) {
+		return job
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(job.GroupVersionKind())
+	obj.SetNamespace(job.Namespace)
+	obj.SetName(job.Name)
+	return obj
+}
+
+// listWorkloads lists the workloads owned by the named CronJob, for every
+// enabled backend, as views.
+func (r *CronJobReconciler) listWorkloads(ctx context.Context, namespace, name string) ([]kbatch.Job, error) {
+	var views []kbatch.Job
+	for _, backend := range r.enabledBackends() {
+		list := backend.newList()
+		if err := r.workloads.List(ctx, list, client.InNamespace(namespace), client.MatchingFields{jobOwnerKey: name}); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			view, err := backend.view(item.(client.Object))
+			if err != nil {
+				return nil, err
+			}
+			views = append(views, view)
+		}
+	}
+	return views, nil
+}
+
+// createWorkload creates the backend's workload in place of the job, and
+// fills the job in as its view.  Like createRunJob does for jobs, it takes a
+// workload of ours that's already there under the same name as the one it
+// was going to create, reporting it as already existing, and falls back to a
+// generated name if something else has taken it.
+func (r *CronJobReconciler) createWorkload(ctx context.Context, cronJob *batch.CronJob, backend workloadBackend, job *kbatch.Job) error {
+	obj, err := backend.build(cronJob, job)
+	if err != nil {
+		return err
+	}
+	writer, err := r.jobWriter(job)
+	if err != nil {
+		return err
+	}
+	resource := schema.GroupResource{Group: backend.kind().Group, Resource: backend.kind().Kind}
+
+	err = writer.Create(ctx, obj, client.FieldOwner(fieldManager))
+	if apierrors.IsAlreadyExists(err) {
+		existing := backend.newObject()
+		err = r.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing)
+		if apierrors.IsNotFound(err) {
+			// it's on its way out: we'll make another go of it next time round
+			return apierrors.NewAlreadyExists(resource, obj.GetName())
+		}
+		if err != nil {
+			return err
+		}
+		if owner := metav1.GetControllerOf(existing); owner != nil && owner.UID == cronJob.UID {
+			if *job, err = backend.view(existing); err != nil {
+				return err
+			}
+			return apierrors.NewAlreadyExists(resource, obj.GetName())
+		}
+
+		r.Recorder.Eventf(cronJob, corev1.EventTypeWarning, "NameTaken", "%s name %s is taken by something else, generating one instead", backend.kind().Kind, obj.GetName())
+		obj.SetGenerateName(obj.GetName() + "-")
+		obj.SetName("")
+		err = writer.Create(ctx, obj, client.FieldOwner(fieldManager))
+	}
+	if err != nil {
+		return err
+	}
+	*job, err = backend.view(obj)
+	return err
+}
+
+// newView starts the view of a workload: a job with the workload's kind and
+// metadata, and no status yet.
+func newView(gvk schema.GroupVersionKind, obj metav1.Object) kbatch.Job {
+	job := kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         obj.GetNamespace(),
+			Name:              obj.GetName(),
+			UID:               obj.GetUID(),
+			ResourceVersion:   obj.GetResourceVersion(),
+			CreationTimestamp: obj.GetCreationTimestamp(),
+			DeletionTimestamp: obj.GetDeletionTimestamp(),
+			Labels:            obj.GetLabels(),
+			Annotations:       obj.GetAnnotations(),
+			OwnerReferences:   obj.GetOwnerReferences(),
+		},
+	}
+	job.SetGroupVersionKind(gvk)
+	return job
+}
+
+// finishView marks the view as having finished the way a job would have:
+// with a Complete or Failed condition, and a completion time if it
+// succeeded.
+func finishView(job *kbatch.Job, conditionType kbatch.JobConditionType, reason, message string, at metav1.Time) {
+	job.Status.Conditions = append(job.Status.Conditions, kbatch.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      at,
+		LastTransitionTime: at,
+		Reason:             reason,
+		Message:            message,
+	})
+	if conditionType == kbatch.JobComplete {
+		job.Status.CompletionTime = &at
+	}
+}
+
+// newUnstructuredWorkload starts a workload of the given kind, with the
+// job's metadata.
+func newUnstructuredWorkload(gvk schema.GroupVersionKind, job *kbatch.Job) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace(job.Namespace)
+	obj.SetName(job.Name)
+	obj.SetLabels(job.Labels)
+	obj.SetAnnotations(job.Annotations)
+	obj.SetOwnerReferences(job.OwnerReferences)
+	return obj
+}
+
+// workloadParameters turns a WorkloadRef's parameters into the list of
+// names and values that Argo and Tekton both take, in order of name.
+func workloadParameters(parameters map[string]string) []interface{} {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]interface{}, len(names))
+	for i, name := range names {
+		params[i] = map[string]interface{}{"name": name, "value": parameters[name]}
+	}
+	return params
+}
+
+// nestedTime reads an RFC 3339 timestamp out of an unstructured workload,
+// returning nil if it isn't there.
+func nestedTime(obj *unstructured.Unstructured, fields ...string) (*metav1.Time, error) {
+	raw, found, err := unstructured.NestedString(obj.Object, fields...)
+	if err != nil || !found || raw == "" {
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.Time{Time: t}, nil
+}
+
+// finishedAt is when a finished workload finished, falling back to when it
+// was created, so that retries always have something to back off from.
+func finishedAt(finished *metav1.Time, obj metav1.Object) metav1.Time {
+	if finished != nil {
+		return *finished
+	}
+	return obj.GetCreationTimestamp()
+}
+
+/*
+Bare Pods run the job template's pod template, once: the rest of the job's
+spec, like its parallelism and backoff limit, doesn't apply.  A pod that goes
+past its active deadline fails with `DeadlineExceeded`, like a job does.
+*/
+
+type podBackend struct{}
+
+func (podBackend) name() batch.WorkloadBackend { return batch.PodBackend }
+func (podBackend) gate() string                { return PodWorkloads }
+func (podBackend) kind() schema.GroupVersionKind {
+	return corev1.SchemeGroupVersion.WithKind("Pod")
+}
+func (podBackend) newObject() client.Object   { return &corev1.Pod{} }
+func (podBackend) newList() client.ObjectList { return &corev1.PodList{} }
+
+func (podBackend) build(_ *batch.CronJob, job *kbatch.Job) (client.Object, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       job.Namespace,
+			Name:            job.Name,
+			Labels:          make(map[string]string),
+			Annotations:     make(map[string]string),
+			OwnerReferences: job.OwnerReferences,
+		},
+		Spec: *job.Spec.Template.Spec.DeepCopy(),
+	}
+	for _, from := range []map[string]string{job.Labels, job.Spec.Template.Labels} {
+		for k, v := range from {
+			pod.Labels[k] = v
+		}
+	}
+	for _, from := range []map[string]string{job.Spec.Template.Annotations, job.Annotations} {
+		for k, v := range from {
+			pod.Annotations[k] = v
+		}
+	}
+	if pod.Spec.ActiveDeadlineSeconds == nil {
+		pod.Spec.ActiveDeadlineSeconds = job.Spec.ActiveDeadlineSeconds
+	}
+	return pod, nil
+}
+
+func (b podBackend) view(obj client.Object) (kbatch.Job, error) {
+	pod := obj.(*corev1.Pod)
+	job := newView(b.kind(), pod)
+	job.Spec.ActiveDeadlineSeconds = pod.Spec.ActiveDeadlineSeconds
+	job.Status.StartTime = pod.Status.StartTime
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		finishView(&job, kbatch.JobComplete, "", "", finishedAt(podFinishTime(pod), pod))
+	case corev1.PodFailed:
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = "PodFailed"
+		}
+		finishView(&job, kbatch.JobFailed, reason, pod.Status.Message, finishedAt(podFinishTime(pod), pod))
+	}
+	return job, nil
+}
+
+// podFinishTime is when the pod's last container finished, if any have.
+func podFinishTime(pod *corev1.Pod) *metav1.Time {
+	var finished *metav1.Time
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if terminated := status.State.Terminated; terminated != nil && (finished == nil || finished.Before(&terminated.FinishedAt)) {
+				finished = terminated.FinishedAt.DeepCopy()
+			}
+		}
+	}
+	return finished
+}
+
+func (podBackend) expire(deadlineSeconds int64) client.Patch {
+	return client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"activeDeadlineSeconds":%d}}`, deadlineSeconds)))
+}
+
+func (podBackend) podsOf(job *kbatch.Job) metav1.ListOptions {
+	return metav1.ListOptions{FieldSelector: "metadata.name=" + job.Name}
+}
+
+/*
+Argo Workflows run a WorkflowTemplate, with the CronJob's parameters as
+arguments.  Their phase says whether they've finished; Argo fails them itself
+once they go past their active deadline.
+*/
+
+type argoWorkflowBackend struct{}
+
+func (argoWorkflowBackend) name() batch.WorkloadBackend { return batch.ArgoWorkflowBackend }
+func (argoWorkflowBackend) gate() string                { return ArgoWorkflows }
+func (argoWorkflowBackend) kind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}
+}
+
+func (b argoWorkflowBackend) newObject() client.Object {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(b.kind())
+	return obj
+}
+
+func (b argoWorkflowBackend) newList() client.ObjectList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(b.kind().GroupVersion().WithKind("WorkflowList"))
+	return list
+}
+
+func (b argoWorkflowBackend) build(cronJob *batch.CronJob, job *kbatch.Job) (client.Object, error) {
+	workflow := newUnstructuredWorkload(b.kind(), job)
+	spec := map[string]interface{}{
+		"workflowTemplateRef": map[string]interface{}{"name": cronJob.Spec.WorkloadRef.Name},
+	}
+	if params := workloadParameters(cronJob.Spec.WorkloadRef.Parameters); len(params) > 0 {
+		spec["arguments"] = map[string]interface{}{"parameters": params}
+	}
+	if ads := job.Spec.ActiveDeadlineSeconds; ads != nil {
+		spec["activeDeadlineSeconds"] = *ads
+	}
+	workflow.Object["spec"] = spec
+	return workflow, nil
+}
+
+func (b argoWorkflowBackend) view(obj client.Object) (kbatch.Job, error) {
+	workflow := obj.(*unstructured.Unstructured)
+	job := newView(b.kind(), workflow)
+	if ads, found, err := unstructured.NestedInt64(workflow.Object, "spec", "activeDeadlineSeconds"); err == nil && found {
+		job.Spec.ActiveDeadlineSeconds = &ads
+	}
+	var err error
+	if job.Status.StartTime, err = nestedTime(workflow, "status", "startedAt"); err != nil {
+		return job, err
+	}
+	finished, err := nestedTime(workflow, "status", "finishedAt")
+	if err != nil {
+		return job, err
+	}
+
+	phase, _, _ := unstructured.NestedString(workflow.Object, "status", "phase")
+	message, _, _ := unstructured.NestedString(workflow.Object, "status", "message")
+	switch phase {
+	case "Succeeded":
+		finishView(&job, kbatch.JobComplete, "", "", finishedAt(finished, workflow))
+	case "Failed", "Error":
+		finishView(&job, kbatch.JobFailed, "Workflow"+phase, message, finishedAt(finished, workflow))
+	}
+	return job, nil
+}
+
+func (argoWorkflowBackend) expire(deadlineSeconds int64) client.Patch {
+	return client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"activeDeadlineSeconds":%d}}`, deadlineSeconds)))
+}
+
+func (argoWorkflowBackend) podsOf(job *kbatch.Job) metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: "workflows.argoproj.io/workflow=" + job.Name}
+}
+
+/*
+Tekton PipelineRuns run a Pipeline, with the CronJob's parameters as the
+run's params.  Their Succeeded condition says whether they've finished.  They
+have no active deadline we can lower once they've started, so those that go
+past their run deadline are cancelled instead, which fails them.
+*/
+
+type tektonPipelineRunBackend struct{}
+
+func (tektonPipelineRunBackend) name() batch.WorkloadBackend {
+	return batch.TektonPipelineRunBackend
+}
+func (tektonPipelineRunBackend) gate() string { return TektonPipelineRuns }
+func (tektonPipelineRunBackend) kind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: "tekton.dev", Version: "v1beta1", Kind: "PipelineRun"}
+}
+
+func (b tektonPipelineRunBackend) newObject() client.Object {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(b.kind())
+	return obj
+}
+
+func (b tektonPipelineRunBackend) newList() client.ObjectList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(b.kind().GroupVersion().WithKind("PipelineRunList"))
+	return list
+}
+
+func (b tektonPipelineRunBackend) build(cronJob *batch.CronJob, job *kbatch.Job) (client.Object, error) {
+	pipelineRun := newUnstructuredWorkload(b.kind(), job)
+	spec := map[string]interface{}{
+		"pipelineRef": map[string]interface{}{"name": cronJob.Spec.WorkloadRef.Name},
+	}
+	if params := workloadParameters(cronJob.Spec.WorkloadRef.Parameters); len(params) > 0 {
+		spec["params"] = params
+	}
+	pipelineRun.Object["spec"] = spec
+	return pipelineRun, nil
+}
+
+func (b tektonPipelineRunBackend) view(obj client.Object) (kbatch.Job, error) {
+	pipelineRun := obj.(*unstructured.Unstructured)
+	job := newView(b.kind(), pipelineRun)
+	var err error
+	if job.Status.StartTime, err = nestedTime(pipelineRun, "status", "startTime"); err != nil {
+		return job, err
+	}
+	completed, err := nestedTime(pipelineRun, "status", "completionTime")
+	if err != nil {
+		return job, err
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(pipelineRun.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Succeeded" {
+			continue
+		}
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		switch condition["status"] {
+		case string(corev1.ConditionTrue):
+			finishView(&job, kbatch.JobComplete, "", "", finishedAt(completed, pipelineRun))
+		case string(corev1.ConditionFalse):
+			finishView(&job, kbatch.JobFailed, reason, message, finishedAt(completed, pipelineRun))
+		}
+	}
+	return job, nil
+}
+
+func (tektonPipelineRunBackend) expire(int64) client.Patch {
+	return client.RawPatch(types.MergePatchType, []byte(`{"spec":{"status":"Cancelled"}}`))
+}
+
+func (tektonPipelineRunBackend) podsOf(job *kbatch.Job) metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: "tekton.dev/pipelineRun=" + job.Name}
+}
@@ -1,6 +1,6 @@
 module kubebuilder-tutorial
 
-go 1.13
+go 1.18
 
 require (
 	cloud.google.com/go v0.51.0 // indirect
@@ -8,8 +8,14 @@ require (
 	github.com/go-logr/logr v0.3.0
 	github.com/onsi/ginkgo v1.14.2
 	github.com/onsi/gomega v1.10.4
+	github.com/prometheus/client_golang v1.7.1
 	github.com/robfig/cron v1.2.0
-	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	go.uber.org/zap v1.15.0
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	k8s.io/api v0.19.0
 	k8s.io/apimachinery v0.19.0
 	k8s.io/client-go v11.0.0+incompatible
@@ -0,0 +1,200 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command namespaced-rbac narrows the manager's generated ClusterRole down to
+// the namespaces it's told to watch, for clusters where it mustn't be given
+// the run of every namespace.
+//
+// The rules for cluster-scoped resources stay in a ClusterRole; everything
+// else goes into a Role, and a RoleBinding, per namespace:
+//
+//	go run ./hack/namespaced-rbac -namespaces=team-a,team-b < config/rbac/role.yaml
+//
+// Namespaces left alone with --exclude-namespaces can't be carved out of a
+// ClusterRole, so only --watch-namespaces narrows the RBAC down.  The groups
+// impersonated along with service accounts are narrowed down to those of the
+// watched namespaces.
+//
+// With -roles-only, the cluster-scoped rules are dropped altogether, for a
+// manager run with --namespaced, which never needs them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterScoped lists the resources, by group, that aren't namespaced.
+var clusterScoped = map[string][]string{
+	"": {"groups", "namespaces"},
+	"batch.tutorial.kubebuilder.io": {
+		"clustercronjobs", "clustercronjobs/status",
+		"maintenancewindows",
+	},
+}
+
+func main() {
+	var (
+		namespaces     string
+		serviceAccount string
+		saNamespace    string
+		rolesOnly      bool
+	)
+	flag.StringVar(&namespaces, "namespaces", "", "A comma-separated list of the namespaces the manager watches.")
+	flag.StringVar(&serviceAccount, "service-account", "default", "The manager's service account.")
+	flag.StringVar(&saNamespace, "service-account-namespace", "system", "The namespace of the manager's service account.")
+	flag.BoolVar(&rolesOnly, "roles-only", false, "Leave out the ClusterRole, for a manager run with --namespaced.")
+	flag.Parse()
+
+	if err := run(os.Stdin, namespaces, rolesOnly, rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      serviceAccount,
+		Namespace: saNamespace,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(in *os.File, namespaces string, rolesOnly bool, subject rbacv1.Subject) error {
+	var watched []string
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			watched = append(watched, ns)
+		}
+	}
+	if len(watched) == 0 {
+		return fmt.Errorf("no namespaces given: the ClusterRole already covers all of them")
+	}
+
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	var role rbacv1.ClusterRole
+	if err := yaml.Unmarshal(data, &role); err != nil {
+		return fmt.Errorf("unable to parse the ClusterRole: %w", err)
+	}
+
+	clusterRules, namespacedRules := splitRules(role.Rules)
+	narrowGroups(clusterRules, watched)
+
+	var objects []interface{}
+	if !rolesOnly {
+		objects = append(objects, &rbacv1.ClusterRole{
+			TypeMeta:   role.TypeMeta,
+			ObjectMeta: objectMeta(role.Name, ""),
+			Rules:      clusterRules,
+		})
+	}
+	for _, ns := range watched {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   typeMeta("Role"),
+				ObjectMeta: objectMeta(role.Name, ns),
+				Rules:      namespacedRules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   typeMeta("RoleBinding"),
+				ObjectMeta: objectMeta(role.Name+"binding", ns),
+				RoleRef: rbacv1.RoleRef{
+					APIGroup: rbacv1.GroupName,
+					Kind:     "Role",
+					Name:     role.Name,
+				},
+				Subjects: []rbacv1.Subject{subject},
+			},
+		)
+	}
+
+	for i, obj := range objects {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(out))
+	}
+	return nil
+}
+
+// splitRules splits the rules into those for cluster-scoped resources and
+// those for namespaced ones.  A rule covering both kinds is split in two.
+func splitRules(rules []rbacv1.PolicyRule) (cluster, namespaced []rbacv1.PolicyRule) {
+	for _, rule := range rules {
+		var clusterResources, namespacedResources []string
+		for _, resource := range rule.Resources {
+			if isClusterScoped(rule.APIGroups, resource) {
+				clusterResources = append(clusterResources, resource)
+			} else {
+				namespacedResources = append(namespacedResources, resource)
+			}
+		}
+		if len(clusterResources) > 0 {
+			r := rule
+			r.Resources = clusterResources
+			cluster = append(cluster, r)
+		}
+		if len(namespacedResources) > 0 {
+			r := rule
+			r.Resources = namespacedResources
+			namespaced = append(namespaced, r)
+		}
+	}
+	return cluster, namespaced
+}
+
+// narrowGroups limits the rules for impersonating groups to the groups of the
+// service accounts in the namespaces.
+func narrowGroups(rules []rbacv1.PolicyRule, namespaces []string) {
+	groups := []string{"system:serviceaccounts", "system:authenticated"}
+	for _, ns := range namespaces {
+		groups = append(groups, "system:serviceaccounts:"+ns)
+	}
+	for i, rule := range rules {
+		if len(rule.Resources) == 1 && rule.Resources[0] == "groups" && len(rule.ResourceNames) == 0 {
+			rules[i].ResourceNames = groups
+		}
+	}
+}
+
+func isClusterScoped(groups []string, resource string) bool {
+	for _, group := range groups {
+		for _, r := range clusterScoped[group] {
+			if r == resource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeMeta(kind string) metav1.TypeMeta {
+	return metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: kind}
+}
+
+func objectMeta(name, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: namespace}
+}
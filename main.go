@@ -17,23 +17,52 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
+	componentconfig "k8s.io/component-base/config/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	batchv1 "kubebuilder-tutorial/api/v1"
+	configv1alpha1 "kubebuilder-tutorial/api/v1alpha1"
 	"kubebuilder-tutorial/controllers"
+	"kubebuilder-tutorial/pkg/audit"
+	"kubebuilder-tutorial/pkg/awssig"
+	"kubebuilder-tutorial/pkg/eventsource"
+	"kubebuilder-tutorial/pkg/externalsecrets"
+	"kubebuilder-tutorial/pkg/logarchive"
+	"kubebuilder-tutorial/pkg/mesh"
+	"kubebuilder-tutorial/pkg/notify"
+	"kubebuilder-tutorial/pkg/promquery"
+	"kubebuilder-tutorial/pkg/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// configScheme knows our config file's kinds.
+	configScheme = runtime.NewScheme()
 )
 
 func init() {
@@ -41,35 +70,333 @@ func init() {
 
 	_ = batchv1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
+
+	_ = configv1alpha1.AddToScheme(configScheme)
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
+	/*
+		Settings come from a ControllerConfig file, if there is one, with any flags
+		given on top: the flags are bound to the config itself, so we parse them once
+		to find the file, load it over their defaults, and parse them again.
+	*/
+	var configFile string
+	config := configv1alpha1.ControllerConfig{}
+	config.LeaderElection = &componentconfig.LeaderElectionConfiguration{LeaderElect: new(bool)}
+	config.Webhook.Port = new(int)
+	config.SyncPeriod = &metav1.Duration{}
+	flag.StringVar(&configFile, "config", "",
+		"A ControllerConfig file to load settings from. Flags given as well win over it.")
+	flag.StringVar(&config.Metrics.BindAddress, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(config.LeaderElection.LeaderElect, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(config.Webhook.Port, "webhook-port", 9443, "The port the webhook server serves at.")
+	flag.StringVar(&config.Tracing.Endpoint, "otlp-endpoint", "",
+		"The host:port of the OTLP gRPC collector to send traces to. Tracing is disabled if empty.")
+	flag.BoolVar(&config.Tracing.Insecure, "otlp-insecure", false, "Connect to the OTLP collector without TLS.")
+	flag.StringVar(&config.AuditSink, "audit-sink", "",
+		"Where to record scheduling decisions: stdout, file:<path> or configmap:<namespace>/<name>. "+
+			"The default RBAC only allows the ConfigMap scheduling-decisions in the manager's namespace. "+
+			"Decisions aren't recorded if empty.")
+	flag.StringVar(&config.LogArchive, "log-archive", "",
+		"Where to archive the logs of jobs before cleaning them up, for CronJobs that ask for it: configmap. "+
+			"Logs aren't archived if empty.")
+	flag.StringVar(&config.SecretProviders, "secret-providers", "",
+		"A comma-separated list of the stores CronJobs can have secrets injected from: vault, aws-secretsmanager. "+
+			"Each is configured from the environment, as its own CLI would be.")
+	flag.StringVar(&config.CloudEventsBindAddress, "cloudevents-bind-address", "",
+		"The address to receive CloudEvents at, for CronJobs' CloudEvents triggers. CloudEvents triggers can't run if empty.")
+	flag.Var((*stringList)(&config.EventSourceHosts), "event-source-hosts",
+		"A comma-separated list of the hosts CronJobs' NATS, Kafka and SQS triggers may connect to, with *.example.com for any host under example.com. "+
+			"Those triggers can't run if empty.")
+	flag.Var((*stringList)(&config.InternalHosts), "internal-hosts",
+		"A comma-separated list of the hosts on the cluster's network CronJobs' notifications and calendars may reach, with *.example.com for any host under example.com. "+
+			"Anywhere else, they have to be at public addresses.")
+	flag.StringVar(&config.PrometheusURL, "prometheus-url", "",
+		"The Prometheus server CronJobs' condition gates query. Condition gates are never met if empty.")
+	flag.StringVar(&config.MeshQuitImage, "mesh-quit-image", mesh.DefaultQuitImage,
+		"The image of the container that has a service mesh sidecar quit once a job's own containers have finished. It needs a busybox shell, with wget.")
+	flag.IntVar(&config.Workers.MaxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"How many CronJobs may be reconciled at once.")
+	flag.DurationVar(&config.Workers.BaseDelay.Duration, "reconcile-base-delay", 5*time.Millisecond,
+		"How long to wait before first retrying a CronJob whose reconcile failed, doubling with each failure.")
+	flag.DurationVar(&config.Workers.MaxDelay.Duration, "reconcile-max-delay", 1000*time.Second,
+		"The longest to wait before retrying a CronJob whose reconcile failed.")
+	flag.Float64Var(&config.Workers.QPS, "reconcile-qps", 10, "How many CronJobs per second may be let out of the queue overall.")
+	flag.IntVar(&config.Workers.Burst, "reconcile-burst", 100, "How many CronJobs may be let out of the queue at once, above the QPS.")
+	flag.Float64Var(&config.Workers.PerObjectQPS, "reconcile-per-object-qps", 2,
+		"How many times per second any one CronJob may be reconciled. Unlimited if zero.")
+	flag.IntVar(&config.Workers.PerObjectBurst, "reconcile-per-object-burst", 10,
+		"How many times any one CronJob may be reconciled in quick succession, above its QPS.")
+	flag.DurationVar(&config.Workers.ReconcileTimeout.Duration, "reconcile-timeout", 2*time.Minute,
+		"The longest a single CronJob reconcile may take before it's abandoned and retried. Unlimited if zero.")
+	flag.DurationVar(&config.SyncPeriod.Duration, "sync-period", 10*time.Hour,
+		"How often every object is reconciled again, whether or not anything changed.")
+	flag.DurationVar(&config.ClockSkewTolerance.Duration, "clock-skew-tolerance", time.Second,
+		"How far ahead of their scheduled time runs may start, to allow for clock skew. CronJobs may override this.")
+	flag.IntVar(&config.Shard.Count, "shard-count", 1,
+		"How many shards to split namespaces between, each with its own leader election.")
+	flag.IntVar(&config.Shard.Index, "shard-index", 0,
+		"Which shard this replica works on, counting from zero. ClusterCronJobs are looked after by shard 0.")
+	flag.StringVar(&config.Tenants.By, "tenant-by", string(controllers.TenantByNamespace),
+		"What the tenants held to budgets of active jobs are: Namespace, or Team, for the CronJobs' team label.")
+	flag.Var(int32Value{&config.Tenants.MaxActiveJobs}, "tenant-max-active-jobs",
+		"The most jobs any one tenant may have running at once. Unlimited if zero.")
+	flag.Var(int32Value{&config.Tenants.TotalMaxActiveJobs}, "total-max-active-jobs",
+		"The most jobs all tenants together may have running at once, with room given to each tenant in turn. Unlimited if zero.")
+	flag.Var((*stringList)(&config.Namespaces), "watch-namespaces",
+		"A comma-separated list of the only namespaces to watch. All of them, if empty.")
+	flag.Var((*stringList)(&config.ExcludeNamespaces), "exclude-namespaces",
+		"A comma-separated list of namespaces to leave alone, even if they're watched.")
+	flag.BoolVar(&config.Namespaced, "namespaced", false,
+		"Run with only namespace-scoped permissions in the watched namespaces, never reading anything cluster-scoped. "+
+			"MaintenanceWindows and Pod Security levels are ignored. Needs --watch-namespaces, and Kubernetes 1.21 or later.")
+	flag.Var((*controllers.FeatureGates)(&config.FeatureGates), "feature-gates",
+		"Optional behaviours to turn on or off, as a comma-separated list of Name=true|false.")
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	// the log level is one of the settings that can change on the fly
+	logLevel := uberzap.NewAtomicLevelAt(zapcore.DebugLevel)
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.Level(logLevel)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "5bc24d40.tutorial.kubebuilder.io",
+	if configFile != "" {
+		loader := ctrl.ConfigFile().AtPath(configFile).OfKind(&config)
+		if err := loader.InjectScheme(configScheme); err != nil {
+			setupLog.Error(err, "unable to load config file", "file", configFile)
+			os.Exit(1)
+		}
+		if _, err := loader.Complete(); err != nil {
+			setupLog.Error(err, "unable to load config file", "file", configFile)
+			os.Exit(1)
+		}
+		// flags given explicitly win over the file
+		flag.Parse()
+	}
+	features := controllers.FeatureGates(config.FeatureGates)
+	if err := features.Validate(); err != nil {
+		setupLog.Error(err, "invalid feature gates")
+		os.Exit(1)
+	}
+
+	// applyLive applies the settings that can change without a restart, both
+	// now and whenever the config file changes
+	live := &controllers.LiveSettings{}
+	applyLive := func(config *configv1alpha1.ControllerConfig) error {
+		level := zapcore.DebugLevel
+		if config.LogLevel != "" {
+			var err error
+			if level, err = parseLogLevel(config.LogLevel); err != nil {
+				return err
+			}
+		}
+		logLevel.SetLevel(level)
+		batchv1.SetDefaultHistoryLimits(config.DefaultSuccessfulJobsHistoryLimit, config.DefaultFailedJobsHistoryLimit)
+		live.Set(config)
+		return nil
+	}
+	if err := applyLive(&config); err != nil {
+		setupLog.Error(err, "invalid config")
+		os.Exit(1)
+	}
+
+	workers := controllers.WorkerOptions{
+		MaxConcurrentReconciles: config.Workers.MaxConcurrentReconciles,
+		BaseDelay:               config.Workers.BaseDelay.Duration,
+		MaxDelay:                config.Workers.MaxDelay.Duration,
+		QPS:                     config.Workers.QPS,
+		Burst:                   config.Workers.Burst,
+		PerObjectQPS:            config.Workers.PerObjectQPS,
+		PerObjectBurst:          config.Workers.PerObjectBurst,
+		ReconcileTimeout:        config.Workers.ReconcileTimeout.Duration,
+	}
+	shard := controllers.Shard{Index: config.Shard.Index, Count: config.Shard.Count}
+
+	var tenants *controllers.TenantScheduler
+	switch by := controllers.TenantKind(config.Tenants.By); by {
+	case "", controllers.TenantByNamespace, controllers.TenantByTeam:
+		if config.Tenants.MaxActiveJobs > 0 || config.Tenants.TotalMaxActiveJobs > 0 || len(config.Tenants.Budgets) > 0 {
+			tenants = &controllers.TenantScheduler{
+				By:                 by,
+				MaxActiveJobs:      config.Tenants.MaxActiveJobs,
+				Budgets:            config.Tenants.Budgets,
+				TotalMaxActiveJobs: config.Tenants.TotalMaxActiveJobs,
+			}
+		}
+	default:
+		setupLog.Error(fmt.Errorf("unknown kind of tenant %q", by), "invalid tenant budgets")
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint: config.Tracing.Endpoint,
+		Insecure: config.Tracing.Insecure,
 	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
+	leaderElectionID := "5bc24d40.tutorial.kubebuilder.io"
+	if config.LeaderElection.ResourceName != "" {
+		leaderElectionID = config.LeaderElection.ResourceName
+	}
+	if shard.Count > 1 {
+		if shard.Index < 0 || shard.Index >= shard.Count {
+			setupLog.Error(fmt.Errorf("shard index %d is out of range for %d shards", shard.Index, shard.Count), "invalid shard")
+			os.Exit(1)
+		}
+		// each shard has a leader of its own
+		leaderElectionID = fmt.Sprintf("shard-%d.%s", shard.Index, leaderElectionID)
+	}
+
+	// the rest of the manager's settings come straight from the config
+	options, err := ctrl.Options{
+		Scheme:           scheme,
+		LeaderElectionID: leaderElectionID,
+	}.AndFrom(&config)
+	if err != nil {
+		setupLog.Error(err, "unable to configure manager")
+		os.Exit(1)
+	}
+	namespaces := config.WatchedNamespaces()
+	namespaceFilter := controllers.NamespaceFilter{Include: namespaces, Exclude: config.ExcludeNamespaces}
+	/*
+		With only namespaced permissions, each namespace gets a cache of its own,
+		even if there's just one: the multi-namespace cache registers our field
+		indexes with each of them, and refuses reads of cluster-scoped objects
+		outright, where a cache for a single namespace would try to list them
+		across the cluster and wait forever for permission.
+	*/
+	switch {
+	case config.Namespaced && len(namespaces) == 0:
+		setupLog.Error(fmt.Errorf("--namespaced needs --watch-namespaces"), "invalid namespaces")
+		os.Exit(1)
+	case len(namespaces) == 1 && !config.Namespaced:
+		options.Namespace = namespaces[0]
+	case len(namespaces) > 0:
+		options.Namespace = ""
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	/*
+		The namespaced install keeps its webhooks to the watched namespaces by their
+		kubernetes.io/metadata.name label, which namespaces only get from Kubernetes
+		1.21 on.  On anything older, the webhooks would never be called at all, so we
+		refuse to run without them.
+	*/
+	if config.Namespaced {
+		if err := checkNamespaceNameLabels(mgr.GetConfig()); err != nil {
+			setupLog.Error(err, "unable to run namespaced")
+			os.Exit(1)
+		}
+	}
+
+	var auditor audit.Sink
+	if config.AuditSink != "" {
+		auditor, err = audit.NewSink(config.AuditSink, mgr.GetAPIReader(), mgr.GetClient())
+		if err != nil {
+			setupLog.Error(err, "unable to set up audit sink")
+			os.Exit(1)
+		}
+	}
+
+	var logStore logarchive.Store
+	var podLogs corev1client.PodsGetter
+	if config.LogArchive != "" {
+		logStore, err = logarchive.NewStore(config.LogArchive, mgr.GetClient())
+		if err != nil {
+			setupLog.Error(err, "unable to set up log archive")
+			os.Exit(1)
+		}
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to create clientset for fetching logs")
+			os.Exit(1)
+		}
+		podLogs = clientset.CoreV1()
+	}
+
+	secretProviders, err := externalsecrets.NewProviders(config.SecretProviders)
+	if err != nil {
+		setupLog.Error(err, "unable to set up secret stores")
+		os.Exit(1)
+	}
+
+	eventTriggers := &controllers.EventTriggers{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("cronjob-controller"),
+		Log:      ctrl.Log.WithName("triggers"),
+		Hosts:    config.EventSourceHosts,
+	}
+	// SQS triggers use the controller's own AWS credentials, if it has any
+	if _, creds, err := awssig.FromEnv(); err == nil {
+		eventTriggers.AWS = &creds
+	}
+	if config.CloudEventsBindAddress != "" {
+		receiver := eventsource.NewReceiver()
+		eventTriggers.CloudEvents = receiver
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return receiver.ListenAndServe(ctx, config.CloudEventsBindAddress)
+		})); err != nil {
+			setupLog.Error(err, "unable to set up CloudEvents receiver")
+			os.Exit(1)
+		}
+	}
+	if err := mgr.Add(eventTriggers); err != nil {
+		setupLog.Error(err, "unable to set up event triggers")
+		os.Exit(1)
+	}
+
+	var prometheus *promquery.Client
+	if config.PrometheusURL != "" {
+		prometheus = &promquery.Client{URL: config.PrometheusURL}
+	}
+
+	var impersonator *controllers.Impersonator
+	if features.Enabled(controllers.ImpersonateJobCreation) {
+		impersonator = &controllers.Impersonator{
+			Config: mgr.GetConfig(),
+			Scheme: mgr.GetScheme(),
+			Mapper: mgr.GetRESTMapper(),
+		}
+	}
 
 	if err = (&controllers.CronJobReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("CronJob"),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("CronJob"),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("cronjob-controller"),
+		Audit:      auditor,
+		Notifier:   notify.NewSender(config.InternalHosts),
+		LogStore:   logStore,
+		PodLogs:    podLogs,
+		Workers:    workers,
+		Shard:      shard,
+		Namespaces: namespaceFilter,
+		Namespaced: config.Namespaced,
+		Tenants:    tenants,
+
+		EventTriggers:      eventTriggers,
+		ClockSkewTolerance: config.ClockSkewTolerance.Duration,
+		Features:           features,
+		Live:               live,
+		Impersonator:       impersonator,
+		SecretProviders:    secretProviders,
+		SecretPaths:        config.SecretPaths,
+		Prometheus:         prometheus,
+		MeshQuitImage:      config.MeshQuitImage,
+		InternalHosts:      config.InternalHosts,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CronJob")
 		os.Exit(1)
@@ -78,11 +405,159 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "CronJob")
 		os.Exit(1)
 	}
+	if err = (&batchv1.CronJobTrigger{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CronJobTrigger")
+		os.Exit(1)
+	}
+	if err = (&batchv1.CronJobGroup{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CronJobGroup")
+		os.Exit(1)
+	}
+	if err = (&batchv1.CronJobPolicy{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CronJobPolicy")
+		os.Exit(1)
+	}
+	if err = (&batchv1.HolidayCalendar{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "HolidayCalendar")
+		os.Exit(1)
+	}
+	if err = (&batchv1.JobTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "JobTemplate")
+		os.Exit(1)
+	}
+	if err = (&batchv1.MaintenanceWindow{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "MaintenanceWindow")
+		os.Exit(1)
+	}
+	if err = (&batchv1.ScheduleOverride{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "ScheduleOverride")
+		os.Exit(1)
+	}
+	if err = (&batchv1.Workflow{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Workflow")
+		os.Exit(1)
+	}
+	// ClusterCronJobs span every namespace, so only one shard can look
+	// after them, and only if it's watching every namespace
+	if shard.Index == 0 && len(namespaces) == 0 {
+		if err = (&controllers.ClusterCronJobReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ClusterCronJob"),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterCronJob")
+			os.Exit(1)
+		}
+	}
+	if err = (&controllers.WorkflowReconciler{
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("Workflow"),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("workflow-controller"),
+		Shard:      shard,
+		Namespaces: namespaceFilter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Workflow")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
+	if configFile != "" {
+		if err := mgr.Add(&controllers.ConfigReloader{
+			Path:   configFile,
+			Scheme: configScheme,
+			Apply:  applyLive,
+			Log:    ctrl.Log.WithName("config"),
+		}); err != nil {
+			setupLog.Error(err, "unable to watch config file")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// parseLogLevel parses a log level: debug, info or error, or a verbosity
+// like 2, which logs everything down to log.V(2).
+func parseLogLevel(value string) (zapcore.Level, error) {
+	if verbosity, err := strconv.Atoi(value); err == nil && verbosity >= 0 {
+		return zapcore.Level(-verbosity), nil
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", value)
+	}
+	return level, nil
+}
+
+// stringList is a flag holding a comma-separated list.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value, replacing the list.
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// minNamespacedVersion is the first Kubernetes version to label namespaces
+// with their names.
+var minNamespacedVersion = version.MustParseGeneric("1.21.0")
+
+// checkNamespaceNameLabels makes sure the API server is new enough to label
+// namespaces with their names.
+func checkNamespaceNameLabels(cfg *rest.Config) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("unable to get the Kubernetes version: %w", err)
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("unable to parse the Kubernetes version: %w", err)
+	}
+	if !serverVersion.AtLeast(minNamespacedVersion) {
+		return fmt.Errorf("Kubernetes %s doesn't label namespaces with kubernetes.io/metadata.name, which the namespaced webhooks select them by: it needs %s or later", info.GitVersion, minNamespacedVersion)
+	}
+	return nil
+}
+
+// int32Value is a flag holding an int32.
+type int32Value struct{ p *int32 }
+
+// String implements flag.Value.
+func (v int32Value) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*v.p), 10)
+}
+
+// Set implements flag.Value.
+func (v int32Value) Set(value string) error {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return err
+	}
+	*v.p = int32(n)
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the scheduling decisions the controller makes, as
+// JSON lines, so that what it decided and why can be reconstructed after the
+// fact.  Records go to a Sink: stdout, a file, or a ConfigMap.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Decision is what the controller decided to do about a scheduled run.
+type Decision string
+
+const (
+	// Ran means a job was created for the run.
+	Ran Decision = "Ran"
+
+	// Skipped means the run was deliberately not started.
+	Skipped Decision = "Skipped"
+
+	// Replaced means running jobs were deleted to make way for the run.
+	Replaced Decision = "Replaced"
+
+	// DeadlineMissed means the run wasn't started before its starting
+	// deadline passed.
+	DeadlineMissed Decision = "DeadlineMissed"
+)
+
+// Record is a single scheduling decision.
+type Record struct {
+	// Time is when the decision was made.
+	Time time.Time `json:"time"`
+
+	Namespace string `json:"namespace"`
+	CronJob   string `json:"cronJob"`
+
+	// ScheduledTime is the run the decision was about.
+	ScheduledTime time.Time `json:"scheduledTime"`
+
+	Decision Decision `json:"decision"`
+
+	// Reason says why, for decisions that have more than one.
+	Reason string `json:"reason,omitempty"`
+
+	// Jobs are the jobs the decision created or deleted.
+	Jobs []string `json:"jobs,omitempty"`
+}
+
+// Sink is somewhere records go.
+type Sink interface {
+	Record(ctx context.Context, record Record) error
+}
+
+// NewSink builds a sink from its flag form: "stdout", "file:<path>", or
+// "configmap:<namespace>/<name>".  The ConfigMap sink reads through reader
+// and writes through writer.
+func NewSink(spec string, reader client.Reader, writer client.Writer) (Sink, error) {
+	switch {
+	case spec == "stdout":
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSink(strings.TrimPrefix(spec, "file:"))
+	case strings.HasPrefix(spec, "configmap:"):
+		parts := strings.SplitN(strings.TrimPrefix(spec, "configmap:"), "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("audit sink %q should be configmap:<namespace>/<name>", spec)
+		}
+		return NewConfigMapSink(reader, writer, types.NamespacedName{Namespace: parts[0], Name: parts[1]}), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q: expected stdout, file:<path> or configmap:<namespace>/<name>", spec)
+	}
+}
+
+// writerSink writes each record as a line of JSON.
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink that writes records to w, one JSON object per
+// line.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+// NewFileSink returns a sink that appends records to the file at path,
+// creating it if need be.
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f), nil
+}
+
+// Record implements Sink.
+func (s *writerSink) Record(_ context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
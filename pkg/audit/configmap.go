@@ -0,0 +1,93 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configMapKey is the key in the ConfigMap's data the records are kept under.
+const configMapKey = "decisions.jsonl"
+
+// maxConfigMapRecords bounds the records kept in a ConfigMap, since
+// ConfigMaps can't grow past a megabyte.  The oldest go first.
+const maxConfigMapRecords = 1000
+
+// configMapSink keeps the most recent records in a ConfigMap, one JSON
+// object per line.
+type configMapSink struct {
+	mu     sync.Mutex
+	reader client.Reader
+	writer client.Writer
+	key    types.NamespacedName
+}
+
+// NewConfigMapSink returns a sink that keeps the most recent records in the
+// given ConfigMap, creating it if need be.  The reader should read straight
+// from the API server, so that we don't need to cache every ConfigMap.
+func NewConfigMapSink(reader client.Reader, writer client.Writer, key types.NamespacedName) Sink {
+	return &configMapSink{reader: reader, writer: writer, key: key}
+}
+
+// Record implements Sink.
+func (s *configMapSink) Record(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var configMap corev1.ConfigMap
+		err := s.reader.Get(ctx, s.key, &configMap)
+		if apierrors.IsNotFound(err) {
+			configMap = corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: s.key.Namespace, Name: s.key.Name},
+				Data:       map[string]string{configMapKey: string(line) + "\n"},
+			}
+			return s.writer.Create(ctx, &configMap)
+		}
+		if err != nil {
+			return err
+		}
+
+		lines := strings.SplitAfter(configMap.Data[configMapKey], "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, string(line)+"\n")
+		if excess := len(lines) - maxConfigMapRecords; excess > 0 {
+			lines = lines[excess:]
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[configMapKey] = strings.Join(lines, "")
+		return s.writer.Update(ctx, &configMap)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clock abstracts away the passage of time, so that controllers can
+// be tested against a clock that only moves when the test moves it, rather
+// than sleeping until runs come due.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock knows how to get the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock: it just calls time.Now.
+type RealClock struct{}
+
+// Now implements Clock.
+func (RealClock) Now() time.Time { return time.Now() }
+
+// FakeClock is a Clock that stands still until it's moved, for tests.  It's
+// safe for concurrent use, so that a test can move it while whatever it's
+// testing waits on it.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+	hooks   []func(time.Time)
+}
+
+// waiter is a channel waiting for the clock to reach a time.
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+// NewFakeClock returns a FakeClock stopped at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock on by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.setLocked(c.now.Add(d))
+}
+
+// SetTime moves the clock to the given time, which may be in its past.
+func (c *FakeClock) SetTime(now time.Time) {
+	c.mu.Lock()
+	c.setLocked(now)
+}
+
+// setLocked moves the clock, then unlocks it before waking up the waiters
+// the time has come for, and calling the hooks.
+func (c *FakeClock) setLocked(now time.Time) {
+	c.now = now
+	var due []waiter
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.until.After(now) {
+			kept = append(kept, w)
+		} else {
+			due = append(due, w)
+		}
+	}
+	c.waiters = kept
+	hooks := append([]func(time.Time){}, c.hooks...)
+	c.mu.Unlock()
+
+	// the earliest waiters wake up first, like timers would
+	sort.SliceStable(due, func(i, j int) bool { return due[i].until.Before(due[j].until) })
+	for _, w := range due {
+		w.ch <- now
+	}
+	for _, hook := range hooks {
+		hook(now)
+	}
+}
+
+// After returns a channel that receives the clock's time once it's been
+// moved on by at least d, like time.After does with the wall clock.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{until: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiters returns how many channels from After are still waiting, so that a
+// test can tell whether whatever it's testing has got round to waiting
+// before moving the clock.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// NextWaiter returns the earliest time anything is waiting for, if anything
+// is.
+func (c *FakeClock) NextWaiter() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var next time.Time
+	for _, w := range c.waiters {
+		if next.IsZero() || w.until.Before(next) {
+			next = w.until
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// OnSet registers a hook that's called with the clock's new time whenever
+// it's moved, after any waiters have been woken up.
+func (c *FakeClock) OnSet(hook func(now time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, hook)
+}
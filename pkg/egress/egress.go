@@ -0,0 +1,156 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package egress limits where the controller connects to on behalf of the
+// objects it reconciles.  Anyone who can create a CronJob can point it at a
+// URL, and mustn't be able to use the controller to reach what they can't
+// reach themselves.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is how many redirects a Client follows, as http.DefaultClient
+// does.
+const maxRedirects = 10
+
+// nonPublicNets are the address ranges that aren't on the internet: the
+// cluster's own network, and cloud metadata services, are somewhere in them.
+var nonPublicNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.168.0.0/16", "224.0.0.0/4", "240.0.0.0/4",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+	} {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		nets = append(nets, ipNet)
+	}
+	return nets
+}()
+
+// IsPublic checks whether the address is on the internet, rather than a
+// private, loopback, link-local or otherwise special one.
+func IsPublic(ip net.IP) bool {
+	for _, ipNet := range nonPublicNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Allowlist is the hosts that may be connected to, by name, or as
+// *.example.com for any host under example.com.
+type Allowlist []string
+
+// Allows checks whether the host, with or without a port, is on the list.
+func (a Allowlist) Allows(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range a {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL returns an error unless the URL's host is on the list.
+func (a Allowlist) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	if !a.Allows(u.Host) {
+		return fmt.Errorf("the controller may not connect to %s", u.Hostname())
+	}
+	return nil
+}
+
+// PublicClient returns an HTTP client that connects to hosts on the list
+// wherever they are, and to any other host only at a public address.  The
+// address is checked as it's dialed, after the host's name has been resolved,
+// redirects included, so a name can't be pointed at the cluster's network to
+// get around it.  Proxies aren't used.
+func (a Allowlist) PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	publicDialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublic(ip) {
+				return fmt.Errorf("the controller may not connect to %s, which isn't a public address", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				if a.Allows(address) {
+					return dialer.DialContext(ctx, network, address)
+				}
+				return publicDialer.DialContext(ctx, network, address)
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// Client returns an HTTP client that only follows redirects to hosts on the
+// list.
+func (a Allowlist) Client() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !a.Allows(req.URL.Host) {
+				return fmt.Errorf("redirected to %s, which the controller may not connect to", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
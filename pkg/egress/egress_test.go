@@ -0,0 +1,190 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package egress
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	testCases := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"172.32.0.1", true},
+		{"2001:4860:4860::8888", true},
+		{"::ffff:8.8.8.8", true},
+
+		{"0.0.0.0", false},
+		{"10.0.0.1", false},
+		{"100.64.0.1", false},
+		{"127.0.0.1", false},
+		{"127.255.255.254", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"fc00::1", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		// IPv4 addresses written as IPv6 are still the IPv4 ones
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tc := range testCases {
+		ip := net.ParseIP(tc.ip)
+		if ip == nil {
+			t.Fatalf("%s doesn't parse", tc.ip)
+		}
+		if public := IsPublic(ip); public != tc.public {
+			t.Errorf("IsPublic(%s) = %t, want %t", tc.ip, public, tc.public)
+		}
+	}
+}
+
+func TestAllowlistAllows(t *testing.T) {
+	allowlist := Allowlist{"hooks.example.com", "*.Internal.Example.org", "10.0.0.5"}
+	testCases := []struct {
+		host    string
+		allowed bool
+	}{
+		{"hooks.example.com", true},
+		{"hooks.example.com:8443", true},
+		{"HOOKS.Example.COM", true},
+		{"hooks.example.com.", true},
+		{"hooks.example.com.:443", true},
+		{"other.example.com", false},
+		{"example.com", false},
+		{"hooks.example.com.evil.net", false},
+		{"evilhooks.example.com", false},
+
+		{"a.internal.example.org", true},
+		{"a.b.internal.example.org:80", true},
+		{"A.INTERNAL.EXAMPLE.ORG.", true},
+		// the wildcard is for hosts under the domain, not the domain itself
+		{"internal.example.org", false},
+		{"notinternal.example.org", false},
+
+		{"10.0.0.5", true},
+		{"10.0.0.5:8080", true},
+		{"10.0.0.6", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		if allowed := allowlist.Allows(tc.host); allowed != tc.allowed {
+			t.Errorf("Allows(%q) = %t, want %t", tc.host, allowed, tc.allowed)
+		}
+	}
+
+	if (Allowlist{}).Allows("hooks.example.com") {
+		t.Error("an empty allowlist allows hooks.example.com")
+	}
+}
+
+// TestPublicClient checks that the client won't connect to the cluster's
+// network or the metadata service, whether it's asked to by address, by a
+// name that resolves to one, or by a redirect, unless the host is allowed.
+func TestPublicClient(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, server.URL+"/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := serverURL.Port()
+	if addrs, err := net.LookupHost("localhost"); err != nil || len(addrs) == 0 {
+		t.Skipf("localhost doesn't resolve: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		allowlist Allowlist
+		url       string
+		allowed   bool
+	}{
+		{
+			name: "loopback address",
+			url:  server.URL + "/",
+		},
+		{
+			name: "name resolving to loopback",
+			url:  "http://localhost:" + port + "/",
+		},
+		{
+			name: "metadata service",
+			url:  "http://169.254.169.254/latest/meta-data/",
+		},
+		{
+			name:      "allowed name",
+			allowlist: Allowlist{"localhost"},
+			url:       "http://localhost:" + port + "/",
+			allowed:   true,
+		},
+		{
+			name:      "allowed address",
+			allowlist: Allowlist{"127.0.0.1"},
+			url:       server.URL + "/",
+			allowed:   true,
+		},
+		{
+			name:      "redirect from an allowed host",
+			allowlist: Allowlist{"localhost"},
+			url:       "http://localhost:" + port + "/redirect",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := tc.allowlist.PublicClient(5 * time.Second).Get(tc.url)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if tc.allowed {
+				if err != nil {
+					t.Fatalf("expected to connect, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("connected to a non-public address")
+			}
+			if !strings.Contains(err.Error(), "isn't a public address") {
+				t.Fatalf("expected to be refused for the address, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Receiver receives CloudEvents over HTTP, in binary or structured content
+// mode, POSTed to the paths its sources are routed at.  Batches aren't
+// supported.  Events are acknowledged with a 202 once they've been handled,
+// and rejected with a 500 if they couldn't be, for the sender to retry.
+//
+// Anyone who can reach the receiver can send it events, so it's best kept
+// inside the cluster, or behind something that authenticates senders.
+type Receiver struct {
+	mu     sync.RWMutex
+	routes map[string]*route
+}
+
+// route is where a source is listening.
+type route struct {
+	types  map[string]bool
+	handle Handler
+}
+
+// NewReceiver returns a receiver with no routes.
+func NewReceiver() *Receiver {
+	return &Receiver{routes: map[string]*route{}}
+}
+
+// Source returns a source of the CloudEvents POSTed to the path that have
+// one of the types, or any type if none are given.  Only one source may
+// receive at a path at a time.
+func (r *Receiver) Source(path string, types []string) Source {
+	return &receiverSource{receiver: r, path: path, types: types}
+}
+
+// receiverSource is a path of a Receiver.
+type receiverSource struct {
+	receiver *Receiver
+	path     string
+	types    []string
+}
+
+// Receive implements Source, routing events at the path to the handler
+// until the context is done.
+func (s *receiverSource) Receive(ctx context.Context, handle Handler) error {
+	rt := &route{handle: handle}
+	if len(s.types) > 0 {
+		rt.types = map[string]bool{}
+		for _, t := range s.types {
+			rt.types[t] = true
+		}
+	}
+
+	s.receiver.mu.Lock()
+	if _, taken := s.receiver.routes[s.path]; taken {
+		s.receiver.mu.Unlock()
+		return fmt.Errorf("already receiving CloudEvents at %s", s.path)
+	}
+	s.receiver.routes[s.path] = rt
+	s.receiver.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.receiver.mu.Lock()
+	delete(s.receiver.routes, s.path)
+	s.receiver.mu.Unlock()
+	return nil
+}
+
+// structuredEvent is a CloudEvent in structured content mode, JSON-encoded.
+type structuredEvent struct {
+	SpecVersion string          `json:"specversion"`
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data"`
+	// encoding/json decodes base64 into []byte for us
+	DataBase64 []byte `json:"data_base64"`
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "CloudEvents must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.mu.RLock()
+	rt := r.routes[req.URL.Path]
+	r.mu.RUnlock()
+	if rt == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var event Event
+	var specVersion string
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/cloudevents+json":
+		var structured structuredEvent
+		if err := json.Unmarshal(body, &structured); err != nil {
+			http.Error(w, fmt.Sprintf("malformed CloudEvent: %v", err), http.StatusBadRequest)
+			return
+		}
+		specVersion = structured.SpecVersion
+		event = Event{ID: structured.ID, Source: structured.Source, Type: structured.Type, Data: structured.DataBase64}
+		if len(structured.Data) > 0 {
+			event.Data = structured.Data
+			// string data is the string itself, not its JSON encoding
+			var s string
+			if json.Unmarshal(structured.Data, &s) == nil {
+				event.Data = []byte(s)
+			}
+		}
+	case "application/cloudevents-batch+json":
+		http.Error(w, "batches of CloudEvents aren't supported", http.StatusUnsupportedMediaType)
+		return
+	default:
+		specVersion = req.Header.Get("Ce-Specversion")
+		event = Event{
+			ID:     req.Header.Get("Ce-Id"),
+			Source: req.Header.Get("Ce-Source"),
+			Type:   req.Header.Get("Ce-Type"),
+			Data:   body,
+		}
+	}
+	if specVersion == "" || event.ID == "" || event.Source == "" || event.Type == "" {
+		http.Error(w, "CloudEvents need a specversion, id, source and type", http.StatusBadRequest)
+		return
+	}
+
+	if rt.types != nil && !rt.types[event.Type] {
+		// not for us, but nothing for the sender to retry either
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := rt.handle(req.Context(), event); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ListenAndServe serves the receiver at the address until the context is
+// done.
+func (r *Receiver) ListenAndServe(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package eventsource receives events from message brokers and over HTTP, so
+// that CronJobs can run when something happens as well as on their schedule.
+// Brokers are spoken to directly, over their own protocols or REST APIs,
+// rather than through their client libraries: we only ever need to consume.
+package eventsource
+
+import (
+	"context"
+)
+
+// maxEventSize is the most of an event's payload we'll read.
+const maxEventSize = 1 << 20
+
+// Event is a message from a source.
+type Event struct {
+	// ID identifies the event within its source, for sources that have IDs.
+	// Sources that redeliver events redeliver them with the same ID.
+	ID string
+	// Type is the kind of event, for sources that have types.
+	Type string
+	// Source is where the event came from: a subject, topic or queue, or a
+	// CloudEvent's own source.
+	Source string
+	// Data is the event's payload.
+	Data []byte
+}
+
+// Handler handles an event.  An error leaves the event for the source to
+// redeliver, if it can.
+type Handler func(ctx context.Context, event Event) error
+
+// Source is somewhere events come from.
+type Source interface {
+	// Receive hands events to the handler as they arrive, until the context
+	// is done or the source fails.  Events are only acknowledged, for
+	// sources that have acknowledgements, once the handler has returned
+	// without an error.
+	Receive(ctx context.Context, handle Handler) error
+}
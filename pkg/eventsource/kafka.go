@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaRecordsType = "application/vnd.kafka.binary.v2+json"
+
+	// kafkaPollTimeout is how long the proxy may hold a poll for records.
+	kafkaPollTimeout = 10 * time.Second
+)
+
+// Kafka receives the records produced to a topic, through a Kafka REST Proxy
+// speaking its v2 API, so that we needn't speak Kafka's own protocol.  We
+// consume as a member of a consumer group, committing each record's offset
+// once it's been handled, so that records we fail to handle, or never got
+// to, are redelivered.
+type Kafka struct {
+	// URL is the REST proxy, e.g. http://kafka-rest:8082.
+	URL string
+	// Topic is the topic to consume.
+	Topic string
+	// Group is the consumer group to consume as.
+	Group string
+	// Instance names our consumer within the group.
+	Instance string
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// kafkaRecord is a record as the proxy hands it out, in binary format.
+type kafkaRecord struct {
+	Topic     string `json:"topic"`
+	Value     []byte `json:"value"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// kafkaOffset is a record's position in its topic.
+type kafkaOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// Receive implements Source.
+func (k *Kafka) Receive(ctx context.Context, handle Handler) error {
+	consumer, err := k.createConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	// the consumer's partitions go to the rest of the group as soon as it's
+	// gone, rather than once the proxy times it out
+	defer func() {
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		k.do(deleteCtx, http.MethodDelete, consumer, nil, nil)
+	}()
+
+	subscription := map[string][]string{"topics": {k.Topic}}
+	if err := k.do(ctx, http.MethodPost, consumer+"/subscription", subscription, nil); err != nil {
+		return k.doneErr(ctx, err)
+	}
+
+	for {
+		var records []kafkaRecord
+		query := fmt.Sprintf("/records?timeout=%d", kafkaPollTimeout.Milliseconds())
+		if err := k.do(ctx, http.MethodGet, consumer+query, nil, &records); err != nil {
+			return k.doneErr(ctx, err)
+		}
+		for _, record := range records {
+			event := Event{
+				ID:     fmt.Sprintf("%s/%d/%d", record.Topic, record.Partition, record.Offset),
+				Source: record.Topic,
+				Data:   record.Value,
+			}
+			if err := handle(ctx, event); err != nil {
+				return err
+			}
+			// the proxy commits the offset after the one we give it
+			offsets := map[string][]kafkaOffset{"offsets": {{Topic: record.Topic, Partition: record.Partition, Offset: record.Offset}}}
+			if err := k.do(ctx, http.MethodPost, consumer+"/offsets", offsets, nil); err != nil {
+				return k.doneErr(ctx, err)
+			}
+		}
+	}
+}
+
+// createConsumer creates our consumer instance in the group, or picks up
+// the one we left behind, returning its URL.
+func (k *Kafka) createConsumer(ctx context.Context) (string, error) {
+	base := strings.TrimSuffix(k.URL, "/") + "/consumers/" + url.PathEscape(k.Group)
+	config := map[string]string{
+		"name":               k.Instance,
+		"format":             "binary",
+		"auto.offset.reset":  "latest",
+		"auto.commit.enable": "false",
+	}
+	err := k.do(ctx, http.MethodPost, base, config, nil)
+	if statusErr, ok := err.(*kafkaStatusError); ok && statusErr.code == http.StatusConflict {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return base + "/instances/" + url.PathEscape(k.Instance), nil
+}
+
+// kafkaStatusError is an unsuccessful response from the proxy.
+type kafkaStatusError struct {
+	code    int
+	status  string
+	message string
+}
+
+func (e *kafkaStatusError) Error() string {
+	return fmt.Sprintf("kafka REST proxy returned %s: %s", e.status, e.message)
+}
+
+// do sends the request body, if any, as JSON, decoding the response into
+// out, if any.
+func (k *Kafka) do(ctx context.Context, method, target string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	if out != nil {
+		req.Header.Set("Accept", kafkaRecordsType)
+	}
+
+	client := k.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var proxyErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, maxEventSize)).Decode(&proxyErr)
+		return &kafkaStatusError{code: resp.StatusCode, status: resp.Status, message: proxyErr.Message}
+	}
+	if out == nil {
+		_, err := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxEventSize))
+		return err
+	}
+	// a poll can bring back a good many records at once
+	return json.NewDecoder(io.LimitReader(resp.Body, 64*maxEventSize)).Decode(out)
+}
+
+// doneErr is the error to return for a failed request: none, if it's only
+// failed because we were done.
+func (k *Kafka) doneErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
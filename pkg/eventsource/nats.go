@@ -0,0 +1,188 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsource
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// natsDefaultPort is where NATS servers listen unless told otherwise.
+	natsDefaultPort = "4222"
+
+	// natsReadTimeout is how long we wait to hear from the server before
+	// giving up on the connection.  Servers ping every couple of minutes.
+	natsReadTimeout = 5 * time.Minute
+)
+
+// NATS receives the messages published to a subject on a NATS server.  Core
+// NATS delivers each message at most once, and has neither IDs nor
+// acknowledgements: messages published while we're not connected are lost.
+type NATS struct {
+	// URL is the server, as nats://host:port, or tls://host:port to connect
+	// over TLS.
+	URL string
+	// Subject is the subject to subscribe to, which may have wildcards.
+	Subject string
+	// Queue, if set, is the queue group to subscribe in, so that each message
+	// goes to only one of the group's subscribers.
+	Queue string
+	// Name is how we introduce ourselves to the server.
+	Name string
+}
+
+// natsInfo is the part of the server's INFO we care about.
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// Receive implements Source.
+func (n *NATS) Receive(ctx context.Context, handle Handler) error {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return fmt.Errorf("invalid NATS URL %q: %w", n.URL, err)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	// closing the connection is the only way to interrupt a read
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		raw.Close()
+	}()
+	conn := raw
+
+	reader := bufio.NewReader(conn)
+	readLine := func() (string, error) {
+		if err := conn.SetReadDeadline(time.Now().Add(natsReadTimeout)); err != nil {
+			return "", err
+		}
+		line, err := reader.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return n.closedErr(ctx, err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("expected INFO from NATS server, got %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fmt.Errorf("unable to parse NATS server's INFO: %w", err)
+	}
+	// the server always greets us in the clear, and only then do we upgrade
+	// to TLS
+	if info.TLSRequired && u.Scheme != "tls" {
+		return fmt.Errorf("NATS server requires TLS: use a tls:// URL")
+	}
+	if u.Scheme == "tls" {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return n.closedErr(ctx, err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	connect, err := json.Marshal(map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     n.Name,
+		"lang":     "go",
+		"version":  "1",
+		"protocol": 1,
+	})
+	if err != nil {
+		return err
+	}
+	subscribe := "SUB " + n.Subject
+	if n.Queue != "" {
+		subscribe += " " + n.Queue
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n%s 1\r\nPING\r\n", connect, subscribe); err != nil {
+		return n.closedErr(ctx, err)
+	}
+
+	for {
+		line, err := readLine()
+		if err != nil {
+			return n.closedErr(ctx, err)
+		}
+		op := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch op {
+		case "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return n.closedErr(ctx, err)
+			}
+		case "PONG", "+OK", "INFO":
+		case "-ERR":
+			return fmt.Errorf("NATS server: %s", strings.TrimSpace(strings.TrimPrefix(line, op)))
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <size>
+			fields := strings.Fields(line)
+			if len(fields) < 4 || len(fields) > 5 {
+				return fmt.Errorf("malformed NATS message: %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 || size > maxEventSize {
+				return fmt.Errorf("unexpected NATS message size in %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return n.closedErr(ctx, err)
+			}
+			if err := handle(ctx, Event{Source: fields[1], Data: payload[:size]}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected message from NATS server: %q", line)
+		}
+	}
+}
+
+// closedErr is the error to return for a failed read or write: none, if
+// it's only failed because we were done.
+func (n *NATS) closedErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kubebuilder-tutorial/pkg/awssig"
+)
+
+// sqsWaitTime is how long each receive waits for messages to arrive, the
+// longest SQS allows.
+const sqsWaitTime = 20
+
+// SQS receives the messages sent to an Amazon SQS queue.  Each is deleted
+// from the queue once it's been handled; messages we fail to handle become
+// visible again after the queue's visibility timeout, and are redelivered.
+type SQS struct {
+	// QueueURL is the queue, e.g.
+	// https://sqs.us-east-1.amazonaws.com/123456789012/my-queue.
+	QueueURL string
+	// Region is the queue's region.  Taken from the queue's URL if empty.
+	Region string
+	// Credentials sign our requests.
+	Credentials awssig.Credentials
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// sqsMessage is a message as ReceiveMessage hands it out.
+type sqsMessage struct {
+	MessageID     string `json:"MessageId"`
+	ReceiptHandle string `json:"ReceiptHandle"`
+	Body          string `json:"Body"`
+}
+
+// Receive implements Source.
+func (s *SQS) Receive(ctx context.Context, handle Handler) error {
+	region := s.Region
+	if region == "" {
+		var err error
+		if region, err = SQSRegion(s.QueueURL); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var received struct {
+			Messages []sqsMessage `json:"Messages"`
+		}
+		err := s.call(ctx, region, "ReceiveMessage", map[string]interface{}{
+			"QueueUrl":            s.QueueURL,
+			"MaxNumberOfMessages": 10,
+			"WaitTimeSeconds":     sqsWaitTime,
+		}, &received)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, message := range received.Messages {
+			event := Event{ID: message.MessageID, Source: s.QueueURL, Data: []byte(message.Body)}
+			if err := handle(ctx, event); err != nil {
+				return err
+			}
+			err := s.call(ctx, region, "DeleteMessage", map[string]interface{}{
+				"QueueUrl":      s.QueueURL,
+				"ReceiptHandle": message.ReceiptHandle,
+			}, nil)
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+		}
+	}
+}
+
+// SQSRegion works out a queue's region from its URL, as long as it's at
+// its regional endpoint.
+func SQSRegion(queueURL string) (string, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid queue URL %q: %w", queueURL, err)
+	}
+	// sqs.<region>.amazonaws.com, or <region>.queue.amazonaws.com for the
+	// legacy endpoints
+	parts := strings.Split(u.Hostname(), ".")
+	switch {
+	case len(parts) >= 4 && parts[0] == "sqs":
+		return parts[1], nil
+	case len(parts) >= 4 && parts[1] == "queue":
+		return parts[0], nil
+	}
+	return "", fmt.Errorf("can't tell the region of queue %q from its URL", queueURL)
+}
+
+// call calls an action of the SQS API with the JSON protocol, decoding the
+// response into out, if any.
+func (s *SQS) call(ctx context.Context, region, action string, input, out interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(s.QueueURL)
+	if err != nil {
+		return err
+	}
+	endpoint := u.Scheme + "://" + u.Host + "/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	awssig.Sign(req, body, region, "sqs", s.Credentials, time.Now())
+
+	client := s.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var sqsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, maxEventSize)).Decode(&sqsErr)
+		return fmt.Errorf("SQS %s returned %s: %s %s", action, resp.Status, sqsErr.Type, sqsErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	// each of the ten messages a receive brings back may be up to 256KiB
+	return json.NewDecoder(io.LimitReader(resp.Body, 4*maxEventSize)).Decode(out)
+}
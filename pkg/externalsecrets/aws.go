@@ -0,0 +1,121 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalsecrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"kubebuilder-tutorial/pkg/awssig"
+)
+
+// SecretsManager resolves secrets from AWS Secrets Manager.  Paths are
+// secrets' names or ARNs.  Secrets stored as JSON objects have fields;
+// binary secrets, and those stored as plain strings, don't.
+type SecretsManager struct {
+	// Region is the AWS region the secrets are in.
+	Region string
+	// AccessKeyID, SecretAccessKey and, for temporary credentials,
+	// SessionToken sign our requests.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint overrides the regional endpoint, for VPC endpoints and the
+	// like.
+	Endpoint string
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// SecretsManagerFromEnv configures Secrets Manager from AWS_REGION (or
+// AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, as the AWS CLI does.
+func SecretsManagerFromEnv() (*SecretsManager, error) {
+	region, creds, err := awssig.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("aws-secretsmanager: %w", err)
+	}
+	return &SecretsManager{
+		Region:          region,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}, nil
+}
+
+// Resolve implements Provider.
+func (s *SecretsManager) Resolve(ctx context.Context, ref Ref) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", s.Region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awssig.Sign(req, body, s.Region, "secretsmanager", awssig.Credentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+	}, time.Now())
+
+	client := s.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secrets manager returned %s for %s", resp.Status, ref.Path)
+	}
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("unable to parse secrets manager's response for %s: %w", ref.Path, err)
+	}
+
+	if secret.SecretString == nil {
+		if ref.Field != "" {
+			return nil, fmt.Errorf("secret %s is binary, so has no field %q", ref.Path, ref.Field)
+		}
+		return secret.SecretBinary, nil
+	}
+	if ref.Field == "" {
+		return []byte(*secret.SecretString), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*secret.SecretString), &fields); err != nil {
+		return nil, fmt.Errorf("secret %s isn't a JSON object, so has no field %q", ref.Path, ref.Field)
+	}
+	return pickField(fields, ref)
+}
@@ -0,0 +1,143 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package externalsecrets resolves secrets from stores outside the cluster,
+// so that jobs can be handed credentials that only exist for as long as they
+// run.  Stores are pluggable: anything that can look a secret up by its path
+// will do.  Vault and AWS Secrets Manager are provided here.
+package externalsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Ref identifies a secret in a store.
+type Ref struct {
+	// Path is where the secret lives in the store.
+	Path string
+	// Field picks one of the secret's fields, for stores that keep several
+	// under one path.  The whole secret if empty.
+	Field string
+}
+
+// Provider is a store secrets can be resolved from.
+type Provider interface {
+	// Resolve fetches the secret's current value.
+	Resolve(ctx context.Context, ref Ref) ([]byte, error)
+}
+
+// Providers are the stores the controller knows, by name.
+type Providers map[string]Provider
+
+// NewProviders builds the stores named, as a comma-separated list, in their
+// flag form: vault and aws-secretsmanager.  Each is configured from the
+// environment its own tools use.
+func NewProviders(spec string) (Providers, error) {
+	providers := Providers{}
+	for _, name := range strings.Split(spec, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "vault":
+			vault, err := VaultFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			providers[name] = vault
+		case "aws-secretsmanager":
+			sm, err := SecretsManagerFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			providers[name] = sm
+		default:
+			return nil, fmt.Errorf("unknown secret store %q: expected vault or aws-secretsmanager", name)
+		}
+	}
+	return providers, nil
+}
+
+// Names lists the stores, sorted.
+func (p Providers) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PathAllowlist says which paths in the stores each namespace's CronJobs may
+// have secrets injected from, as prefixes keyed by namespace, with "*" for
+// every namespace.  {namespace} in a prefix stands for the namespace itself.
+type PathAllowlist map[string][]string
+
+// Allows checks whether CronJobs in the namespace may have the secret at the
+// path injected.  Paths with . or .. in them never are, whatever their
+// prefix, since stores may resolve them to somewhere else.
+func (a PathAllowlist) Allows(namespace, path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	for _, key := range []string{namespace, "*"} {
+		for _, prefix := range a[key] {
+			if strings.HasPrefix(path, strings.Replace(prefix, "{namespace}", namespace, -1)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pickField takes the field from a secret made of several, or returns the
+// whole of it, as JSON, if no field is asked for.
+func pickField(fields map[string]interface{}, ref Ref) ([]byte, error) {
+	if ref.Field == "" {
+		return json.Marshal(fields)
+	}
+	value, ok := fields[ref.Field]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no field %q", ref.Path, ref.Field)
+	}
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
+}
+
+// envOrFile returns the environment variable, or failing that the contents of
+// the file named by the variable with _FILE appended, as mounted tokens are.
+func envOrFile(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	file := os.Getenv(name + "_FILE")
+	if file == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,103 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault resolves secrets from HashiCorp Vault's key/value secrets engines.
+// Paths are as Vault's HTTP API has them, so a secret in a version 2 engine
+// mounted at secret/ is at secret/data/<name>.
+type Vault struct {
+	// Address is Vault's URL, like https://vault.example.com:8200.
+	Address string
+	// Token authenticates us to Vault.
+	Token string
+	// Namespace is the Vault Enterprise namespace to work in, if any.
+	Namespace string
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// VaultFromEnv configures Vault from VAULT_ADDR, VAULT_TOKEN (or
+// VAULT_TOKEN_FILE) and VAULT_NAMESPACE, as the vault CLI does.
+func VaultFromEnv() (*Vault, error) {
+	token, err := envOrFile("VAULT_TOKEN")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Vault token: %w", err)
+	}
+	vault := &Vault{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     token,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+	if vault.Address == "" || vault.Token == "" {
+		return nil, fmt.Errorf("vault needs VAULT_ADDR and VAULT_TOKEN to be set")
+	}
+	return vault, nil
+}
+
+// maxResponseSize bounds what we read of a store's response.
+const maxResponseSize = 1 << 20
+
+// Resolve implements Provider.
+func (v *Vault) Resolve(ctx context.Context, ref Ref) ([]byte, error) {
+	url := strings.TrimSuffix(v.Address, "/") + "/v1/" + strings.TrimPrefix(ref.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, ref.Path)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("unable to parse vault's response for %s: %w", ref.Path, err)
+	}
+
+	// version 2 engines wrap the secret up along with its metadata
+	fields := secret.Data
+	if inner, ok := fields["data"].(map[string]interface{}); ok {
+		if _, ok := fields["metadata"]; ok {
+			fields = inner
+		}
+	}
+	return pickField(fields, ref)
+}
@@ -0,0 +1,101 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// maxFeedSize bounds what we read of a feed: a few years of a busy calendar
+// fit comfortably.
+const maxFeedSize = 4 << 20
+
+// Feed is an iCalendar feed as fetched from its URL.
+type Feed struct {
+	// Data is the feed's iCalendar data.
+	Data string
+	// ETag and LastModified are what the server said to validate the feed
+	// with when fetching it again, if anything.
+	ETag         string
+	LastModified string
+}
+
+// Fetcher fetches iCalendar feeds over HTTP, like the secret iCal addresses
+// of Google Calendar or Outlook calendars.
+type Fetcher struct {
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// Fetch fetches the feed at the URL.  Given the feed as last fetched, it only
+// has the server send it again if it's changed since, and returns the last
+// one if it hasn't.  Calendars' addresses are often secrets in themselves, so
+// errors leave the URL out.
+func (f *Fetcher) Fetch(ctx context.Context, feedURL string, last *Feed) (*Feed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, errors.New("invalid feed URL")
+	}
+	req.Header.Set("Accept", "text/calendar")
+	if last != nil {
+		if last.ETag != "" {
+			req.Header.Set("If-None-Match", last.ETag)
+		}
+		if last.LastModified != "" {
+			req.Header.Set("If-Modified-Since", last.LastModified)
+		}
+	}
+
+	client := f.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && last != nil:
+		return last, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetching the feed returned %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("the feed is larger than %d bytes", maxFeedSize)
+	}
+	return &Feed{
+		Data:         string(data),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,201 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ical reads the events out of iCalendar (RFC 5545) data, like the
+// calendars governments and banks publish their holidays in.  It only knows
+// about as much of the format as that takes: recurrence rules, for one, are
+// not expanded, so each event stands for its first occurrence only.  It can
+// fetch the feeds that calendar services publish, too.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT.
+type Event struct {
+	// Summary is the event's title.
+	Summary string
+	// Start is when the event starts.  For all-day events, that's midnight
+	// UTC on the day.
+	Start time.Time
+	// End is when the event ends, exclusively.  For all-day events, that's
+	// midnight UTC on the day after the last one.
+	End time.Time
+	// AllDay is whether the event covers whole days rather than a time range.
+	AllDay bool
+}
+
+// Dates lists the days the event covers, as YYYY-MM-DD: every day of an
+// all-day event, or the day a timed one starts on, in its own time zone.
+func (e Event) Dates() []string {
+	if !e.AllDay {
+		return []string{e.Start.Format("2006-01-02")}
+	}
+	var dates []string
+	for day := e.Start; day.Before(e.End); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+	return dates
+}
+
+// Parse reads every VEVENT from the given iCalendar data.  Feeds may come
+// from anywhere, so errors only say where the problem is, never quoting the
+// data itself.
+func Parse(data string) ([]Event, error) {
+	var events []Event
+	var current *Event
+	var hasEnd bool
+
+	for n, line := range unfold(data) {
+		name, params, value, err := splitLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current, hasEnd = &Event{}, false
+		case name == "END" && value == "VEVENT":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: END:VEVENT without BEGIN:VEVENT", n+1)
+			}
+			if current.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event has no DTSTART", n+1)
+			}
+			if !hasEnd {
+				// RFC 5545 section 3.6.1: an all-day event without an end
+				// lasts the day, and a timed one no time at all
+				current.End = current.Start
+				if current.AllDay {
+					current.End = current.Start.AddDate(0, 0, 1)
+				}
+			}
+			events = append(events, *current)
+			current = nil
+		case current == nil:
+			// not part of an event
+		case name == "SUMMARY":
+			current.Summary = unescape(value)
+		case name == "DTSTART":
+			start, allDay, err := parseTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTSTART: %v", n+1, err)
+			}
+			current.Start, current.AllDay = start, allDay
+		case name == "DTEND":
+			end, _, err := parseTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTEND: %v", n+1, err)
+			}
+			current.End, hasEnd = end, true
+		}
+	}
+	if current != nil {
+		return nil, errors.New("unterminated VEVENT")
+	}
+	return events, nil
+}
+
+// unfold splits the data into content lines, joining lines that were folded
+// onto continuation lines starting with whitespace.
+func unfold(data string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitLine splits a content line like DTSTART;VALUE=DATE:20250101 into its
+// name, parameters and value.  Quoted parameter values, which may contain
+// colons, aren't supported beyond being skipped over.
+func splitLine(line string) (name string, params map[string]string, value string, err error) {
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		}
+		if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", errors.New("expected NAME:VALUE")
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params = make(map[string]string)
+	for _, param := range parts[1:] {
+		if eq := strings.Index(param, "="); eq > 0 {
+			params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+// parseTime parses a DATE or DATE-TIME value, returning whether it was a
+// date.  Floating times, with neither a UTC marker nor a TZID, are taken to
+// be in UTC.  Errors don't quote the value, which time's own errors would.
+func parseTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, errors.New("expected a date like 20060102")
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, errors.New("expected a time like 20060102T150405Z")
+		}
+		return t, false, nil
+	}
+
+	loc := time.UTC
+	if tzid, ok := params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, false, errors.New("unknown TZID")
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, errors.New("expected a time like 20060102T150405")
+	}
+	return t, false, nil
+}
+
+// unescape undoes the escaping of TEXT values.
+func unescape(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
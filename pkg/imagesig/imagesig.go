@@ -0,0 +1,387 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package imagesig verifies cosign signatures of container images, as
+// stored by `cosign sign --key` alongside the image in its registry, against
+// public keys.  Only anonymous pulls are supported: the signatures of images
+// in private registries can't be checked.
+package imagesig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Verifier checks images' signatures.
+type Verifier interface {
+	// Verify checks that the image is signed by at least one of the keys,
+	// returning the digest the signature is for.
+	Verify(ctx context.Context, image string, keys []crypto.PublicKey) (string, error)
+}
+
+// TransientError reports a failure to talk to a registry that's worth
+// retrying: the network, or the registry's own trouble, rather than the image
+// or its signatures.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// IsTransient reports whether the error, or any it wraps, is a
+// TransientError.
+func IsTransient(err error) bool {
+	var transient *TransientError
+	return errors.As(err, &transient)
+}
+
+// transientStatus reports whether a registry's response status means it
+// might answer differently later.
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// ParsePublicKey parses a PEM-encoded public key, as written by
+// `cosign generate-key-pair`.
+func ParsePublicKey(data string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM-encoded public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Registry is the host of the registry.
+	Registry string
+	// Repository is the image's path in the registry.
+	Repository string
+	// Tag and Digest are whichever the reference has.
+	Tag    string
+	Digest string
+}
+
+// ParseReference parses an image reference the way container runtimes do:
+// images without a registry come from Docker Hub, and those without a tag
+// or digest are the latest.
+func ParseReference(image string) (Reference, error) {
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !strings.HasPrefix(ref.Digest, "sha256:") {
+			return Reference{}, fmt.Errorf("image %q: unsupported digest", image)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("image %q: no name", image)
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = "registry-1.docker.io", name
+		if len(parts) == 1 {
+			ref.Repository = "library/" + name
+		}
+	}
+	if ref.Registry == "docker.io" || ref.Registry == "index.docker.io" {
+		ref.Registry = "registry-1.docker.io"
+	}
+	return ref, nil
+}
+
+// CosignVerifier verifies signatures made with `cosign sign --key`.
+type CosignVerifier struct {
+	// Client makes the requests to registries.  http.DefaultClient if nil.
+	Client *http.Client
+}
+
+var _ Verifier = &CosignVerifier{}
+
+const (
+	// signatureAnnotation holds a signature layer's base64-encoded signature.
+	signatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	manifestMediaTypes = "application/vnd.oci.image.manifest.v1+json," +
+		"application/vnd.oci.image.index.v1+json," +
+		"application/vnd.docker.distribution.manifest.v2+json," +
+		"application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Verify implements Verifier.
+func (v *CosignVerifier) Verify(ctx context.Context, image string, keys []crypto.PublicKey) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	r := &registry{client: v.Client, ref: ref}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+
+	digest := ref.Digest
+	if digest == "" {
+		if digest, err = r.resolve(ctx, ref.Tag); err != nil {
+			return "", fmt.Errorf("image %s: unable to resolve tag: %w", image, err)
+		}
+	}
+
+	// cosign keeps the signatures of an image under a tag named after its
+	// digest
+	var manifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	body, _, err := r.get(ctx, "manifests/"+strings.Replace(digest, ":", "-", 1)+".sig", manifestMediaTypes)
+	if err != nil {
+		return "", fmt.Errorf("image %s: no signatures found: %w", image, err)
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return "", fmt.Errorf("image %s: unable to parse signatures: %w", image, err)
+	}
+	// a signature we couldn't fetch might be the valid one
+	var unfetched error
+	for _, layer := range manifest.Layers {
+		signature, err := base64.StdEncoding.DecodeString(layer.Annotations[signatureAnnotation])
+		if err != nil || len(signature) == 0 {
+			continue
+		}
+		payload, _, err := r.get(ctx, "blobs/"+layer.Digest, "")
+		if IsTransient(err) {
+			unfetched = err
+			continue
+		}
+		if err != nil || !matchesDigest(payload, layer.Digest) {
+			continue
+		}
+		if signedDigest(payload) != digest {
+			continue
+		}
+		for _, key := range keys {
+			if verifySignature(key, payload, signature) {
+				return digest, nil
+			}
+		}
+	}
+	if unfetched != nil {
+		return "", fmt.Errorf("image %s: unable to fetch a signature: %w", image, unfetched)
+	}
+	return "", fmt.Errorf("image %s: no valid signature by a trusted key", image)
+}
+
+// signedDigest returns the image digest a cosign signature payload vouches
+// for.
+func signedDigest(payload []byte) string {
+	var simpleSigning struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return ""
+	}
+	return simpleSigning.Critical.Image.DockerManifestDigest
+}
+
+// verifySignature checks the signature of the payload against the key.
+func verifySignature(key crypto.PublicKey, payload, signature []byte) bool {
+	hash := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+			return false
+		}
+		return ecdsa.Verify(key, hash[:], sig.R, sig.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	default:
+		return false
+	}
+}
+
+// matchesDigest checks content against its sha256 digest.
+func matchesDigest(content []byte, digest string) bool {
+	sum := sha256.Sum256(content)
+	return digest == "sha256:"+hex.EncodeToString(sum[:])
+}
+
+// maxResponseSize bounds what we read from registries: manifests and
+// signature payloads are small.
+const maxResponseSize = 4 << 20
+
+// registry talks to the registry an image is in, anonymously.
+type registry struct {
+	client *http.Client
+	ref    Reference
+	token  string
+}
+
+// resolve returns the digest of the manifest the tag points at.
+func (r *registry) resolve(ctx context.Context, tag string) (string, error) {
+	body, header, err := r.get(ctx, "manifests/"+tag, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	if digest := header.Get("Docker-Content-Digest"); digest != "" {
+		if !matchesDigest(body, digest) {
+			return "", fmt.Errorf("manifest doesn't match digest %s", digest)
+		}
+		return digest, nil
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// get fetches a path under the image's repository, getting a token if the
+// registry asks for one.
+func (r *registry) get(ctx context.Context, path, accept string) ([]byte, http.Header, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/%s", r.ref.Registry, r.ref.Repository, path)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, nil, &TransientError{Err: err}
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, &TransientError{Err: err}
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, resp.Header, nil
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			if err := r.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, nil, err
+			}
+		case transientStatus(resp.StatusCode):
+			return nil, nil, &TransientError{Err: fmt.Errorf("GET %s: %s", url, resp.Status)}
+		default:
+			return nil, nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+	}
+}
+
+// challengeParam picks the parameters out of a Bearer challenge.
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate gets an anonymous token, as the challenge asks.
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("authentication challenge %q has no realm", challenge)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"], nil)
+	if err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + r.ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return &TransientError{Err: err}
+	}
+	defer resp.Body.Close()
+	if transientStatus(resp.StatusCode) {
+		return &TransientError{Err: fmt.Errorf("unable to get a registry token: %s", resp.Status)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to get a registry token: %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return err
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	return nil
+}
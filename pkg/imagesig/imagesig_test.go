@@ -0,0 +1,337 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagesig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		image   string
+		ref     Reference
+		invalid bool
+	}{
+		{image: "busybox", ref: Reference{Registry: "registry-1.docker.io", Repository: "library/busybox", Tag: "latest"}},
+		{image: "busybox:1.36", ref: Reference{Registry: "registry-1.docker.io", Repository: "library/busybox", Tag: "1.36"}},
+		{image: "team/app:v2", ref: Reference{Registry: "registry-1.docker.io", Repository: "team/app", Tag: "v2"}},
+		{image: "docker.io/library/busybox", ref: Reference{Registry: "registry-1.docker.io", Repository: "library/busybox", Tag: "latest"}},
+		{image: "index.docker.io/team/app", ref: Reference{Registry: "registry-1.docker.io", Repository: "team/app", Tag: "latest"}},
+		{image: "ghcr.io/org/team/app:v1", ref: Reference{Registry: "ghcr.io", Repository: "org/team/app", Tag: "v1"}},
+		{image: "localhost/app", ref: Reference{Registry: "localhost", Repository: "app", Tag: "latest"}},
+		{image: "registry.local:5000/app", ref: Reference{Registry: "registry.local:5000", Repository: "app", Tag: "latest"}},
+		{image: "registry.local:5000/app:v1", ref: Reference{Registry: "registry.local:5000", Repository: "app", Tag: "v1"}},
+		{image: "ghcr.io/org/app@sha256:abc", ref: Reference{Registry: "ghcr.io", Repository: "org/app", Digest: "sha256:abc"}},
+		{image: "ghcr.io/org/app:v1@sha256:abc", ref: Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "v1", Digest: "sha256:abc"}},
+
+		{image: "", invalid: true},
+		{image: ":v1", invalid: true},
+		{image: "ghcr.io/org/app@md5:abc", invalid: true},
+	}
+	for _, tc := range testCases {
+		ref, err := ParseReference(tc.image)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("ParseReference(%q) = %+v, expected an error", tc.image, ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseReference(%q): %v", tc.image, err)
+			continue
+		}
+		if ref != tc.ref {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tc.image, ref, tc.ref)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"ecdsa": encodePublicKey(t, &ecdsaKey.PublicKey),
+		// cosign's keys come with a comment before them
+		"with text around it": "cosign public key\n" + encodePublicKey(t, &ecdsaKey.PublicKey) + "\n",
+	} {
+		key, err := ParsePublicKey(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !ecdsaKey.PublicKey.Equal(key) {
+			t.Errorf("%s: parsed a different key", name)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"empty":       "",
+		"not PEM":     "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE",
+		"not a key":   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")})),
+		"private key": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: mustMarshalECPrivateKey(t, ecdsaKey)})),
+	} {
+		if _, err := ParsePublicKey(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}}`)
+	hash := sha256.Sum256(payload)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Signature := ed25519.Sign(ed25519Private, payload)
+
+	testCases := []struct {
+		name      string
+		key       crypto.PublicKey
+		signature []byte
+		valid     bool
+	}{
+		{"ecdsa", &ecdsaKey.PublicKey, ecdsaSignature, true},
+		{"rsa", &rsaKey.PublicKey, rsaSignature, true},
+		{"ed25519", ed25519Public, ed25519Signature, true},
+		{"ecdsa with an rsa signature", &ecdsaKey.PublicKey, rsaSignature, false},
+		{"rsa with an ecdsa signature", &rsaKey.PublicKey, ecdsaSignature, false},
+		{"ed25519 with an ecdsa signature", ed25519Public, ecdsaSignature, false},
+		{"ecdsa with trailing data", &ecdsaKey.PublicKey, append(append([]byte(nil), ecdsaSignature...), 0), false},
+		{"empty signature", &ecdsaKey.PublicKey, nil, false},
+		{"unsupported key", "not a key", ecdsaSignature, false},
+	}
+	for _, tc := range testCases {
+		if valid := verifySignature(tc.key, payload, tc.signature); valid != tc.valid {
+			t.Errorf("%s: verifySignature = %t, want %t", tc.name, valid, tc.valid)
+		}
+	}
+	if verifySignature(&ecdsaKey.PublicKey, append(payload, ' '), ecdsaSignature) {
+		t.Error("a signature verified for a payload it wasn't made for")
+	}
+}
+
+// fakeRegistry serves an image, and cosign signatures of it, the way a
+// registry that wants anonymous tokens does.
+type fakeRegistry struct {
+	manifest  []byte
+	signature []byte
+	// payload is what the signature is for
+	payload []byte
+	// unavailable makes the registry fail every request for signatures
+	unavailable bool
+}
+
+func (f *fakeRegistry) digest() string {
+	sum := sha256.Sum256(f.manifest)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"token":"anonymous"}`))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer anonymous" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+r.Host+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	payloadSum := sha256.Sum256(f.payload)
+	payloadDigest := "sha256:" + hex.EncodeToString(payloadSum[:])
+	switch r.URL.Path {
+	case "/v2/team/app/manifests/v1":
+		w.Header().Set("Docker-Content-Digest", f.digest())
+		w.Write(f.manifest)
+	case "/v2/team/app/manifests/" + strings.Replace(f.digest(), ":", "-", 1) + ".sig":
+		if f.unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"layers": []map[string]interface{}{{
+				"digest":      payloadDigest,
+				"annotations": map[string]string{signatureAnnotation: base64.StdEncoding.EncodeToString(f.signature)},
+			}},
+		})
+	case "/v2/team/app/blobs/" + payloadDigest:
+		w.Write(f.payload)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCosignVerifierVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(payload []byte) []byte {
+		hash := sha256.Sum256(payload)
+		signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	payloadFor := func(digest string) []byte {
+		return []byte(`{"critical":{"image":{"docker-manifest-digest":"` + digest + `"}}}`)
+	}
+
+	manifest := []byte(`{"schemaVersion":2}`)
+	sum := sha256.Sum256(manifest)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	testCases := []struct {
+		name      string
+		registry  fakeRegistry
+		image     string
+		keys      []crypto.PublicKey
+		verified  bool
+		transient bool
+	}{
+		{
+			name:     "signed by a trusted key",
+			registry: fakeRegistry{manifest: manifest, payload: payloadFor(digest), signature: sign(payloadFor(digest))},
+			image:    "team/app:v1",
+			keys:     []crypto.PublicKey{&otherKey.PublicKey, &key.PublicKey},
+			verified: true,
+		},
+		{
+			name:     "by digest",
+			registry: fakeRegistry{manifest: manifest, payload: payloadFor(digest), signature: sign(payloadFor(digest))},
+			image:    "team/app@" + digest,
+			keys:     []crypto.PublicKey{&key.PublicKey},
+			verified: true,
+		},
+		{
+			name:     "signed by an untrusted key",
+			registry: fakeRegistry{manifest: manifest, payload: payloadFor(digest), signature: sign(payloadFor(digest))},
+			image:    "team/app:v1",
+			keys:     []crypto.PublicKey{&otherKey.PublicKey},
+		},
+		{
+			name:     "signature for another image",
+			registry: fakeRegistry{manifest: manifest, payload: payloadFor("sha256:0000"), signature: sign(payloadFor("sha256:0000"))},
+			image:    "team/app:v1",
+			keys:     []crypto.PublicKey{&key.PublicKey},
+		},
+		{
+			name:     "signature that doesn't match its payload",
+			registry: fakeRegistry{manifest: manifest, payload: payloadFor(digest), signature: sign([]byte("something else"))},
+			image:    "team/app:v1",
+			keys:     []crypto.PublicKey{&key.PublicKey},
+		},
+		{
+			name:     "unsigned",
+			registry: fakeRegistry{manifest: manifest},
+			image:    "team/app@sha256:" + strings.Repeat("0", 64),
+			keys:     []crypto.PublicKey{&key.PublicKey},
+		},
+		{
+			name:      "registry unavailable",
+			registry:  fakeRegistry{manifest: manifest, payload: payloadFor(digest), signature: sign(payloadFor(digest)), unavailable: true},
+			image:     "team/app:v1",
+			keys:      []crypto.PublicKey{&key.PublicKey},
+			transient: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := tc.registry
+			server := httptest.NewTLSServer(&registry)
+			defer server.Close()
+			verifier := &CosignVerifier{Client: server.Client()}
+
+			image := strings.TrimPrefix(server.URL, "https://") + "/" + tc.image
+			verifiedDigest, err := verifier.Verify(context.Background(), image, tc.keys)
+			switch {
+			case tc.verified:
+				if err != nil {
+					t.Fatalf("expected %s to verify, got %v", image, err)
+				}
+				if verifiedDigest != digest {
+					t.Fatalf("verified digest %s, want %s", verifiedDigest, digest)
+				}
+			case err == nil:
+				t.Fatalf("%s verified", image)
+			case IsTransient(err) != tc.transient:
+				t.Fatalf("expected a transient error to be %t, got %v", tc.transient, err)
+			}
+		})
+	}
+}
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustMarshalECPrivateKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,135 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logarchive stores the logs of finished jobs somewhere that outlives
+// them.  Stores are pluggable: anything that can put a blob under a name and
+// say where it went will do, be it an object store bucket or, as provided
+// here, a ConfigMap.
+package logarchive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Archive is a set of logs to store.
+type Archive struct {
+	// Namespace and Name identify the logs; Name is unique within the
+	// namespace, like the name of the job they came from.
+	Namespace string
+	Name      string
+
+	// Labels and OwnerReferences are applied to the stored logs, where the
+	// store supports it.
+	Labels          map[string]string
+	OwnerReferences []metav1.OwnerReference
+
+	Logs []byte
+}
+
+// Store is somewhere logs can be archived.
+type Store interface {
+	// Put stores the archive, and returns where it went, as a URL-like
+	// location for people to find it by.
+	Put(ctx context.Context, archive Archive) (string, error)
+}
+
+// Pruner is a Store that can delete what it has archived.  Stores that
+// implement it get their archives deleted once nobody can find them any
+// more; others are left to their own retention.
+type Pruner interface {
+	// Delete deletes the archive at the location Put returned for it, if
+	// it's still there.
+	Delete(ctx context.Context, location string) error
+}
+
+// Sweeper is a Store that keeps archives where owner references can't reach,
+// such as an object store outside the cluster, and can delete all of a
+// CronJob's at once.  Nothing else cleans those up when the CronJob goes, so
+// it's held on to with a finalizer until they're gone.
+type Sweeper interface {
+	// DeleteAll deletes every archive in the namespace with the given labels.
+	DeleteAll(ctx context.Context, namespace string, labels map[string]string) error
+}
+
+// NewStore builds a store from its flag form.  Only "configmap" is built in;
+// object stores plug in by implementing Store.
+func NewStore(spec string, writer client.Writer) (Store, error) {
+	switch spec {
+	case "configmap":
+		return NewConfigMapStore(writer), nil
+	default:
+		return nil, fmt.Errorf("unknown log archive %q: expected configmap", spec)
+	}
+}
+
+// maxConfigMapLogBytes bounds the logs kept in a ConfigMap, leaving room
+// under the megabyte limit for everything else.  We keep the end of the logs,
+// since that's where failures usually show.
+const maxConfigMapLogBytes = 900 * 1024
+
+// configMapKey is the key in the ConfigMap's binary data the logs are kept
+// under.
+const configMapKey = "logs"
+
+// configMapStore keeps each archive in a ConfigMap of its own, named after
+// the archive, in the archive's namespace.
+type configMapStore struct {
+	writer client.Writer
+}
+
+// NewConfigMapStore returns a store that keeps logs in ConfigMaps.
+func NewConfigMapStore(writer client.Writer) Store {
+	return &configMapStore{writer: writer}
+}
+
+// Put implements Store.
+func (s *configMapStore) Put(ctx context.Context, archive Archive) (string, error) {
+	logs := archive.Logs
+	if excess := len(logs) - maxConfigMapLogBytes; excess > 0 {
+		logs = logs[excess:]
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       archive.Namespace,
+			Name:            archive.Name + "-logs",
+			Labels:          archive.Labels,
+			OwnerReferences: archive.OwnerReferences,
+		},
+		BinaryData: map[string][]byte{configMapKey: logs},
+	}
+	// we may have archived these already and failed to record it
+	if err := s.writer.Create(ctx, configMap); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return fmt.Sprintf("configmap://%s/%s", configMap.Namespace, configMap.Name), nil
+}
+
+// Delete implements Pruner.
+func (s *configMapStore) Delete(ctx context.Context, location string) error {
+	parts := strings.Split(strings.TrimPrefix(location, "configmap://"), "/")
+	if !strings.HasPrefix(location, "configmap://") || len(parts) != 2 {
+		return fmt.Errorf("%q is not a ConfigMap location", location)
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}}
+	return client.IgnoreNotFound(s.writer.Delete(ctx, configMap))
+}
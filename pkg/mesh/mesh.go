@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mesh deals with the sidecars service meshes inject into pods.  A
+// sidecar keeps running once the pod's own containers have finished, so the
+// pod never completes, and neither does its job.  Only Istio's sidecar is
+// handled, by asking it to quit once everything else in the pod is done.
+package mesh
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// InjectionLabel turns Istio's sidecar injection on or off for a
+	// namespace.
+	InjectionLabel = "istio-injection"
+
+	// RevisionLabel has a namespace, or a pod, injected by a particular
+	// revision of Istio.
+	RevisionLabel = "istio.io/rev"
+
+	// InjectLabel turns Istio's sidecar injection on or off for a pod.  It
+	// works as an annotation too.
+	InjectLabel = "sidecar.istio.io/inject"
+
+	// QuitContainerName is the name of the container that has the sidecar
+	// quit.
+	QuitContainerName = "mesh-sidecar-quit"
+
+	// DefaultQuitImage is the image the quit container runs, unless told
+	// otherwise.  It needs a busybox shell, with wget.
+	DefaultQuitImage = "busybox:1.36"
+)
+
+// quitScript waits for every process in the pod besides its own, the pause
+// container's and the sidecar's to exit, then tells the sidecar's agent to
+// quit.  It starts after the containers before it in the pod, so if it finds
+// nothing else running, they've already finished.  The agent may not be
+// listening yet if they finished quickly, so it keeps trying for a while.
+const quitScript = `self=$$
+others_running() {
+  for dir in /proc/[0-9]*; do
+    pid=${dir#/proc/}
+    case $pid in 1|$self) continue;; esac
+    read -r comm 2>/dev/null <$dir/comm || continue
+    case $comm in pause|pilot-agent|envoy) continue;; esac
+    ppid=$(sed -n 's/^PPid:[[:space:]]*//p' $dir/status 2>/dev/null)
+    [ "$ppid" = "$self" ] && continue
+    return 0
+  done
+  return 1
+}
+while others_running; do sleep 2; done
+for attempt in $(seq 30); do
+  wget -q -O /dev/null --post-data= http://localhost:15020/quitquitquit && exit 0
+  sleep 1
+done
+echo "unable to reach the mesh sidecar to have it quit" >&2
+`
+
+// SidecarInjected reports whether Istio would inject its sidecar into pods
+// from the template, in a namespace with the given labels.  Without the
+// namespace's labels, only the template's own say counts.
+func SidecarInjected(namespaceLabels map[string]string, template *corev1.PodTemplateSpec) bool {
+	if namespaceLabels[InjectionLabel] == "disabled" {
+		return false
+	}
+	// the pod's own say comes next, label first, as Istio has it
+	for _, from := range []map[string]string{template.Labels, template.Annotations} {
+		if value, ok := from[InjectLabel]; ok {
+			return value == "true"
+		}
+	}
+	if _, ok := template.Labels[RevisionLabel]; ok {
+		return true
+	}
+	if _, ok := namespaceLabels[RevisionLabel]; ok {
+		return true
+	}
+	return namespaceLabels[InjectionLabel] == "enabled"
+}
+
+// AddQuitter adds a container to the pod spec that has the sidecar quit once
+// the pod's other containers have finished.  Only pods whose restartPolicy is
+// Never should get it: it can't tell a container that's finished from one
+// that's waiting to restart.  It has to see their processes, so the pod's
+// containers share a process namespace.  The container meets
+// the restricted Pod Security Standard, so as not to get the pod rejected.
+// Adding it twice changes nothing.
+func AddQuitter(spec *corev1.PodSpec, image string) {
+	for _, container := range spec.Containers {
+		if container.Name == QuitContainerName {
+			return
+		}
+	}
+
+	share, nonRoot, escalate := true, true, false
+	user := int64(65534)
+	spec.ShareProcessNamespace = &share
+	spec.Containers = append(spec.Containers, corev1.Container{
+		Name:    QuitContainerName,
+		Image:   image,
+		Command: []string{"/bin/sh", "-c", quitScript},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("16Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:                &user,
+			RunAsNonRoot:             &nonRoot,
+			AllowPrivilegeEscalation: &escalate,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		},
+	})
+}
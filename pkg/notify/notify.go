@@ -0,0 +1,165 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify sends notifications about CronJob runs to HTTP endpoints,
+// either as plain JSON or as Slack-compatible incoming webhook payloads.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"kubebuilder-tutorial/pkg/egress"
+)
+
+// Format is the shape of the payload we send.
+type Format string
+
+const (
+	// JSON sends the whole message as a JSON object, with the rendered text
+	// under "text".
+	JSON Format = "JSON"
+
+	// Slack sends just the rendered text, as Slack's incoming webhooks expect.
+	Slack Format = "Slack"
+)
+
+// Target is an endpoint to notify.
+type Target struct {
+	URL      string
+	Format   Format
+	Template string
+	// Internal targets are the controller's own, and may be anywhere.  Others
+	// belong to CronJobs, and are only reached at public addresses, unless
+	// they're on the sender's list of internal hosts.
+	Internal bool
+}
+
+// Message describes what happened.  It's also what templates are executed
+// against.
+type Message struct {
+	Event         string    `json:"event"`
+	Namespace     string    `json:"namespace"`
+	CronJob       string    `json:"cronJob"`
+	ScheduledTime time.Time `json:"scheduledTime"`
+	Job           string    `json:"job,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	Text          string    `json:"text"`
+}
+
+// defaultTemplate is used for targets without a template of their own.
+const defaultTemplate = `CronJob {{.Namespace}}/{{.CronJob}}: {{.Event}}` +
+	`{{if not .ScheduledTime.IsZero}} for the run scheduled at {{.ScheduledTime.Format "2006-01-02T15:04:05Z07:00"}}{{end}}` +
+	`{{if .Job}} (job {{.Job}}){{end}}{{if .Reason}}: {{.Reason}}{{end}}`
+
+// ParseTemplate checks that a message template parses.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("notification").Option("missingkey=error").Parse(text)
+}
+
+// Sender sends notifications, retrying failed deliveries with exponential
+// backoff.
+type Sender struct {
+	// Client delivers to internal targets, and PublicClient to the rest.
+	Client       *http.Client
+	PublicClient *http.Client
+
+	// Attempts is how many times delivery is tried before giving up.
+	Attempts int
+
+	// Backoff is the wait after the first failed attempt, doubling after
+	// each one after that.
+	Backoff time.Duration
+}
+
+// NewSender returns a sender with sensible timeouts and retries, which
+// delivers to CronJobs' targets on the given internal hosts as well as at
+// public addresses.
+func NewSender(internalHosts egress.Allowlist) *Sender {
+	return &Sender{
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		PublicClient: internalHosts.PublicClient(10 * time.Second),
+		Attempts:     3,
+		Backoff:      time.Second,
+	}
+}
+
+// Send renders the message for the target and delivers it.
+func (s *Sender) Send(ctx context.Context, target Target, msg Message) error {
+	text := target.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, msg); err != nil {
+		return err
+	}
+	msg.Text = rendered.String()
+
+	var payload interface{} = msg
+	if target.Format == Slack {
+		payload = map[string]string{"text": msg.Text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.Backoff
+	for attempt := 1; ; attempt++ {
+		err = s.post(ctx, target, body)
+		if err == nil || attempt >= s.Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Sender) post(ctx context.Context, target Target, body []byte) error {
+	url := target.URL
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.PublicClient
+	if target.Internal {
+		client = s.Client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification to %s got status %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podsecurity checks pod templates against the Pod Security Standards
+// a namespace enforces, so that pods that Pod Security admission would reject
+// can be caught before anything tries to create them.  It follows the latest
+// version of the standards, whatever version the namespace pins.
+package podsecurity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Level is one of the Pod Security Standards.
+type Level string
+
+const (
+	// Privileged allows anything.
+	Privileged Level = "privileged"
+
+	// Baseline keeps out known privilege escalations.
+	Baseline Level = "baseline"
+
+	// Restricted follows current pod hardening best practices.
+	Restricted Level = "restricted"
+)
+
+// EnforceLabel is the namespace label that sets the level Pod Security
+// admission enforces.
+const EnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// EnforcedLevel returns the level enforced in a namespace with the given
+// labels.  Like Pod Security admission, it takes a level it doesn't know to
+// be the strictest one.
+func EnforcedLevel(namespaceLabels map[string]string) Level {
+	value, ok := namespaceLabels[EnforceLabel]
+	if !ok {
+		return Privileged
+	}
+	switch level := Level(value); level {
+	case Privileged, Baseline, Restricted:
+		return level
+	default:
+		return Restricted
+	}
+}
+
+// Check lists the ways the pod template breaks the given level, or nothing if
+// it meets it.
+func Check(level Level, template *corev1.PodTemplateSpec) []string {
+	var c checker
+	switch level {
+	case Baseline:
+		c.baseline(&template.ObjectMeta, &template.Spec)
+	case Restricted:
+		c.baseline(&template.ObjectMeta, &template.Spec)
+		c.restricted(&template.Spec)
+	}
+	return c.violations
+}
+
+// checker collects the violations.
+type checker struct {
+	violations []string
+}
+
+func (c *checker) addf(format string, args ...interface{}) {
+	c.violations = append(c.violations, fmt.Sprintf(format, args...))
+}
+
+// containers lists all of the pod's containers, init containers first, with
+// the paths to them.
+func containers(spec *corev1.PodSpec) (paths []string, all []*corev1.Container) {
+	for i := range spec.InitContainers {
+		paths = append(paths, fmt.Sprintf("initContainers[%d]", i))
+		all = append(all, &spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		paths = append(paths, fmt.Sprintf("containers[%d]", i))
+		all = append(all, &spec.Containers[i])
+	}
+	return paths, all
+}
+
+var (
+	// baselineCapabilities are the capabilities baseline allows adding.
+	baselineCapabilities = map[corev1.Capability]bool{
+		"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true,
+		"FSETID": true, "KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true,
+		"SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true,
+		"SYS_CHROOT": true,
+	}
+
+	// safeSysctls are the sysctls baseline allows setting.
+	safeSysctls = map[string]bool{
+		"kernel.shm_rmid_forced":              true,
+		"net.ipv4.ip_local_port_range":        true,
+		"net.ipv4.ip_unprivileged_port_start": true,
+		"net.ipv4.tcp_syncookies":             true,
+		"net.ipv4.ping_group_range":           true,
+	}
+
+	// seLinuxTypes are the SELinux types baseline allows, besides none.
+	seLinuxTypes = map[string]bool{
+		"container_t": true, "container_init_t": true, "container_kvm_t": true,
+	}
+)
+
+// appArmorAnnotationPrefix prefixes the annotations setting containers'
+// AppArmor profiles.
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+// baseline checks the pod against the baseline level.
+func (c *checker) baseline(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	if spec.HostNetwork {
+		c.addf("spec.hostNetwork must not be true")
+	}
+	if spec.HostPID {
+		c.addf("spec.hostPID must not be true")
+	}
+	if spec.HostIPC {
+		c.addf("spec.hostIPC must not be true")
+	}
+	for i, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			c.addf("spec.volumes[%d] (%s) must not be a hostPath volume", i, volume.Name)
+		}
+	}
+
+	// annotations go in order, so that the violations come out the same
+	// every time
+	var annotations []string
+	for key := range meta.Annotations {
+		annotations = append(annotations, key)
+	}
+	sort.Strings(annotations)
+	for _, key := range annotations {
+		if !strings.HasPrefix(key, appArmorAnnotationPrefix) {
+			continue
+		}
+		if profile := meta.Annotations[key]; profile != "runtime/default" && !strings.HasPrefix(profile, "localhost/") {
+			c.addf("metadata.annotations[%s] must be runtime/default or localhost/*, not %q", key, profile)
+		}
+	}
+
+	if sc := spec.SecurityContext; sc != nil {
+		c.seLinux("spec.securityContext.seLinuxOptions", sc.SELinuxOptions)
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			c.addf("spec.securityContext.seccompProfile.type must not be Unconfined")
+		}
+		for _, sysctl := range sc.Sysctls {
+			if !safeSysctls[sysctl.Name] {
+				c.addf("spec.securityContext.sysctls must not set %s", sysctl.Name)
+			}
+		}
+	}
+
+	paths, all := containers(spec)
+	for i, container := range all {
+		path := "spec." + paths[i]
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				c.addf("%s.ports must not set a hostPort, not %d", path, port.HostPort)
+			}
+		}
+		sc := container.SecurityContext
+		if sc == nil {
+			continue
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			c.addf("%s.securityContext.privileged must not be true", path)
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					c.addf("%s.securityContext.capabilities.add must not add %s", path, capability)
+				}
+			}
+		}
+		c.seLinux(path+".securityContext.seLinuxOptions", sc.SELinuxOptions)
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			c.addf("%s.securityContext.procMount must be Default", path)
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			c.addf("%s.securityContext.seccompProfile.type must not be Unconfined", path)
+		}
+	}
+}
+
+// seLinux checks SELinux options against the baseline level.
+func (c *checker) seLinux(path string, options *corev1.SELinuxOptions) {
+	if options == nil {
+		return
+	}
+	if options.Type != "" && !seLinuxTypes[options.Type] {
+		c.addf("%s.type must not be %s", path, options.Type)
+	}
+	if options.User != "" {
+		c.addf("%s.user must not be set", path)
+	}
+	if options.Role != "" {
+		c.addf("%s.role must not be set", path)
+	}
+}
+
+// restricted checks the pod against what the restricted level adds to the
+// baseline.
+func (c *checker) restricted(spec *corev1.PodSpec) {
+	for i, volume := range spec.Volumes {
+		switch src := volume.VolumeSource; {
+		case src.ConfigMap != nil, src.CSI != nil, src.DownwardAPI != nil, src.EmptyDir != nil,
+			src.Ephemeral != nil, src.PersistentVolumeClaim != nil, src.Projected != nil, src.Secret != nil:
+		case src.HostPath != nil:
+			// baseline has already said so
+		default:
+			c.addf("spec.volumes[%d] (%s) must be a configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret volume", i, volume.Name)
+		}
+	}
+
+	podNonRoot, podSeccomp := false, false
+	if sc := spec.SecurityContext; sc != nil {
+		if sc.RunAsNonRoot != nil {
+			if !*sc.RunAsNonRoot {
+				c.addf("spec.securityContext.runAsNonRoot must not be false")
+			}
+			podNonRoot = *sc.RunAsNonRoot
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			c.addf("spec.securityContext.runAsUser must not be 0")
+		}
+		podSeccomp = sc.SeccompProfile != nil && allowedSeccomp(sc.SeccompProfile.Type)
+	}
+
+	paths, all := containers(spec)
+	for i, container := range all {
+		path := "spec." + paths[i]
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			c.addf("%s.securityContext.allowPrivilegeEscalation must be false", path)
+		}
+		switch {
+		case sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot:
+			c.addf("%s.securityContext.runAsNonRoot must not be false", path)
+		case sc.RunAsNonRoot == nil && !podNonRoot:
+			c.addf("%s.securityContext.runAsNonRoot must be true, here or for the pod", path)
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			c.addf("%s.securityContext.runAsUser must not be 0", path)
+		}
+		switch {
+		case sc.SeccompProfile != nil && !allowedSeccomp(sc.SeccompProfile.Type):
+			if sc.SeccompProfile.Type != corev1.SeccompProfileTypeUnconfined {
+				c.addf("%s.securityContext.seccompProfile.type must be RuntimeDefault or Localhost", path)
+			}
+		case sc.SeccompProfile == nil && !podSeccomp:
+			c.addf("%s.securityContext.seccompProfile.type must be RuntimeDefault or Localhost, here or for the pod", path)
+		}
+
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" && baselineCapabilities[capability] {
+					c.addf("%s.securityContext.capabilities.add must only add NET_BIND_SERVICE, not %s", path, capability)
+				}
+			}
+		}
+		if !dropsAll {
+			c.addf("%s.securityContext.capabilities.drop must include ALL", path)
+		}
+	}
+}
+
+// allowedSeccomp reports whether restricted allows the seccomp profile type.
+func allowedSeccomp(profile corev1.SeccompProfileType) bool {
+	return profile == corev1.SeccompProfileTypeRuntimeDefault || profile == corev1.SeccompProfileTypeLocalhost
+}
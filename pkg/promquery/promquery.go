@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package promquery evaluates PromQL queries against Prometheus's HTTP API,
+// for queries that come down to a single number.
+package promquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseSize bounds what we read of a response.
+const maxResponseSize = 1 << 20
+
+// ErrNoData is returned for a query whose result is empty, like a
+// comparison that filtered out every series.
+var ErrNoData = errors.New("query returned no data")
+
+// Client queries a Prometheus server, or anything else serving its HTTP API,
+// like Thanos or Mimir.
+type Client struct {
+	// URL is the server's base URL, e.g. http://prometheus:9090.
+	URL string
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// queryResponse is the response to an instant query.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// sample is a vector's sample, of which we only need the [timestamp, value]
+// pair.
+type sample struct {
+	Value []interface{} `json:"value"`
+}
+
+// Query evaluates the query at the given time, returning its value.  The
+// result must be a scalar or a vector with a single sample; an empty vector
+// gives ErrNoData.
+func (c *Client) Query(ctx context.Context, query string, at time.Time) (float64, error) {
+	params := url.Values{
+		"query": {query},
+		"time":  {strconv.FormatFloat(float64(at.UnixNano())/1e9, 'f', 3, 64)},
+	}
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/api/v1/query"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// failed queries still come with a JSON body saying why
+	var response queryResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return 0, fmt.Errorf("prometheus returned %s, which we couldn't parse: %w", resp.Status, err)
+	}
+	if response.Status != "success" {
+		return 0, fmt.Errorf("prometheus returned %s: %s: %s", resp.Status, response.ErrorType, response.Error)
+	}
+
+	switch response.Data.ResultType {
+	case "scalar":
+		var value []interface{}
+		if err := json.Unmarshal(response.Data.Result, &value); err != nil {
+			return 0, fmt.Errorf("unable to parse scalar result: %w", err)
+		}
+		return parseValue(value)
+	case "vector":
+		var samples []sample
+		if err := json.Unmarshal(response.Data.Result, &samples); err != nil {
+			return 0, fmt.Errorf("unable to parse vector result: %w", err)
+		}
+		switch len(samples) {
+		case 0:
+			return 0, ErrNoData
+		case 1:
+			return parseValue(samples[0].Value)
+		default:
+			return 0, fmt.Errorf("query returned %d series, rather than one: aggregate them, with sum() or max() say", len(samples))
+		}
+	default:
+		return 0, fmt.Errorf("query returned a %s, rather than a scalar or an instant vector", response.Data.ResultType)
+	}
+}
+
+// parseValue parses a [timestamp, "value"] pair.
+func parseValue(pair []interface{}) (float64, error) {
+	if len(pair) != 2 {
+		return 0, fmt.Errorf("malformed sample %v", pair)
+	}
+	raw, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("malformed sample value %v", pair[1])
+	}
+	return strconv.ParseFloat(raw, 64)
+}
@@ -0,0 +1,109 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package runadmission asks external policy endpoints whether a CronJob's run
+// may go ahead, just before its job is created.  Endpoints get a Review with
+// the run's context, as JSON, and answer with a Response.
+package runadmission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Review describes a run about to start.
+type Review struct {
+	Namespace string            `json:"namespace"`
+	CronJob   string            `json:"cronJob"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Job is the name of the job about to be created.
+	Job           string    `json:"job"`
+	ScheduledTime time.Time `json:"scheduledTime"`
+	// ConsecutiveFailures is how many of the CronJob's runs have failed in a
+	// row.
+	ConsecutiveFailures int32 `json:"consecutiveFailures"`
+	// Load is how busy things are.
+	Load Load `json:"load"`
+}
+
+// Load describes how many jobs are running.
+type Load struct {
+	// CronJobActiveJobs is how many jobs the CronJob has running.
+	CronJobActiveJobs int32 `json:"cronJobActiveJobs"`
+	// NamespaceActiveJobs is how many jobs the CronJobs in the namespace have
+	// running.
+	NamespaceActiveJobs int32 `json:"namespaceActiveJobs"`
+	// TenantActiveJobs and TotalActiveJobs are how many jobs the CronJob's
+	// tenant, and all tenants, have running, if the controller holds tenants
+	// to budgets.
+	TenantActiveJobs *int32 `json:"tenantActiveJobs,omitempty"`
+	TotalActiveJobs  *int32 `json:"totalActiveJobs,omitempty"`
+}
+
+// Response is an endpoint's verdict on a run.
+type Response struct {
+	Allowed bool `json:"allowed"`
+	// Reason explains a denial.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Client sends Reviews to endpoints.
+type Client struct {
+	// HTTP makes the requests.  http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+// maxResponseSize bounds what we read of a response.
+const maxResponseSize = 1 << 20
+
+// Review posts the review to the endpoint, waiting at most the timeout for
+// its verdict.
+func (c *Client) Review(ctx context.Context, url string, timeout time.Duration, review *Review) (*Response, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy endpoint returned %s", resp.Status)
+	}
+	var response Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("unable to parse policy endpoint's response: %w", err)
+	}
+	return &response, nil
+}
@@ -0,0 +1,153 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// DSTPolicy says what happens to a cron schedule's activations when daylight
+// saving time starts or ends.  Left to itself, a cron schedule silently skips
+// activations at times the clocks jump over, and runs those at times the
+// clocks go back over twice.
+type DSTPolicy string
+
+const (
+	// SkipNonexistent skips activations at times that don't exist, since the
+	// clocks went forward past them, and only runs those at times that happen
+	// twice, since the clocks went back, the first time round.
+	SkipNonexistent DSTPolicy = "SkipNonexistent"
+
+	// RunEarlier runs activations at times that don't exist as soon as the
+	// clocks have gone forward, and only runs those at times that happen
+	// twice the first, earlier, time round.
+	RunEarlier DSTPolicy = "RunEarlier"
+
+	// RunBoth runs activations at times that don't exist as soon as the
+	// clocks have gone forward, and runs those at times that happen twice
+	// both times round.
+	RunBoth DSTPolicy = "RunBoth"
+)
+
+// WithDSTPolicy wraps a schedule so that it's evaluated in the given
+// location, like InLocation, with daylight saving time transitions handled as
+// per the policy.  That only makes a difference to cron expressions: other
+// schedules don't deal in wall clock times in the first place.
+func WithDSTPolicy(sched Schedule, loc *time.Location, policy DSTPolicy) Schedule {
+	spec, ok := sched.(*cron.SpecSchedule)
+	if !ok {
+		return InLocation(sched, loc)
+	}
+	return &withDSTPolicy{sched: spec, loc: loc, policy: policy}
+}
+
+type withDSTPolicy struct {
+	sched  *cron.SpecSchedule
+	loc    *time.Location
+	policy DSTPolicy
+}
+
+// Next implements Schedule.
+func (s *withDSTPolicy) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	next := s.sched.Next(t)
+	if s.policy != RunBoth {
+		for !next.IsZero() && repeatedWallTime(next) {
+			next = s.sched.Next(next)
+		}
+	}
+	if next.IsZero() || s.policy == SkipNonexistent {
+		return next
+	}
+
+	// anything the clocks jumped over before next runs as they jump
+	for from := t; ; {
+		jump, ok := nextSpringForward(s.loc, from, next)
+		if !ok {
+			return next
+		}
+		if s.jumpedOver(jump) {
+			return jump
+		}
+		from = jump
+	}
+}
+
+// jumpedOver checks whether the clocks going forward at the given time skip
+// any of the schedule's activations.  We find those by carrying on as if
+// the clocks hadn't changed: an activation that would then have fallen in
+// the hour (or so) they skipped, and which doesn't happen anyway, is one
+// we'd otherwise miss.
+func (s *withDSTPolicy) jumpedOver(jump time.Time) bool {
+	name, before := jump.Add(-time.Second).Zone()
+	_, after := jump.Zone()
+	unchanged := time.FixedZone(name, before)
+
+	missed := s.sched.Next(jump.Add(-time.Second).In(unchanged))
+	if missed.IsZero() || !missed.Before(jump.Add(time.Duration(after-before)*time.Second)) {
+		return false
+	}
+	return !s.sched.Next(missed.Add(-time.Second).In(s.loc)).Equal(missed)
+}
+
+// repeatedWallTime checks whether the clock read the same as it does at the
+// given time once already, before being turned back.
+func repeatedWallTime(t time.Time) bool {
+	_, offset := t.Zone()
+	// transitions are months apart, so half a day back is safely before
+	// any that just happened
+	_, offsetBefore := t.Add(-12 * time.Hour).Zone()
+	if offsetBefore <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(offsetBefore-offset) * time.Second)
+	const wallClock = "2006-01-02 15:04:05"
+	return earlier.Format(wallClock) == t.Format(wallClock)
+}
+
+// nextSpringForward finds the first time after from, and no later than to,
+// at which the clocks go forward in the given location.
+func nextSpringForward(loc *time.Location, from, to time.Time) (time.Time, bool) {
+	offsetAt := func(t time.Time) int {
+		_, offset := t.In(loc).Zone()
+		return offset
+	}
+
+	// step a day at a time, then narrow down on the second in any day the
+	// offset went up in
+	for lo := from; lo.Before(to); {
+		hi := lo.Add(24 * time.Hour)
+		if hi.After(to) {
+			hi = to
+		}
+		if before := offsetAt(lo); offsetAt(hi) > before {
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if offsetAt(mid) > before {
+					hi = mid
+				} else {
+					lo = mid
+				}
+			}
+			return hi.Truncate(time.Second).In(loc), true
+		}
+		lo = hi
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,84 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"time"
+)
+
+// maxHolidayShift is how many days ahead we look for a business day to move
+// an activation to, before giving up on it.
+const maxHolidayShift = 31
+
+// NextBusinessDay wraps a schedule so that activations falling on a holiday
+// move to the same time on the next business day instead: the next weekday
+// that isn't a holiday.  Days are judged in the time zone the schedule's
+// activations come in.
+func NextBusinessDay(sched Schedule, isHoliday func(time.Time) bool) Schedule {
+	return &nextBusinessDay{sched: sched, isHoliday: isHoliday}
+}
+
+type nextBusinessDay struct {
+	sched     Schedule
+	isHoliday func(time.Time) bool
+}
+
+// Next implements Schedule.
+func (s *nextBusinessDay) Next(t time.Time) time.Time {
+	// an activation from before t may have moved past it, and moved
+	// activations can overtake unmoved ones, so we look back as far as
+	// anything could have moved from, and forward to the first activation
+	// that stays put.  Only a run of days off right before t can have moved
+	// anything past it; we go back a day further, since t needn't be in the
+	// time zone that days are judged in.
+	from := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, -1)
+	for days := 0; days < maxHolidayShift && s.dayOff(from.AddDate(0, 0, -1)); days++ {
+		from = from.AddDate(0, 0, -1)
+	}
+
+	var next time.Time
+	for a := s.sched.Next(from.Add(-time.Nanosecond)); !a.IsZero(); a = s.sched.Next(a) {
+		moved, ok := s.move(a)
+		if ok && moved.After(t) && (next.IsZero() || moved.Before(next)) {
+			next = moved
+		}
+		if a.After(t) && !s.isHoliday(a) {
+			break
+		}
+	}
+	return next
+}
+
+// move moves an activation to the next business day if it falls on a
+// holiday, reporting false if there's no business day close enough.
+func (s *nextBusinessDay) move(a time.Time) (time.Time, bool) {
+	if !s.isHoliday(a) {
+		return a, true
+	}
+	for days := 1; days <= maxHolidayShift; days++ {
+		day := time.Date(a.Year(), a.Month(), a.Day()+days, a.Hour(), a.Minute(), a.Second(), a.Nanosecond(), a.Location())
+		if !s.dayOff(day) {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dayOff checks whether the given time falls on a weekend or a holiday.
+func (s *nextBusinessDay) dayOff(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday || s.isHoliday(t)
+}
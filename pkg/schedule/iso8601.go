@@ -0,0 +1,179 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepeatingInterval is an ISO 8601 repeating interval of the form
+// R[n]/<start>/<period>: it activates at start and then once every period,
+// n times in total if n is given, or forever otherwise.
+type RepeatingInterval struct {
+	// Start is the first activation.
+	Start time.Time
+	// Period is the time between activations.
+	Period Period
+	// Repetitions is the total number of activations, or -1 for no limit.
+	Repetitions int
+}
+
+// Period is an ISO 8601 duration.  The date part is kept apart from the time
+// part, since years, months and days don't have a fixed length.
+type Period struct {
+	Years, Months, Days int
+	Time                time.Duration
+}
+
+// ParseRepeatingInterval parses an ISO 8601 repeating interval with an
+// explicit start time, like R/2024-01-01T00:00:00Z/PT6H or R5/2024-01-01T00:00:00Z/P1D.
+func ParseRepeatingInterval(spec string) (*RepeatingInterval, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "R") {
+		return nil, fmt.Errorf("expected R[n]/<start>/<period>, got %q", spec)
+	}
+
+	interval := &RepeatingInterval{Repetitions: -1}
+	if count := parts[0][1:]; count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number of repetitions %q", count)
+		}
+		interval.Repetitions = n
+	}
+
+	start, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time %q: %v", parts[1], err)
+	}
+	// offsets can take a start out of the years RFC 3339 can write down
+	if year := start.UTC().Year(); year < 1 || year > 9999 {
+		return nil, fmt.Errorf("start time %q is out of range", parts[1])
+	}
+	interval.Start = start
+
+	period, err := ParsePeriod(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	interval.Period = period
+
+	return interval, nil
+}
+
+// maxPeriodSeconds is the longest period we accept, roughly.  Any longer,
+// and there would be no second activation within the years we can represent
+// anyway.
+const maxPeriodSeconds = 10000 * 366 * 86400
+
+var periodPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParsePeriod parses an ISO 8601 duration like P1DT12H.  Only whole numbers
+// are supported, and the period must be non-empty, and no longer than about
+// ten thousand years.
+func ParsePeriod(spec string) (Period, error) {
+	match := periodPattern.FindStringSubmatch(spec)
+	if match == nil || spec == "P" || strings.HasSuffix(spec, "T") {
+		return Period{}, fmt.Errorf("invalid ISO 8601 duration %q", spec)
+	}
+
+	// Each field is checked against the longest period before adding them
+	// up, so that nothing can overflow, going by days of 24 hours, months of
+	// 31 days and years of 366.
+	secondsEach := [8]int64{1: 366 * 86400, 2: 31 * 86400, 3: 7 * 86400, 4: 86400, 5: 3600, 6: 60, 7: 1}
+	var fields [8]int64
+	for i := 1; i < len(fields); i++ {
+		if match[i] == "" {
+			continue
+		}
+		// the pattern guarantees we've got digits, so the only possible
+		// failure is overflow
+		n, err := strconv.ParseInt(match[i], 10, 64)
+		if err != nil || n > maxPeriodSeconds/secondsEach[i] {
+			return Period{}, fmt.Errorf("ISO 8601 duration %q is too long", spec)
+		}
+		fields[i] = n
+	}
+	// the time part has to fit in a time.Duration, too
+	seconds := fields[5]*3600 + fields[6]*60 + fields[7]
+	if seconds > math.MaxInt64/int64(time.Second) {
+		return Period{}, fmt.Errorf("ISO 8601 duration %q is too long", spec)
+	}
+	period := Period{
+		Years:  int(fields[1]),
+		Months: int(fields[2]),
+		Days:   int(fields[3]*7 + fields[4]),
+		Time:   time.Duration(seconds) * time.Second,
+	}
+	if length := fields[1]*366*86400 + fields[2]*31*86400 + int64(period.Days)*86400 + seconds; length > maxPeriodSeconds {
+		return Period{}, fmt.Errorf("ISO 8601 duration %q is too long", spec)
+	}
+	if period.Years <= 0 && period.Months <= 0 && period.Days <= 0 && period.Time <= 0 {
+		return Period{}, fmt.Errorf("ISO 8601 duration %q must be positive", spec)
+	}
+	return period, nil
+}
+
+// activation returns the k-th activation time, counting from zero.
+func (i *RepeatingInterval) activation(k int) time.Time {
+	t := i.Start.AddDate(k*i.Period.Years, k*i.Period.Months, k*i.Period.Days)
+	if i.Period.Time <= 0 {
+		return t
+	}
+	// k periods may be more than a time.Duration can hold, so they go on in
+	// as many steps as it takes
+	perStep := int(math.MaxInt64 / int64(i.Period.Time))
+	for ; k > perStep; k -= perStep {
+		t = t.Add(time.Duration(perStep) * i.Period.Time)
+	}
+	return t.Add(time.Duration(k) * i.Period.Time)
+}
+
+// Next implements Schedule.
+func (i *RepeatingInterval) Next(t time.Time) time.Time {
+	if t.Before(i.Start) {
+		if i.Repetitions == 0 {
+			return time.Time{}
+		}
+		return i.Start
+	}
+
+	// Guess how many activations have passed from the length of the first
+	// period, then walk to the right one -- calendar periods vary in length,
+	// so the guess may be off by a little.  It's worked out in seconds, since
+	// the time since the start may be more than a time.Duration can hold.
+	k := 1
+	if first := i.activation(1).Sub(i.Start); first > 0 {
+		k = int(float64(t.Unix()-i.Start.Unix())/first.Seconds()) + 1
+	}
+	for k > 1 && i.activation(k-1).After(t) {
+		k--
+	}
+	for !i.activation(k).After(t) {
+		k++
+	}
+
+	if i.Repetitions >= 0 && k >= i.Repetitions {
+		return time.Time{}
+	}
+	return i.activation(k)
+}
@@ -0,0 +1,162 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	testCases := []struct {
+		spec    string
+		period  Period
+		invalid bool
+	}{
+		{spec: "PT6H", period: Period{Time: 6 * time.Hour}},
+		{spec: "PT90M", period: Period{Time: 90 * time.Minute}},
+		{spec: "PT1S", period: Period{Time: time.Second}},
+		{spec: "P1D", period: Period{Days: 1}},
+		{spec: "P1W", period: Period{Days: 7}},
+		{spec: "P2W3D", period: Period{Days: 17}},
+		{spec: "P1M", period: Period{Months: 1}},
+		{spec: "P1Y2M3DT4H5M6S", period: Period{Years: 1, Months: 2, Days: 3, Time: 4*time.Hour + 5*time.Minute + 6*time.Second}},
+		{spec: "P10000Y", period: Period{Years: 10000}},
+
+		{spec: "", invalid: true},
+		{spec: "P", invalid: true},
+		{spec: "PT", invalid: true},
+		{spec: "P1DT", invalid: true},
+		{spec: "1D", invalid: true},
+		{spec: "p1d", invalid: true},
+		{spec: "P1.5D", invalid: true},
+		{spec: "P-1D", invalid: true},
+		{spec: "PT1H2D", invalid: true},
+		{spec: "P0D", invalid: true},
+		{spec: "PT0S", invalid: true},
+		{spec: "P10001Y", invalid: true},
+		{spec: "P9999Y1000D", invalid: true},
+		{spec: "PT99999999999999999999S", invalid: true},
+	}
+	for _, tc := range testCases {
+		period, err := ParsePeriod(tc.spec)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("ParsePeriod(%q) = %+v, expected an error", tc.spec, period)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePeriod(%q): %v", tc.spec, err)
+			continue
+		}
+		if period != tc.period {
+			t.Errorf("ParsePeriod(%q) = %+v, want %+v", tc.spec, period, tc.period)
+		}
+	}
+}
+
+func TestParseRepeatingInterval(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		start       time.Time
+		repetitions int
+		invalid     bool
+	}{
+		{spec: "R/2024-01-01T00:00:00Z/PT6H", start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), repetitions: -1},
+		{spec: "R5/2024-01-01T00:00:00Z/P1D", start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), repetitions: 5},
+		{spec: "R0/2024-01-01T00:00:00Z/P1D", start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), repetitions: 0},
+		{spec: "R/2024-01-01T09:00:00+05:30/PT1H", start: time.Date(2024, 1, 1, 3, 30, 0, 0, time.UTC), repetitions: -1},
+
+		{spec: "R/2024-01-01T00:00:00Z", invalid: true},
+		{spec: "2024-01-01T00:00:00Z/PT1H", invalid: true},
+		{spec: "X/2024-01-01T00:00:00Z/PT1H", invalid: true},
+		{spec: "R/2024-01-01T00:00:00Z/PT1H/", invalid: true},
+		{spec: "Rx/2024-01-01T00:00:00Z/PT1H", invalid: true},
+		{spec: "R-1/2024-01-01T00:00:00Z/PT1H", invalid: true},
+		{spec: "R/2024-01-01/PT1H", invalid: true},
+		{spec: "R/2024-01-01T00:00:00Z/P", invalid: true},
+		{spec: "R/2024-01-01T00:00:00Z/PT0S", invalid: true},
+		// the offset takes it back into year zero
+		{spec: "R/0001-01-01T00:00:00+01:00/PT1H", invalid: true},
+	}
+	for _, tc := range testCases {
+		interval, err := ParseRepeatingInterval(tc.spec)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("ParseRepeatingInterval(%q) = %+v, expected an error", tc.spec, interval)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRepeatingInterval(%q): %v", tc.spec, err)
+			continue
+		}
+		if !interval.Start.Equal(tc.start) || interval.Repetitions != tc.repetitions {
+			t.Errorf("ParseRepeatingInterval(%q) starts at %s with %d repetitions, want %s with %d",
+				tc.spec, interval.Start, interval.Repetitions, tc.start, tc.repetitions)
+		}
+	}
+}
+
+func TestRepeatingIntervalNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	testCases := []struct {
+		spec string
+		from string
+		next string // empty for no more activations
+	}{
+		{"R/2024-01-01T00:00:00Z/PT6H", "2023-12-31T12:00:00Z", "2024-01-01T00:00:00Z"},
+		{"R/2024-01-01T00:00:00Z/PT6H", "2024-01-01T00:00:00Z", "2024-01-01T06:00:00Z"},
+		{"R/2024-01-01T00:00:00Z/PT6H", "2024-01-01T05:59:59Z", "2024-01-01T06:00:00Z"},
+		{"R/2024-01-01T00:00:00Z/PT6H", "2024-03-01T01:00:00Z", "2024-03-01T06:00:00Z"},
+		{"R/2024-01-01T00:00:00+05:30/PT12H", "2024-01-01T00:00:00Z", "2024-01-01T06:30:00Z"},
+		{"R/2024-01-15T09:00:00Z/P1M", "2024-05-20T00:00:00Z", "2024-06-15T09:00:00Z"},
+		{"R/2024-01-01T00:00:00Z/P1DT1H", "2024-01-02T00:00:00Z", "2024-01-02T01:00:00Z"},
+		// from long ago, without overflowing
+		{"R/1000-01-01T00:00:00Z/PT1H", "2024-01-01T00:30:00Z", "2024-01-01T01:00:00Z"},
+
+		{"R3/2024-01-01T00:00:00Z/P1D", "2024-01-02T00:00:00Z", "2024-01-03T00:00:00Z"},
+		{"R3/2024-01-01T00:00:00Z/P1D", "2024-01-03T00:00:00Z", ""},
+		{"R1/2024-01-01T00:00:00Z/P1D", "2023-12-01T00:00:00Z", "2024-01-01T00:00:00Z"},
+		{"R1/2024-01-01T00:00:00Z/P1D", "2024-01-01T00:00:00Z", ""},
+		{"R0/2024-01-01T00:00:00Z/P1D", "2023-12-01T00:00:00Z", ""},
+	}
+	for _, tc := range testCases {
+		interval, err := ParseRepeatingInterval(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseRepeatingInterval(%q): %v", tc.spec, err)
+		}
+		next := interval.Next(at(tc.from))
+		if tc.next == "" {
+			if !next.IsZero() {
+				t.Errorf("%s after %s: got %s, expected no more activations", tc.spec, tc.from, next)
+			}
+			continue
+		}
+		if !next.Equal(at(tc.next)) {
+			t.Errorf("%s after %s: got %s, want %s", tc.spec, tc.from, next.UTC(), tc.next)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedule knows how to turn the schedule strings accepted by a
+// CronJob into activation times.  Standard cron expressions are handled by
+// github.com/robfig/cron; ISO 8601 repeating intervals are handled here.
+package schedule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Schedule describes a job's duty cycle.  It's deliberately the same shape as
+// cron.Schedule, so that cron schedules can be used as-is.
+type Schedule interface {
+	// Next returns the next activation time, later than the given time, or
+	// the zero time if the schedule will never activate again.
+	Next(time.Time) time.Time
+}
+
+// Parse parses a schedule, which is either a standard five-field cron
+// expression (or one of its @-descriptors), or an ISO 8601 repeating
+// interval like R/2024-01-01T00:00:00Z/PT6H.
+func Parse(spec string) (Schedule, error) {
+	if IsRepeatingInterval(spec) {
+		return ParseRepeatingInterval(spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// secondsParser parses cron expressions with a leading seconds field.
+var secondsParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseWithSeconds parses a schedule like Parse does, except that cron
+// expressions have six fields, the first of which is the second.
+func ParseWithSeconds(spec string) (Schedule, error) {
+	if IsRepeatingInterval(spec) {
+		return ParseRepeatingInterval(spec)
+	}
+	return secondsParser.Parse(spec)
+}
+
+// IsRepeatingInterval checks whether the schedule looks like an ISO 8601
+// repeating interval rather than a cron expression.
+func IsRepeatingInterval(spec string) bool {
+	return strings.HasPrefix(spec, "R") && strings.Contains(spec, "/")
+}
@@ -0,0 +1,41 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+// Times is a schedule that activates at the given times and no others, like
+// the events of a calendar.  They needn't be sorted.
+func Times(times []time.Time) Schedule {
+	sorted := append(fixedTimes(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	return sorted
+}
+
+type fixedTimes []time.Time
+
+// Next implements Schedule.
+func (s fixedTimes) Next(t time.Time) time.Time {
+	i := sort.Search(len(s), func(i int) bool { return s[i].After(t) })
+	if i == len(s) {
+		return time.Time{}
+	}
+	return s[i]
+}
@@ -0,0 +1,124 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InLocation wraps a schedule so that it's evaluated in the given location:
+// a cron expression like "0 9 * * *" then means 9am in that time zone.
+func InLocation(sched Schedule, loc *time.Location) Schedule {
+	return &inLocation{sched: sched, loc: loc}
+}
+
+type inLocation struct {
+	sched Schedule
+	loc   *time.Location
+}
+
+// Next implements Schedule.
+func (s *inLocation) Next(t time.Time) time.Time {
+	return s.sched.Next(t.In(s.loc))
+}
+
+// zoneinfoDirs are the places time zone databases are usually installed.
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+}
+
+// LoadLocation loads a location from the time zone database, like
+// time.LoadLocation, but refuses the empty name and "Local", which would
+// silently stand for UTC or for whatever zone the controller happens to run
+// in.  When the name is unknown, the error suggests the closest known name.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("time zone must be named explicitly, like UTC or Europe/Berlin")
+	}
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		return loc, nil
+	}
+	if suggestion := suggestLocation(name); suggestion != "" {
+		return nil, fmt.Errorf("unknown time zone %q, did you mean %q?", name, suggestion)
+	}
+	return nil, fmt.Errorf("unknown time zone %q", name)
+}
+
+// suggestLocation finds the known time zone name that's closest to the given
+// one, or returns the empty string if there's nothing close.
+func suggestLocation(name string) string {
+	want := strings.ToLower(strings.Replace(name, " ", "_", -1))
+	best, bestDistance := "", len(want)/3+1
+	for _, dir := range zoneinfoDirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			candidate, err := filepath.Rel(dir, path)
+			if err != nil || strings.ToUpper(candidate[:1]) != candidate[:1] {
+				// skip the database's own files, like zone.tab
+				return nil
+			}
+			if d := editDistance(want, strings.ToLower(candidate)); d < bestDistance {
+				best, bestDistance = candidate, d
+			}
+			return nil
+		})
+		if best != "" {
+			break
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}